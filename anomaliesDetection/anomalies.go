@@ -25,6 +25,8 @@ import (
 
 	"github.com/trackit/trackit-server/aws"
 	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/es"
 )
 
@@ -42,9 +44,9 @@ type (
 		Date           string
 	}
 
-	// AnalyzedCost is returned by Bollinger Band algorithm and contains
-	// every necessary data for it. It also contains metadata, ignored by
-	// the algorithm.
+	// AnalyzedCost is returned by the anomaly detectors and contains
+	// every necessary data for them. It also contains metadata, ignored by
+	// the detectors.
 	AnalyzedCost struct {
 		Meta      AnalyzedCostEssentialMeta
 		Cost      float64
@@ -56,10 +58,11 @@ type (
 
 	// AnomalyEsQueryParams will store the parsed query params
 	AnomalyEsQueryParams struct {
-		DateBegin time.Time
-		DateEnd   time.Time
-		Account   string
-		Index     string
+		DateBegin  time.Time
+		DateEnd    time.Time
+		Account    string
+		Index      string
+		Conversion currency.Conversion
 	}
 
 	// ElasticSearchFunction is a function passed to makeElasticSearchRequest,
@@ -71,6 +74,7 @@ type (
 		aggregationPeriod string,
 		client *elastic.Client,
 		index string,
+		conversion currency.Conversion,
 	) *elastic.SearchService
 
 	// elasticSearchDateElem is used to get usageStartDate from awsdetailedlineitems.
@@ -79,8 +83,9 @@ type (
 	}
 )
 
-// RunAnomaliesDetection run every anomaly detection algorithms and store results in ElasticSearch.
-func RunAnomaliesDetection(account aws.AwsAccount, lastUpdate time.Time, ctx context.Context) (time.Time, error) {
+// RunAnomaliesDetection run every anomaly detection algorithms with the detector
+// named detectorName and store results in ElasticSearch.
+func RunAnomaliesDetection(account aws.AwsAccount, lastUpdate time.Time, detectorName string, ctx context.Context) (time.Time, error) {
 	esIndex := es.IndexNameForUserId(account.UserId, s3.IndexPrefixLineItem)
 	begin, end, err := getDateRange(account, lastUpdate, ctx)
 	if err != nil {
@@ -91,20 +96,27 @@ func RunAnomaliesDetection(account aws.AwsAccount, lastUpdate time.Time, ctx con
 		"awsAccount": account.Id,
 		"begin":      begin,
 		"end":        end,
+		"detector":   detectorName,
 	})
+	// Anomalies are stored in the base currency and converted when displayed.
+	conversion, err := currency.NewConversion(db.Db, currency.Base)
+	if err != nil {
+		return begin, err
+	}
 	parsedParams := AnomalyEsQueryParams{
-		DateBegin: begin,
-		DateEnd:   end,
-		Account:   account.AwsIdentity,
-		Index:     esIndex,
+		DateBegin:  begin,
+		DateEnd:    end,
+		Account:    account.AwsIdentity,
+		Index:      esIndex,
+		Conversion: conversion,
 	}
-	return end, runAnomaliesDetectionForProducts(parsedParams, account, ctx)
+	return end, runAnomaliesDetectionForProducts(parsedParams, account, getDetector(detectorName), ctx)
 }
 
 // makeElasticSearchDateRangeRequest makes the ElasticSearch request to get begin or end date
 func makeElasticSearchDateRangeRequest(ctx context.Context, begin bool, account string, index string) (time.Time, error) {
 	searchService := getDateRangeElasticSearchParams(account, begin, es.Client, index)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "anomaliesDetection", searchService)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -156,7 +168,8 @@ func getDateRange(account aws.AwsAccount, lastUpdate time.Time, ctx context.Cont
 	return begin, end, nil
 }
 
-// deleteOffset deletes the offset set in createQueryTimeRange.
+// deleteOffset deletes the costs before dateBegin, which are the history
+// productGetAnomaliesData retrieves for the detector.
 func deleteOffset(aCosts AnalyzedCosts, dateBegin time.Time) AnalyzedCosts {
 	var toDelete []int
 	for i, aCost := range aCosts {
@@ -182,8 +195,9 @@ func makeElasticSearchRequest(ctx context.Context, esFct ElasticSearchFunction,
 		"day",
 		es.Client,
 		parsedParams.Index,
+		parsedParams.Conversion,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "anomaliesDetection", searchService)
 	if err != nil {
 		return nil, err
 	}
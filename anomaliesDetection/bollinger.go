@@ -60,10 +60,18 @@ func deviation(sigma float64, period int) float64 {
 	return deviation
 }
 
-// analyseAnomalies calculates anomalies with Bollinger Bands algorithm and
-// const values above. It consists in generating an upper band, which, if
-// exceeded, make an alert.
-func analyseAnomalies(aCosts AnalyzedCosts) AnalyzedCosts {
+// bollingerDetector detects anomalies with the Bollinger Bands algorithm and
+// the config.AnomalyDetectionBollingerBand* values. It consists in generating
+// an upper band from a moving window, which, if exceeded, makes an alert.
+type bollingerDetector struct{}
+
+// Period returns config.AnomalyDetectionBollingerBandPeriod.
+func (bollingerDetector) Period() int {
+	return config.AnomalyDetectionBollingerBandPeriod
+}
+
+// Detect calculates anomalies with Bollinger Bands algorithm.
+func (bollingerDetector) Detect(aCosts AnalyzedCosts) AnalyzedCosts {
 	for index := range aCosts {
 		if index > 0 {
 			a := &aCosts[index]
@@ -99,9 +107,9 @@ func addPadding(aCosts AnalyzedCosts, dateBegin time.Time) AnalyzedCosts {
 }
 
 // computeAnomalies calls every functions to well format
-// AnalyzedCosts and do BollingerBand.
-func computeAnomalies(ctx context.Context, aCosts AnalyzedCosts, dateBegin time.Time) AnalyzedCosts {
+// AnalyzedCosts and run the detector.
+func computeAnomalies(ctx context.Context, aCosts AnalyzedCosts, dateBegin time.Time, detector Detector) AnalyzedCosts {
 	aCosts = addPadding(aCosts, dateBegin)
-	aCosts = analyseAnomalies(aCosts)
+	aCosts = detector.Detect(aCosts)
 	return aCosts
 }
@@ -0,0 +1,130 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/models"
+)
+
+// Detector is an anomaly detection algorithm. It takes the daily costs of a
+// product, sorted by date, sets their upper band and marks the ones exceeding
+// it as anomalies.
+type Detector interface {
+	// Period returns the number of days of history needed before the first
+	// analyzed day.
+	Period() int
+	// Detect sets the upper band of the costs and marks the anomalies.
+	Detect(AnalyzedCosts) AnalyzedCosts
+}
+
+// minDeviation returns deviation, raised to
+// config.AnomalyDetectionMinDeviationPercent of the expected cost. Flat costs
+// have no deviation, which would make any change an anomaly.
+func minDeviation(deviation, expected float64) float64 {
+	return math.Max(deviation, math.Abs(expected)*config.AnomalyDetectionMinDeviationPercent/100)
+}
+
+// defaultDetectorName is used if config.AnomalyDetectionAlgorithm is not a
+// valid detector name.
+const defaultDetectorName = "bollinger"
+
+// detectors maps the detector names which can be selected by users and AWS
+// accounts to the detectors.
+var detectors = map[string]Detector{
+	"bollinger":          bollingerDetector{},
+	"weekly-seasonality": weeklySeasonalityDetector{},
+	"ewma":               ewmaDetector{},
+	"mad":                madDetector{},
+}
+
+// DetectorNames returns the sorted names of the available detectors.
+func DetectorNames() []string {
+	names := make([]string, 0, len(detectors))
+	for name := range detectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidDetector returns an error if no detector has this name.
+func ValidDetector(name string) error {
+	if _, ok := detectors[name]; !ok {
+		return fmt.Errorf("Unknown anomaly detector '%s'. Available detectors are %s.", name, strings.Join(DetectorNames(), ", "))
+	}
+	return nil
+}
+
+// getDetector returns the detector with this name. The default detector is
+// returned if there is none.
+func getDetector(name string) Detector {
+	if detector, ok := detectors[name]; ok {
+		return detector
+	}
+	return detectors[GetDefaultDetectorName()]
+}
+
+// GetDefaultDetectorName returns the name of the detector used when neither
+// the user nor the AWS account selected one.
+func GetDefaultDetectorName() string {
+	if _, ok := detectors[config.AnomalyDetectionAlgorithm]; ok {
+		return config.AnomalyDetectionAlgorithm
+	}
+	return defaultDetectorName
+}
+
+// GetDetectorName returns the name of the detector used for an AWS account:
+// the one selected for the account, else the one selected by the user, else
+// the default one. dbAwsAccount can be nil to get the detector of the user.
+func GetDetectorName(dbUser *models.User, dbAwsAccount *models.AwsAccount) string {
+	if dbAwsAccount != nil && dbAwsAccount.AnomaliesDetector.Valid && ValidDetector(dbAwsAccount.AnomaliesDetector.String) == nil {
+		return dbAwsAccount.AnomaliesDetector.String
+	} else if dbUser != nil && dbUser.AnomaliesDetector.Valid && ValidDetector(dbUser.AnomaliesDetector.String) == nil {
+		return dbUser.AnomaliesDetector.String
+	}
+	return GetDefaultDetectorName()
+}
+
+// GetAwsAccountDetectorName returns the name of the detector used for an AWS
+// account, taking its owner's choice into account.
+func GetAwsAccountDetectorName(tx *sql.Tx, dbAwsAccount *models.AwsAccount) (string, error) {
+	dbUser, err := models.UserByID(tx, dbAwsAccount.UserID)
+	if err != nil {
+		return "", err
+	}
+	return GetDetectorName(dbUser, dbAwsAccount), nil
+}
+
+// standardDeviation calculates the standard deviation of the costs.
+func standardDeviation(aCosts AnalyzedCosts, avg float64) float64 {
+	return math.Sqrt(sigma(aCosts, avg) / float64(len(aCosts)))
+}
+
+// median calculates the median of values. values is sorted in place.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
@@ -0,0 +1,116 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"testing"
+)
+
+// makeAnalyzedCosts creates AnalyzedCosts from daily costs.
+func makeAnalyzedCosts(costs []float64) AnalyzedCosts {
+	aCosts := make(AnalyzedCosts, len(costs))
+	for i, cost := range costs {
+		aCosts[i].Cost = cost
+	}
+	return aCosts
+}
+
+func TestWeeklySeasonalityDetectorIgnoresWeeklyPattern(t *testing.T) {
+	week := []float64{100, 10, 10, 10, 10, 10, 10}
+	var costs []float64
+	for i := 0; i < 5; i++ {
+		costs = append(costs, week...)
+	}
+	aCosts := weeklySeasonalityDetector{}.Detect(makeAnalyzedCosts(costs))
+	for i, aCost := range aCosts {
+		if aCost.Anomaly {
+			t.Fatalf("Expected no anomaly but got one on day %d", i)
+		}
+	}
+}
+
+func TestWeeklySeasonalityDetectorDetectsSpike(t *testing.T) {
+	week := []float64{100, 10, 10, 10, 10, 10, 10}
+	var costs []float64
+	for i := 0; i < 4; i++ {
+		costs = append(costs, week...)
+	}
+	costs = append(costs, 100, 50)
+	aCosts := weeklySeasonalityDetector{}.Detect(makeAnalyzedCosts(costs))
+	if !aCosts[len(aCosts)-1].Anomaly {
+		t.Fatalf("Expected an anomaly on the last day")
+	}
+	if aCosts[len(aCosts)-2].Anomaly {
+		t.Fatalf("Expected no anomaly on the day before the last")
+	}
+}
+
+func TestEwmaDetectorDetectsSpike(t *testing.T) {
+	aCosts := ewmaDetector{}.Detect(makeAnalyzedCosts([]float64{10, 11, 10, 9, 10, 11, 10, 9, 10, 11, 10, 9, 50}))
+	for i, aCost := range aCosts[:len(aCosts)-1] {
+		if aCost.Anomaly {
+			t.Fatalf("Expected no anomaly but got one on day %d", i)
+		}
+	}
+	if !aCosts[len(aCosts)-1].Anomaly {
+		t.Fatalf("Expected an anomaly on the last day")
+	}
+}
+
+func TestMadDetectorIsNotSkewedBySpikes(t *testing.T) {
+	aCosts := madDetector{}.Detect(makeAnalyzedCosts([]float64{10, 11, 10, 200, 9, 10, 11, 10, 40}))
+	if !aCosts[len(aCosts)-1].Anomaly {
+		t.Fatalf("Expected an anomaly on the last day")
+	}
+}
+
+func TestDetectorsIgnoreSmallChangesOfFlatCosts(t *testing.T) {
+	costs := make([]float64, 35)
+	for i := range costs {
+		costs[i] = 100
+	}
+	costs = append(costs, 101, 200)
+	for _, name := range []string{"weekly-seasonality", "ewma", "mad"} {
+		aCosts := detectors[name].Detect(makeAnalyzedCosts(costs))
+		for i, aCost := range aCosts[:len(aCosts)-1] {
+			if aCost.Anomaly {
+				t.Fatalf("%s: expected no anomaly but got one on day %d", name, i)
+			}
+		}
+		if !aCosts[len(aCosts)-1].Anomaly {
+			t.Fatalf("%s: expected an anomaly on the last day", name)
+		}
+	}
+}
+
+func TestMedian(t *testing.T) {
+	if res := median([]float64{3, 1, 2}); res != 2 {
+		t.Fatalf("Expected 2 but got %v", res)
+	}
+	if res := median([]float64{4, 1, 2, 3}); res != 2.5 {
+		t.Fatalf("Expected 2.5 but got %v", res)
+	}
+}
+
+func TestValidDetector(t *testing.T) {
+	for _, name := range DetectorNames() {
+		if err := ValidDetector(name); err != nil {
+			t.Fatalf("Expected %s to be valid but got %v", name, err)
+		}
+	}
+	if err := ValidDetector("unknown"); err == nil {
+		t.Fatalf("Expected unknown to be invalid")
+	}
+}
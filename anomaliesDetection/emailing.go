@@ -0,0 +1,233 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/aws"
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/costs/anomalies/anomalyFilters"
+	"github.com/trackit/trackit-server/costs/anomalies/anomalyType"
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/mail"
+	"github.com/trackit/trackit-server/models"
+)
+
+// NotifyAnomalies emails a digest of the new anomalies of an AWS account to
+// its owner and to the users it is shared with. Only the anomalies detected in
+// the config.AnomalyEmailingPeriod days before end with a level of at least
+// config.AnomalyEmailingMinLevel are considered. Anomalies snoozed or filtered
+// by a recipient are skipped, and every emailed anomaly is recorded so that it
+// is never sent twice to the same recipient.
+func NotifyAnomalies(ctx context.Context, tx *sql.Tx, account aws.AwsAccount, end time.Time) error {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	params := AnomalyEsQueryParams{
+		DateBegin: end.AddDate(0, 0, -config.AnomalyEmailingPeriod),
+		DateEnd:   end,
+		Account:   account.AwsIdentity,
+		Index:     es.IndexNameForUserId(account.UserId, IndexPrefixAnomaliesDetection),
+	}
+	raw, err := getAnomaliesFromEs(ctx, params)
+	if err != nil {
+		return err
+	}
+	candidates := getAnomaliesToEmail(raw)
+	if len(candidates) == 0 {
+		return nil
+	}
+	recipients, err := getAnomaliesRecipients(tx, account)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err := notifyRecipient(ctx, tx, account, recipient, candidates); err != nil {
+			logger.Error("Failed to email anomalies.", map[string]interface{}{
+				"awsAccountId": account.Id,
+				"recipient":    recipient.Email,
+				"error":        err.Error(),
+			})
+		}
+	}
+	return nil
+}
+
+// getAnomaliesToEmail keeps the anomalies which are abnormal, not recurrent
+// and whose level is high enough to be emailed. They are grouped by product.
+func getAnomaliesToEmail(raw esProductAnomaliesWithId) anomalyType.ProductAnomalies {
+	res := make(anomalyType.ProductAnomalies)
+	for _, r := range raw {
+		if !r.Source.Abnormal || r.Source.Recurrent {
+			continue
+		}
+		level, prettyLevel := GetAnomalyLevel(r.Source.Abnormal, r.Source.Cost.Value, r.Source.Cost.MaxExpected)
+		if level < config.AnomalyEmailingMinLevel {
+			continue
+		}
+		if date, err := time.Parse("2006-01-02T15:04:05Z", r.Source.Date); err == nil {
+			res[r.Source.Product] = append(res[r.Source.Product], anomalyType.ProductAnomaly{
+				Id:          r.Id,
+				Date:        date,
+				Cost:        r.Source.Cost.Value,
+				UpperBand:   r.Source.Cost.MaxExpected,
+				Abnormal:    r.Source.Abnormal,
+				Recurrent:   r.Source.Recurrent,
+				Level:       level,
+				PrettyLevel: prettyLevel,
+			})
+		}
+	}
+	return res
+}
+
+// getAnomaliesRecipients returns the owner of an AWS account and the users
+// who accepted to share it.
+func getAnomaliesRecipients(tx *sql.Tx, account aws.AwsAccount) ([]*models.User, error) {
+	owner, err := models.UserByID(tx, account.UserId)
+	if err != nil {
+		return nil, err
+	}
+	recipients := []*models.User{owner}
+	sharedAccounts, err := models.SharedAccountsByAccountID(tx, account.Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, sharedAccount := range sharedAccounts {
+		if !sharedAccount.SharingAccepted {
+			continue
+		}
+		if user, err := models.UserByID(tx, sharedAccount.UserID); err != nil {
+			return nil, err
+		} else {
+			recipients = append(recipients, user)
+		}
+	}
+	return recipients, nil
+}
+
+// getRecipientAnomalies returns the anomalies a recipient has to be emailed:
+// the ones which are neither snoozed, filtered nor already emailed.
+func getRecipientAnomalies(ctx context.Context, tx *sql.Tx, account aws.AwsAccount, recipient *models.User, candidates anomalyType.ProductAnomalies) ([]anomalyType.ProductAnomaly, map[string]string, error) {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	snoozedAnomalies, err := models.AnomalySnoozingsByUserID(tx, recipient.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	snoozed := make(map[string]bool)
+	for _, snoozedAnomaly := range snoozedAnomalies {
+		snoozed[snoozedAnomaly.AnomalyID] = true
+	}
+	productAnomalies := make(anomalyType.ProductAnomalies)
+	for product, ans := range candidates {
+		productAnomalies[product] = make([]anomalyType.ProductAnomaly, len(ans))
+		for i, an := range ans {
+			an.Snoozed = snoozed[an.Id]
+			productAnomalies[product][i] = an
+		}
+	}
+	res := anomalyType.AnomaliesDetectionResponse{account.AwsIdentity: productAnomalies}
+	if recipient.AnomaliesFilters != nil {
+		var filters anomalyType.Filters
+		if err := json.Unmarshal(recipient.AnomaliesFilters, &filters); err != nil {
+			logger.Error("Failed to unmarshal anomalies filters", map[string]interface{}{
+				"userId": recipient.ID,
+				"error":  err.Error(),
+			})
+		} else {
+			res = anomalyFilters.Apply(filters, res)
+		}
+	}
+	var toEmail []anomalyType.ProductAnomaly
+	products := make(map[string]string)
+	for product, ans := range res[account.AwsIdentity] {
+		for _, an := range ans {
+			if an.Snoozed || an.Filtered {
+				continue
+			}
+			if emailed, err := models.IsAnomalyAlreadyEmailed(tx, account.Id, product, recipient.Email, an.Date); err != nil {
+				return nil, nil, err
+			} else if !emailed {
+				toEmail = append(toEmail, an)
+				products[an.Id] = product
+			}
+		}
+	}
+	sort.Slice(toEmail, func(i, j int) bool {
+		if toEmail[i].Date.Equal(toEmail[j].Date) {
+			return products[toEmail[i].Id] < products[toEmail[j].Id]
+		}
+		return toEmail[i].Date.Before(toEmail[j].Date)
+	})
+	return toEmail, products, nil
+}
+
+// notifyRecipient sends the anomalies digest to a recipient and records the
+// emailed anomalies.
+func notifyRecipient(ctx context.Context, tx *sql.Tx, account aws.AwsAccount, recipient *models.User, candidates anomalyType.ProductAnomalies) error {
+	toEmail, products, err := getRecipientAnomalies(ctx, tx, account, recipient, candidates)
+	if err != nil || len(toEmail) == 0 {
+		return err
+	}
+	conversion, err := currency.NewConversion(tx, recipient.Currency)
+	if err != nil {
+		return err
+	}
+	converted := make([]anomalyType.ProductAnomaly, len(toEmail))
+	for i, an := range toEmail {
+		if an.Cost, err = conversion.Convert(an.Cost, currency.Base, an.Date); err != nil {
+			return err
+		} else if an.UpperBand, err = conversion.Convert(an.UpperBand, currency.Base, an.Date); err != nil {
+			return err
+		}
+		converted[i] = an
+	}
+	subject, body := buildAnomaliesDigest(account, converted, products, conversion.Target)
+	if err := mail.SendMail(recipient.Email, subject, body, ctx); err != nil {
+		return err
+	}
+	for _, an := range toEmail {
+		dbEmailedAnomaly := models.EmailedAnomaly{
+			AwsAccountID: account.Id,
+			Product:      products[an.Id],
+			Recipient:    recipient.Email,
+			Date:         an.Date,
+		}
+		if err := dbEmailedAnomaly.Insert(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildAnomaliesDigest builds the subject and the body of the mail listing
+// the anomalies of an AWS account, whose costs are in currencyCode.
+func buildAnomaliesDigest(account aws.AwsAccount, anomalies []anomalyType.ProductAnomaly, products map[string]string, currencyCode string) (string, string) {
+	subject := fmt.Sprintf("TrackIt detected %d cost anomalies on %s", len(anomalies), account.Pretty)
+	body := fmt.Sprintf("Hello,\r\n\r\nTrackIt detected the following cost anomalies on your AWS account %s (%s):\r\n\r\n", account.Pretty, account.AwsIdentity)
+	for _, an := range anomalies {
+		body += fmt.Sprintf("- %s, %s: %.2f %s spent while %.2f %s was expected at most (%s)\r\n",
+			an.Date.Format("2006-01-02"), products[an.Id], an.Cost, currencyCode, an.UpperBand, currencyCode, an.PrettyLevel)
+	}
+	body += "\r\nYou can snooze these anomalies or filter them out on https://re.trackit.io.\r\n"
+	return subject, body
+}
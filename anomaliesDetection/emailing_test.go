@@ -0,0 +1,187 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trackit/trackit-server/aws"
+	"github.com/trackit/trackit-server/costs/anomalies/anomalyType"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/users"
+)
+
+// makeEsAnomaly creates an anomaly as stored in ElasticSearch.
+func makeEsAnomaly(id, product, date string, cost, maxExpected float64, abnormal, recurrent bool) esProductAnomalyWithId {
+	var an esProductAnomalyWithId
+	an.Id = id
+	an.Source.Product = product
+	an.Source.Date = date
+	an.Source.Abnormal = abnormal
+	an.Source.Recurrent = recurrent
+	an.Source.Cost.Value = cost
+	an.Source.Cost.MaxExpected = maxExpected
+	return an
+}
+
+// anomalyIds returns the ids of anomalies grouped by product.
+func anomalyIds(anomalies anomalyType.ProductAnomalies) map[string][]string {
+	res := make(map[string][]string)
+	for product, ans := range anomalies {
+		for _, an := range ans {
+			res[product] = append(res[product], an.Id)
+		}
+	}
+	return res
+}
+
+func TestGetAnomaliesToEmail(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		raw      esProductAnomaliesWithId
+		expected map[string][]string
+	}{
+		{"normal", esProductAnomaliesWithId{
+			makeEsAnomaly("a", "AmazonEC2", "2018-02-01T00:00:00Z", 300, 100, false, false),
+		}, map[string][]string{}},
+		{"recurrent", esProductAnomaliesWithId{
+			makeEsAnomaly("a", "AmazonEC2", "2018-02-01T00:00:00Z", 300, 100, true, true),
+		}, map[string][]string{}},
+		{"level too low", esProductAnomaliesWithId{
+			makeEsAnomaly("a", "AmazonEC2", "2018-02-01T00:00:00Z", 130, 100, true, false),
+		}, map[string][]string{}},
+		{"invalid date", esProductAnomaliesWithId{
+			makeEsAnomaly("a", "AmazonEC2", "2018-02-01", 300, 100, true, false),
+		}, map[string][]string{}},
+		{"grouped by product", esProductAnomaliesWithId{
+			makeEsAnomaly("a", "AmazonEC2", "2018-02-01T00:00:00Z", 300, 100, true, false),
+			makeEsAnomaly("b", "AmazonS3", "2018-02-01T00:00:00Z", 160, 100, true, false),
+			makeEsAnomaly("c", "AmazonEC2", "2018-02-02T00:00:00Z", 250, 100, true, false),
+			makeEsAnomaly("d", "AmazonS3", "2018-02-02T00:00:00Z", 110, 100, true, false),
+		}, map[string][]string{
+			"AmazonEC2": {"a", "c"},
+			"AmazonS3":  {"b"},
+		}},
+	} {
+		if ids := anomalyIds(getAnomaliesToEmail(c.raw)); !reflect.DeepEqual(ids, c.expected) {
+			t.Errorf("%s: anomalies to email should be %v, are %v instead.", c.name, c.expected, ids)
+		}
+	}
+}
+
+func TestBuildAnomaliesDigest(t *testing.T) {
+	account := aws.AwsAccount{Pretty: "Production", AwsIdentity: "123456789012"}
+	anomalies := []anomalyType.ProductAnomaly{
+		{Id: "a", Date: time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC), Cost: 300, UpperBand: 100, PrettyLevel: "critical"},
+		{Id: "b", Date: time.Date(2018, time.February, 2, 0, 0, 0, 0, time.UTC), Cost: 160.5, UpperBand: 100, PrettyLevel: "high"},
+	}
+	products := map[string]string{"a": "AmazonEC2", "b": "AmazonS3"}
+	subject, body := buildAnomaliesDigest(account, anomalies, products, "EUR")
+	if expected := "TrackIt detected 2 cost anomalies on Production"; subject != expected {
+		t.Errorf("Subject should be %q, is %q instead.", expected, subject)
+	}
+	for _, expected := range []string{
+		"your AWS account Production (123456789012)",
+		"- 2018-02-01, AmazonEC2: 300.00 EUR spent while 100.00 EUR was expected at most (critical)\r\n",
+		"- 2018-02-02, AmazonS3: 160.50 EUR spent while 100.00 EUR was expected at most (high)\r\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Body should contain %q, is %q instead.", expected, body)
+		}
+	}
+	if strings.Index(body, "AmazonEC2") > strings.Index(body, "AmazonS3") {
+		t.Errorf("Anomalies should be listed in the given order.")
+	}
+}
+
+// This test is intended to be run against an empty database with the schema
+// already in place.
+func TestGetRecipientAnomalies(t *testing.T) {
+	ctx := context.Background()
+	owner, err := users.CreateUserWithPassword(ctx, db.Db, "anomalies.owner@example.com", "anomaliesPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	dbAccount := models.AwsAccount{UserID: owner.Id, Pretty: "Production", AwsIdentity: "123456789012"}
+	if err := dbAccount.Insert(db.Db); err != nil {
+		t.Fatalf("Creating AWS account: error should be nil, instead is \"%s\".", err.Error())
+	}
+	account := aws.AwsAccount{Id: dbAccount.ID, UserId: owner.Id, AwsIdentity: dbAccount.AwsIdentity}
+	first := time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC)
+	second := first.AddDate(0, 0, 1)
+	candidates := anomalyType.ProductAnomalies{
+		"AmazonEC2": {
+			{Id: "ec2-second", Date: second, Abnormal: true},
+			{Id: "ec2-first", Date: first, Abnormal: true},
+		},
+		"AmazonS3": {
+			{Id: "s3-first", Date: first, Abnormal: true},
+			{Id: "s3-snoozed", Date: second, Abnormal: true},
+		},
+	}
+	snoozing := models.AnomalySnoozing{UserID: owner.Id, AnomalyID: "s3-snoozed"}
+	if err := snoozing.Insert(db.Db); err != nil {
+		t.Fatalf("Snoozing anomaly: error should be nil, instead is \"%s\".", err.Error())
+	}
+	emailed := models.EmailedAnomaly{AwsAccountID: account.Id, Product: "AmazonEC2", Recipient: "anomalies.emailed@example.com", Date: first}
+	if err := emailed.Insert(db.Db); err != nil {
+		t.Fatalf("Recording emailed anomaly: error should be nil, instead is \"%s\".", err.Error())
+	}
+	for _, c := range []struct {
+		name      string
+		recipient *models.User
+		expected  []string
+	}{
+		{"snoozed", &models.User{ID: owner.Id, Email: owner.Email}, []string{"ec2-first", "s3-first", "ec2-second"}},
+		{"not snoozed", &models.User{Email: "anomalies.shared@example.com"}, []string{"ec2-first", "s3-first", "ec2-second", "s3-snoozed"}},
+		{"filtered", &models.User{Email: "anomalies.filtered@example.com", AnomaliesFilters: []byte(`[{"rule":"product","data":["S3"]}]`)}, []string{"s3-first", "s3-snoozed"}},
+		{"already emailed", &models.User{Email: "anomalies.emailed@example.com"}, []string{"s3-first", "ec2-second", "s3-snoozed"}},
+	} {
+		tx, err := db.Db.Begin()
+		if err != nil {
+			t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+		}
+		toEmail, products, err := getRecipientAnomalies(ctx, tx, account, c.recipient, candidates)
+		tx.Rollback()
+		if err != nil {
+			t.Errorf("%s: error should be nil, instead is \"%s\".", c.name, err.Error())
+			continue
+		}
+		ids := make([]string, len(toEmail))
+		for i, an := range toEmail {
+			ids[i] = an.Id
+		}
+		if !reflect.DeepEqual(ids, c.expected) {
+			t.Errorf("%s: anomalies to email should be %v, are %v instead.", c.name, c.expected, ids)
+		}
+		for _, id := range ids {
+			if expected := strings.SplitN(id, "-", 2)[0]; !strings.Contains(strings.ToLower(products[id]), expected) {
+				t.Errorf("%s: anomaly %s should be from product %s, is from %q instead.", c.name, id, expected, products[id])
+			}
+		}
+	}
+	for product, ans := range candidates {
+		for _, an := range ans {
+			if an.Snoozed || an.Filtered {
+				t.Errorf("Candidate %s of %s should not be modified.", an.Id, product)
+			}
+		}
+	}
+}
@@ -19,7 +19,7 @@ import (
 
 	"gopkg.in/olivere/elastic.v5"
 
-	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/currency"
 )
 
 const (
@@ -37,10 +37,7 @@ func createQueryAccountFilter(account string) *elastic.TermQuery {
 
 // createQueryTimeRange creates and return a new *elastic.RangeQuery based on the duration
 // defined by durationBegin and durationEnd.
-// durationBegin is reduced by period. This offset is deleted later.
 func createQueryTimeRange(durationBegin time.Time, durationEnd time.Time) *elastic.RangeQuery {
-	periodDuration := time.Duration(config.AnomalyDetectionBollingerBandPeriod) * 24 * time.Hour
-	durationBegin = durationBegin.Add(-periodDuration - 1)
 	return elastic.NewRangeQuery("usageStartDate").
 		From(durationBegin).To(durationEnd)
 }
@@ -56,12 +53,13 @@ func createQueryTimeRange(durationBegin time.Time, durationEnd time.Time) *elast
 //	- client *elastic.Client : an instance of *elastic.Client that represent an Elastic Search client.
 //	- index string : The Elastic Search index on wich to execute the query. In this context the default value
 //	should be "awsdetailedlineitems"
+//	- conversion currency.Conversion : The conversion of the costs to the currency they are analyzed in
 // This function excepts arguments passed to it to be sanitize. If they are not, the following cases will make
 // it crash :
 //	- If the client is nil or malconfigured, it will crash
 //	- If the index is not an index present in the ES, it will crash
 func getProductElasticSearchParams(account string, durationBegin time.Time,
-	durationEnd time.Time, aggregationPeriod string, client *elastic.Client, index string) *elastic.SearchService {
+	durationEnd time.Time, aggregationPeriod string, client *elastic.Client, index string, conversion currency.Conversion) *elastic.SearchService {
 	query := elastic.NewBoolQuery()
 	query = query.Filter(createQueryAccountFilter(account))
 	query = query.Filter(createQueryTimeRange(durationBegin, durationEnd))
@@ -69,7 +67,7 @@ func getProductElasticSearchParams(account string, durationBegin time.Time,
 
 	search.Aggregation("products", elastic.NewTermsAggregation().Field("productCode").Size(aggregationMaxSize).
 		SubAggregation("dates", elastic.NewDateHistogramAggregation().Field("usageStartDate").ExtendedBounds(durationBegin, durationEnd).Interval(aggregationPeriod).
-			SubAggregation("cost", elastic.NewSumAggregation().Field("unblendedCost"))))
+			SubAggregation("cost", conversion.SumAggregation("unblendedCost"))))
 	return search
 }
 
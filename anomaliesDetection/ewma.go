@@ -0,0 +1,70 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"math"
+
+	"github.com/trackit/trackit-server/config"
+)
+
+// defaultEwmaAlpha is used if config.AnomalyDetectionEwmaAlpha is not
+// between 0 and 1.
+const defaultEwmaAlpha = 0.3
+
+// ewmaDetector detects anomalies with an exponentially weighted moving average
+// and standard deviation. Recent costs weigh more than older ones, so the
+// upper band follows lasting changes of the spending faster than a window.
+type ewmaDetector struct{}
+
+// ewmaAlpha returns the smoothing factor of the moving average.
+func ewmaAlpha() float64 {
+	if alpha := config.AnomalyDetectionEwmaAlpha; alpha > 0 && alpha < 1 {
+		return alpha
+	}
+	return defaultEwmaAlpha
+}
+
+// Period returns the number of days after which the weight of the older costs
+// falls below 5%.
+func (ewmaDetector) Period() int {
+	return int(math.Ceil(math.Log(0.05) / math.Log(1-ewmaAlpha())))
+}
+
+// Detect generates an upper band from the moving average and standard
+// deviation of the previous costs, then updates them with the current cost.
+// No anomaly is marked before Period days, while the moving standard deviation
+// is not reliable yet.
+func (d ewmaDetector) Detect(aCosts AnalyzedCosts) AnalyzedCosts {
+	alpha := ewmaAlpha()
+	warmUp := d.Period()
+	var avg, variance float64
+	for index := range aCosts {
+		a := &aCosts[index]
+		if index > 0 {
+			a.UpperBand = avg + minDeviation(math.Sqrt(variance), avg)*config.AnomalyDetectionEwmaStandardDeviationCoefficient
+			if index >= warmUp && a.Cost > a.UpperBand {
+				a.Anomaly = true
+			}
+			diff := a.Cost - avg
+			increment := alpha * diff
+			avg += increment
+			variance = (1 - alpha) * (variance + diff*increment)
+		} else {
+			avg = a.Cost
+		}
+	}
+	return aCosts
+}
@@ -0,0 +1,41 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/trackit/trackit-server/config"
+)
+
+// GetAnomalyLevel gets the anomaly level depending on its cost and the
+// maximum expected cost, following config.AnomalyDetectionLevels. It returns
+// the level and its pretty name.
+func GetAnomalyLevel(abnormal bool, cost float64, maxExpected float64) (int, string) {
+	if !abnormal {
+		return 0, ""
+	}
+	prettyLevels := strings.Split(config.AnomalyDetectionPrettyLevels, ",")
+	percent := (cost * 100) / maxExpected
+	levels := strings.Split(config.AnomalyDetectionLevels, ",")
+	for i, level := range levels[1:] {
+		l, _ := strconv.ParseFloat(level, 64)
+		if percent < l {
+			return i, prettyLevels[i]
+		}
+	}
+	return len(levels) - 1, prettyLevels[len(levels)-1]
+}
@@ -0,0 +1,60 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"math"
+
+	"github.com/trackit/trackit-server/config"
+)
+
+// madScaleFactor makes the median absolute deviation comparable to the
+// standard deviation of normally distributed costs.
+const madScaleFactor = 1.4826
+
+// madDetector detects anomalies with the median and the median absolute
+// deviation of a moving window. Unlike the average and the standard deviation,
+// they are not skewed by the spikes of the window.
+type madDetector struct{}
+
+// Period returns config.AnomalyDetectionMadPeriod.
+func (madDetector) Period() int {
+	return config.AnomalyDetectionMadPeriod
+}
+
+// Detect generates an upper band from the median and the median absolute
+// deviation of the costs of the previous days.
+func (madDetector) Detect(aCosts AnalyzedCosts) AnalyzedCosts {
+	for index := range aCosts {
+		if index > 0 {
+			a := &aCosts[index]
+			window := aCosts[index-min(index, config.AnomalyDetectionMadPeriod) : index]
+			values := make([]float64, len(window))
+			for i, w := range window {
+				values[i] = w.Cost
+			}
+			med := median(values)
+			for i, w := range window {
+				values[i] = math.Abs(w.Cost - med)
+			}
+			mad := median(values)
+			a.UpperBand = med + minDeviation(mad*madScaleFactor, med)*config.AnomalyDetectionMadCoefficient
+			if a.Cost > a.UpperBand {
+				a.Anomaly = true
+			}
+		}
+	}
+	return aCosts
+}
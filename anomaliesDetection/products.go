@@ -84,9 +84,9 @@ type (
 
 // runAnomaliesDetectionForProducts will get data from ElasticSearch,
 // compute anomalies and ingest the result in ElasticSearch.
-func runAnomaliesDetectionForProducts(parsedParams AnomalyEsQueryParams, account aws.AwsAccount, ctx context.Context) (err error) {
+func runAnomaliesDetectionForProducts(parsedParams AnomalyEsQueryParams, account aws.AwsAccount, detector Detector, ctx context.Context) (err error) {
 	var res AnalyzedCosts
-	if res, err = productGetAnomaliesData(ctx, parsedParams); err != nil {
+	if res, err = productGetAnomaliesData(ctx, parsedParams, detector); err != nil {
 	} else if err = productSaveAnomaliesData(ctx, res, account); err != nil {
 	} else if err = removeRecurrence(ctx, parsedParams, account); err != nil {
 	}
@@ -226,9 +226,12 @@ func productGetTotalCostByDay(typedDocument esProductTypedResult) totalCostByDay
 }
 
 // productGetAnomaliesData returns product anomalies based on query params, in JSON format.
-func productGetAnomaliesData(ctx context.Context, params AnomalyEsQueryParams) (AnalyzedCosts, error) {
+func productGetAnomaliesData(ctx context.Context, params AnomalyEsQueryParams, detector Detector) (AnalyzedCosts, error) {
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
-	sr, err := makeElasticSearchRequest(ctx, getProductElasticSearchParams, params)
+	// The history needed by the detector is retrieved too. This offset is deleted later.
+	esParams := params
+	esParams.DateBegin = params.DateBegin.AddDate(0, 0, -detector.Period()).Add(-1)
+	sr, err := makeElasticSearchRequest(ctx, getProductElasticSearchParams, esParams)
 	if err != nil {
 		return nil, err
 	}
@@ -254,7 +257,7 @@ func productGetAnomaliesData(ctx context.Context, params AnomalyEsQueryParams) (
 				Anomaly: false,
 			})
 		}
-		aCosts = computeAnomalies(ctx, aCosts, params.DateBegin)
+		aCosts = computeAnomalies(ctx, aCosts, esParams.DateBegin, detector)
 		aCosts = deleteOffset(aCosts, params.DateBegin)
 		totalAnalyzedCosts = append(totalAnalyzedCosts, aCosts...)
 	}
@@ -120,7 +120,7 @@ func transformAnomaliesToMap(raw esProductAnomaliesWithId) anomaliesByProduct {
 // getAnomaliesFromEs returns product anomalies in ElasticSearch
 func getAnomaliesFromEs(ctx context.Context, params AnomalyEsQueryParams) (esProductAnomaliesWithId, error) {
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
-	sr, err := getAnomalyElasticSearchParams(params.Account, params.DateBegin, params.DateEnd, es.Client, params.Index, TypeProductAnomaliesDetection).Do(ctx)
+	sr, err := es.DoSearch(ctx, "anomaliesDetection/recurrence", getAnomalyElasticSearchParams(params.Account, params.DateBegin, params.DateEnd, es.Client, params.Index, TypeProductAnomaliesDetection))
 	if err != nil {
 		return nil, err
 	}
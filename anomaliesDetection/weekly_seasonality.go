@@ -0,0 +1,50 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"github.com/trackit/trackit-server/config"
+)
+
+// weeklySeasonalityDetector detects anomalies by comparing each day to the same
+// weekday of the config.AnomalyDetectionWeeklySeasonalityWeeks previous weeks.
+// Workloads following a weekly pattern, such as weekend batches, are then not
+// flagged each time the pattern repeats.
+type weeklySeasonalityDetector struct{}
+
+// Period returns the number of days in config.AnomalyDetectionWeeklySeasonalityWeeks.
+func (weeklySeasonalityDetector) Period() int {
+	return 7 * config.AnomalyDetectionWeeklySeasonalityWeeks
+}
+
+// Detect generates an upper band from the average and the standard deviation
+// of the costs of the same weekday. Costs must be daily, without gaps.
+func (weeklySeasonalityDetector) Detect(aCosts AnalyzedCosts) AnalyzedCosts {
+	for index := range aCosts {
+		sameWeekday := make(AnalyzedCosts, 0, config.AnomalyDetectionWeeklySeasonalityWeeks)
+		for week := 1; week <= config.AnomalyDetectionWeeklySeasonalityWeeks && index-7*week >= 0; week++ {
+			sameWeekday = append(sameWeekday, aCosts[index-7*week])
+		}
+		if len(sameWeekday) > 0 {
+			a := &aCosts[index]
+			avg := average(sameWeekday)
+			a.UpperBand = avg + minDeviation(standardDeviation(sameWeekday, avg), avg)*config.AnomalyDetectionWeeklySeasonalityStandardDeviationCoefficient
+			if a.Cost > a.UpperBand {
+				a.Anomaly = true
+			}
+		}
+	}
+	return aCosts
+}
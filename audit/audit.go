@@ -0,0 +1,154 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/routes"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin                  = "user.login"
+	ActionLoginFailure           = "user.login.failure"
+	ActionPasswordReset          = "user.password.reset"
+	ActionViewerCreate           = "user.viewer.create"
+	ActionUserDelete             = "user.delete"
+	ActionOidcLink               = "user.oidc.link"
+	ActionAwsAccountCreate       = "aws.account.create"
+	ActionAwsAccountUpdate       = "aws.account.update"
+	ActionAwsAccountDelete       = "aws.account.delete"
+	ActionBillRepositoryCreate   = "aws.billrepository.create"
+	ActionBillRepositoryUpdate   = "aws.billrepository.update"
+	ActionBillRepositoryDelete   = "aws.billrepository.delete"
+	ActionShareInvite            = "share.invite"
+	ActionShareUpdate            = "share.update"
+	ActionShareDelete            = "share.delete"
+	ActionAnomaliesFiltersUpdate = "anomalies.filters.update"
+	ActionAnomaliesSnooze        = "anomalies.snooze"
+	ActionAnomaliesUnsnooze      = "anomalies.unsnooze"
+)
+
+// Types of the targets of the actions.
+const (
+	TargetUser           = "user"
+	TargetAwsAccount     = "aws_account"
+	TargetBillRepository = "bill_repository"
+	TargetShare          = "share"
+	TargetAnomaly        = "anomaly"
+)
+
+// Actor is the user who performed an action. Its zero value stands for an
+// unknown actor, such as someone failing to log in.
+type Actor struct {
+	Id    int
+	Email string
+}
+
+type contextKey uint
+
+const contextKeyActor = contextKey(iota)
+
+// ContextWithActor returns a copy of a context which holds the user performing
+// the actions of a request. It is set when the request is authenticated, so
+// that viewers acting as their parent are still recorded as themselves.
+func ContextWithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, contextKeyActor, actor)
+}
+
+// ActorFromContext returns the actor held by a context, or the zero Actor.
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(contextKeyActor).(Actor)
+	return actor
+}
+
+// Entry is an action to be recorded in the audit log.
+type Entry struct {
+	Action string
+	// Actor defaults to the actor held by the request's context.
+	Actor Actor
+	// OwnerId is the ID of the user whose audit log the entry is part of.
+	OwnerId int
+	// AwsAccountId is the ID of the AWS account the action is about, if
+	// any. The entry can then be seen by all of the account's
+	// administrators.
+	AwsAccountId int
+	TargetType   string
+	TargetId     int
+	// Before and After are the states of the target before and after the
+	// action. They are stored as JSON.
+	Before interface{}
+	After  interface{}
+}
+
+// Record appends an entry to the audit log, along with the ID and client
+// address of the request the action was performed in. It should be given the
+// request's transaction, so that the entry is only kept if the action
+// succeeds.
+func Record(r *http.Request, db models.XODB, e Entry) error {
+	logger := jsonlog.LoggerFromContextOrDefault(r.Context())
+	if e.Actor == (Actor{}) {
+		e.Actor = ActorFromContext(r.Context())
+	}
+	dbAuditLog := models.AuditLog{
+		Created:      time.Now(),
+		OwnerUserID:  e.OwnerId,
+		AwsAccountID: nullInt64(e.AwsAccountId),
+		ActorUserID:  nullInt64(e.Actor.Id),
+		ActorEmail:   e.Actor.Email,
+		Action:       e.Action,
+		TargetType:   e.TargetType,
+		TargetID:     nullInt64(e.TargetId),
+		RequestID:    routes.RequestIdFromContext(r.Context()),
+		IP:           routes.RemoteAddress(r),
+	}
+	var err error
+	if dbAuditLog.BeforePayload, err = marshalPayload(e.Before); err != nil {
+		logger.Error("Failed to marshal audit log payload.", err.Error())
+		return err
+	}
+	if dbAuditLog.AfterPayload, err = marshalPayload(e.After); err != nil {
+		logger.Error("Failed to marshal audit log payload.", err.Error())
+		return err
+	}
+	if err = dbAuditLog.Insert(db); err != nil {
+		logger.Error("Failed to insert audit log entry.", map[string]interface{}{
+			"action": e.Action,
+			"error":  err.Error(),
+		})
+	}
+	return err
+}
+
+// marshalPayload marshals a payload to JSON, or to nil if it is nil.
+func marshalPayload(payload interface{}) ([]byte, error) {
+	if payload == nil {
+		return nil, nil
+	}
+	return json.Marshal(payload)
+}
+
+// nullInt64 converts an ID to a sql.NullInt64 which is null for zero.
+func nullInt64(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
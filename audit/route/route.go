@@ -0,0 +1,181 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package audit_route
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+	"github.com/trackit/trackit-server/users/shared_account"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 1000
+)
+
+type (
+	// auditLogEntry is an entry of the audit log as returned by getAuditLog.
+	auditLogEntry struct {
+		Id           int             `json:"id"`
+		Created      time.Time       `json:"created"`
+		OwnerId      int             `json:"ownerId"`
+		AwsAccountId *int64          `json:"awsAccountId"`
+		ActorId      *int64          `json:"actorId"`
+		ActorEmail   string          `json:"actorEmail"`
+		Action       string          `json:"action"`
+		TargetType   string          `json:"targetType"`
+		TargetId     *int64          `json:"targetId"`
+		RequestId    string          `json:"requestId"`
+		Ip           string          `json:"ip"`
+		Before       json.RawMessage `json:"before"`
+		After        json.RawMessage `json:"after"`
+	}
+
+	// auditLogResponseBody is the response body of getAuditLog. Next is the
+	// value of the before query argument to get the following page, or zero
+	// if there are no more entries.
+	auditLogResponseBody struct {
+		Entries []auditLogEntry `json:"entries"`
+		Next    int             `json:"next"`
+	}
+)
+
+var (
+	limitQueryArg = routes.QueryArg{
+		Name:        "limit",
+		Type:        routes.QueryArgUint{},
+		Description: "The maximum number of entries to return, 50 by default and at most 1000.",
+		Optional:    true,
+	}
+	beforeQueryArg = routes.QueryArg{
+		Name:        "before",
+		Type:        routes.QueryArgUint{},
+		Description: "Only return entries older than the one with this ID.",
+		Optional:    true,
+	}
+)
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getAuditLog).With(
+			db.RequestTransaction{db.Db},
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.QueryArgs{limitQueryArg, beforeQueryArg},
+			routes.Documentation{
+				Summary:     "get the audit log",
+				Description: "Responds with the security and configuration actions performed on the user's account and on the AWS accounts they administer, most recent first.",
+			},
+		),
+	}.H().Register("/audit")
+}
+
+// getAuditLog returns a page of the audit log of the authenticated user. It
+// includes the entries about AWS accounts shared with the user with the
+// administrator permission level.
+func getAuditLog(request *http.Request, a routes.Arguments) (int, interface{}) {
+	logger := jsonlog.LoggerFromContextOrDefault(request.Context())
+	user := a[users.AuthenticatedUser].(users.User)
+	tx := a[db.Transaction].(*sql.Tx)
+	limit := defaultLimit
+	if l, ok := a[limitQueryArg].(uint); ok && l > 0 {
+		limit = int(l)
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+	}
+	before := 0
+	if b, ok := a[beforeQueryArg].(uint); ok {
+		before = int(b)
+	}
+	awsAccountIds, err := administeredSharedAccountIds(tx, user)
+	if err != nil {
+		logger.Error("Failed to get shared accounts.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to get audit log.")
+	}
+	dbAuditLogs, err := models.AuditLogsForOwnerOrAwsAccounts(tx, user.Id, awsAccountIds, before, limit)
+	if err != nil {
+		logger.Error("Failed to get audit log.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to get audit log.")
+	}
+	res := auditLogResponseBody{Entries: make([]auditLogEntry, len(dbAuditLogs))}
+	for i, dbAuditLog := range dbAuditLogs {
+		res.Entries[i] = auditLogEntryFromDbAuditLog(*dbAuditLog)
+	}
+	if len(dbAuditLogs) == limit {
+		res.Next = dbAuditLogs[len(dbAuditLogs)-1].ID
+	}
+	return http.StatusOK, res
+}
+
+// administeredSharedAccountIds returns the IDs of the AWS accounts shared
+// with a user with the administrator permission level.
+func administeredSharedAccountIds(tx *sql.Tx, user users.User) ([]int, error) {
+	dbSharedAccounts, err := models.SharedAccountsByUserID(tx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(dbSharedAccounts))
+	for _, dbSharedAccount := range dbSharedAccounts {
+		if dbSharedAccount.SharingAccepted && dbSharedAccount.UserPermission == shared_account.AdminLevel {
+			ids = append(ids, dbSharedAccount.AccountID)
+		}
+	}
+	return ids, nil
+}
+
+func auditLogEntryFromDbAuditLog(dbAuditLog models.AuditLog) auditLogEntry {
+	return auditLogEntry{
+		Id:           dbAuditLog.ID,
+		Created:      dbAuditLog.Created,
+		OwnerId:      dbAuditLog.OwnerUserID,
+		AwsAccountId: nullInt64Pointer(dbAuditLog.AwsAccountID),
+		ActorId:      nullInt64Pointer(dbAuditLog.ActorUserID),
+		ActorEmail:   dbAuditLog.ActorEmail,
+		Action:       dbAuditLog.Action,
+		TargetType:   dbAuditLog.TargetType,
+		TargetId:     nullInt64Pointer(dbAuditLog.TargetID),
+		RequestId:    dbAuditLog.RequestID,
+		Ip:           dbAuditLog.IP,
+		Before:       rawPayload(dbAuditLog.BeforePayload),
+		After:        rawPayload(dbAuditLog.AfterPayload),
+	}
+}
+
+// nullInt64Pointer returns nil for a null sql.NullInt64, so that it is
+// marshaled as null.
+func nullInt64Pointer(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+// rawPayload returns a JSON payload as is, or null if it is empty.
+func rawPayload(payload []byte) json.RawMessage {
+	if len(payload) == 0 {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(payload)
+}
@@ -22,6 +22,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/es"
 	"github.com/trackit/trackit-server/models"
@@ -68,6 +69,16 @@ func deleteAwsAccount(r *http.Request, a routes.Arguments) (int, interface{}) {
 			return http.StatusInternalServerError, errors.New("specified AWS account is not in user's accounts")
 		}
 	}
+	if err := audit.Record(r, tx, audit.Entry{
+		Action:       audit.ActionAwsAccountDelete,
+		OwnerId:      aa.UserId,
+		AwsAccountId: aa.Id,
+		TargetType:   audit.TargetAwsAccount,
+		TargetId:     aa.Id,
+		Before:       aa,
+	}); err != nil {
+		return http.StatusInternalServerError, errors.New("failed to delete AWS account")
+	}
 	go func() {
 		for _, br := range dbAwsBillRepositories {
 			err = es.CleanByBillRepositoryId(context.Background(), aa.UserId, br.ID)
@@ -21,6 +21,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/routes"
 	"github.com/trackit/trackit-server/users"
@@ -60,12 +61,24 @@ func patchAwsAccountWithValidBody(r *http.Request, tx *sql.Tx, user users.User,
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
 	awsAccount, err := aws.GetAwsAccountWithIdFromUser(user, id, tx)
 	if err == nil {
+		before := awsAccount
 		awsAccount.Pretty = body.Pretty
 		awsAccount.Payer = body.Payer
 		if err := awsAccount.UpdatePrettyAwsAccount(ctx, tx); err != nil {
 			logger.Error("failed to update AWS Account", err)
 			return 500, errFailUpdateAccount
 		}
+		if err := audit.Record(r, tx, audit.Entry{
+			Action:       audit.ActionAwsAccountUpdate,
+			OwnerId:      awsAccount.UserId,
+			AwsAccountId: awsAccount.Id,
+			TargetType:   audit.TargetAwsAccount,
+			TargetId:     awsAccount.Id,
+			Before:       before,
+			After:        awsAccount,
+		}); err != nil {
+			return 500, errFailUpdateAccount
+		}
 	} else {
 		logger.Error("failed to get user's AWS accounts", err.Error())
 		return 500, errors.New("failed to retrieve AWS accounts")
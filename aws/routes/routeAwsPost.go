@@ -23,6 +23,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/routes"
 	"github.com/trackit/trackit-server/users"
@@ -71,6 +72,16 @@ func postAwsAccountWithValidBody(r *http.Request, tx *sql.Tx, user users.User, b
 		logger.Warning("tried to add AWS account with bad external", account)
 		return 400, errors.New("incorrect external. Use /aws/next to get expected external")
 	} else if err := testAndCreateAwsAccount(ctx, tx, &account, &user); err == nil {
+		if err := audit.Record(r, tx, audit.Entry{
+			Action:       audit.ActionAwsAccountCreate,
+			OwnerId:      user.Id,
+			AwsAccountId: account.Id,
+			TargetType:   audit.TargetAwsAccount,
+			TargetId:     account.Id,
+			After:        account,
+		}); err != nil {
+			return 500, errFailCreateAccount
+		}
 		return 200, account
 	} else {
 		switch err {
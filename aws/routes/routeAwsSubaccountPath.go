@@ -23,6 +23,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/aws"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/routes"
@@ -55,6 +56,7 @@ func patchAwsSubaccountWithValidBody(r *http.Request, tx *sql.Tx, user users.Use
 		logger.Warning("failed to get user's AWS accounts", err.Error())
 		return 400, errors.New("failed to retrieve AWS accounts.")
 	}
+	before := awsAccount
 	awsAccount.RoleArn = body.RoleArn
 	awsAccount.External = body.External
 	if awsAccount.ParentId.Valid == false {
@@ -67,6 +69,17 @@ func patchAwsSubaccountWithValidBody(r *http.Request, tx *sql.Tx, user users.Use
 		logger.Info("role account id does not match aws identity", awsAccount)
 		return 400, errors.New("role account id does not match aws identity.")
 	} else if err := testAndUpdateSubaccount(ctx, tx, awsAccount, user); err == nil {
+		if err := audit.Record(r, tx, audit.Entry{
+			Action:       audit.ActionAwsAccountUpdate,
+			OwnerId:      awsAccount.UserId,
+			AwsAccountId: awsAccount.Id,
+			TargetType:   audit.TargetAwsAccount,
+			TargetId:     awsAccount.Id,
+			Before:       before,
+			After:        awsAccount,
+		}); err != nil {
+			return 500, errFailUpdateAccount
+		}
 		return 200, awsAccount
 	} else {
 		switch err {
@@ -25,6 +25,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/aws"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/es"
@@ -53,7 +54,7 @@ func init() {
 			}},
 			routes.Documentation{
 				Summary:     "add a new bill repository to an aws account",
-				Description: "Adds a bill repository to an AWS account.",
+				Description: "Adds a bill repository to an AWS account. Without an endpoint, bills are read from AWS S3 with the role of the AWS account. An http or https endpoint reads them from an S3-compatible service with the static credentials of the body. A file endpoint reads them from a local directory where the bucket is a subdirectory.",
 			},
 		),
 		http.MethodPatch: routes.H(patchBillRepository).With(
@@ -85,7 +86,7 @@ func init() {
 		routes.QueryArgs{routes.AwsAccountIdQueryArg},
 		routes.Documentation{
 			Summary:     "interact with aws account's bill repositories",
-			Description: "A bill repository is an S3 location (bucket+prefix) where Cost And Usage Reports can be found.",
+			Description: "A bill repository is an S3 location (bucket+prefix) where Cost And Usage Reports can be found. It may reside on AWS S3, on an S3-compatible endpoint or in a local directory.",
 		},
 	).Register("/aws/billrepository")
 }
@@ -97,11 +98,17 @@ const (
 )
 
 // BillRepository is a location where the server may look for bill objects.
+// An empty Endpoint stands for AWS S3. Otherwise, it is either the URL of an
+// S3-compatible service, accessed with AccessKeyId and SecretAccessKey, or a
+// file URL to a local directory.
 type BillRepository struct {
 	Id                   int       `json:"id"`
 	AwsAccountId         int       `json:"awsAccountId"`
 	Bucket               string    `json:"bucket"`
 	Prefix               string    `json:"prefix"`
+	Endpoint             string    `json:"endpoint"`
+	AccessKeyId          string    `json:"accessKeyId"`
+	SecretAccessKey      string    `json:"-"`
 	Error                string    `json:"error"`
 	LastImportedManifest time.Time `json:"lastImportedManifest"`
 	NextUpdate           time.Time `json:"nextUpdate"`
@@ -111,9 +118,12 @@ type BillRepository struct {
 // not perform checks on the repository.
 func CreateBillRepository(aa aws.AwsAccount, br BillRepository, tx *sql.Tx) (BillRepository, error) {
 	dbbr := models.AwsBillRepository{
-		Prefix:       br.Prefix,
-		Bucket:       br.Bucket,
-		AwsAccountID: aa.Id,
+		Prefix:          br.Prefix,
+		Bucket:          br.Bucket,
+		Endpoint:        br.Endpoint,
+		AccessKeyID:     br.AccessKeyId,
+		SecretAccessKey: br.SecretAccessKey,
+		AwsAccountID:    aa.Id,
 	}
 	var out BillRepository
 	err := dbbr.Insert(tx)
@@ -127,6 +137,9 @@ func CreateBillRepository(aa aws.AwsAccount, br BillRepository, tx *sql.Tx) (Bil
 func UpdateBillRepositorySafe(dbBr *models.AwsBillRepository, br BillRepository, tx *sql.Tx) (BillRepository, error) {
 	dbBr.Prefix = br.Prefix
 	dbBr.Bucket = br.Bucket
+	dbBr.Endpoint = br.Endpoint
+	dbBr.AccessKeyID = br.AccessKeyId
+	dbBr.SecretAccessKey = br.SecretAccessKey
 	dbBr.AwsAccountID = br.AwsAccountId
 	dbBr.NextUpdate = br.NextUpdate
 	dbBr.LastImportedManifest = br.LastImportedManifest
@@ -202,6 +215,9 @@ func billRepoFromDbBillRepo(dbBillRepo models.AwsBillRepository) BillRepository
 		Id:                   dbBillRepo.ID,
 		Bucket:               dbBillRepo.Bucket,
 		Prefix:               dbBillRepo.Prefix,
+		Endpoint:             dbBillRepo.Endpoint,
+		AccessKeyId:          dbBillRepo.AccessKeyID,
+		SecretAccessKey:      dbBillRepo.SecretAccessKey,
 		Error:                dbBillRepo.Error,
 		AwsAccountId:         dbBillRepo.AwsAccountID,
 		LastImportedManifest: dbBillRepo.LastImportedManifest,
@@ -214,6 +230,9 @@ func dbBillRepoFromBillRepo(br BillRepository) models.AwsBillRepository {
 		ID:                   br.Id,
 		Bucket:               br.Bucket,
 		Prefix:               br.Prefix,
+		Endpoint:             br.Endpoint,
+		AccessKeyID:          br.AccessKeyId,
+		SecretAccessKey:      br.SecretAccessKey,
 		Error:                br.Error,
 		AwsAccountID:         br.AwsAccountId,
 		LastImportedManifest: br.LastImportedManifest,
@@ -222,8 +241,22 @@ func dbBillRepoFromBillRepo(br BillRepository) models.AwsBillRepository {
 }
 
 type postBillRepositoryBody struct {
-	Prefix string `json:"prefix" req:""`
-	Bucket string `json:"bucket" req:"nonzero"`
+	Prefix          string `json:"prefix" req:""`
+	Bucket          string `json:"bucket" req:"nonzero"`
+	Endpoint        string `json:"endpoint"`
+	AccessKeyId     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+}
+
+// billRepository returns the BillRepository described by the body.
+func (body postBillRepositoryBody) billRepository() BillRepository {
+	return BillRepository{
+		Bucket:          body.Bucket,
+		Prefix:          body.Prefix,
+		Endpoint:        body.Endpoint,
+		AccessKeyId:     body.AccessKeyId,
+		SecretAccessKey: body.SecretAccessKey,
+	}
 }
 
 func postBillRepository(r *http.Request, a routes.Arguments) (int, interface{}) {
@@ -246,8 +279,11 @@ func postBillRepositoryWithValidBody(
 	aa aws.AwsAccount,
 	body postBillRepositoryBody,
 ) (int, interface{}) {
-	br, err := CreateBillRepository(aa, BillRepository{Bucket: body.Bucket, Prefix: body.Prefix}, tx)
+	br, err := CreateBillRepository(aa, body.billRepository(), tx)
 	if err == nil {
+		if err := recordBillRepositoryChange(r, tx, aa, audit.ActionBillRepositoryCreate, br.Id, nil, br); err != nil {
+			return http.StatusInternalServerError, errors.New("failed to create bill repository")
+		}
 		go UpdateReport(context.Background(), aa, br)
 		return http.StatusOK, br
 	} else {
@@ -290,8 +326,15 @@ func patchBillRepositoryWithValidBody(
 		})
 		return http.StatusNotFound, errors.New("failed to find bill repository to update")
 	}
-	br, err := UpdateBillRepositorySafe(dbBillingRepo, BillRepository{Id: brId, AwsAccountId: aa.Id, Bucket: body.Bucket, Prefix: body.Prefix}, tx)
+	before := billRepoFromDbBillRepo(*dbBillingRepo)
+	br := body.billRepository()
+	br.Id = brId
+	br.AwsAccountId = aa.Id
+	br, err = UpdateBillRepositorySafe(dbBillingRepo, br, tx)
 	if err == nil {
+		if err := recordBillRepositoryChange(r, tx, aa, audit.ActionBillRepositoryUpdate, br.Id, before, br); err != nil {
+			return http.StatusInternalServerError, errors.New("failed to update bill repository")
+		}
 		go func() {
 			err = es.CleanByBillRepositoryId(context.Background(), aa.UserId, br.Id)
 			if err != nil {
@@ -321,6 +364,8 @@ func isBillRepositoryValid(br postBillRepositoryBody) error {
 		return err
 	} else if err := isPrefixValid(br.Prefix); err != nil {
 		return err
+	} else if _, err := parseEndpoint(br.billRepository()); err != nil {
+		return err
 	} else {
 		return nil
 	}
@@ -349,7 +394,7 @@ func isPrefixValid(p string) error {
 
 func isBillRepositoryAccessible(ctx context.Context, aa aws.AwsAccount, body postBillRepositoryBody) error {
 	l := jsonlog.LoggerFromContextOrDefault(ctx)
-	_, _, err := getServiceForRepository(ctx, aa, BillRepository{Bucket: body.Bucket, Prefix: body.Prefix})
+	_, err := getSourceForRepository(ctx, aa, body.billRepository())
 	if err != nil {
 		l.Warning("Trying to add a bad bill location.", err.Error())
 		return errors.New("Couldn't access to this bill location.")
@@ -372,8 +417,15 @@ func deleteBillRepository(r *http.Request, a routes.Arguments) (int, interface{}
 	aa := a[aws.AwsAccountSelection].(aws.AwsAccount)
 	brId := a[routes.BillPositoryQueryArg].(int)
 	tx := a[db.Transaction].(*sql.Tx)
+	var before interface{}
+	if dbBr, err := models.AwsBillRepositoryByID(tx, brId); err == nil {
+		before = billRepoFromDbBillRepo(*dbBr)
+	}
 	err := DeleteBillRepositoryById(brId, tx)
 	if err == nil {
+		if err := recordBillRepositoryChange(r, tx, aa, audit.ActionBillRepositoryDelete, brId, before, nil); err != nil {
+			return http.StatusInternalServerError, errors.New("Failed to delete billing repository.")
+		}
 		go func() {
 			err = es.CleanByBillRepositoryId(context.Background(), aa.UserId, brId)
 			if err != nil {
@@ -393,6 +445,20 @@ func deleteBillRepository(r *http.Request, a routes.Arguments) (int, interface{}
 
 }
 
+// recordBillRepositoryChange records a change to one of an AWS account's bill
+// repositories in the audit log.
+func recordBillRepositoryChange(r *http.Request, tx *sql.Tx, aa aws.AwsAccount, action string, brId int, before, after interface{}) error {
+	return audit.Record(r, tx, audit.Entry{
+		Action:       action,
+		OwnerId:      aa.UserId,
+		AwsAccountId: aa.Id,
+		TargetType:   audit.TargetBillRepository,
+		TargetId:     brId,
+		Before:       before,
+		After:        after,
+	})
+}
+
 func getBillRepository(r *http.Request, a routes.Arguments) (int, interface{}) {
 	aa := a[aws.AwsAccountSelection].(aws.AwsAccount)
 	tx := a[db.Transaction].(*sql.Tx)
@@ -0,0 +1,273 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/trackit/jsonlog"
+
+	taws "github.com/trackit/trackit-server/aws"
+	"github.com/trackit/trackit-server/config"
+)
+
+const (
+	// endpointSchemeFile is the scheme of the endpoints of bill repositories
+	// stored in a local directory.
+	endpointSchemeFile = "file"
+)
+
+var (
+	ErrInvalidEndpoint           = errors.New("endpoint must be an http, https or file URL")
+	ErrDirectoryEndpointDisabled = errors.New("file endpoints are disabled")
+	ErrDirectoryEndpointOutside  = errors.New("file endpoint is outside of the bill repository directory")
+	ErrCustomEndpointDisabled    = errors.New("custom endpoints are disabled")
+	ErrMissingStaticCredentials  = errors.New("custom endpoints require an access key ID and a secret access key")
+	ErrUnexpectedCredentials     = errors.New("only custom endpoints accept static credentials")
+	ErrKeyOutsideDirectory       = errors.New("key is outside of the bill repository directory")
+
+	// errMaxCheckedKeys stops the walk of a directory once
+	// MaxCheckedKeysByRepository keys were checked.
+	errMaxCheckedKeys = errors.New("checked maximum amount of keys")
+)
+
+// billSource is a location bill objects can be listed and read from. Its
+// implementations let ReadBills process bills from AWS S3, from an
+// S3-compatible endpoint or from a local directory alike.
+type billSource interface {
+	// getKeys sends to the returned channel the keys of the objects of the
+	// bill repository which may hold new bills.
+	getKeys(context.Context) <-chan BillKey
+	// getObject returns a reader for the object at key in bucket.
+	getObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// awsBillSource reads bills from AWS S3, with credentials obtained by
+// assuming the role of an AWS account.
+type awsBillSource struct {
+	s3svc *s3.S3
+	brr   billRepositoryWithRegion
+	s3mgr dumbS3Manager
+}
+
+// compatibleBillSource reads bills from an S3-compatible endpoint, such as
+// MinIO, with static credentials.
+type compatibleBillSource struct {
+	s3svc *s3.S3
+	brr   billRepositoryWithRegion
+}
+
+// directoryBillSource reads bills from a local directory laid out like the
+// bucket of a bill repository.
+type directoryBillSource struct {
+	directory string
+	br        BillRepository
+}
+
+// getSourceForRepository returns the billSource a bill repository is read
+// from, depending on its endpoint.
+func getSourceForRepository(ctx context.Context, aa taws.AwsAccount, br BillRepository) (billSource, error) {
+	endpoint, err := parseEndpoint(br)
+	if err != nil {
+		return nil, err
+	} else if endpoint == nil {
+		s3svc, brr, err := getServiceForRepository(ctx, aa, br)
+		if err != nil {
+			return nil, err
+		}
+		source := awsBillSource{s3svc: s3svc, brr: brr}
+		source.s3mgr.init(s3svc.Client.Config.Credentials)
+		return source, nil
+	} else if endpoint.Scheme == endpointSchemeFile {
+		return getDirectorySourceForRepository(br, endpoint)
+	} else {
+		return getCompatibleSourceForRepository(ctx, br)
+	}
+}
+
+// parseEndpoint parses and validates the endpoint of a bill repository
+// against the server's configuration. It returns nil for repositories read
+// from AWS S3.
+func parseEndpoint(br BillRepository) (*url.URL, error) {
+	if br.Endpoint == "" {
+		if br.AccessKeyId != "" || br.SecretAccessKey != "" {
+			return nil, ErrUnexpectedCredentials
+		}
+		return nil, nil
+	}
+	endpoint, err := url.Parse(br.Endpoint)
+	if err != nil {
+		return nil, ErrInvalidEndpoint
+	}
+	switch endpoint.Scheme {
+	case endpointSchemeFile:
+		if config.BillRepositoryDirectory == "" {
+			return nil, ErrDirectoryEndpointDisabled
+		} else if br.AccessKeyId != "" || br.SecretAccessKey != "" {
+			return nil, ErrUnexpectedCredentials
+		} else if endpoint.Host != "" || !isWithinDirectory(config.BillRepositoryDirectory, endpoint.Path) {
+			return nil, ErrDirectoryEndpointOutside
+		}
+	case "http", "https":
+		if !config.BillRepositoryCustomEndpoints {
+			return nil, ErrCustomEndpointDisabled
+		} else if endpoint.Host == "" {
+			return nil, ErrInvalidEndpoint
+		} else if br.AccessKeyId == "" || br.SecretAccessKey == "" {
+			return nil, ErrMissingStaticCredentials
+		}
+	default:
+		return nil, ErrInvalidEndpoint
+	}
+	return endpoint, nil
+}
+
+// isWithinDirectory tells whether path is directory or one of its
+// descendants, once both are cleaned.
+func isWithinDirectory(directory, path string) bool {
+	directory = filepath.Clean(directory)
+	path = filepath.Clean(path)
+	return path == directory || strings.HasPrefix(path, directory+string(filepath.Separator))
+}
+
+// getCompatibleSourceForRepository returns a compatibleBillSource for a bill
+// repository with a custom endpoint.
+func getCompatibleSourceForRepository(ctx context.Context, br BillRepository) (compatibleBillSource, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials(br.AccessKeyId, br.SecretAccessKey, ""),
+		Endpoint:         aws.String(br.Endpoint),
+		Region:           aws.String(config.AwsRegion),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return compatibleBillSource{}, err
+	}
+	s3svc := s3.New(sess)
+	input := s3.HeadBucketInput{Bucket: aws.String(br.Bucket)}
+	if _, err := s3svc.HeadBucketWithContext(ctx, &input); err != nil {
+		return compatibleBillSource{}, err
+	}
+	jsonlog.LoggerFromContextOrDefault(ctx).Debug("Obtained S3-compatible service to read bills.", map[string]interface{}{"endpoint": br.Endpoint, "bucket": br.Bucket})
+	return compatibleBillSource{
+		s3svc: s3svc,
+		brr:   billRepositoryWithRegion{br, config.AwsRegion},
+	}, nil
+}
+
+// getDirectorySourceForRepository returns a directoryBillSource for a bill
+// repository with a file endpoint. The bucket of the repository is a
+// directory within the endpoint's path.
+func getDirectorySourceForRepository(br BillRepository, endpoint *url.URL) (directoryBillSource, error) {
+	directory := filepath.Join(filepath.FromSlash(endpoint.Path), br.Bucket)
+	if !isWithinDirectory(config.BillRepositoryDirectory, directory) {
+		return directoryBillSource{}, ErrDirectoryEndpointOutside
+	} else if info, err := os.Stat(directory); err != nil {
+		return directoryBillSource{}, err
+	} else if !info.IsDir() {
+		return directoryBillSource{}, errors.New("bucket is not a directory")
+	}
+	return directoryBillSource{directory, br}, nil
+}
+
+func (s awsBillSource) getKeys(ctx context.Context) <-chan BillKey {
+	return getKeys(ctx, s.s3svc, s.brr)
+}
+
+func (s awsBillSource) getObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	return s.s3mgr.rawS3GetObjectToReader(ctx, &httpClient, s.brr.Region, bucket, key)
+}
+
+func (s compatibleBillSource) getKeys(ctx context.Context) <-chan BillKey {
+	return getKeys(ctx, s.s3svc, s.brr)
+}
+
+func (s compatibleBillSource) getObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	input := s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	}
+	output, err := s.s3svc.GetObjectWithContext(ctx, &input)
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+// getKeys walks the directory of the bill repository. Like getKeys for S3,
+// it only sends the keys matching the repository prefix which may have
+// been modified since the last imported manifest, and gives up after
+// MaxCheckedKeysByRepository keys.
+func (s directoryBillSource) getKeys(ctx context.Context) <-chan BillKey {
+	c := make(chan BillKey)
+	l := jsonlog.LoggerFromContextOrDefault(ctx)
+	l.Debug("Getting manifest files from directory.", map[string]interface{}{"directory": s.directory, "billRepository": s.br})
+	go func() {
+		defer close(c)
+		count := 0
+		err := filepath.Walk(s.directory, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			} else if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(s.directory, path)
+			if err != nil {
+				return err
+			}
+			key := filepath.ToSlash(rel)
+			if !strings.HasPrefix(key, s.br.Prefix) || !s.br.LastImportedManifest.Before(info.ModTime().AddDate(0, 1, 0)) {
+				return nil
+			} else if count += 1; count > MaxCheckedKeysByRepository {
+				l.Warning("Checked maximum amount of keys for repository.", s.br)
+				return errMaxCheckedKeys
+			}
+			select {
+			case c <- BillKey{
+				Key:          key,
+				Bucket:       s.br.Bucket,
+				LastModified: info.ModTime(),
+			}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && err != errMaxCheckedKeys {
+			l.Error("Failed to list files from directory.", err.Error())
+		}
+	}()
+	return c
+}
+
+// getObject opens the file at key in the directory of the bill repository.
+// The bucket is ignored: manifests copied from another bucket still refer
+// to report files within the directory.
+func (s directoryBillSource) getObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	path := filepath.Join(s.directory, filepath.FromSlash(key))
+	if !isWithinDirectory(s.directory, path) {
+		return nil, ErrKeyOutsideDirectory
+	}
+	return os.Open(path)
+}
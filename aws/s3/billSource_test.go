@@ -0,0 +1,143 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package s3
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/trackit/trackit-server/config"
+)
+
+const testManifest = `{
+	"bucket": "original-bucket",
+	"reportKeys": ["bills/report/20180101-20180201/1234/report-1.csv.gz"],
+	"compression": "GZIP",
+	"billingPeriod": {"start": "20180101T000000Z", "end": "20180201T000000Z"}
+}`
+
+const testBill = `identity/LineItemId,lineItem/UsageAccountId,lineItem/LineItemType,lineItem/UnblendedCost
+a,123456789012,Usage,1.5
+b,123456789012,Tax,0.3
+`
+
+// writeTestFile writes a file and its parent directories.
+func writeTestFile(t *testing.T, path string, content []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	} else if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// makeTestBillDirectory creates a directory laid out like a bucket with a
+// usage and cost report of two line items.
+func makeTestBillDirectory(t *testing.T) string {
+	root, err := ioutil.TempDir("", "bills")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket := filepath.Join(root, "my-bucket", "bills", "report", "20180101-20180201")
+	writeTestFile(t, filepath.Join(bucket, "report-Manifest.json"), []byte(testManifest))
+	if err := os.MkdirAll(filepath.Join(bucket, "1234"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(bucket, "1234", "report-1.csv.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(testBill)); err != nil {
+		t.Fatal(err)
+	} else if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestParseEndpoint(t *testing.T) {
+	defer func(directory string, custom bool) {
+		config.BillRepositoryDirectory = directory
+		config.BillRepositoryCustomEndpoints = custom
+	}(config.BillRepositoryDirectory, config.BillRepositoryCustomEndpoints)
+	config.BillRepositoryDirectory = "/var/bills"
+	config.BillRepositoryCustomEndpoints = false
+	cases := []struct {
+		br  BillRepository
+		err error
+	}{
+		{BillRepository{}, nil},
+		{BillRepository{AccessKeyId: "key"}, ErrUnexpectedCredentials},
+		{BillRepository{Endpoint: "file:///var/bills/archive"}, nil},
+		{BillRepository{Endpoint: "file:///var/bills/../etc"}, ErrDirectoryEndpointOutside},
+		{BillRepository{Endpoint: "file:///var/billsarchive"}, ErrDirectoryEndpointOutside},
+		{BillRepository{Endpoint: "http://minio:9000", AccessKeyId: "key", SecretAccessKey: "secret"}, ErrCustomEndpointDisabled},
+		{BillRepository{Endpoint: "ftp://minio"}, ErrInvalidEndpoint},
+	}
+	for _, c := range cases {
+		if _, err := parseEndpoint(c.br); err != c.err {
+			t.Errorf("Expected %v for %s, got %v", c.err, c.br.Endpoint, err)
+		}
+	}
+	config.BillRepositoryCustomEndpoints = true
+	if _, err := parseEndpoint(BillRepository{Endpoint: "http://minio:9000", AccessKeyId: "key", SecretAccessKey: "secret"}); err != nil {
+		t.Errorf("Unexpected error %s", err.Error())
+	} else if _, err := parseEndpoint(BillRepository{Endpoint: "http://minio:9000"}); err != ErrMissingStaticCredentials {
+		t.Errorf("Expected ErrMissingStaticCredentials, got %v", err)
+	}
+}
+
+func TestDirectoryBillSource(t *testing.T) {
+	root := makeTestBillDirectory(t)
+	defer os.RemoveAll(root)
+	defer func(directory string) { config.BillRepositoryDirectory = directory }(config.BillRepositoryDirectory)
+	config.BillRepositoryDirectory = root
+	br := BillRepository{Bucket: "my-bucket", Prefix: "bills/", Endpoint: "file://" + filepath.ToSlash(root)}
+	endpoint, err := parseEndpoint(br)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	source, err := getDirectorySourceForRepository(br, endpoint)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	ctx := context.Background()
+	acceptAll := func(manifest, bool) bool { return true }
+	manifests := getManifests(ctx, source, getManifestKeys(ctx, source.getKeys(ctx)))
+	var lineItems []LineItem
+	importBills(ctx, source, manifests, func(li LineItem, ok bool) {
+		if ok {
+			lineItems = append(lineItems, li)
+		}
+	}, acceptAll)
+	if len(lineItems) != 2 {
+		t.Fatalf("Expected 2 line items, got %d", len(lineItems))
+	}
+	costs := map[string]string{}
+	for _, li := range lineItems {
+		costs[li.LineItemId] = li.UnblendedCost
+	}
+	if costs["a"] != "1.5" || costs["b"] != "0.3" {
+		t.Errorf("Unexpected line items %v", lineItems)
+	}
+	if _, err := source.getObject(ctx, "", "../../etc/passwd"); err != ErrKeyOutsideDirectory {
+		t.Errorf("Expected ErrKeyOutsideDirectory, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package s3
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Cost types are the cost metrics of line items which can be summed.
+const (
+	CostTypeUnblended    = "unblended"
+	CostTypeBlended      = "blended"
+	CostTypeAmortized    = "amortized"
+	CostTypeNetUnblended = "net-unblended"
+	CostTypeNetAmortized = "net-amortized"
+)
+
+// costTypeFields maps the cost types to the line item fields holding them.
+var costTypeFields = map[string]string{
+	CostTypeUnblended:    "unblendedCost",
+	CostTypeBlended:      "blendedCost",
+	CostTypeAmortized:    "amortizedCost",
+	CostTypeNetUnblended: "netUnblendedCost",
+	CostTypeNetAmortized: "netAmortizedCost",
+}
+
+// CostTypeField returns the line item field holding the costs of a cost type.
+// An empty cost type stands for CostTypeUnblended.
+func CostTypeField(costType string) (string, error) {
+	if costType == "" {
+		costType = CostTypeUnblended
+	}
+	if field, ok := costTypeFields[costType]; ok {
+		return field, nil
+	}
+	return "", fmt.Errorf("Invalid cost type %q.", costType)
+}
+
+// computeCostMetrics computes the amortized costs of a line item, which
+// spread reservation upfront fees and savings plan commitments over the usage
+// they cover. Net costs fall back to their counterparts before discounts when
+// the report has no net columns.
+func computeCostMetrics(li LineItem) LineItem {
+	if li.NetUnblendedCost == "" {
+		li.NetUnblendedCost = li.UnblendedCost
+	}
+	li.AmortizedCost = amortizedCost(li, false)
+	li.NetAmortizedCost = amortizedCost(li, true)
+	return li
+}
+
+// amortizedCost returns the amortized cost of a line item, after discounts
+// if net is true.
+func amortizedCost(li LineItem, net bool) float64 {
+	pick := func(cost, netCost string) float64 {
+		if net && netCost != "" {
+			return parseCost(netCost)
+		}
+		return parseCost(cost)
+	}
+	switch li.LineItemType {
+	case "DiscountedUsage":
+		return pick(li.ReservationEffectiveCost, li.ReservationNetEffectiveCost)
+	case "RIFee":
+		return pick(li.ReservationUnusedUpfrontFee, li.ReservationNetUnusedUpfrontFee) +
+			pick(li.ReservationUnusedRecurringFee, li.ReservationNetUnusedRecurringFee)
+	case "SavingsPlanCoveredUsage":
+		return pick(li.SavingsPlanEffectiveCost, li.SavingsPlanNetEffectiveCost)
+	case "SavingsPlanRecurringFee":
+		return parseCost(li.SavingsPlanTotalCommitmentToDate) - parseCost(li.SavingsPlanUsedCommitment)
+	case "SavingsPlanNegation", "SavingsPlanUpfrontFee":
+		return 0
+	case "Fee":
+		if li.ReservationArn != "" {
+			return 0
+		}
+	}
+	return pick(li.UnblendedCost, li.NetUnblendedCost)
+}
+
+// parseCost parses a cost column, empty or invalid costs being zero.
+func parseCost(cost string) float64 {
+	value, _ := strconv.ParseFloat(cost, 64)
+	return value
+}
@@ -0,0 +1,105 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package s3
+
+import (
+	"testing"
+)
+
+func TestCostTypeField(t *testing.T) {
+	for costType, expected := range map[string]string{
+		"":              "unblendedCost",
+		"unblended":     "unblendedCost",
+		"blended":       "blendedCost",
+		"amortized":     "amortizedCost",
+		"net-unblended": "netUnblendedCost",
+		"net-amortized": "netAmortizedCost",
+	} {
+		if field, err := CostTypeField(costType); err != nil {
+			t.Errorf("Cost type %q should be valid: %s", costType, err.Error())
+		} else if field != expected {
+			t.Errorf("Field of cost type %q should be %s, is %s.", costType, expected, field)
+		}
+	}
+	if _, err := CostTypeField("list"); err == nil {
+		t.Errorf("Cost type \"list\" should be invalid.")
+	}
+}
+
+func TestComputeCostMetrics(t *testing.T) {
+	for _, c := range []struct {
+		name         string
+		li           LineItem
+		amortized    float64
+		netAmortized float64
+	}{
+		{
+			"usage",
+			LineItem{LineItemType: "Usage", UnblendedCost: "2", NetUnblendedCost: "1.5"},
+			2, 1.5,
+		},
+		{
+			"usage without net columns",
+			LineItem{LineItemType: "Usage", UnblendedCost: "2"},
+			2, 2,
+		},
+		{
+			"reserved usage",
+			LineItem{LineItemType: "DiscountedUsage", UnblendedCost: "0", ReservationEffectiveCost: "0.5", ReservationNetEffectiveCost: "0.25"},
+			0.5, 0.25,
+		},
+		{
+			"unused reservation",
+			LineItem{LineItemType: "RIFee", UnblendedCost: "10", ReservationUnusedUpfrontFee: "1", ReservationUnusedRecurringFee: "2"},
+			3, 3,
+		},
+		{
+			"reservation upfront fee",
+			LineItem{LineItemType: "Fee", UnblendedCost: "1000", ReservationArn: "arn:aws:ec2:us-east-1:123456789012:reserved-instances/id"},
+			0, 0,
+		},
+		{
+			"other fee",
+			LineItem{LineItemType: "Fee", UnblendedCost: "5"},
+			5, 5,
+		},
+		{
+			"savings plan usage",
+			LineItem{LineItemType: "SavingsPlanCoveredUsage", UnblendedCost: "4", SavingsPlanEffectiveCost: "3", SavingsPlanNetEffectiveCost: "2.5"},
+			3, 2.5,
+		},
+		{
+			"savings plan negation",
+			LineItem{LineItemType: "SavingsPlanNegation", UnblendedCost: "-4"},
+			0, 0,
+		},
+		{
+			"savings plan commitment",
+			LineItem{LineItemType: "SavingsPlanRecurringFee", UnblendedCost: "10", SavingsPlanTotalCommitmentToDate: "10", SavingsPlanUsedCommitment: "7"},
+			3, 3,
+		},
+	} {
+		li := computeCostMetrics(c.li)
+		if li.AmortizedCost != c.amortized {
+			t.Errorf("Amortized cost of %s should be %f, is %f.", c.name, c.amortized, li.AmortizedCost)
+		}
+		if li.NetAmortizedCost != c.netAmortized {
+			t.Errorf("Net amortized cost of %s should be %f, is %f.", c.name, c.netAmortized, li.NetAmortizedCost)
+		}
+		if li.NetUnblendedCost == "" {
+			t.Errorf("Net unblended cost of %s should default to the unblended cost.", c.name)
+		}
+	}
+}
@@ -168,6 +168,7 @@ func ingestLineItems(ctx context.Context, bp *elastic.BulkProcessor, index strin
 			}
 			li.BillRepositoryId = br.Id
 			li = extractTags(li)
+			li = computeCostMetrics(li)
 			rq := elastic.NewBulkIndexRequest()
 			rq = rq.Index(index)
 			rq = rq.OpType(opTypeCreate)
@@ -240,6 +241,6 @@ func afterBulk(ctx context.Context) func(int64, []elastic.BulkableRequest, *elas
 				"took":        resp.Took,
 			})
 		}
-
+		es.RecordBulk("lineItems", reqs, resp, err)
 	}
 }
@@ -0,0 +1,120 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package s3
+
+import (
+	"fmt"
+
+	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/routes"
+)
+
+// Line item types of usage and cost reports.
+const (
+	LineItemTypeUsage                   = "Usage"
+	LineItemTypeDiscountedUsage         = "DiscountedUsage"
+	LineItemTypeSavingsPlanCoveredUsage = "SavingsPlanCoveredUsage"
+	LineItemTypeSavingsPlanNegation     = "SavingsPlanNegation"
+	LineItemTypeSavingsPlanUpfrontFee   = "SavingsPlanUpfrontFee"
+	LineItemTypeSavingsPlanRecurringFee = "SavingsPlanRecurringFee"
+	LineItemTypeTax                     = "Tax"
+	LineItemTypeCredit                  = "Credit"
+	LineItemTypeRefund                  = "Refund"
+	LineItemTypeFee                     = "Fee"
+	LineItemTypeRIFee                   = "RIFee"
+	LineItemTypeBundledDiscount         = "BundledDiscount"
+	LineItemTypeEdpDiscount             = "EdpDiscount"
+	LineItemTypePrivateRateDiscount     = "PrivateRateDiscount"
+	LineItemTypeDiscount                = "Discount"
+)
+
+// lineItemTypes is the set of the known line item types.
+var lineItemTypes = map[string]bool{
+	LineItemTypeUsage:                   true,
+	LineItemTypeDiscountedUsage:         true,
+	LineItemTypeSavingsPlanCoveredUsage: true,
+	LineItemTypeSavingsPlanNegation:     true,
+	LineItemTypeSavingsPlanUpfrontFee:   true,
+	LineItemTypeSavingsPlanRecurringFee: true,
+	LineItemTypeTax:                     true,
+	LineItemTypeCredit:                  true,
+	LineItemTypeRefund:                  true,
+	LineItemTypeFee:                     true,
+	LineItemTypeRIFee:                   true,
+	LineItemTypeBundledDiscount:         true,
+	LineItemTypeEdpDiscount:             true,
+	LineItemTypePrivateRateDiscount:     true,
+	LineItemTypeDiscount:                true,
+}
+
+// LineItemTypeFilter selects line items by type. An empty Include selects
+// all the types but the excluded ones.
+type LineItemTypeFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// LineItemTypeFilterFromArguments builds a LineItemTypeFilter from the
+// routes.LineItemTypesIncludeOptionalQueryArg and
+// routes.LineItemTypesExcludeOptionalQueryArg query args, and validates it.
+func LineItemTypeFilterFromArguments(a routes.Arguments) (LineItemTypeFilter, error) {
+	var f LineItemTypeFilter
+	if a[routes.LineItemTypesIncludeOptionalQueryArg] != nil {
+		f.Include = a[routes.LineItemTypesIncludeOptionalQueryArg].([]string)
+	}
+	if a[routes.LineItemTypesExcludeOptionalQueryArg] != nil {
+		f.Exclude = a[routes.LineItemTypesExcludeOptionalQueryArg].([]string)
+	}
+	return f, f.Validate()
+}
+
+// Validate checks that the filter only holds known line item types.
+func (f LineItemTypeFilter) Validate() error {
+	for _, types := range [][]string{f.Include, f.Exclude} {
+		for _, t := range types {
+			if !lineItemTypes[t] {
+				return fmt.Errorf("Invalid line item type %q.", t)
+			}
+		}
+	}
+	return nil
+}
+
+// Query returns the query selecting the line items matched by the filter, or
+// nil if it matches all of them.
+func (f LineItemTypeFilter) Query() elastic.Query {
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return nil
+	}
+	query := elastic.NewBoolQuery()
+	if len(f.Include) > 0 {
+		query = query.Filter(elastic.NewTermsQuery("lineItemType", stringsToInterfaces(f.Include)...))
+	}
+	if len(f.Exclude) > 0 {
+		query = query.MustNot(elastic.NewTermsQuery("lineItemType", stringsToInterfaces(f.Exclude)...))
+	}
+	return query
+}
+
+// stringsToInterfaces converts a []string to a []interface{}, as expected by
+// elastic.NewTermsQuery.
+func stringsToInterfaces(strings []string) []interface{} {
+	interfaces := make([]interface{}, len(strings))
+	for i, s := range strings {
+		interfaces[i] = s
+	}
+	return interfaces
+}
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"regexp"
 	"time"
@@ -33,6 +34,7 @@ import (
 	taws "github.com/trackit/trackit-server/aws"
 	"github.com/trackit/trackit-server/config"
 	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/metrics"
 	"github.com/trackit/trackit-server/util/csv"
 )
 
@@ -67,12 +69,14 @@ func (t *billTime) UnmarshalJSON(b []byte) error {
 }
 
 type manifest struct {
-	SourceBucket  string   `json:"sourceBucket"`
-	Bucket        string   `json:"bucket"`
-	ReportKeys    []string `json:"reportKeys"`
-	Compression   string   `json:"compression"`
-	ReportName    string   `json:"reportName"`
-	Account       string   `json:"account"`
+	SourceBucket  string           `json:"sourceBucket"`
+	Bucket        string           `json:"bucket"`
+	ReportKeys    []string         `json:"reportKeys"`
+	Compression   string           `json:"compression"`
+	ContentType   string           `json:"contentType"`
+	ReportName    string           `json:"reportName"`
+	Account       string           `json:"account"`
+	Columns       []manifestColumn `json:"columns"`
 	BillingPeriod struct {
 		Start billTime `json:"start"`
 		End   billTime `json:"end"`
@@ -80,6 +84,13 @@ type manifest struct {
 	LastModified time.Time
 }
 
+// manifestColumn is a column of the reports described by a manifest, e.g.
+// the "UnblendedCost" column of the "lineItem" category.
+type manifestColumn struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+}
+
 // BillKey is a key where a bill object may be found.
 type BillKey struct {
 	Region       string
@@ -117,6 +128,24 @@ type LineItem struct {
 	TaxType            string            `csv:"lineItem/TaxType"             json:"taxType"`
 	Any                map[string]string `csv:",any"                         json:"-"`
 	Tags               []LineItemTags    `csv:"-"                            json:"tags,omitempty"`
+
+	// Cost metrics other than the unblended cost. The amortized costs are
+	// computed by computeCostMetrics on ingestion.
+	BlendedCost                      string  `csv:"lineItem/BlendedCost"                                     json:"blendedCost"`
+	NetUnblendedCost                 string  `csv:"lineItem/NetUnblendedCost"                                json:"netUnblendedCost"`
+	ReservationArn                   string  `csv:"reservation/ReservationARN"                               json:"-"`
+	ReservationEffectiveCost         string  `csv:"reservation/EffectiveCost"                                json:"reservationEffectiveCost"`
+	ReservationNetEffectiveCost      string  `csv:"reservation/NetEffectiveCost"                             json:"reservationNetEffectiveCost"`
+	ReservationUnusedUpfrontFee      string  `csv:"reservation/UnusedAmortizedUpfrontFeeForBillingPeriod"    json:"-"`
+	ReservationNetUnusedUpfrontFee   string  `csv:"reservation/NetUnusedAmortizedUpfrontFeeForBillingPeriod" json:"-"`
+	ReservationUnusedRecurringFee    string  `csv:"reservation/UnusedRecurringFee"                           json:"-"`
+	ReservationNetUnusedRecurringFee string  `csv:"reservation/NetUnusedRecurringFee"                        json:"-"`
+	SavingsPlanEffectiveCost         string  `csv:"savingsPlan/SavingsPlanEffectiveCost"                     json:"savingsPlanEffectiveCost"`
+	SavingsPlanNetEffectiveCost      string  `csv:"savingsPlan/NetSavingsPlanEffectiveCost"                  json:"savingsPlanNetEffectiveCost"`
+	SavingsPlanTotalCommitmentToDate string  `csv:"savingsPlan/TotalCommitmentToDate"                        json:"-"`
+	SavingsPlanUsedCommitment        string  `csv:"savingsPlan/UsedCommitment"                               json:"-"`
+	AmortizedCost                    float64 `csv:"-"                                                        json:"amortizedCost"`
+	NetAmortizedCost                 float64 `csv:"-"                                                        json:"netAmortizedCost"`
 }
 
 type LineItemTags struct {
@@ -128,6 +157,17 @@ func (li LineItem) EsId() string {
 	return fmt.Sprintf("%s/%s", li.TimeInterval, li.LineItemId)
 }
 
+var (
+	manifestsProcessedTotal = metrics.NewCounter(
+		"trackit_bill_manifests_processed_total",
+		"Number of usage and cost report manifests processed during bill ingestion.",
+	)
+	lineItemsReadTotal = metrics.NewCounter(
+		"trackit_bill_line_items_read_total",
+		"Number of line items read from usage and cost reports during bill ingestion.",
+	)
+)
+
 type OnLineItem func(LineItem, bool)
 type ManifestPredicate func(manifest, bool) bool
 
@@ -135,17 +175,17 @@ type ManifestPredicate func(manifest, bool) bool
 // `oli` for each one.
 func ReadBills(ctx context.Context, aa taws.AwsAccount, br BillRepository, oli OnLineItem, mp ManifestPredicate) (time.Time, error) {
 	var lastManifest time.Time
-	s3svc, brr, err := getServiceForRepository(ctx, aa, br)
+	source, err := getSourceForRepository(ctx, aa, br)
 	if err != nil {
 		return lastManifest, err
 	}
-	jsonlog.LoggerFromContextOrDefault(ctx).Debug("Obtained S3 service to read bills.", map[string]interface{}{"account": aa, "billRepository": br})
-	mck := getKeys(ctx, s3svc, brr)
+	jsonlog.LoggerFromContextOrDefault(ctx).Debug("Obtained source to read bills.", map[string]interface{}{"account": aa, "billRepository": br})
+	mck := source.getKeys(ctx)
 	mck = getManifestKeys(ctx, mck)
-	mc := getManifests(ctx, s3svc, mck)
+	mc := getManifests(ctx, source, mck)
 	mc, lastManifestPromise := selectManifests(mp, mc)
 	es.CleanCurrentMonthBillByBillRepositoryId(ctx, aa.UserId, br.Id)
-	importBills(ctx, s3svc, mc, oli, mp)
+	importBills(ctx, source, mc, oli, mp)
 	return <-lastManifestPromise, nil
 }
 
@@ -173,36 +213,42 @@ func selectManifests(mp ManifestPredicate, mc <-chan manifest) (<-chan manifest,
 
 // importBills imports LineItems for bill files described in manifests sent to
 // the `manifests` channel.
-func importBills(ctx context.Context, s3svc *s3.S3, manifests <-chan manifest, oli OnLineItem, mp ManifestPredicate) {
+func importBills(ctx context.Context, source billSource, manifests <-chan manifest, oli OnLineItem, mp ManifestPredicate) {
 	l := jsonlog.LoggerFromContextOrDefault(ctx)
 	outs, out := mergecdLineItem()
 	for m := range manifests {
 		l.Debug("Will attempt ingesting bills.", m)
+		manifestsProcessedTotal.Inc()
 		for _, s := range m.ReportKeys {
 			l.Debug("Will attempt ingesting bill part.", map[string]interface{}{"key": s, "manifest": m})
-			outs <- importBill(ctx, s3svc, s, m, mp)
+			outs <- importBill(ctx, source, s, m, mp)
 		}
 	}
 	close(outs)
 	for lineItem := range out {
+		lineItemsReadTotal.Inc()
 		oli(lineItem, true)
 	}
 	oli(LineItem{}, false)
 }
 
 // importBill imports LineItems for a single bill file.
-func importBill(ctx context.Context, s3svc *s3.S3, s string, m manifest, mp ManifestPredicate) <-chan LineItem {
+func importBill(ctx context.Context, source billSource, s string, m manifest, mp ManifestPredicate) <-chan LineItem {
 	outs, out := mergecdLineItem()
 	go func() {
 		defer close(outs)
 		ctx, cancel := context.WithCancel(ctx)
 		l := jsonlog.LoggerFromContextOrDefault(ctx)
-		reader, err := getBillReader(ctx, s3svc, s, m)
+		reader, err := getBillReader(ctx, source, s, m)
 		if err != nil {
 			l.Error("Failed to read bill.", err.Error())
 		} else {
 			l.Debug("Reading bill.", map[string]interface{}{"key": s, "manifest": m})
-			outs <- readBill(ctx, cancel, reader, s, m, mp)
+			if isParquetManifest(m) {
+				outs <- readParquetBill(ctx, reader, s, m, mp)
+			} else {
+				outs <- readBill(ctx, cancel, reader, s, m, mp)
+			}
 		}
 	}()
 	return out
@@ -260,21 +306,25 @@ func decodeRecord(d *csv.Decoder) (LineItem, error) {
 }
 
 // getBillReader returns a ReadCloser for a const and usage report. It will use
-// the object described by the key s and the manifest m.
-func getBillReader(ctx context.Context, s3svc *s3.S3, s string, m manifest) (io.ReadCloser, error) {
+// the object described by the key s and the manifest m. Parquet reports are
+// compressed internally and thus read as is.
+func getBillReader(ctx context.Context, source billSource, s string, m manifest) (io.ReadCloser, error) {
+	if isParquetManifest(m) {
+		return getRawBillReader(ctx, source, s, m)
+	}
 	switch m.Compression {
 	case "GZIP":
-		return getGzipBillReader(ctx, s3svc, s, m)
+		return getGzipBillReader(ctx, source, s, m)
 	default:
 		jsonlog.LoggerFromContextOrDefault(ctx).Error("Unsupported  compression scheme.", map[string]interface{}{"key": s, "manifest": m})
 		return nil, ErrUnsupportedCompression
 	}
 }
 
-// getGzipBillReader returns a ReadCloser for a GZIP-compressed S3 object which
-// is downloaded on the fly.
-func getGzipBillReader(ctx context.Context, s3svc *s3.S3, s string, m manifest) (io.ReadCloser, error) {
-	if reader, err := getRawBillReader(ctx, s3svc, s, m); err == nil {
+// getGzipBillReader returns a ReadCloser for a GZIP-compressed object which
+// is read on the fly.
+func getGzipBillReader(ctx context.Context, source billSource, s string, m manifest) (io.ReadCloser, error) {
+	if reader, err := getRawBillReader(ctx, source, s, m); err == nil {
 		return gzip.NewReader(reader)
 	} else {
 		return nil, err
@@ -283,25 +333,18 @@ func getGzipBillReader(ctx context.Context, s3svc *s3.S3, s string, m manifest)
 
 // getRawBillReader gets an io.ReadCloser for the raw data from a billing
 // file.
-func getRawBillReader(ctx context.Context, s3svc *s3.S3, s string, m manifest) (io.ReadCloser, error) {
-	var bucket = m.Bucket
-	var key = s
-	var region = *s3svc.Client.Config.Region
-	var dumbS3mgr dumbS3Manager
-	dumbS3mgr.init(s3svc.Client.Config.Credentials)
-	return dumbS3mgr.rawS3GetObjectToReader(ctx, &httpClient, region, bucket, key)
+func getRawBillReader(ctx context.Context, source billSource, s string, m manifest) (io.ReadCloser, error) {
+	return source.getObject(ctx, m.Bucket, s)
 }
 
 // getManifests downloads the manifest whose keys are sent to the in channel.
 // It immediately returns with a channel where manifest objects will be sent.
-func getManifests(ctx context.Context, s3svc *s3.S3, in <-chan BillKey) <-chan manifest {
+func getManifests(ctx context.Context, source billSource, in <-chan BillKey) <-chan manifest {
 	outs, out := mergecdManifest()
 	go func() {
 		defer close(outs)
-		var s3mgr dumbS3Manager
-		s3mgr.init(s3svc.Client.Config.Credentials)
 		for bk := range in {
-			outs <- readManifest(ctx, &s3mgr, bk)
+			outs <- readManifest(ctx, source, bk)
 		}
 	}()
 	return out
@@ -310,12 +353,12 @@ func getManifests(ctx context.Context, s3svc *s3.S3, in <-chan BillKey) <-chan m
 // readManifest downloads and parses a manifest file asynchronously. Returns a
 // channel where at most one manifest object will be sent, then the channel
 // will be closed.
-func readManifest(ctx context.Context, s3mgr *dumbS3Manager, bk BillKey) <-chan manifest {
+func readManifest(ctx context.Context, source billSource, bk BillKey) <-chan manifest {
 	out := make(chan manifest)
 	go func() {
 		defer close(out)
 		logger := jsonlog.LoggerFromContextOrDefault(ctx)
-		buf, err := s3mgr.rawS3GetObjectToBuffer(ctx, &httpClient, bk.Region, bk.Bucket, bk.Key)
+		buf, err := readObject(ctx, source, bk.Bucket, bk.Key)
 		if err != nil {
 			logger.Error("Failed to download usage and cost manifest.", map[string]interface{}{"billKey": bk, "error": err.Error()})
 			return
@@ -336,6 +379,16 @@ func readManifest(ctx context.Context, s3mgr *dumbS3Manager, bk BillKey) <-chan
 	return out
 }
 
+// readObject reads a whole object from a billSource.
+func readObject(ctx context.Context, source billSource, bucket, key string) ([]byte, error) {
+	reader, err := source.getObject(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
 // getServiceForRepository instantiates an *s3.S3 service from an AwsAccount
 // and a billRepositoryWithRegion. It returns a nil error if the operation was
 // successful.
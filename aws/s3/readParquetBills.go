@@ -0,0 +1,350 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackit/jsonlog"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/types"
+
+	"github.com/trackit/trackit-server/config"
+)
+
+const (
+	// formatParquet is the compression and content type of manifests
+	// describing Parquet reports.
+	formatParquet = "Parquet"
+	// parquetBatchSize is the number of rows of a Parquet report decoded at
+	// once, column by column.
+	parquetBatchSize = 10000
+	// parquetTagPrefix is the prefix of the names of the Parquet columns
+	// holding user tags.
+	parquetTagPrefix = "resource_tags_user_"
+)
+
+var (
+	ErrParquetExternalFile = errors.New("parquet reports with external column chunks are not supported")
+	ErrParquetReadOnly     = errors.New("parquet reports are read only")
+	ErrParquetTooLarge     = errors.New("parquet report is larger than the maximum size")
+)
+
+// lineItemParquetFields maps the Parquet column names of the usage and cost
+// reports to the index of the LineItem field they are stored in.
+var lineItemParquetFields = getLineItemParquetFields()
+
+// parquetFile is a local Parquet file implementing source.ParquetFile. The
+// Parquet reader opens it once per column.
+type parquetFile struct {
+	*os.File
+	path string
+}
+
+// parquetColumn is a column of a Parquet report which is stored in LineItems.
+type parquetColumn struct {
+	path    string
+	element *parquet.SchemaElement
+	field   int
+	anyKey  string
+}
+
+// isParquetManifest tells whether the reports described by a manifest are
+// Parquet files rather than gzipped CSV files.
+func isParquetManifest(m manifest) bool {
+	return strings.EqualFold(m.Compression, formatParquet) || strings.EqualFold(m.ContentType, formatParquet)
+}
+
+// parquetColumnName converts the name of a CSV report column to the name of
+// the same column in Parquet reports, e.g. "lineItem/UnblendedCost" to
+// "line_item_unblended_cost".
+func parquetColumnName(name string) string {
+	var converted []rune
+	var previous rune
+	for _, r := range name {
+		switch {
+		case r == '/' || r == ':':
+			converted = append(converted, '_')
+		case r >= 'A' && r <= 'Z':
+			if previous != 0 && previous != '/' && previous != ':' && previous != '_' {
+				converted = append(converted, '_')
+			}
+			converted = append(converted, r-'A'+'a')
+		default:
+			converted = append(converted, r)
+		}
+		previous = r
+	}
+	return string(converted)
+}
+
+// getLineItemParquetFields builds lineItemParquetFields from the csv tags of
+// LineItem.
+func getLineItemParquetFields() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(LineItem{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("csv")
+		if tag != "" && tag != "-" && !strings.HasPrefix(tag, ",") && t.Field(i).Type.Kind() == reflect.String {
+			fields[parquetColumnName(tag)] = i
+		}
+	}
+	return fields
+}
+
+// getParquetColumns lists the columns of a Parquet report which are stored
+// in LineItems. User tags are stored in LineItem.Any under the name of their
+// CSV column, which is recovered from the manifest since Parquet column
+// names are lowercase. Other columns are not decoded at all.
+func getParquetColumns(pr *reader.ParquetReader, m manifest) []parquetColumn {
+	csvNames := make(map[string]string, len(m.Columns))
+	for _, c := range m.Columns {
+		name := c.Category + "/" + c.Name
+		csvNames[parquetColumnName(name)] = name
+	}
+	var columns []parquetColumn
+	for _, path := range pr.SchemaHandler.ValueColumns {
+		exPath := common.StrToPath(pr.SchemaHandler.InPathToExPath[path])
+		name := exPath[len(exPath)-1]
+		column := parquetColumn{
+			path:    path,
+			element: pr.SchemaHandler.SchemaElements[pr.SchemaHandler.MapIndex[path]],
+			field:   -1,
+		}
+		if field, ok := lineItemParquetFields[name]; ok {
+			column.field = field
+		} else if csvName, ok := csvNames[name]; ok && strings.HasPrefix(csvName, tagPrefix) {
+			column.anyKey = csvName
+		} else if strings.HasPrefix(name, parquetTagPrefix) {
+			column.anyKey = tagPrefix + strings.TrimPrefix(name, parquetTagPrefix)
+		} else {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// readParquetBill returns a channel of all LineItems in a single Parquet bill
+// file. Rows are decoded by batches of parquetBatchSize, one column at a
+// time.
+func readParquetBill(ctx context.Context, reader io.ReadCloser, s string, m manifest, mp ManifestPredicate) <-chan LineItem {
+	out := make(chan LineItem)
+	go func() {
+		defer reader.Close()
+		defer close(out)
+		l := jsonlog.LoggerFromContextOrDefault(ctx)
+		file, err := getParquetFile(reader)
+		if err != nil {
+			l.Error("Failed to store Parquet bill.", map[string]interface{}{"key": s, "error": err.Error()})
+			return
+		}
+		defer file.remove(reader)
+		if err := decodeParquetBill(file, m, func(li LineItem) bool {
+			if mp(m, false) || li.InvoiceId == "" {
+				select {
+				case out <- li:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}); err != nil {
+			l.Error("Failed to read Parquet bill.", map[string]interface{}{"key": s, "error": err.Error()})
+		}
+	}()
+	return out
+}
+
+// decodeParquetBill decodes the LineItems of a Parquet bill file and runs
+// `onLineItem` for each of them, until it returns false.
+func decodeParquetBill(file parquetFile, m manifest, onLineItem func(LineItem) bool) error {
+	pr, err := reader.NewParquetColumnReader(file, int64(runtime.NumCPU()))
+	if err != nil {
+		return err
+	}
+	defer pr.ReadStop()
+	for _, rowGroup := range pr.Footer.RowGroups {
+		for _, chunk := range rowGroup.Columns {
+			if chunk.FilePath != nil && *chunk.FilePath != "" {
+				return ErrParquetExternalFile
+			}
+		}
+	}
+	columns := getParquetColumns(pr, m)
+	rows := pr.GetNumRows()
+	for read := int64(0); read < rows; read += parquetBatchSize {
+		batch := rows - read
+		if batch > parquetBatchSize {
+			batch = parquetBatchSize
+		}
+		lineItems := make([]LineItem, batch)
+		for _, column := range columns {
+			values, _, _, err := pr.ReadColumnByPath(column.path, batch)
+			if err != nil {
+				return err
+			}
+			column.store(lineItems, values)
+		}
+		for _, li := range lineItems {
+			if !onLineItem(li) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// store stores the values of a column in the LineItems of the same rows.
+func (c parquetColumn) store(lineItems []LineItem, values []interface{}) {
+	for i, value := range values {
+		if i >= len(lineItems) {
+			return
+		} else if value == nil {
+			continue
+		}
+		formatted := formatParquetValue(value, c.element)
+		if c.field >= 0 {
+			reflect.ValueOf(&lineItems[i]).Elem().Field(c.field).SetString(formatted)
+		} else {
+			if lineItems[i].Any == nil {
+				lineItems[i].Any = make(map[string]string)
+			}
+			lineItems[i].Any[c.anyKey] = formatted
+		}
+	}
+}
+
+// formatParquetValue formats a Parquet value the way it is written in CSV
+// reports. Timestamps are formatted as RFC 3339 UTC dates.
+func formatParquetValue(value interface{}, element *parquet.SchemaElement) string {
+	switch v := value.(type) {
+	case string:
+		if element.GetType() == parquet.Type_INT96 {
+			return types.INT96ToTime(v).UTC().Format(time.RFC3339)
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case int64:
+		if unit, ok := getParquetTimeUnit(element); ok {
+			return time.Unix(0, v*int64(unit)).UTC().Format(time.RFC3339)
+		}
+		return strconv.FormatInt(v, 10)
+	case int32:
+		if element.ConvertedType != nil && *element.ConvertedType == parquet.ConvertedType_DATE {
+			return time.Unix(int64(v)*24*60*60, 0).UTC().Format(time.RFC3339)
+		}
+		return strconv.FormatInt(int64(v), 10)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// getParquetTimeUnit returns the unit of an INT64 timestamp column, from
+// either its logical type or its converted type.
+func getParquetTimeUnit(element *parquet.SchemaElement) (time.Duration, bool) {
+	if element.LogicalType != nil && element.LogicalType.IsSetTIMESTAMP() {
+		unit := element.LogicalType.TIMESTAMP.Unit
+		switch {
+		case unit.IsSetMILLIS():
+			return time.Millisecond, true
+		case unit.IsSetMICROS():
+			return time.Microsecond, true
+		case unit.IsSetNANOS():
+			return time.Nanosecond, true
+		}
+	} else if element.ConvertedType != nil {
+		switch *element.ConvertedType {
+		case parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return time.Millisecond, true
+		case parquet.ConvertedType_TIMESTAMP_MICROS:
+			return time.Microsecond, true
+		}
+	}
+	return 0, false
+}
+
+// getParquetFile returns a seekable local file with the content of reader,
+// as needed by the Parquet reader. Bills read from a local directory are used
+// in place, others are first copied to a temporary file in
+// config.ParquetBillDirectory. The copy is aborted once it exceeds
+// config.ParquetBillMaxSize, so that a bill cannot fill the disk up.
+func getParquetFile(reader io.Reader) (parquetFile, error) {
+	if file, ok := reader.(*os.File); ok {
+		return parquetFile{file, file.Name()}, nil
+	}
+	file, err := ioutil.TempFile(config.ParquetBillDirectory, "bill")
+	if err != nil {
+		return parquetFile{}, err
+	}
+	if written, err := io.Copy(file, io.LimitReader(reader, config.ParquetBillMaxSize+1)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return parquetFile{}, err
+	} else if written > config.ParquetBillMaxSize {
+		file.Close()
+		os.Remove(file.Name())
+		return parquetFile{}, ErrParquetTooLarge
+	} else if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return parquetFile{}, err
+	}
+	return parquetFile{file, file.Name()}, nil
+}
+
+// remove closes and deletes a temporary file made by getParquetFile from
+// reader. Files used in place are left to their reader.
+func (f parquetFile) remove(reader io.Reader) {
+	if f.File != reader {
+		f.Close()
+		os.Remove(f.path)
+	}
+}
+
+// Open opens the file again, so that columns can be read concurrently.
+func (f parquetFile) Open(name string) (source.ParquetFile, error) {
+	if name != "" && name != f.path {
+		return nil, ErrParquetExternalFile
+	}
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, err
+	}
+	return parquetFile{file, f.path}, nil
+}
+
+// Create is required by source.ParquetFile but bills are never written.
+func (f parquetFile) Create(string) (source.ParquetFile, error) {
+	return nil, ErrParquetReadOnly
+}
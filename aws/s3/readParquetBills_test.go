@@ -0,0 +1,148 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package s3
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/trackit/trackit-server/config"
+)
+
+// testParquetLineItem is a row of a Parquet usage and cost report.
+type testParquetLineItem struct {
+	LineItemId     string  `parquet:"name=identity_line_item_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TimeInterval   string  `parquet:"name=identity_time_interval, type=BYTE_ARRAY, convertedtype=UTF8"`
+	UsageStartDate int64   `parquet:"name=line_item_usage_start_date, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	UnblendedCost  float64 `parquet:"name=line_item_unblended_cost, type=DOUBLE"`
+	TaxType        *string `parquet:"name=line_item_tax_type, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	ProductName    string  `parquet:"name=product_product_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Environment    string  `parquet:"name=resource_tags_user_environment, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Team           string  `parquet:"name=resource_tags_user_team, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// writeTestParquetBill writes rows to a temporary Parquet file.
+func writeTestParquetBill(t *testing.T, rows []testParquetLineItem) parquetFile {
+	file, err := ioutil.TempFile("", "bill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf := parquetFile{file, file.Name()}
+	pw, err := writer.NewParquetWriter(pf, new(testParquetLineItem), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range rows {
+		if err := pw.Write(rows[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		t.Fatal(err)
+	} else if _, err := file.Seek(0, os.SEEK_SET); err != nil {
+		t.Fatal(err)
+	}
+	return pf
+}
+
+func TestParquetColumnName(t *testing.T) {
+	cases := map[string]string{
+		"identity/LineItemId":        "identity_line_item_id",
+		"lineItem/UnblendedCost":     "line_item_unblended_cost",
+		"product/servicecode":        "product_servicecode",
+		"reservation/ReservationARN": "reservation_reservation_a_r_n",
+		"resourceTags/user:Name":     "resource_tags_user_name",
+	}
+	for name, expected := range cases {
+		if converted := parquetColumnName(name); converted != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, name, converted)
+		}
+	}
+}
+
+func TestIsParquetManifest(t *testing.T) {
+	if !isParquetManifest(manifest{Compression: "Parquet"}) {
+		t.Errorf("Expected a Parquet compression to be detected")
+	} else if !isParquetManifest(manifest{ContentType: "Parquet"}) {
+		t.Errorf("Expected a Parquet content type to be detected")
+	} else if isParquetManifest(manifest{Compression: "GZIP", ContentType: "text/csv"}) {
+		t.Errorf("Expected gzipped CSV not to be detected as Parquet")
+	}
+}
+
+func TestDecodeParquetBill(t *testing.T) {
+	tax := "VAT"
+	start := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	pf := writeTestParquetBill(t, []testParquetLineItem{
+		{"a", "2018-01-01T00:00:00Z/2018-01-01T01:00:00Z", start.UnixNano() / int64(time.Millisecond), 1.5, nil, "Amazon EC2", "production", "ops"},
+		{"b", "2018-01-01T00:00:00Z/2018-01-01T01:00:00Z", start.UnixNano() / int64(time.Millisecond), 0.25, &tax, "Amazon EC2", "", ""},
+	})
+	defer pf.remove(nil)
+	m := manifest{
+		Compression: "Parquet",
+		Columns:     []manifestColumn{{"resourceTags", "user:Environment"}},
+	}
+	var lineItems []LineItem
+	if err := decodeParquetBill(pf, m, func(li LineItem) bool {
+		lineItems = append(lineItems, li)
+		return true
+	}); err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if len(lineItems) != 2 {
+		t.Fatalf("Expected 2 line items, got %d", len(lineItems))
+	}
+	li := lineItems[0]
+	if li.LineItemId != "a" || li.UnblendedCost != "1.5" || li.UsageStartDate != "2018-01-01T00:00:00Z" || li.TaxType != "" {
+		t.Errorf("Unexpected line item %v", li)
+	}
+	if li.Any["resourceTags/user:Environment"] != "production" || li.Any["resourceTags/user:team"] != "ops" || len(li.Any) != 2 {
+		t.Errorf("Unexpected tags %v", li.Any)
+	}
+	if li := lineItems[1]; li.LineItemId != "b" || li.UnblendedCost != "0.25" || li.TaxType != "VAT" {
+		t.Errorf("Unexpected line item %v", li)
+	}
+}
+
+func TestGetParquetFileMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(directory string, maxSize int64) {
+		config.ParquetBillDirectory, config.ParquetBillMaxSize = directory, maxSize
+	}(config.ParquetBillDirectory, config.ParquetBillMaxSize)
+	config.ParquetBillDirectory, config.ParquetBillMaxSize = dir, 4
+	reader := strings.NewReader("1234")
+	pf, err := getParquetFile(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	} else if !strings.HasPrefix(pf.path, dir) {
+		t.Errorf("Expected the bill to be stored in %s, got %s", dir, pf.path)
+	}
+	pf.remove(reader)
+	if _, err := getParquetFile(strings.NewReader("12345")); err != ErrParquetTooLarge {
+		t.Errorf("Expected ErrParquetTooLarge, got %v", err)
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("Expected the bills to be removed, %d remain", len(files))
+	}
+}
@@ -67,6 +67,8 @@ func GetBillRepositoryWithPendingForAwsAccount(tx *sql.Tx, awsAccountId int) ([]
 		  aws_bill_repository.aws_account_id         AS aws_account_id,
 		  aws_bill_repository.bucket                 AS bucket,
 		  aws_bill_repository.prefix                 AS prefix,
+		  aws_bill_repository.endpoint               AS endpoint,
+		  aws_bill_repository.access_key_id          AS access_key_id,
 		  aws_bill_repository.error                  AS error,
 		  aws_bill_repository.last_imported_manifest AS last_imported_manifest,
 		  aws_bill_repository.next_update            AS next_update,
@@ -95,6 +97,8 @@ func GetBillRepositoryWithPendingForAwsAccount(tx *sql.Tx, awsAccountId int) ([]
 			&res[i].AwsAccountId,
 			&res[i].Bucket,
 			&res[i].Prefix,
+			&res[i].Endpoint,
+			&res[i].AccessKeyId,
 			&res[i].Error,
 			&res[i].LastImportedManifest,
 			&res[i].NextUpdate,
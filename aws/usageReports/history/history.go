@@ -32,6 +32,8 @@ import (
 	"github.com/trackit/trackit-server/aws/usageReports/elasticache"
 	tes "github.com/trackit/trackit-server/aws/usageReports/es"
 	"github.com/trackit/trackit-server/aws/usageReports/rds"
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/es"
 )
 
@@ -69,13 +71,14 @@ func GetHistoryDate() (time.Time, time.Time) {
 }
 
 // makeElasticSearchRequestForCost will make the actual request to the ElasticSearch
+// The costs are summed once converted by conversion.
 // It will return the data, an http status code (as int) and an error.
 // Because an error can be generated, but is not critical and is not needed to be known by
 // the user (e.g if the index does not exists because it was not yet indexed ) the error will
 // be returned, but instead of having a 500 status code, it will return the provided status code
 // with empty data
 func makeElasticSearchRequestForCost(ctx context.Context, client *elastic.Client, aa aws.AwsAccount,
-	startDate, endDate time.Time, product string, partition int) (*elastic.SearchResult, int, error) {
+	startDate, endDate time.Time, product string, partition int, conversion currency.Conversion) (*elastic.SearchResult, int, error) {
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
 	index := es.IndexNameForUserId(aa.UserId, es.IndexPrefixLineItems)
 	query := elastic.NewBoolQuery()
@@ -86,7 +89,7 @@ func makeElasticSearchRequestForCost(ctx context.Context, client *elastic.Client
 	search := client.Search().Index(index).Size(0).Query(query)
 	search.Aggregation("resources", elastic.NewTermsAggregation().Field("resourceId").Size(utils.MaxAggregationSize).Partition(partition).NumPartitions(numPartition).
 		SubAggregation("regions", elastic.NewTermsAggregation().Field("availabilityZone").Size(utils.MaxAggregationSize).
-			SubAggregation("cost", elastic.NewSumAggregation().Field("unblendedCost"))))
+			SubAggregation("cost", conversion.SumAggregation("unblendedCost"))))
 	result, err := search.Do(ctx)
 	if err != nil {
 		if elastic.IsNotFound(err) {
@@ -108,12 +111,12 @@ func makeElasticSearchRequestForCost(ctx context.Context, client *elastic.Client
 // getCostPerResource returns the parsed result of ES
 // This response contains the list of the resources of the specified product with the cost and region associated
 func getCostPerResource(ctx context.Context, aa aws.AwsAccount, startDate time.Time, endDate time.Time,
-	product string) ([]utils.CostPerResource, error) {
+	product string, conversion currency.Conversion) ([]utils.CostPerResource, error) {
 	var parsedResult EsRegionPerResourceResult
 	response := make([]utils.CostPerResource, 0)
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
 	for i := 0; i < numPartition; i++ {
-		result, returnCode, err := makeElasticSearchRequestForCost(ctx, es.Client, aa, startDate, endDate, product, i)
+		result, returnCode, err := makeElasticSearchRequestForCost(ctx, es.Client, aa, startDate, endDate, product, i, conversion)
 		if err != nil {
 			if returnCode != http.StatusOK {
 				return response, err
@@ -160,22 +163,22 @@ func concatErrors(tabError []error) error {
 }
 
 // getInstanceInfo sort products and call history reports
-func getInstancesInfo(ctx context.Context, aa aws.AwsAccount, startDate time.Time, endDate time.Time) (bool, error) {
+func getInstancesInfo(ctx context.Context, aa aws.AwsAccount, startDate time.Time, endDate time.Time, conversion currency.Conversion) (bool, error) {
 	var ec2Created, rdsCreated, esCreated, elastiCacheCreated bool
-	ec2Cost, ec2Err := getCostPerResource(ctx, aa, startDate, endDate, "AmazonEC2")
-	cloudWatchCost, cloudWatchErr := getCostPerResource(ctx, aa, startDate, endDate, "AmazonCloudWatch")
+	ec2Cost, ec2Err := getCostPerResource(ctx, aa, startDate, endDate, "AmazonEC2", conversion)
+	cloudWatchCost, cloudWatchErr := getCostPerResource(ctx, aa, startDate, endDate, "AmazonCloudWatch", conversion)
 	if ec2Err == nil && cloudWatchErr == nil {
 		ec2Created, ec2Err = ec2.PutEc2MonthlyReport(ctx, ec2Cost, cloudWatchCost, aa, startDate, endDate)
 	}
-	rdsCost, rdsErr := getCostPerResource(ctx, aa, startDate, endDate, "AmazonRDS")
+	rdsCost, rdsErr := getCostPerResource(ctx, aa, startDate, endDate, "AmazonRDS", conversion)
 	if rdsErr == nil {
 		rdsCreated, rdsErr = rds.PutRdsMonthlyReport(ctx, rdsCost, aa, startDate, endDate)
 	}
-	esCost, esErr := getCostPerResource(ctx, aa, startDate, endDate, "AmazonES")
+	esCost, esErr := getCostPerResource(ctx, aa, startDate, endDate, "AmazonES", conversion)
 	if esErr == nil {
 		esCreated, esErr = tes.PutEsMonthlyReport(ctx, esCost, aa, startDate, endDate)
 	}
-	elastiCacheCost, elastiCacheErr := getCostPerResource(ctx, aa, startDate, endDate, "AmazonElastiCache")
+	elastiCacheCost, elastiCacheErr := getCostPerResource(ctx, aa, startDate, endDate, "AmazonElastiCache", conversion)
 	if elastiCacheErr == nil {
 		elastiCacheCreated, elastiCacheErr = elasticache.PutElastiCacheMonthlyReport(ctx, elastiCacheCost, aa, startDate, endDate)
 	}
@@ -232,5 +235,11 @@ func FetchHistoryInfos(ctx context.Context, aa aws.AwsAccount) (bool, error) {
 		logger.Info("Billing data are not completed", nil)
 		return false, ErrBillingDataIncomplete
 	}
-	return getInstancesInfo(ctx, aa, startDate, endDate)
+	// The reports are stored with their costs in currency.Base, and converted
+	// to the display currency of their reader.
+	conversion, err := currency.NewConversion(db.Db, currency.Base)
+	if err != nil {
+		return false, err
+	}
+	return getInstancesInfo(ctx, aa, startDate, endDate, conversion)
 }
@@ -112,7 +112,7 @@ func afterBulk(ctx context.Context) func(int64, []elastic.BulkableRequest, *elas
 				"took":        resp.Took,
 			})
 		}
-
+		es.RecordBulk("usageReports", reqs, resp, err)
 	}
 }
 
@@ -16,6 +16,7 @@ package config
 
 import (
 	"flag"
+	"time"
 )
 
 const (
@@ -25,6 +26,8 @@ const (
 var (
 	// HttpAddress is the address and port the server shall bind to.
 	HttpAddress string
+	// MetricsAddress is the address and port the metrics are served on, apart from the API.
+	MetricsAddress string
 	// SqlProtocol is the name of the Sql database, as used in the protocol in the URL.
 	SqlProtocol string
 	// SqlAddress is the string passed to the Sql driver to connect to the database.
@@ -33,6 +36,20 @@ var (
 	AuthIssuer string
 	// AuthSecret is the secret used to sign and verify JWT tokens.
 	AuthSecret string
+	// AccessTokenLifetime is the time during which a JWT token returned by the login is valid.
+	AccessTokenLifetime time.Duration
+	// RefreshTokenLifetime is the time during which a refresh token can be exchanged for a new JWT token.
+	RefreshTokenLifetime time.Duration
+	// TrustForwardedFor, if set, indicates the server is behind a load balancer whose X-Forwarded-For header gives the client address.
+	TrustForwardedFor bool
+	// LoginMaxFailuresPerEmail is the number of failed login, password reset or two-factor attempts on an account after which it is locked out.
+	LoginMaxFailuresPerEmail int
+	// LoginMaxFailuresPerAddress is the number of failed login attempts from a client address after which it is locked out.
+	LoginMaxFailuresPerAddress int
+	// LoginFailureWindow is the time after which a failed login attempt is forgotten.
+	LoginFailureWindow time.Duration
+	// LoginLockout is the time during which an email or a client address is locked out after too many failed login attempts.
+	LoginLockout time.Duration
 	// AwsRegion is the AWS region the product operates in.
 	AwsRegion string
 	// BackendId is an identifier for the current instance of the server.
@@ -72,14 +89,38 @@ var (
 	Task string
 	// Periodics, if true, indicates periodic tasks should be run in goroutines within the process.
 	Periodics bool
+	// ShutdownTimeout is the time the server waits for in-flight requests and running periodic tasks to end when asked to stop.
+	ShutdownTimeout time.Duration
+	// JobsWorkers is the maximum number of per-account jobs run at once by the periodic tasks.
+	JobsWorkers int
+	// JobsPollPeriod is the period at which the periodic tasks look for accounts due for an update.
+	JobsPollPeriod time.Duration
+	// JobsClaimLease is the time during which an account claimed by a backend can not be claimed by another one. It must be longer than the longest job.
+	JobsClaimLease time.Duration
 	// Aws Market place product code
 	MarketPlaceProductCode string
+	// AnomalyDetectionAlgorithm is the name of the default algorithm used to detect anomalies. It can be overridden by users and AWS accounts.
+	AnomalyDetectionAlgorithm string
 	// AnomalyDetectionBollingerBandPeriod is the period in day used to generate the upper band.
 	AnomalyDetectionBollingerBandPeriod int
 	// AnomalyDetectionBollingerBandStandardDeviationCoefficient is the coefficient applied to the standard deviation used to generate the upper band.
 	AnomalyDetectionBollingerBandStandardDeviationCoefficient float64
 	// AnomalyDetectionBollingerBandUpperBandCoefficient is the coefficient applied to the upper band.
 	AnomalyDetectionBollingerBandUpperBandCoefficient float64
+	// AnomalyDetectionWeeklySeasonalityWeeks is the number of weeks in which the same weekday is compared to generate the upper band.
+	AnomalyDetectionWeeklySeasonalityWeeks int
+	// AnomalyDetectionWeeklySeasonalityStandardDeviationCoefficient is the coefficient applied to the standard deviation of the same weekday costs.
+	AnomalyDetectionWeeklySeasonalityStandardDeviationCoefficient float64
+	// AnomalyDetectionEwmaAlpha is the smoothing factor of the exponentially weighted moving average, between 0 and 1.
+	AnomalyDetectionEwmaAlpha float64
+	// AnomalyDetectionEwmaStandardDeviationCoefficient is the coefficient applied to the exponentially weighted moving standard deviation.
+	AnomalyDetectionEwmaStandardDeviationCoefficient float64
+	// AnomalyDetectionMadPeriod is the period in day used to compute the median and the median absolute deviation.
+	AnomalyDetectionMadPeriod int
+	// AnomalyDetectionMadCoefficient is the coefficient applied to the scaled median absolute deviation to generate the upper band.
+	AnomalyDetectionMadCoefficient float64
+	// AnomalyDetectionMinDeviationPercent is the minimum deviation, in percent of the expected cost, used by the weekly seasonality, EWMA and median absolute deviation algorithms. Without it, any change of a flat cost would be an anomaly.
+	AnomalyDetectionMinDeviationPercent float64
 	// AnomalyDetectionDisturbanceCleaningMinPercentOfDailyBill is the percentage of the daily bill an anomaly has to exceed. Otherwise, it's considered as a disturbance.
 	AnomalyDetectionDisturbanceCleaningMinPercentOfDailyBill float64
 	// AnomalyDetectionDisturbanceCleaningMinAbsoluteCost is the cost an anomaly has to exceed. Otherwise, it's considered as a disturbance.
@@ -96,14 +137,48 @@ var (
 	AnomalyDetectionPrettyLevels string
 	// AnomalyEmailingMinLevel is the minimum level required for the mail to be sent.
 	AnomalyEmailingMinLevel int
+	// AnomalyEmailingPeriod is the period in day before the last anomalies detection in which new anomalies are emailed.
+	AnomalyEmailingPeriod int
+	// AdminEmails are the emails of the users allowed to use the administration routes.
+	AdminEmails stringArray
+	// BudgetAlertThresholds are the default percentages of a budget at which an alert is emailed. Example: "50,80,100".
+	BudgetAlertThresholds string
+	// OidcIssuer is the URL of the OpenID Connect provider used for single sign-on. Single sign-on is disabled if empty.
+	OidcIssuer string
+	// OidcClientId is the client ID of the server at the OpenID Connect provider.
+	OidcClientId string
+	// OidcClientSecret is the client secret of the server at the OpenID Connect provider.
+	OidcClientSecret string
+	// OidcRedirectUrl is the URL the OpenID Connect provider redirects users to after they authenticated.
+	OidcRedirectUrl string
+	// OidcAllowedDomains are the email domains allowed to log in with single sign-on. At least one must be set for anyone to log in with single sign-on.
+	OidcAllowedDomains stringArray
+	// OidcViewerParent is the email of the user new single sign-on users are created as viewers of. They are created as regular users if empty.
+	OidcViewerParent string
+	// BillRepositoryDirectory is the directory file:// bill repositories must reside in. They are disabled if empty.
+	BillRepositoryDirectory string
+	// BillRepositoryCustomEndpoints allows bill repositories to be read from custom S3-compatible endpoints with static credentials.
+	BillRepositoryCustomEndpoints bool
+	// ParquetBillDirectory is the directory Parquet bills read from S3 are stored in while they are imported. The system's temporary directory is used if empty.
+	ParquetBillDirectory string
+	// ParquetBillMaxSize is the size in bytes above which Parquet bills read from S3 are not imported.
+	ParquetBillMaxSize int64
 )
 
 func init() {
 	flag.StringVar(&HttpAddress, "http-address", "[::1]:8080", "The port and address the HTTP server listens to.")
+	flag.StringVar(&MetricsAddress, "metrics-address", "[::1]:8081", "The port and address the metrics are served on. Metrics are not served if left empty.")
 	flag.StringVar(&SqlProtocol, "sql-protocol", "mysql", "The protocol used to communicate with the SQL database.")
 	flag.StringVar(&SqlAddress, "sql-address", "trackit:trackitpassword@tcp(127.0.0.1)/trackit?parseTime=true", "The address (username, password, transport, address and database) for the SQL database.")
 	flag.StringVar(&AuthIssuer, "auth-issuer", "trackit", "The 'iss' field for the JWT tokens.")
 	flag.StringVar(&AuthSecret, "auth-secret", "trackitdefaultsecret", "The secret used to sign and verify JWT tokens.")
+	flag.DurationVar(&AccessTokenLifetime, "access-token-lifetime", 15*time.Minute, "Time during which a JWT token is valid.")
+	flag.DurationVar(&RefreshTokenLifetime, "refresh-token-lifetime", 60*24*time.Hour, "Time during which a refresh token can be used.")
+	flag.BoolVar(&TrustForwardedFor, "trust-forwarded-for", false, "The client address should be read from the X-Forwarded-For header set by a load balancer.")
+	flag.IntVar(&LoginMaxFailuresPerEmail, "login-max-failures-per-email", 5, "Number of failed login, password reset or two-factor attempts on an account after which it is locked out.")
+	flag.IntVar(&LoginMaxFailuresPerAddress, "login-max-failures-per-address", 50, "Number of failed login attempts from a client address after which it is locked out.")
+	flag.DurationVar(&LoginFailureWindow, "login-failure-window", 15*time.Minute, "Time after which a failed login attempt is forgotten.")
+	flag.DurationVar(&LoginLockout, "login-lockout", 15*time.Minute, "Time during which an email or a client address is locked out after too many failed login attempts.")
 	flag.StringVar(&AwsRegion, "aws-region", "us-east-1", "The AWS region the server operates in.")
 	flag.StringVar(&BackendId, "backend-id", "", "The ID to be sent to clients through the 'X-Backend-ID' field. Generated if left empty.")
 	flag.StringVar(&ReportsBucket, "reports-bucket", "", "The bucket name where the reports are stored. The feature is disabled if left empty.")
@@ -123,10 +198,22 @@ func init() {
 	flag.StringVar(&SmtpSender, "smtp-sender", "", "The mail address used to send mails.")
 	flag.StringVar(&Task, "task", "server", "The task to be run.")
 	flag.BoolVar(&Periodics, "periodics", true, "Periodic jobs should be run by the process.")
+	flag.DurationVar(&ShutdownTimeout, "shutdown-timeout", 30*time.Second, "Time to wait for in-flight requests and running periodic tasks when stopping.")
+	flag.IntVar(&JobsWorkers, "jobs-workers", 4, "Maximum number of per-account jobs run at once by the process.")
+	flag.DurationVar(&JobsPollPeriod, "jobs-poll-period", 10*time.Minute, "Period at which accounts due for an update are looked for.")
+	flag.DurationVar(&JobsClaimLease, "jobs-claim-lease", 6*time.Hour, "Time during which an account claimed by a backend can not be claimed by another one.")
 	flag.StringVar(&MarketPlaceProductCode, "market-place-product-code", "productcode", "Aws market place product code.")
+	flag.StringVar(&AnomalyDetectionAlgorithm, "anomaly-detection-algorithm", "bollinger", "Default algorithm used to detect anomalies: bollinger, weekly-seasonality, ewma or mad.")
 	flag.IntVar(&AnomalyDetectionBollingerBandPeriod, "anomaly-detection-bollinger-band-period", 3, "Period used by the Bollinger Band algorithm.")
 	flag.Float64Var(&AnomalyDetectionBollingerBandStandardDeviationCoefficient, "anomaly-detection-bollinger-band-standard-deviation-coefficient", 3.0, "Coefficient used by the Bollinger Band algorithm to generate the standard deviation.")
 	flag.Float64Var(&AnomalyDetectionBollingerBandUpperBandCoefficient, "anomaly-detection-bollinger-band-upper-band-coefficient", 1.05, "Coefficient used by the Bollinger Band algorithm to generate the upper band.")
+	flag.IntVar(&AnomalyDetectionWeeklySeasonalityWeeks, "anomaly-detection-weekly-seasonality-weeks", 4, "Number of weeks used by the weekly seasonality algorithm.")
+	flag.Float64Var(&AnomalyDetectionWeeklySeasonalityStandardDeviationCoefficient, "anomaly-detection-weekly-seasonality-standard-deviation-coefficient", 3.0, "Coefficient used by the weekly seasonality algorithm to generate the upper band.")
+	flag.Float64Var(&AnomalyDetectionEwmaAlpha, "anomaly-detection-ewma-alpha", 0.3, "Smoothing factor used by the EWMA algorithm.")
+	flag.Float64Var(&AnomalyDetectionEwmaStandardDeviationCoefficient, "anomaly-detection-ewma-standard-deviation-coefficient", 3.0, "Coefficient used by the EWMA algorithm to generate the upper band.")
+	flag.IntVar(&AnomalyDetectionMadPeriod, "anomaly-detection-mad-period", 14, "Period used by the median absolute deviation algorithm.")
+	flag.Float64Var(&AnomalyDetectionMadCoefficient, "anomaly-detection-mad-coefficient", 3.5, "Coefficient used by the median absolute deviation algorithm to generate the upper band.")
+	flag.Float64Var(&AnomalyDetectionMinDeviationPercent, "anomaly-detection-min-deviation-percent", 5.0, "Minimum deviation, in percent of the expected cost, used by the weekly seasonality, EWMA and median absolute deviation algorithms.")
 	flag.Float64Var(&AnomalyDetectionDisturbanceCleaningMinPercentOfDailyBill, "anomaly-detection-disturbance-cleaning-min-percent-of-daily-bill", 5.0, "Percentage of the daily bill an anomaly has to exceed.")
 	flag.Float64Var(&AnomalyDetectionDisturbanceCleaningMinAbsoluteCost, "anomaly-detection-disturbance-cleaning-absolute-cost", 20.0, "Absolute cost an anomaly has to exceed.")
 	flag.IntVar(&AnomalyDetectionDisturbanceCleaningHighestSpendingMinRank, "anomaly-detection-disturbance-cleaning-highest-spending-min-rank", 5, "Minimum rank of the service where the anomaly has been detected.")
@@ -135,6 +222,19 @@ func init() {
 	flag.StringVar(&AnomalyDetectionLevels, "anomaly-detection-levels", "0,120,150,200", "Rules to generate the levels.")
 	flag.StringVar(&AnomalyDetectionPrettyLevels, "anomaly-detection-pretty-levels", "low,medium,high,critical", "Pretty names of the levels.")
 	flag.IntVar(&AnomalyEmailingMinLevel, "anomaly-emailing-min-level", 2, "Minimum level for the mail to be sent.")
+	flag.IntVar(&AnomalyEmailingPeriod, "anomaly-emailing-period", 7, "Period in day in which new anomalies are emailed.")
+	flag.Var(&AdminEmails, "admin-email", "The email of a user allowed to use the administration routes. Can be repeated.")
+	flag.StringVar(&BudgetAlertThresholds, "budget-alert-thresholds", "50,80,100", "Default percentages of a budget at which an alert is emailed.")
+	flag.StringVar(&OidcIssuer, "oidc-issuer", "", "The URL of the OpenID Connect provider used for single sign-on.")
+	flag.StringVar(&OidcClientId, "oidc-client-id", "", "The client ID at the OpenID Connect provider.")
+	flag.StringVar(&OidcClientSecret, "oidc-client-secret", "", "The client secret at the OpenID Connect provider.")
+	flag.StringVar(&OidcRedirectUrl, "oidc-redirect-url", "", "The URL users are redirected to after authenticating with the OpenID Connect provider.")
+	flag.Var(&OidcAllowedDomains, "oidc-allowed-domain", "An email domain allowed to log in with single sign-on. Can be repeated. No domain is allowed by default.")
+	flag.StringVar(&OidcViewerParent, "oidc-viewer-parent", "", "The email of the user new single sign-on users are created as viewers of.")
+	flag.StringVar(&BillRepositoryDirectory, "bill-repository-directory", "", "The directory file:// bill repositories must reside in. They are disabled if left empty.")
+	flag.BoolVar(&BillRepositoryCustomEndpoints, "bill-repository-custom-endpoints", false, "Bill repositories can be read from custom S3-compatible endpoints.")
+	flag.StringVar(&ParquetBillDirectory, "parquet-bill-directory", "", "The directory Parquet bills are stored in while they are imported. The system's temporary directory is used if left empty.")
+	flag.Int64Var(&ParquetBillMaxSize, "parquet-bill-max-size", 4<<30, "The size in bytes above which Parquet bills are not imported.")
 	flag.Parse()
 	if len(EsAddress) == 0 {
 		EsAddress = stringArray{"http://127.0.0.1:9200"}
@@ -20,7 +20,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -28,9 +27,9 @@ import (
 	"gopkg.in/olivere/elastic.v5"
 
 	"github.com/trackit/trackit-server/anomaliesDetection"
-	"github.com/trackit/trackit-server/config"
 	"github.com/trackit/trackit-server/costs/anomalies/anomalyFilters"
 	"github.com/trackit/trackit-server/costs/anomalies/anomalyType"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/errors"
 	"github.com/trackit/trackit-server/es"
@@ -93,7 +92,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams anomalyType.Anom
 		index,
 		parsedParams.AnomalyType,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "costs/anomalies", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -127,23 +126,6 @@ func getSnoozedAnomalies(userId int, tx *sql.Tx) (map[string]bool, error) {
 	}
 }
 
-// getAnomalyLevel get anomaly level depending on their cost.
-func getAnomalyLevel(typedDocument esProductAnomalyTypedResult) (int, string) {
-	if !typedDocument.Abnormal {
-		return 0, ""
-	}
-	prettyLevels := strings.Split(config.AnomalyDetectionPrettyLevels, ",")
-	percent := (typedDocument.Cost.Value * 100) / typedDocument.Cost.MaxExpected
-	levels := strings.Split(config.AnomalyDetectionLevels, ",")
-	for i, level := range levels[1:] {
-		l, _ := strconv.ParseFloat(level, 64)
-		if percent < l {
-			return i, prettyLevels[i]
-		}
-	}
-	return len(levels) - 1, prettyLevels[len(levels)-1]
-}
-
 func formatAnomaliesData(raw *elastic.SearchResult, snoozedAnomalies map[string]bool, ctx context.Context) (anomalyType.AnomaliesDetectionResponse, error) {
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
 	res := make(anomalyType.AnomaliesDetectionResponse)
@@ -160,7 +142,7 @@ func formatAnomaliesData(raw *elastic.SearchResult, snoozedAnomalies map[string]
 		if _, ok := res[typedDocument.Account][typedDocument.Product]; !ok {
 			res[typedDocument.Account][typedDocument.Product] = make([]anomalyType.ProductAnomaly, 0)
 		}
-		level, prettyLevel := getAnomalyLevel(typedDocument)
+		level, prettyLevel := anomalies.GetAnomalyLevel(typedDocument.Abnormal, typedDocument.Cost.Value, typedDocument.Cost.MaxExpected)
 		if date, err := time.Parse("2006-01-02T15:04:05.000Z", typedDocument.Date); err == nil {
 			res[typedDocument.Account][typedDocument.Product] = append(res[typedDocument.Account][typedDocument.Product], anomalyType.ProductAnomaly{
 				Id:          typedDocument.Id,
@@ -199,6 +181,23 @@ func removeNormalProduct(res anomalyType.AnomaliesDetectionResponse) anomalyType
 	return res
 }
 
+// convertAnomalies converts the costs of the anomalies, stored in the base
+// currency, with a conversion.
+func convertAnomalies(res anomalyType.AnomaliesDetectionResponse, conversion currency.Conversion) (err error) {
+	for _, productAnomalies := range res {
+		for _, ans := range productAnomalies {
+			for i := range ans {
+				if ans[i].Cost, err = conversion.Convert(ans[i].Cost, currency.Base, ans[i].Date); err != nil {
+					return
+				} else if ans[i].UpperBand, err = conversion.Convert(ans[i].UpperBand, currency.Base, ans[i].Date); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
 // applyFilters will apply all filters to the response.
 func applyFilters(res anomalyType.AnomaliesDetectionResponse, user users.User, ctx context.Context, tx *sql.Tx) anomalyType.AnomaliesDetectionResponse {
 	l := jsonlog.LoggerFromContextOrDefault(ctx)
@@ -259,5 +258,10 @@ func getAnomaliesData(request *http.Request, a routes.Arguments) (int, interface
 		return http.StatusInternalServerError, err
 	}
 	res = removeNormalProduct(res)
+	if conversion, err := currency.ForUser(tx, user); err != nil {
+		return http.StatusInternalServerError, err
+	} else if err := convertAnomalies(res, conversion); err != nil {
+		return http.StatusInternalServerError, err
+	}
 	return http.StatusOK, applyFilters(res, user, request.Context(), tx)
 }
@@ -0,0 +1,213 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package anomalies
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/anomaliesDetection"
+	"github.com/trackit/trackit-server/aws"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+)
+
+type (
+	// DetectorBody is the body required to select an anomaly detector.
+	// An empty detector resets the selection.
+	DetectorBody struct {
+		Detector string `json:"detector"`
+	}
+
+	// DetectorResponse is the body sent by the anomaly detector routes.
+	// Detector is the detector in use and Available lists the detectors
+	// which can be selected.
+	DetectorResponse struct {
+		Detector  string   `json:"detector"`
+		Available []string `json:"available"`
+	}
+)
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getAnomaliesDetector).With(
+			db.RequestTransaction{Db: db.Db},
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.Documentation{
+				Summary:     "get the anomaly detector",
+				Description: "Responds with the anomaly detector used for the AWS accounts which do not select one",
+			},
+		),
+		http.MethodPost: routes.H(postAnomaliesDetector).With(
+			db.RequestTransaction{Db: db.Db},
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{DetectorBody{"weekly-seasonality"}},
+			routes.Documentation{
+				Summary:     "select the anomaly detector",
+				Description: "Selects the anomaly detector used for the AWS accounts which do not select one",
+			},
+		),
+	}.H().Register("/costs/anomalies/detector")
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getAwsAccountAnomaliesDetector).With(
+			db.RequestTransaction{Db: db.Db},
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.QueryArgs{routes.AwsAccountIdQueryArg},
+			aws.RequireAwsAccountId{},
+			routes.Documentation{
+				Summary:     "get the anomaly detector of an AWS account",
+				Description: "Responds with the anomaly detector used for an AWS account",
+			},
+		),
+		http.MethodPost: routes.H(postAwsAccountAnomaliesDetector).With(
+			db.RequestTransaction{Db: db.Db},
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{DetectorBody{"ewma"}},
+			routes.QueryArgs{routes.AwsAccountIdQueryArg},
+			aws.RequireAwsAccountId{},
+			routes.Documentation{
+				Summary:     "select the anomaly detector of an AWS account",
+				Description: "Selects the anomaly detector used for an AWS account, overriding the one selected by the user",
+			},
+		),
+	}.H().Register("/costs/anomalies/detector/account")
+}
+
+// getDetectorFromBody validates the detector of a DetectorBody and returns it
+// as it is stored in the database.
+func getDetectorFromBody(body DetectorBody) (sql.NullString, error) {
+	if body.Detector == "" {
+		return sql.NullString{}, nil
+	} else if err := anomalies.ValidDetector(body.Detector); err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: body.Detector, Valid: true}, nil
+}
+
+// getAnomaliesDetector is a route handler which returns
+// the caller's anomaly detector.
+func getAnomaliesDetector(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	if dbUser, err := models.UserByID(tx, user.Id); err != nil {
+		l.Error("Failed to get user with id", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to retrieve anomaly detector.")
+	} else {
+		return http.StatusOK, DetectorResponse{anomalies.GetDetectorName(dbUser, nil), anomalies.DetectorNames()}
+	}
+}
+
+// postAnomaliesDetector is a route handler which lets the user
+// select the anomaly detector of their AWS accounts.
+func postAnomaliesDetector(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body DetectorBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	detector, err := getDetectorFromBody(body)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	dbUser, err := models.UserByID(tx, user.Id)
+	if err != nil {
+		l.Error("Failed to get user with id", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to update anomaly detector.")
+	}
+	dbUser.AnomaliesDetector = detector
+	if err := dbUser.Save(tx); err != nil {
+		l.Error("Failed to save anomaly detector", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to update anomaly detector.")
+	}
+	return http.StatusOK, DetectorResponse{anomalies.GetDetectorName(dbUser, nil), anomalies.DetectorNames()}
+}
+
+// getAwsAccountAnomaliesDetector is a route handler which returns
+// the anomaly detector of an AWS account.
+func getAwsAccountAnomaliesDetector(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	aa := a[aws.AwsAccountSelection].(aws.AwsAccount)
+	if dbAwsAccount, err := models.AwsAccountByID(tx, aa.Id); err != nil {
+		l.Error("Failed to get AWS account with id", map[string]interface{}{
+			"awsAccountId": aa.Id,
+			"error":        err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to retrieve anomaly detector.")
+	} else if detector, err := anomalies.GetAwsAccountDetectorName(tx, dbAwsAccount); err != nil {
+		l.Error("Failed to get anomaly detector of AWS account", map[string]interface{}{
+			"awsAccountId": aa.Id,
+			"error":        err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to retrieve anomaly detector.")
+	} else {
+		return http.StatusOK, DetectorResponse{detector, anomalies.DetectorNames()}
+	}
+}
+
+// postAwsAccountAnomaliesDetector is a route handler which lets the user
+// select the anomaly detector of one of their AWS accounts.
+func postAwsAccountAnomaliesDetector(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body DetectorBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	aa := a[aws.AwsAccountSelection].(aws.AwsAccount)
+	detector, err := getDetectorFromBody(body)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	dbAwsAccount, err := models.AwsAccountByID(tx, aa.Id)
+	if err != nil {
+		l.Error("Failed to get AWS account with id", map[string]interface{}{
+			"awsAccountId": aa.Id,
+			"error":        err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to update anomaly detector.")
+	}
+	dbAwsAccount.AnomaliesDetector = detector
+	if err := dbAwsAccount.Save(tx); err != nil {
+		l.Error("Failed to save anomaly detector", map[string]interface{}{
+			"awsAccountId": aa.Id,
+			"error":        err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to update anomaly detector.")
+	} else if name, err := anomalies.GetAwsAccountDetectorName(tx, dbAwsAccount); err != nil {
+		l.Error("Failed to get anomaly detector of AWS account", map[string]interface{}{
+			"awsAccountId": aa.Id,
+			"error":        err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to update anomaly detector.")
+	} else {
+		return http.StatusOK, DetectorResponse{name, anomalies.DetectorNames()}
+	}
+}
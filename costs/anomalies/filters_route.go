@@ -8,6 +8,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/costs/anomalies/anomalyFilters"
 	"github.com/trackit/trackit-server/costs/anomalies/anomalyType"
 	"github.com/trackit/trackit-server/db"
@@ -123,13 +124,14 @@ func postAnomaliesFiltersWithValidFilters(r *http.Request, tx *sql.Tx, dbUser *m
 			"error":  err.Error(),
 		})
 	} else {
+		before := dbUser.AnomaliesFilters
 		dbUser.AnomaliesFilters = res
 		if err := dbUser.Save(tx); err != nil {
 			l.Error("Failed to save anomalies filters", map[string]interface{}{
 				"userId": dbUser.ID,
 				"error":  err.Error(),
 			})
-		} else {
+		} else if err := recordFiltersUpdate(r, tx, dbUser.ID, before, res); err == nil {
 			filters := FiltersBody{anomalyType.Filters{}}
 			if dbUser.AnomaliesFilters != nil {
 				if err := json.Unmarshal(dbUser.AnomaliesFilters, &filters.Filters); err != nil {
@@ -145,3 +147,19 @@ func postAnomaliesFiltersWithValidFilters(r *http.Request, tx *sql.Tx, dbUser *m
 	}
 	return http.StatusInternalServerError, errors.New("Failed to update filters.")
 }
+
+// recordFiltersUpdate records an update of a user's anomalies filters in the
+// audit log. The filters are given as JSON.
+func recordFiltersUpdate(r *http.Request, tx *sql.Tx, userId int, before, after []byte) error {
+	entry := audit.Entry{
+		Action:     audit.ActionAnomaliesFiltersUpdate,
+		OwnerId:    userId,
+		TargetType: audit.TargetUser,
+		TargetId:   userId,
+		After:      json.RawMessage(after),
+	}
+	if before != nil {
+		entry.Before = json.RawMessage(before)
+	}
+	return audit.Record(r, tx, entry)
+}
@@ -16,8 +16,10 @@ package anomalies
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/models"
 	"github.com/trackit/trackit-server/routes"
@@ -70,6 +72,9 @@ func snoozeAnomalies(request *http.Request, a routes.Arguments) (int, interface{
 			res.Anomalies = append(res.Anomalies, anomalyId)
 		}
 	}
+	if err := recordSnoozing(request, tx, audit.ActionAnomaliesSnooze, user, res); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to snooze anomalies.")
+	}
 	return http.StatusOK, res
 }
 
@@ -86,5 +91,23 @@ func unsnoozeAnomalies(request *http.Request, a routes.Arguments) (int, interfac
 			res.Anomalies = append(res.Anomalies, anomalyId)
 		}
 	}
+	if err := recordSnoozing(request, tx, audit.ActionAnomaliesUnsnooze, user, res); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to unsnooze anomalies.")
+	}
 	return http.StatusOK, res
 }
+
+// recordSnoozing records the anomalies which were snoozed or unsnoozed in the
+// audit log. Anomalies are identified by strings, hence they are recorded as
+// the payload rather than as the target.
+func recordSnoozing(request *http.Request, tx *sql.Tx, action string, user users.User, snoozed snoozingBody) error {
+	if len(snoozed.Anomalies) == 0 {
+		return nil
+	}
+	return audit.Record(request, tx, audit.Entry{
+		Action:     action,
+		OwnerId:    user.Id,
+		TargetType: audit.TargetAnomaly,
+		After:      snoozed,
+	})
+}
@@ -0,0 +1,196 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package budgets
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/mail"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/users"
+)
+
+const (
+	// AlertKindActual is the kind of the alerts sent when the amount spent
+	// reaches a threshold.
+	AlertKindActual = "actual"
+	// AlertKindForecast is the kind of the alerts sent when the amount
+	// forecast to be spent at the end of the period reaches a threshold.
+	AlertKindForecast = "forecast"
+)
+
+// CheckBudgets evaluates all the budgets at date and emails their owners
+// when a threshold is reached. Each budget is checked in its own transaction,
+// and a budget failing to be evaluated does not prevent the others from being
+// checked.
+func CheckBudgets(ctx context.Context, db *sql.DB, date time.Time) error {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	dbBudgets, err := models.AllBudgets(db)
+	if err != nil {
+		return err
+	}
+	for _, dbBudget := range dbBudgets {
+		if err := checkBudgetInTransaction(ctx, db, *dbBudget, date); err != nil {
+			logger.Error("Failed to check budget.", map[string]interface{}{
+				"budgetId": dbBudget.ID,
+				"userId":   dbBudget.UserID,
+				"error":    err.Error(),
+			})
+		}
+	}
+	return nil
+}
+
+// checkBudgetInTransaction runs checkBudget in a transaction, which is rolled
+// back if the alert could not be sent so that it is sent at the next check.
+func checkBudgetInTransaction(ctx context.Context, db *sql.DB, dbBudget models.Budget, date time.Time) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	return checkBudget(ctx, tx, dbBudget, date)
+}
+
+// checkBudget evaluates a budget and alerts its owner of the thresholds
+// reached and not already alerted of during the current period. The alerts
+// are claimed before the mail is sent, so that backends checking the budget
+// at the same time don't send them twice.
+func checkBudget(ctx context.Context, tx *sql.Tx, dbBudget models.Budget, date time.Time) error {
+	budget, err := budgetFromDbBudget(dbBudget)
+	if err != nil {
+		return err
+	}
+	user, err := users.GetUserWithId(tx, dbBudget.UserID)
+	if err != nil {
+		return err
+	}
+	status, err := GetBudgetStatus(ctx, tx, user, budget, date)
+	if err != nil {
+		return err
+	}
+	dbAlerts, err := models.BudgetAlertsByBudgetIDPeriodBegin(tx, budget.Id, status.PeriodBegin)
+	if err != nil {
+		return err
+	}
+	fired := map[string]map[int]bool{
+		AlertKindActual:   {},
+		AlertKindForecast: {},
+	}
+	for _, dbAlert := range dbAlerts {
+		if fired[dbAlert.Kind] != nil {
+			fired[dbAlert.Kind][dbAlert.Threshold] = true
+		}
+	}
+	actual := getReachedThresholds(budget.Thresholds, status.ActualPercentage, fired[AlertKindActual])
+	forecast := getReachedThresholds(budget.Thresholds, status.ForecastPercentage, fired[AlertKindForecast])
+	forecast = getUnreachedThresholds(forecast, status.ActualPercentage)
+	if actual, err = claimBudgetAlerts(tx, status, AlertKindActual, actual); err != nil {
+		return err
+	} else if forecast, err = claimBudgetAlerts(tx, status, AlertKindForecast, forecast); err != nil {
+		return err
+	} else if len(actual) == 0 && len(forecast) == 0 {
+		return nil
+	}
+	subject, body := buildBudgetAlert(status, actual, forecast)
+	return mail.SendMail(user.Email, subject, body, ctx)
+}
+
+// getReachedThresholds returns the thresholds reached by percentage which
+// are not in fired.
+func getReachedThresholds(thresholds []int, percentage float64, fired map[int]bool) []int {
+	reached := []int{}
+	for _, threshold := range thresholds {
+		if percentage >= float64(threshold) && !fired[threshold] {
+			reached = append(reached, threshold)
+		}
+	}
+	return reached
+}
+
+// getUnreachedThresholds returns the thresholds not reached by percentage.
+// Once the actual spending reached a threshold, forecasting it is pointless.
+func getUnreachedThresholds(thresholds []int, percentage float64) []int {
+	unreached := []int{}
+	for _, threshold := range thresholds {
+		if percentage < float64(threshold) {
+			unreached = append(unreached, threshold)
+		}
+	}
+	return unreached
+}
+
+// claimBudgetAlerts records the alerts of a kind for a budget so that they
+// are not sent again during the same period, and returns the thresholds which
+// were not recorded yet. An alert recorded by another transaction is only
+// skipped once that transaction commits.
+func claimBudgetAlerts(tx *sql.Tx, status BudgetStatus, kind string, thresholds []int) ([]int, error) {
+	const sqlstr = `INSERT IGNORE INTO budget_alert (
+		budget_id, period_begin, threshold, kind
+	) VALUES (
+		?, ?, ?, ?
+	)`
+	claimed := []int{}
+	for _, threshold := range thresholds {
+		res, err := tx.Exec(sqlstr, status.Id, status.PeriodBegin, threshold, kind)
+		if err != nil {
+			return nil, err
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if affected == 1 {
+			claimed = append(claimed, threshold)
+		}
+	}
+	return claimed, nil
+}
+
+// buildBudgetAlert builds the subject and the body of the mail warning a
+// user about the thresholds reached by a budget, with its amounts in the
+// status' display currency.
+func buildBudgetAlert(status BudgetStatus, actual, forecast []int) (string, string) {
+	subject := fmt.Sprintf("TrackIt budget alert for %s", status.Name)
+	body := fmt.Sprintf("Hello,\r\n\r\nYour budget %s of %.2f %s per %s (%s to %s) needs your attention:\r\n\r\n",
+		status.Name, status.DisplayAmount, status.DisplayCurrency, status.Period, status.PeriodBegin.Format("2006-01-02"), status.PeriodEnd.Format("2006-01-02"))
+	if len(actual) > 0 {
+		body += fmt.Sprintf("- %.2f %s has been spent, reaching %s of the budget.\r\n", status.Actual, status.DisplayCurrency, formatPercentages(actual))
+	}
+	if len(forecast) > 0 {
+		body += fmt.Sprintf("- %.2f %s is forecast to be spent by the end of the period, reaching %s of the budget.\r\n", status.Forecast, status.DisplayCurrency, formatPercentages(forecast))
+	}
+	body += "\r\nYou can review your budgets on https://re.trackit.io.\r\n"
+	return subject, body
+}
+
+// formatPercentages formats thresholds as a human readable list.
+func formatPercentages(thresholds []int) string {
+	formatted := make([]string, len(thresholds))
+	for i, threshold := range thresholds {
+		formatted[i] = fmt.Sprintf("%d%%", threshold)
+	}
+	return strings.Join(formatted, ", ")
+}
@@ -0,0 +1,236 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package budgets lets users set the amount of money they expect to spend
+// per month or per quarter on a scope of their costs, and warns them when
+// this amount is about to be exceeded.
+package budgets
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/users"
+)
+
+const (
+	// PeriodMonth is the period of a budget reset every month.
+	PeriodMonth = "month"
+	// PeriodQuarter is the period of a budget reset every quarter.
+	PeriodQuarter = "quarter"
+)
+
+var (
+	ErrInvalidPeriod    = errors.New("period must be either month or quarter")
+	ErrInvalidAmount    = errors.New("amount must be positive")
+	ErrInvalidThreshold = errors.New("thresholds must be positive percentages")
+	ErrInvalidTag       = errors.New("tag key and tag value must be set together")
+	ErrBudgetNotFound   = errors.New("budget not found")
+)
+
+// Budget is an amount of money expected to be spent per period on the costs
+// of a list of AWS accounts, optionally restricted to a product and a tag.
+// Amount is expressed in Currency, the display currency of the user when the
+// budget was last saved. An empty AwsAccounts list stands for all the accounts of the user. An
+// empty Product stands for all the products. When TagKey is set, only the
+// costs tagged with TagValue are considered.
+type Budget struct {
+	Id          int      `json:"id"`
+	Name        string   `json:"name"`
+	Amount      float64  `json:"amount"`
+	Currency    string   `json:"currency"`
+	Period      string   `json:"period"`
+	AwsAccounts []string `json:"awsAccounts"`
+	Product     string   `json:"product"`
+	TagKey      string   `json:"tagKey"`
+	TagValue    string   `json:"tagValue"`
+	Thresholds  []int    `json:"thresholds"`
+}
+
+// validate checks a budget is consistent before it is saved.
+func (b Budget) validate() error {
+	if b.Period != PeriodMonth && b.Period != PeriodQuarter {
+		return ErrInvalidPeriod
+	} else if b.Amount <= 0 {
+		return ErrInvalidAmount
+	} else if (b.TagKey == "") != (b.TagValue == "") {
+		return ErrInvalidTag
+	}
+	for _, threshold := range b.Thresholds {
+		if threshold <= 0 {
+			return ErrInvalidThreshold
+		}
+	}
+	return nil
+}
+
+// GetPeriodBounds returns the beginning and the end of the budget period
+// containing date.
+func GetPeriodBounds(period string, date time.Time) (time.Time, time.Time) {
+	date = date.UTC()
+	month := date.Month()
+	months := 1
+	if period == PeriodQuarter {
+		month = (month-1)/3*3 + 1
+		months = 3
+	}
+	begin := time.Date(date.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+	end := begin.AddDate(0, months, 0).Add(-time.Nanosecond)
+	return begin, end
+}
+
+// parseThresholds parses a comma separated list of percentages, sorted in
+// ascending order.
+func parseThresholds(raw string) ([]int, error) {
+	thresholds := []int{}
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		} else if threshold, err := strconv.Atoi(s); err != nil {
+			return nil, err
+		} else if threshold <= 0 {
+			return nil, ErrInvalidThreshold
+		} else {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	sort.Ints(thresholds)
+	return thresholds, nil
+}
+
+// formatThresholds formats thresholds as a comma separated list.
+func formatThresholds(thresholds []int) string {
+	formatted := make([]string, len(thresholds))
+	for i, threshold := range thresholds {
+		formatted[i] = strconv.Itoa(threshold)
+	}
+	return strings.Join(formatted, ",")
+}
+
+// budgetFromDbBudget builds a Budget from its database representation. When
+// the budget has no threshold of its own, config.BudgetAlertThresholds is
+// used.
+func budgetFromDbBudget(dbBudget models.Budget) (Budget, error) {
+	budget := Budget{
+		Id:          dbBudget.ID,
+		Name:        dbBudget.Name,
+		Amount:      dbBudget.Amount,
+		Currency:    dbBudget.Currency,
+		Period:      dbBudget.Period,
+		AwsAccounts: []string{},
+		Product:     dbBudget.Product,
+		TagKey:      dbBudget.TagKey,
+		TagValue:    dbBudget.TagValue,
+	}
+	if dbBudget.AwsAccounts != nil {
+		if err := json.Unmarshal(dbBudget.AwsAccounts, &budget.AwsAccounts); err != nil {
+			return budget, fmt.Errorf("failed to parse budget accounts: %s", err.Error())
+		}
+	}
+	thresholds := dbBudget.Thresholds
+	if thresholds == "" {
+		thresholds = config.BudgetAlertThresholds
+	}
+	var err error
+	if budget.Thresholds, err = parseThresholds(thresholds); err != nil {
+		return budget, fmt.Errorf("failed to parse budget thresholds: %s", err.Error())
+	}
+	return budget, nil
+}
+
+// fillDbBudget copies a Budget into its database representation.
+func fillDbBudget(dbBudget *models.Budget, budget Budget) error {
+	awsAccounts, err := json.Marshal(budget.AwsAccounts)
+	if err != nil {
+		return err
+	}
+	thresholds := append([]int{}, budget.Thresholds...)
+	sort.Ints(thresholds)
+	dbBudget.Name = budget.Name
+	dbBudget.Amount = budget.Amount
+	dbBudget.Currency = budget.Currency
+	dbBudget.Period = budget.Period
+	dbBudget.AwsAccounts = awsAccounts
+	dbBudget.Product = budget.Product
+	dbBudget.TagKey = budget.TagKey
+	dbBudget.TagValue = budget.TagValue
+	dbBudget.Thresholds = formatThresholds(thresholds)
+	return nil
+}
+
+// GetBudgetsForUser retrieves all the budgets of a user.
+func GetBudgetsForUser(tx *sql.Tx, user users.User) ([]Budget, error) {
+	dbBudgets, err := models.BudgetsByUserID(tx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	budgets := make([]Budget, len(dbBudgets))
+	for i, dbBudget := range dbBudgets {
+		if budgets[i], err = budgetFromDbBudget(*dbBudget); err != nil {
+			return nil, err
+		}
+	}
+	return budgets, nil
+}
+
+// getDbBudgetForUser retrieves a budget by its ID, ensuring it belongs to the
+// user.
+func getDbBudgetForUser(tx *sql.Tx, user users.User, budgetId int) (*models.Budget, error) {
+	dbBudget, err := models.BudgetByID(tx, budgetId)
+	if err == sql.ErrNoRows || (err == nil && dbBudget.UserID != user.Id) {
+		return nil, ErrBudgetNotFound
+	}
+	return dbBudget, err
+}
+
+// CreateBudget saves a new budget for a user.
+func CreateBudget(tx *sql.Tx, user users.User, budget Budget) (Budget, error) {
+	dbBudget := models.Budget{UserID: user.Id}
+	if err := fillDbBudget(&dbBudget, budget); err != nil {
+		return budget, err
+	} else if err := dbBudget.Insert(tx); err != nil {
+		return budget, err
+	}
+	return budgetFromDbBudget(dbBudget)
+}
+
+// UpdateBudget replaces a budget of a user.
+func UpdateBudget(tx *sql.Tx, user users.User, budgetId int, budget Budget) (Budget, error) {
+	dbBudget, err := getDbBudgetForUser(tx, user, budgetId)
+	if err != nil {
+		return budget, err
+	} else if err := fillDbBudget(dbBudget, budget); err != nil {
+		return budget, err
+	} else if err := dbBudget.Update(tx); err != nil {
+		return budget, err
+	}
+	return budgetFromDbBudget(*dbBudget)
+}
+
+// DeleteBudget deletes a budget of a user, along with its alerts.
+func DeleteBudget(tx *sql.Tx, user users.User, budgetId int) error {
+	dbBudget, err := getDbBudgetForUser(tx, user, budgetId)
+	if err != nil {
+		return err
+	}
+	return dbBudget.Delete(tx)
+}
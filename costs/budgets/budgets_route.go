@@ -0,0 +1,231 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package budgets
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+)
+
+type (
+	// BudgetBody is the body required by postBudget and patchBudget.
+	BudgetBody struct {
+		Name        string   `json:"name" req:"nonzero"`
+		Amount      float64  `json:"amount"`
+		Period      string   `json:"period" req:"nonzero"`
+		AwsAccounts []string `json:"awsAccounts"`
+		Product     string   `json:"product"`
+		TagKey      string   `json:"tagKey"`
+		TagValue    string   `json:"tagValue"`
+		Thresholds  []int    `json:"thresholds"`
+	}
+)
+
+// budgetIdQueryArg allows to get the ID of a budget in the URL parameters.
+var budgetIdQueryArg = routes.QueryArg{
+	Name:        "budget-id",
+	Type:        routes.QueryArgInt{},
+	Description: "The ID of the budget.",
+}
+
+func init() {
+	exampleBody := BudgetBody{
+		Name:        "Production EC2",
+		Amount:      1000,
+		Period:      PeriodMonth,
+		AwsAccounts: []string{"123456789012"},
+		Product:     "AmazonEC2",
+		TagKey:      "environment",
+		TagValue:    "production",
+		Thresholds:  []int{50, 80, 100},
+	}
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getBudgets).With(
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.Documentation{
+				Summary:     "get the budgets",
+				Description: "Responds with the budgets of the user and their status for the current period: the amount spent so far and the amount forecast for the whole period, in the user's display currency.",
+			},
+		),
+		http.MethodPost: routes.H(postBudget).With(
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{exampleBody},
+			routes.Documentation{
+				Summary:     "create a budget",
+				Description: "Creates a budget whose amount is in the user's display currency. The period is either month or quarter. No AWS account means all of them and no product means all of them. When no threshold is provided, the default ones are used.",
+			},
+		),
+		http.MethodPatch: routes.H(patchBudget).With(
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.RequestContentType{"application/json"},
+			routes.QueryArgs{budgetIdQueryArg},
+			routes.RequestBody{exampleBody},
+			routes.Documentation{
+				Summary:     "edit a budget",
+				Description: "Replaces the budget with the body, whose amount is in the user's display currency. The thresholds already alerted of during the current period are not alerted of again.",
+			},
+		),
+		http.MethodDelete: routes.H(deleteBudget).With(
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.QueryArgs{budgetIdQueryArg},
+			routes.Documentation{
+				Summary:     "delete a budget",
+				Description: "Deletes a budget and the record of its alerts.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		routes.Documentation{
+			Summary:     "interact with the budgets",
+			Description: "A budget is an amount expected to be spent per month or quarter on some AWS accounts, optionally restricted to a product and a tag. Its owner is emailed when the amount spent or forecast reaches its thresholds.",
+		},
+	).Register("/costs/budgets")
+}
+
+// getBudgets is a route handler which returns the caller's budgets along
+// with their current status.
+func getBudgets(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	budgets, err := GetBudgetsForUser(tx, user)
+	if err != nil {
+		l.Error("Failed to get budgets.", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to retrieve budgets.")
+	}
+	now := time.Now().UTC()
+	statuses := make([]BudgetStatus, len(budgets))
+	for i, budget := range budgets {
+		if statuses[i], err = GetBudgetStatus(r.Context(), tx, user, budget, now); err != nil {
+			l.Error("Failed to get budget status.", map[string]interface{}{
+				"userId":   user.Id,
+				"budgetId": budget.Id,
+				"error":    err.Error(),
+			})
+			return http.StatusInternalServerError, errors.New("Failed to retrieve budgets.")
+		}
+	}
+	return http.StatusOK, statuses
+}
+
+// budgetFromBody builds a valid budget from a request body, ensuring the
+// user has access to its AWS accounts. The amount is taken to be in the
+// user's display currency.
+func budgetFromBody(tx *sql.Tx, user users.User, body BudgetBody) (Budget, error) {
+	budget := Budget{
+		Name:        body.Name,
+		Amount:      body.Amount,
+		Currency:    user.Currency,
+		Period:      body.Period,
+		AwsAccounts: body.AwsAccounts,
+		Product:     body.Product,
+		TagKey:      body.TagKey,
+		TagValue:    body.TagValue,
+		Thresholds:  body.Thresholds,
+	}
+	if budget.AwsAccounts == nil {
+		budget.AwsAccounts = []string{}
+	}
+	if err := budget.validate(); err != nil {
+		return budget, err
+	}
+	if len(budget.AwsAccounts) > 0 {
+		if _, _, err := es.GetAccountsAndIndexes(budget.AwsAccounts, user, tx, s3.IndexPrefixLineItem); err != nil {
+			return budget, err
+		}
+	}
+	return budget, nil
+}
+
+// postBudget is a route handler which lets the user create a budget.
+func postBudget(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body BudgetBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	budget, err := budgetFromBody(tx, user, body)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Body is invalid (%s).", err.Error())
+	}
+	if budget, err = CreateBudget(tx, user, budget); err != nil {
+		l.Error("Failed to create budget.", map[string]interface{}{
+			"userId": user.Id,
+			"budget": budget,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to create budget.")
+	}
+	return http.StatusOK, budget
+}
+
+// patchBudget is a route handler which lets the user edit a budget.
+func patchBudget(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body BudgetBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	budgetId := a[budgetIdQueryArg].(int)
+	budget, err := budgetFromBody(tx, user, body)
+	if err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Body is invalid (%s).", err.Error())
+	}
+	if budget, err = UpdateBudget(tx, user, budgetId, budget); err == ErrBudgetNotFound {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		l.Error("Failed to update budget.", map[string]interface{}{
+			"userId":   user.Id,
+			"budgetId": budgetId,
+			"error":    err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to update budget.")
+	}
+	return http.StatusOK, budget
+}
+
+// deleteBudget is a route handler which lets the user delete a budget.
+func deleteBudget(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	budgetId := a[budgetIdQueryArg].(int)
+	if err := DeleteBudget(tx, user, budgetId); err == ErrBudgetNotFound {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		l.Error("Failed to delete budget.", map[string]interface{}{
+			"userId":   user.Id,
+			"budgetId": budgetId,
+			"error":    err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to delete budget.")
+	}
+	return http.StatusOK, nil
+}
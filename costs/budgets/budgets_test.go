@@ -0,0 +1,206 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package budgets
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/users"
+)
+
+func TestGetPeriodBoundsMonth(t *testing.T) {
+	begin, end := GetPeriodBounds(PeriodMonth, time.Date(2018, time.February, 14, 12, 0, 0, 0, time.UTC))
+	if !begin.Equal(time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected period beginning %s", begin)
+	}
+	if !end.Equal(time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)) {
+		t.Errorf("Unexpected period end %s", end)
+	}
+}
+
+func TestGetPeriodBoundsQuarter(t *testing.T) {
+	begin, end := GetPeriodBounds(PeriodQuarter, time.Date(2018, time.December, 31, 23, 0, 0, 0, time.UTC))
+	if !begin.Equal(time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected period beginning %s", begin)
+	}
+	if !end.Equal(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)) {
+		t.Errorf("Unexpected period end %s", end)
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := parseThresholds("100, 50,80")
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	if !reflect.DeepEqual(thresholds, []int{50, 80, 100}) {
+		t.Errorf("Unexpected thresholds %v", thresholds)
+	}
+	if _, err := parseThresholds("50,-1"); err == nil {
+		t.Errorf("Expected an error for a negative threshold")
+	}
+}
+
+func TestValidateBudget(t *testing.T) {
+	budget := Budget{Name: "test", Amount: 100, Period: PeriodQuarter, Thresholds: []int{80}}
+	if err := budget.validate(); err != nil {
+		t.Errorf("Unexpected error %s", err.Error())
+	}
+	budget.Period = "week"
+	if err := budget.validate(); err != ErrInvalidPeriod {
+		t.Errorf("Expected ErrInvalidPeriod, got %v", err)
+	}
+	budget.Period = PeriodMonth
+	budget.TagKey = "environment"
+	if err := budget.validate(); err != ErrInvalidTag {
+		t.Errorf("Expected ErrInvalidTag, got %v", err)
+	}
+}
+
+func TestGetLinearForecast(t *testing.T) {
+	begin, end := GetPeriodBounds(PeriodMonth, time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC))
+	date := time.Date(2018, time.April, 10, 0, 0, 0, 0, time.UTC)
+	forecast := getLinearForecast(90, begin, end, date)
+	if forecast < 299.99 || forecast > 300.01 {
+		t.Errorf("Expected a forecast of 300, got %f", forecast)
+	}
+	if forecast := getLinearForecast(90, begin, end, end); forecast != 90 {
+		t.Errorf("Expected the forecast of an ended period to be the actual cost, got %f", forecast)
+	}
+}
+
+func TestSumBudgetCosts(t *testing.T) {
+	doc := es.SimplifiedCostsDocument{
+		ChildrenKind: "product",
+		Children: []es.SimplifiedCostsDocument{
+			{
+				Key:          "AmazonEC2",
+				ChildrenKind: es.TagChildrenKind,
+				Children: []es.SimplifiedCostsDocument{
+					{Key: "production", HasValue: true, Value: 10},
+					{Key: "staging", HasValue: true, Value: 20},
+					{Key: es.TagUntaggedBucketKey, HasValue: true, Value: 40},
+				},
+			},
+			{
+				Key:          "AmazonS3",
+				ChildrenKind: es.TagChildrenKind,
+				Children: []es.SimplifiedCostsDocument{
+					{Key: "production", HasValue: true, Value: 80},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		budget   Budget
+		expected float64
+	}{
+		{Budget{Product: "AmazonEC2", TagKey: "env", TagValue: "production"}, 10},
+		{Budget{TagKey: "env", TagValue: "production"}, 90},
+		{Budget{Product: "AmazonRDS", TagKey: "env", TagValue: "production"}, 0},
+		{Budget{Product: "AmazonEC2"}, 70},
+		{Budget{}, 150},
+	}
+	for _, test := range tests {
+		if total := sumBudgetCosts(test.budget, doc); total != test.expected {
+			t.Errorf("Expected %f for budget %v, got %f", test.expected, test.budget, total)
+		}
+	}
+}
+
+func TestGetReachedThresholds(t *testing.T) {
+	thresholds := []int{50, 80, 100}
+	reached := getReachedThresholds(thresholds, 85, map[int]bool{50: true})
+	if !reflect.DeepEqual(reached, []int{80}) {
+		t.Errorf("Unexpected reached thresholds %v", reached)
+	}
+	unreached := getUnreachedThresholds([]int{50, 80, 100}, 85)
+	if !reflect.DeepEqual(unreached, []int{100}) {
+		t.Errorf("Unexpected unreached thresholds %v", unreached)
+	}
+}
+
+func TestBuildBudgetAlert(t *testing.T) {
+	status := BudgetStatus{
+		Budget:          Budget{Name: "Production", Amount: 1000, Currency: "USD", Period: PeriodMonth},
+		DisplayAmount:   800,
+		DisplayCurrency: "EUR",
+		PeriodBegin:     time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:       time.Date(2018, time.May, 1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond),
+		Actual:          650,
+		Forecast:        900,
+	}
+	_, body := buildBudgetAlert(status, []int{80}, []int{100})
+	for _, expected := range []string{
+		"Your budget Production of 800.00 EUR per month (2018-04-01 to 2018-04-30)",
+		"- 650.00 EUR has been spent, reaching 80% of the budget.",
+		"- 900.00 EUR is forecast to be spent",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected the alert to contain %q, got %q", expected, body)
+		}
+	}
+}
+
+// This test is intended to be run against an empty database with the schema
+// already in place.
+func TestClaimBudgetAlerts(t *testing.T) {
+	user, err := users.CreateUserWithPassword(context.Background(), db.Db, "budget.alerts@example.com", "budgetPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	dbBudget := models.Budget{UserID: user.Id, Name: "Alerts", Amount: 100, Period: PeriodMonth}
+	if err := dbBudget.Insert(db.Db); err != nil {
+		t.Fatalf("Creating budget: error should be nil, instead is \"%s\".", err.Error())
+	}
+	status := BudgetStatus{
+		Budget:      Budget{Id: dbBudget.ID},
+		PeriodBegin: time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for _, c := range []struct {
+		thresholds []int
+		commit     bool
+		expected   []int
+	}{
+		{[]int{50, 100}, true, []int{50, 100}},
+		{[]int{50, 100, 150}, false, []int{150}},
+		{[]int{50, 100, 150}, true, []int{150}},
+		{[]int{50, 100, 150}, true, []int{}},
+	} {
+		tx, err := db.Db.Begin()
+		if err != nil {
+			t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+		}
+		claimed, err := claimBudgetAlerts(tx, status, AlertKindActual, c.thresholds)
+		if err != nil {
+			tx.Rollback()
+			t.Fatalf("Claiming %v: error should be nil, instead is \"%s\".", c.thresholds, err.Error())
+		} else if !reflect.DeepEqual(claimed, c.expected) {
+			t.Errorf("Claiming %v should claim %v, claims %v instead.", c.thresholds, c.expected, claimed)
+		}
+		if c.commit {
+			tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}
+}
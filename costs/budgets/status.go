@@ -0,0 +1,126 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package budgets
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/costs"
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/users"
+)
+
+// BudgetStatus is the state of a budget at a given date: the amount spent
+// since the beginning of its period and the amount forecast to be spent at
+// its end. Costs are in DisplayCurrency, which DisplayAmount is the budget
+// amount converted to, and percentages are relative to DisplayAmount.
+type BudgetStatus struct {
+	Budget
+	DisplayAmount      float64   `json:"displayAmount"`
+	DisplayCurrency    string    `json:"displayCurrency"`
+	PeriodBegin        time.Time `json:"periodBegin"`
+	PeriodEnd          time.Time `json:"periodEnd"`
+	Actual             float64   `json:"actual"`
+	ActualPercentage   float64   `json:"actualPercentage"`
+	Forecast           float64   `json:"forecast"`
+	ForecastPercentage float64   `json:"forecastPercentage"`
+}
+
+// GetBudgetStatus computes the status of a budget at date from the line
+// items of its accounts. Costs are converted to the user's display currency,
+// and so is the budget amount, at the end of the evaluated range.
+func GetBudgetStatus(ctx context.Context, tx *sql.Tx, user users.User, budget Budget, date time.Time) (BudgetStatus, error) {
+	status := BudgetStatus{Budget: budget}
+	status.PeriodBegin, status.PeriodEnd = GetPeriodBounds(budget.Period, date)
+	dateEnd := date.UTC()
+	if dateEnd.After(status.PeriodEnd) {
+		dateEnd = status.PeriodEnd
+	}
+	accountsAndIndexes, _, err := es.GetAccountsAndIndexes(budget.AwsAccounts, user, tx, s3.IndexPrefixLineItem)
+	if err != nil {
+		return status, err
+	}
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		return status, err
+	}
+	status.DisplayCurrency = conversion.Target
+	if status.DisplayAmount, err = conversion.Convert(budget.Amount, budget.Currency, dateEnd); err != nil {
+		return status, err
+	}
+	params := costs.EsQueryParams{
+		DateBegin:         status.PeriodBegin,
+		DateEnd:           dateEnd,
+		AccountList:       accountsAndIndexes.Accounts,
+		IndexList:         accountsAndIndexes.Indexes,
+		AggregationParams: getAggregationParams(budget),
+		Conversion:        conversion,
+	}
+	doc, returnCode, err := costs.MakeElasticSearchRequestAndParseIt(ctx, params)
+	if err != nil && returnCode != http.StatusOK {
+		return status, err
+	}
+	status.Actual = sumBudgetCosts(budget, doc)
+	status.Forecast = getLinearForecast(status.Actual, status.PeriodBegin, status.PeriodEnd, dateEnd)
+	status.ActualPercentage = status.Actual / status.DisplayAmount * 100
+	status.ForecastPercentage = status.Forecast / status.DisplayAmount * 100
+	return status, nil
+}
+
+// getAggregationParams returns the criteria the costs of a budget have to be
+// broken down by so that its product and tag can be picked from the result.
+func getAggregationParams(budget Budget) []string {
+	aggregationParams := []string{"product"}
+	if budget.TagKey != "" {
+		aggregationParams = append(aggregationParams, "tag:"+budget.TagKey)
+	}
+	return aggregationParams
+}
+
+// sumBudgetCosts sums the costs of a document built with the criteria from
+// getAggregationParams, keeping only the product and the tag value of the
+// budget.
+func sumBudgetCosts(budget Budget, doc es.SimplifiedCostsDocument) float64 {
+	if doc.HasValue {
+		return doc.Value
+	}
+	var total float64
+	for _, child := range doc.Children {
+		if doc.ChildrenKind == "product" && budget.Product != "" && child.Key != budget.Product {
+			continue
+		} else if doc.ChildrenKind == es.TagChildrenKind && budget.TagKey != "" && child.Key != budget.TagValue {
+			continue
+		}
+		total += sumBudgetCosts(budget, child)
+	}
+	return total
+}
+
+// getLinearForecast extrapolates the amount spent between begin and date to
+// the whole period ending at end.
+func getLinearForecast(actual float64, begin, end, date time.Time) float64 {
+	elapsed := date.Sub(begin)
+	if elapsed <= 0 {
+		return actual
+	} else if !date.Before(end) {
+		return actual
+	}
+	return actual * float64(end.Sub(begin)) / float64(elapsed)
+}
@@ -26,6 +26,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/errors"
 	"github.com/trackit/trackit-server/es"
@@ -42,6 +43,7 @@ var simpleCriterionMap = map[string]bool{
 	"week":             true,
 	"day":              true,
 	"account":          true,
+	"lineitemtype":     true,
 	"product":          true,
 	"region":           true,
 	"availabilityzone": true,
@@ -54,6 +56,9 @@ type EsQueryParams struct {
 	AccountList       []string
 	IndexList         []string
 	AggregationParams []string
+	LineItemTypes     s3.LineItemTypeFilter
+	CostType          string
+	Conversion        currency.Conversion
 }
 
 // costQueryArgs allows to get required queryArgs params
@@ -63,10 +68,13 @@ var costsQueryArgs = []routes.QueryArg{
 	routes.DateEndQueryArg,
 	routes.QueryArg{
 		Name:        "by",
-		Description: "Criteria for the ES aggregation, comma separated. Possible values are year, month, week, day, account, product, region, tag(soon)",
+		Description: "Criteria for the ES aggregation, comma separated. Possible values are year, month, week, day, account, product, region, availabilityzone, lineitemtype, tag:<TAG_KEY>",
 		Type:        routes.QueryArgStringSlice{},
 		Optional:    false,
 	},
+	routes.CostTypeOptionalQueryArg,
+	routes.LineItemTypesIncludeOptionalQueryArg,
+	routes.LineItemTypesExcludeOptionalQueryArg,
 }
 
 func init() {
@@ -83,17 +91,15 @@ func init() {
 	}.H().Register("/costs")
 }
 
-// validateCriteraParam will validate the different criterions.
+// ValidateCriteriaParam will validate the different criterions.
 // It validate the criterion by checking its presence in the simpleCriterionMap
 // or, in the case of the special criterion tag, will check if it is in the
 // correct format : 'tag:*' (with no more than one ':')
-// Right now the tags are not enabled and will generate an error if they are
-// used because they are not yet implemented in the new ElasticSearch mapping
-func validateCriteriaParam(parsedParams EsQueryParams) error {
+func ValidateCriteriaParam(parsedParams EsQueryParams) error {
 	for _, criterion := range parsedParams.AggregationParams {
 		if !simpleCriterionMap[criterion] {
 			if len(criterion) >= 5 && criterion[:4] == "tag:" && strings.Count(criterion, ":") == 1 {
-				return fmt.Errorf("tags not yet implemented")
+				continue
 			}
 			return fmt.Errorf("Error parsing criterion : %s", criterion)
 		}
@@ -110,6 +116,18 @@ func validateCriteriaParam(parsedParams EsQueryParams) error {
 func MakeElasticSearchRequestAndParseIt(ctx context.Context, parsedParams EsQueryParams) (es.SimplifiedCostsDocument, int, error) {
 	l := jsonlog.LoggerFromContextOrDefault(ctx)
 	index := strings.Join(parsedParams.IndexList, ",")
+	costField, err := s3.CostTypeField(parsedParams.CostType)
+	if err != nil {
+		return es.SimplifiedCostsDocument{}, http.StatusBadRequest, err
+	}
+	if conversion, err := getLineItemsConversion(ctx, parsedParams, index); err != nil {
+		l.Warning("Failed to get the currencies of the line items, converting all of them.", map[string]interface{}{
+			"index": index,
+			"error": err.Error(),
+		})
+	} else {
+		parsedParams.Conversion = conversion
+	}
 	searchService := GetElasticSearchParams(
 		parsedParams.AccountList,
 		parsedParams.DateBegin,
@@ -117,8 +135,11 @@ func MakeElasticSearchRequestAndParseIt(ctx context.Context, parsedParams EsQuer
 		parsedParams.AggregationParams,
 		es.Client,
 		index,
+		parsedParams.LineItemTypes,
+		costField,
+		parsedParams.Conversion,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "costs", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -144,6 +165,30 @@ func MakeElasticSearchRequestAndParseIt(ctx context.Context, parsedParams EsQuer
 	return simplifiedCostDocument, http.StatusOK, nil
 }
 
+// getLineItemsConversion returns the conversion of the query params, along
+// with the currencies of the line items they match, so that costs are only
+// converted when some of them are not in the target currency.
+func getLineItemsConversion(ctx context.Context, parsedParams EsQueryParams, index string) (currency.Conversion, error) {
+	query := createLineItemsQuery(parsedParams.AccountList, parsedParams.DateBegin, parsedParams.DateEnd, parsedParams.LineItemTypes)
+	search := es.Client.Search().Index(index).IgnoreUnavailable(true).Size(0).Query(query).
+		Aggregation("currencies", currency.CurrenciesAggregation())
+	res, err := es.DoSearch(ctx, "costs-currencies", search)
+	if err != nil {
+		return parsedParams.Conversion, err
+	}
+	currencies := []string{}
+	if terms, found := res.Aggregations.Terms("currencies"); found {
+		for _, bucket := range terms.Buckets {
+			if key, ok := bucket.Key.(string); ok {
+				currencies = append(currencies, key)
+			} else {
+				return parsedParams.Conversion, fmt.Errorf("unexpected currency %v", bucket.Key)
+			}
+		}
+	}
+	return parsedParams.Conversion.WithCurrencies(currencies), nil
+}
+
 // getCostsData returns the cost data based on the query params, in JSON format.
 func getCostData(request *http.Request, a routes.Arguments) (int, interface{}) {
 	user := a[users.AuthenticatedUser].(users.User)
@@ -156,7 +201,16 @@ func getCostData(request *http.Request, a routes.Arguments) (int, interface{}) {
 	if a[costsQueryArgs[0]] != nil {
 		parsedParams.AccountList = a[costsQueryArgs[0]].([]string)
 	}
-	if err := validateCriteriaParam(parsedParams); err != nil {
+	if a[costsQueryArgs[4]] != nil {
+		parsedParams.CostType = a[costsQueryArgs[4]].(string)
+	}
+	if err := ValidateCriteriaParam(parsedParams); err != nil {
+		return http.StatusBadRequest, err
+	} else if _, err := s3.CostTypeField(parsedParams.CostType); err != nil {
+		return http.StatusBadRequest, err
+	}
+	var err error
+	if parsedParams.LineItemTypes, err = s3.LineItemTypeFilterFromArguments(a); err != nil {
 		return http.StatusBadRequest, err
 	}
 	tx := a[db.Transaction].(*sql.Tx)
@@ -166,6 +220,10 @@ func getCostData(request *http.Request, a routes.Arguments) (int, interface{}) {
 	}
 	parsedParams.AccountList = accountsAndIndexes.Accounts
 	parsedParams.IndexList = accountsAndIndexes.Indexes
+	if parsedParams.Conversion, err = currency.ForUser(tx, user); err != nil {
+		jsonlog.LoggerFromContextOrDefault(request.Context()).Error("Failed to get exchange rates.", err.Error())
+		return http.StatusInternalServerError, fmt.Errorf("could not get exchange rates")
+	}
 	simplifiedCostDocument, returnCode, err := MakeElasticSearchRequestAndParseIt(request.Context(), parsedParams)
 	if err != nil {
 		if returnCode == http.StatusOK {
@@ -0,0 +1,250 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package credits reports the AWS credits applied to the bills of a user
+// month by month, and how much is left of the promotional credits they were
+// granted.
+package credits
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/users"
+)
+
+const (
+	// dateFormat is the format of the dates of promotional credits.
+	dateFormat = "2006-01-02"
+	// monthFormat is the format of the months of a credits report.
+	monthFormat = "2006-01"
+)
+
+var (
+	ErrInvalidAmount             = errors.New("amount must be positive")
+	ErrInvalidDates              = errors.New("expiration must not be before begin")
+	ErrPromotionalCreditNotFound = errors.New("promotional credit not found")
+)
+
+type (
+	// PromotionalCredit is an amount of credits granted by AWS, usable on
+	// the bills from Begin to Expiration included. Amount is expressed in
+	// Currency, the display currency of the user when it was created.
+	PromotionalCredit struct {
+		Id         int     `json:"id"`
+		Name       string  `json:"name"`
+		Amount     float64 `json:"amount"`
+		Currency   string  `json:"currency"`
+		Begin      string  `json:"begin"`
+		Expiration string  `json:"expiration"`
+	}
+
+	// PromotionalCreditStatus is the state of a promotional credit at the
+	// end of a credits report.
+	PromotionalCreditStatus struct {
+		PromotionalCredit
+		Used      float64 `json:"used"`
+		Remaining float64 `json:"remaining"`
+		Expired   bool    `json:"expired"`
+	}
+
+	// MonthlyCredits is the amount of credits applied to the bills of a
+	// month, and the amount of promotional credits remaining at its end.
+	MonthlyCredits struct {
+		Month     string  `json:"month"`
+		Applied   float64 `json:"applied"`
+		Remaining float64 `json:"remaining"`
+	}
+
+	// CreditsReport is the response of the credits route.
+	CreditsReport struct {
+		Months             []MonthlyCredits          `json:"months"`
+		PromotionalCredits []PromotionalCreditStatus `json:"promotionalCredits"`
+	}
+)
+
+// validate checks a promotional credit is consistent before it is saved.
+func (pc PromotionalCredit) validate() error {
+	begin, err := time.Parse(dateFormat, pc.Begin)
+	if err != nil {
+		return err
+	}
+	expiration, err := time.Parse(dateFormat, pc.Expiration)
+	if err != nil {
+		return err
+	}
+	if pc.Amount <= 0 {
+		return ErrInvalidAmount
+	} else if expiration.Before(begin) {
+		return ErrInvalidDates
+	}
+	return nil
+}
+
+// promotionalCreditFromDbPromotionalCredit builds a PromotionalCredit from
+// its database representation.
+func promotionalCreditFromDbPromotionalCredit(dbCredit models.PromotionalCredit) PromotionalCredit {
+	return PromotionalCredit{
+		Id:         dbCredit.ID,
+		Name:       dbCredit.Name,
+		Amount:     dbCredit.Amount,
+		Currency:   dbCredit.Currency,
+		Begin:      dbCredit.Begin.Format(dateFormat),
+		Expiration: dbCredit.Expiration.Format(dateFormat),
+	}
+}
+
+// GetPromotionalCreditsForUser retrieves all the promotional credits of a
+// user, ordered by expiration.
+func GetPromotionalCreditsForUser(tx *sql.Tx, user users.User) ([]PromotionalCredit, error) {
+	dbCredits, err := models.PromotionalCreditsByUserID(tx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	credits := make([]PromotionalCredit, len(dbCredits))
+	for i, dbCredit := range dbCredits {
+		credits[i] = promotionalCreditFromDbPromotionalCredit(*dbCredit)
+	}
+	sort.SliceStable(credits, func(i, j int) bool {
+		return credits[i].Expiration < credits[j].Expiration
+	})
+	return credits, nil
+}
+
+// CreatePromotionalCredit saves a new promotional credit for a user.
+func CreatePromotionalCredit(tx *sql.Tx, user users.User, credit PromotionalCredit) (PromotionalCredit, error) {
+	begin, _ := time.Parse(dateFormat, credit.Begin)
+	expiration, _ := time.Parse(dateFormat, credit.Expiration)
+	dbCredit := models.PromotionalCredit{
+		UserID:     user.Id,
+		Name:       credit.Name,
+		Amount:     credit.Amount,
+		Currency:   credit.Currency,
+		Begin:      begin,
+		Expiration: expiration,
+	}
+	if err := dbCredit.Insert(tx); err != nil {
+		return credit, err
+	}
+	return promotionalCreditFromDbPromotionalCredit(dbCredit), nil
+}
+
+// convertPromotionalCredits converts the amounts of promotional credits to
+// the target currency of a conversion, at the date each of them begins.
+func convertPromotionalCredits(conversion currency.Conversion, credits []PromotionalCredit) ([]PromotionalCredit, error) {
+	converted := make([]PromotionalCredit, len(credits))
+	for i, credit := range credits {
+		begin, err := time.Parse(dateFormat, credit.Begin)
+		if err != nil {
+			return nil, err
+		} else if credit.Amount, err = conversion.Convert(credit.Amount, credit.Currency, begin); err != nil {
+			return nil, err
+		}
+		credit.Currency = conversion.Target
+		converted[i] = credit
+	}
+	return converted, nil
+}
+
+// DeletePromotionalCredit deletes a promotional credit of a user.
+func DeletePromotionalCredit(tx *sql.Tx, user users.User, creditId int) error {
+	dbCredit, err := models.PromotionalCreditByID(tx, creditId)
+	if err == sql.ErrNoRows || (err == nil && dbCredit.UserID != user.Id) {
+		return ErrPromotionalCreditNotFound
+	} else if err != nil {
+		return err
+	}
+	return dbCredit.Delete(tx)
+}
+
+// buildReport simulates the burn-down of promotional credits from the
+// credits applied each month, keyed by month. Applied credits are taken
+// from the active promotional credit expiring first. What is left of a
+// promotional credit when it expires is lost. Only the months between begin
+// and end are reported, but the burn-down starts with the earliest
+// promotional credit so that the remaining amounts account for the months
+// before begin.
+func buildReport(applied map[string]float64, credits []PromotionalCredit, begin, end time.Time) CreditsReport {
+	report := CreditsReport{
+		Months:             []MonthlyCredits{},
+		PromotionalCredits: make([]PromotionalCreditStatus, len(credits)),
+	}
+	begins := make([]time.Time, len(credits))
+	expirations := make([]time.Time, len(credits))
+	month := monthOf(begin)
+	for i, credit := range credits {
+		report.PromotionalCredits[i] = PromotionalCreditStatus{PromotionalCredit: credit, Remaining: credit.Amount}
+		begins[i], _ = time.Parse(dateFormat, credit.Begin)
+		expirations[i], _ = time.Parse(dateFormat, credit.Expiration)
+		if begins[i].Before(month) {
+			month = monthOf(begins[i])
+		}
+	}
+	order := make([]int, len(credits))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return expirations[order[i]].Before(expirations[order[j]])
+	})
+	for ; !month.After(end); month = month.AddDate(0, 1, 0) {
+		monthEnd := month.AddDate(0, 1, -1)
+		toApply := applied[month.Format(monthFormat)]
+		remaining := 0.0
+		for _, i := range order {
+			status := &report.PromotionalCredits[i]
+			if begins[i].After(monthEnd) || expirations[i].Before(month) {
+				continue
+			}
+			used := toApply
+			if used > status.Remaining {
+				used = status.Remaining
+			}
+			status.Used += used
+			status.Remaining -= used
+			toApply -= used
+			if expirations[i].After(monthEnd) {
+				remaining += status.Remaining
+			}
+		}
+		for _, i := range order {
+			if begins[i].After(monthEnd) {
+				remaining += report.PromotionalCredits[i].Remaining
+			}
+		}
+		if !month.Before(monthOf(begin)) {
+			report.Months = append(report.Months, MonthlyCredits{
+				Month:     month.Format(monthFormat),
+				Applied:   applied[month.Format(monthFormat)],
+				Remaining: remaining,
+			})
+		}
+	}
+	for i := range report.PromotionalCredits {
+		if expirations[i].Before(end) {
+			report.PromotionalCredits[i].Expired = true
+		}
+	}
+	return report
+}
+
+// monthOf returns the first day of the month containing date.
+func monthOf(date time.Time) time.Time {
+	date = date.UTC()
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
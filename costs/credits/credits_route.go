@@ -0,0 +1,257 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package credits
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/costs"
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+)
+
+type (
+	// PromotionalCreditBody is the body required by postPromotionalCredit.
+	PromotionalCreditBody struct {
+		Name       string  `json:"name" req:"nonzero"`
+		Amount     float64 `json:"amount"`
+		Begin      string  `json:"begin" req:"nonzero"`
+		Expiration string  `json:"expiration" req:"nonzero"`
+	}
+)
+
+// promotionalCreditIdQueryArg allows to get the ID of a promotional credit
+// in the URL parameters.
+var promotionalCreditIdQueryArg = routes.QueryArg{
+	Name:        "promotional-credit-id",
+	Type:        routes.QueryArgInt{},
+	Description: "The ID of the promotional credit.",
+}
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getCredits).With(
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.QueryArgs{
+				routes.AwsAccountsOptionalQueryArg,
+				routes.DateBeginQueryArg,
+				routes.DateEndQueryArg,
+			},
+			routes.Documentation{
+				Summary:     "get the credits",
+				Description: "Responds with the credits applied to the bills each month between begin and end, and the burn-down of the user's promotional credits: the amount remaining at the end of each month and the amount used of each promotional credit. Amounts are converted to the user's display currency.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		routes.Documentation{
+			Summary:     "view the credits",
+			Description: "Credits are the line items of type Credit. Each month, they are taken from the promotional credits active during that month, the ones expiring first being used first.",
+		},
+	).Register("/costs/credits")
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getPromotionalCredits).With(
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.Documentation{
+				Summary:     "get the promotional credits",
+				Description: "Responds with the promotional credits of the user, ordered by expiration, each with the currency of its amount.",
+			},
+		),
+		http.MethodPost: routes.H(postPromotionalCredit).With(
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{PromotionalCreditBody{
+				Name:       "Activate",
+				Amount:     5000,
+				Begin:      "2018-01-01",
+				Expiration: "2018-12-31",
+			}},
+			routes.Documentation{
+				Summary:     "create a promotional credit",
+				Description: "Creates a promotional credit. The amount is expressed in the user's display currency. Dates are formatted as YYYY-MM-DD and the expiration date is included.",
+			},
+		),
+		http.MethodDelete: routes.H(deletePromotionalCredit).With(
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.QueryArgs{promotionalCreditIdQueryArg},
+			routes.Documentation{
+				Summary:     "delete a promotional credit",
+				Description: "Deletes a promotional credit.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		routes.Documentation{
+			Summary:     "interact with the promotional credits",
+			Description: "A promotional credit is an amount of credits granted by AWS, usable on the bills of a period of time.",
+		},
+	).Register("/costs/credits/promotional")
+}
+
+// getCredits is a route handler which returns the credits applied to the
+// caller's bills and the burn-down of their promotional credits.
+func getCredits(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	accounts := []string{}
+	if a[routes.AwsAccountsOptionalQueryArg] != nil {
+		accounts = a[routes.AwsAccountsOptionalQueryArg].([]string)
+	}
+	begin := a[routes.DateBeginQueryArg].(time.Time)
+	end := a[routes.DateEndQueryArg].(time.Time).Add(24*time.Hour - time.Second)
+	accountsAndIndexes, returnCode, err := es.GetAccountsAndIndexes(accounts, user, tx, s3.IndexPrefixLineItem)
+	if err != nil {
+		return returnCode, err
+	}
+	credits, err := GetPromotionalCreditsForUser(tx, user)
+	if err != nil {
+		l.Error("Failed to get promotional credits.", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to retrieve credits.")
+	}
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		l.Error("Failed to get exchange rates.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to retrieve credits.")
+	}
+	if credits, err = convertPromotionalCredits(conversion, credits); err != nil {
+		l.Error("Failed to convert promotional credits.", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to retrieve credits.")
+	}
+	historyBegin := begin
+	for _, credit := range credits {
+		if creditBegin, _ := time.Parse(dateFormat, credit.Begin); creditBegin.Before(historyBegin) {
+			historyBegin = creditBegin
+		}
+	}
+	params := costs.EsQueryParams{
+		DateBegin:         monthOf(historyBegin),
+		DateEnd:           end,
+		AccountList:       accountsAndIndexes.Accounts,
+		IndexList:         accountsAndIndexes.Indexes,
+		AggregationParams: []string{"month"},
+		LineItemTypes:     s3.LineItemTypeFilter{Include: []string{s3.LineItemTypeCredit}},
+		Conversion:        conversion,
+	}
+	applied, returnCode, err := getAppliedCredits(r.Context(), params)
+	if err != nil {
+		return returnCode, err
+	}
+	return http.StatusOK, buildReport(applied, credits, begin, end)
+}
+
+// getAppliedCredits returns the amount of credits applied each month, keyed
+// by month. Credits are negative costs, hence the sign change.
+func getAppliedCredits(ctx context.Context, params costs.EsQueryParams) (map[string]float64, int, error) {
+	applied := make(map[string]float64)
+	doc, returnCode, err := costs.MakeElasticSearchRequestAndParseIt(ctx, params)
+	if err != nil {
+		if returnCode == http.StatusOK {
+			return applied, returnCode, nil
+		}
+		return nil, returnCode, err
+	}
+	for _, child := range doc.Children {
+		if len(child.Key) < len(monthFormat) {
+			return nil, http.StatusInternalServerError, fmt.Errorf("could not parse month %q", child.Key)
+		}
+		applied[child.Key[:len(monthFormat)]] -= child.Value
+	}
+	return applied, http.StatusOK, nil
+}
+
+// getPromotionalCredits is a route handler which returns the caller's
+// promotional credits.
+func getPromotionalCredits(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	credits, err := GetPromotionalCreditsForUser(tx, user)
+	if err != nil {
+		l.Error("Failed to get promotional credits.", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to retrieve promotional credits.")
+	}
+	return http.StatusOK, credits
+}
+
+// postPromotionalCredit is a route handler which lets the user create a
+// promotional credit.
+func postPromotionalCredit(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body PromotionalCreditBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	credit := PromotionalCredit{
+		Name:       body.Name,
+		Amount:     body.Amount,
+		Currency:   user.Currency,
+		Begin:      body.Begin,
+		Expiration: body.Expiration,
+	}
+	if err := credit.validate(); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Body is invalid (%s).", err.Error())
+	}
+	credit, err := CreatePromotionalCredit(tx, user, credit)
+	if err != nil {
+		l.Error("Failed to create promotional credit.", map[string]interface{}{
+			"userId":            user.Id,
+			"promotionalCredit": credit,
+			"error":             err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to create promotional credit.")
+	}
+	return http.StatusOK, credit
+}
+
+// deletePromotionalCredit is a route handler which lets the user delete a
+// promotional credit.
+func deletePromotionalCredit(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[users.AuthenticatedUser].(users.User)
+	creditId := a[promotionalCreditIdQueryArg].(int)
+	if err := DeletePromotionalCredit(tx, user, creditId); err == ErrPromotionalCreditNotFound {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		l.Error("Failed to delete promotional credit.", map[string]interface{}{
+			"userId":              user.Id,
+			"promotionalCreditId": creditId,
+			"error":               err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to delete promotional credit.")
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,110 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package credits
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/trackit/trackit-server/currency"
+)
+
+func TestValidatePromotionalCredit(t *testing.T) {
+	credit := PromotionalCredit{Name: "test", Amount: 100, Begin: "2018-01-01", Expiration: "2018-12-31"}
+	if err := credit.validate(); err != nil {
+		t.Errorf("Unexpected error %s", err.Error())
+	}
+	credit.Amount = 0
+	if err := credit.validate(); err != ErrInvalidAmount {
+		t.Errorf("Expected ErrInvalidAmount, got %v", err)
+	}
+	credit.Amount = 100
+	credit.Expiration = "2017-12-31"
+	if err := credit.validate(); err != ErrInvalidDates {
+		t.Errorf("Expected ErrInvalidDates, got %v", err)
+	}
+	credit.Expiration = "31/12/2018"
+	if err := credit.validate(); err == nil {
+		t.Errorf("Expected an error for a malformed date")
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	credits := []PromotionalCredit{
+		{Id: 1, Amount: 100, Begin: "2018-01-01", Expiration: "2018-02-28"},
+		{Id: 2, Amount: 200, Begin: "2018-01-15", Expiration: "2018-12-31"},
+	}
+	applied := map[string]float64{
+		"2018-01": 30,
+		"2018-02": 120,
+		"2018-03": 50,
+	}
+	begin := time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2018, time.March, 31, 23, 59, 59, 0, time.UTC)
+	report := buildReport(applied, credits, begin, end)
+	expectedMonths := []MonthlyCredits{
+		{Month: "2018-02", Applied: 120, Remaining: 150},
+		{Month: "2018-03", Applied: 50, Remaining: 100},
+	}
+	if !reflect.DeepEqual(report.Months, expectedMonths) {
+		t.Errorf("Unexpected months %v", report.Months)
+	}
+	expectedCredits := []PromotionalCreditStatus{
+		{PromotionalCredit: credits[0], Used: 100, Remaining: 0, Expired: true},
+		{PromotionalCredit: credits[1], Used: 100, Remaining: 100, Expired: false},
+	}
+	if !reflect.DeepEqual(report.PromotionalCredits, expectedCredits) {
+		t.Errorf("Unexpected promotional credits %v", report.PromotionalCredits)
+	}
+}
+
+func TestBuildReportWithoutPromotionalCredits(t *testing.T) {
+	applied := map[string]float64{"2018-01": 10}
+	begin := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2018, time.January, 31, 0, 0, 0, 0, time.UTC)
+	report := buildReport(applied, nil, begin, end)
+	expectedMonths := []MonthlyCredits{{Month: "2018-01", Applied: 10, Remaining: 0}}
+	if !reflect.DeepEqual(report.Months, expectedMonths) {
+		t.Errorf("Unexpected months %v", report.Months)
+	}
+	if len(report.PromotionalCredits) != 0 {
+		t.Errorf("Unexpected promotional credits %v", report.PromotionalCredits)
+	}
+}
+
+func TestConvertPromotionalCredits(t *testing.T) {
+	rates := make(currency.Rates)
+	rates.Add("EUR", currency.Rate{Date: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC), Rate: 1.2})
+	rates.Add("EUR", currency.Rate{Date: time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC), Rate: 1.25})
+	credits := []PromotionalCredit{
+		{Id: 1, Amount: 120, Currency: "USD", Begin: "2018-01-15", Expiration: "2018-12-31"},
+		{Id: 2, Amount: 100, Currency: "EUR", Begin: "2018-02-15", Expiration: "2018-12-31"},
+	}
+	converted, err := convertPromotionalCredits(currency.Conversion{Target: "EUR", Rates: rates}, credits)
+	if err != nil {
+		t.Fatalf("Unexpected error %s", err.Error())
+	}
+	expected := []PromotionalCredit{
+		{Id: 1, Amount: 100, Currency: "EUR", Begin: "2018-01-15", Expiration: "2018-12-31"},
+		{Id: 2, Amount: 100, Currency: "EUR", Begin: "2018-02-15", Expiration: "2018-12-31"},
+	}
+	if !reflect.DeepEqual(converted, expected) {
+		t.Errorf("Unexpected promotional credits %v", converted)
+	}
+	if credits[0].Currency != "USD" {
+		t.Errorf("The promotional credits converted should not be modified")
+	}
+}
@@ -36,21 +36,39 @@ type PricePoint struct {
 
 type costDiff map[string][]PricePoint
 
-// ToCSVable generates the CSV content from a costDiff
-func (cd costDiff) ToCSVable() [][]string {
-	csv := [][]string{}
-	keys := []string{}
-	for k, _ := range cd {
+// sortedUsageTypes returns the usage types of a costDiff in alphabetical
+// order.
+func (cd costDiff) sortedUsageTypes() []string {
+	keys := make([]string, 0, len(cd))
+	for k := range cd {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
-	for _, usageTypeName := range keys {
-		if len(csv) == 0 {
+	return keys
+}
+
+// ToCSVable generates the CSV content from a costDiff
+func (cd costDiff) ToCSVable() [][]string {
+	csv := [][]string{}
+	cd.StreamCSV(func(row []string) error {
+		csv = append(csv, row)
+		return nil
+	})
+	return csv
+}
+
+// StreamCSV writes a costDiff as CSV, one usage type per row, without
+// building the whole CSV content first.
+func (cd costDiff) StreamCSV(write func([]string) error) error {
+	for i, usageTypeName := range cd.sortedUsageTypes() {
+		if i == 0 {
 			header := []string{"usageType"}
 			for _, costEntry := range cd[usageTypeName] {
 				header = append(header, fmt.Sprintf("cost-%s", costEntry.Date), fmt.Sprintf("variation-%s", costEntry.Date))
 			}
-			csv = append(csv, header)
+			if err := write(header); err != nil {
+				return err
+			}
 		}
 		row := []string{usageTypeName}
 		for _, costEntry := range cd[usageTypeName] {
@@ -62,9 +80,26 @@ func (cd costDiff) ToCSVable() [][]string {
 			}
 			row = append(row, strconv.FormatFloat(costEntry.Cost, 'f', -1, 64), variationStr)
 		}
-		csv = append(csv, row)
+		if err := write(row); err != nil {
+			return err
+		}
 	}
-	return csv
+	return nil
+}
+
+// StreamNDJSON writes a costDiff as newline delimited JSON, one usage type
+// with its price points per line.
+func (cd costDiff) StreamNDJSON(write func(interface{}) error) error {
+	for _, usageTypeName := range cd.sortedUsageTypes() {
+		err := write(struct {
+			UsageType   string       `json:"usageType"`
+			PricePoints []PricePoint `json:"pricePoints"`
+		}{usageTypeName, cd[usageTypeName]})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // getVariations compute the percentage of variation between each pair of consecutive
@@ -28,6 +28,7 @@ import (
 	"github.com/trackit/trackit-server/aws"
 	"github.com/trackit/trackit-server/aws/s3"
 	"github.com/trackit/trackit-server/aws/usageReports/history"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/errors"
 	"github.com/trackit/trackit-server/es"
@@ -51,6 +52,9 @@ type esQueryParams struct {
 	accountList       []string
 	indexList         []string
 	aggregationPeriod string
+	lineItemTypes     s3.LineItemTypeFilter
+	costField         string
+	conversion        currency.Conversion
 }
 
 // diffQueryArgs allows to get required queryArgs params
@@ -64,6 +68,9 @@ var diffQueryArgs = []routes.QueryArg{
 		Type:        routes.QueryArgString{},
 		Optional:    false,
 	},
+	routes.CostTypeOptionalQueryArg,
+	routes.LineItemTypesIncludeOptionalQueryArg,
+	routes.LineItemTypesExcludeOptionalQueryArg,
 }
 
 func init() {
@@ -96,8 +103,11 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams esQueryParams) (
 		parsedParams.aggregationPeriod,
 		es.Client,
 		index,
+		parsedParams.lineItemTypes,
+		parsedParams.costField,
+		parsedParams.conversion,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "costs/diff", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -158,6 +168,7 @@ func TaskDiffData(ctx context.Context, aa aws.AwsAccount, date time.Time) (data
 		dateBegin:         dateBegin,
 		dateEnd:           dateEnd,
 		aggregationPeriod: "day",
+		costField:         "unblendedCost",
 	}
 	var tx *sql.Tx
 	if tx, err = db.Db.BeginTx(ctx, nil); err != nil {
@@ -173,6 +184,9 @@ func TaskDiffData(ctx context.Context, aa aws.AwsAccount, date time.Time) (data
 	}
 	parsedParams.accountList = accountsAndIndexes.Accounts
 	parsedParams.indexList = accountsAndIndexes.Indexes
+	if parsedParams.conversion, err = currency.ForUser(tx, user); err != nil {
+		return costDiff{}, err
+	}
 	_, diffData := getDiffData(ctx, parsedParams)
 	return convertDiffData(ctx, diffData)
 }
@@ -191,6 +205,18 @@ func prepareGetDiffData(request *http.Request, a routes.Arguments) (int, interfa
 	if _, ok := validAggregationPeriodMap[parsedParams.aggregationPeriod]; ok == false {
 		return http.StatusBadRequest, fmt.Errorf("invalid aggregation period : %s", parsedParams.aggregationPeriod)
 	}
+	var costType string
+	if a[diffQueryArgs[4]] != nil {
+		costType = a[diffQueryArgs[4]].(string)
+	}
+	costField, err := s3.CostTypeField(costType)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	parsedParams.costField = costField
+	if parsedParams.lineItemTypes, err = s3.LineItemTypeFilterFromArguments(a); err != nil {
+		return http.StatusBadRequest, err
+	}
 	tx := a[db.Transaction].(*sql.Tx)
 	accountsAndIndexes, returnCode, err := es.GetAccountsAndIndexes(parsedParams.accountList, user, tx, s3.IndexPrefixLineItem)
 	if err != nil {
@@ -198,5 +224,9 @@ func prepareGetDiffData(request *http.Request, a routes.Arguments) (int, interfa
 	}
 	parsedParams.accountList = accountsAndIndexes.Accounts
 	parsedParams.indexList = accountsAndIndexes.Indexes
+	if parsedParams.conversion, err = currency.ForUser(tx, user); err != nil {
+		jsonlog.LoggerFromContextOrDefault(request.Context()).Error("Failed to get exchange rates.", err.Error())
+		return http.StatusInternalServerError, fmt.Errorf("could not get exchange rates")
+	}
 	return getDiffData(request.Context(), parsedParams)
 }
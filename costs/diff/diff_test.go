@@ -0,0 +1,50 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCostDiffToCSVable(t *testing.T) {
+	cd := costDiff{
+		"b": {{"2018-01", 10, 0}, {"2018-02", 5, -50}},
+		"a": {{"2018-01", 1, 0}, {"2018-02", 2, 100}},
+	}
+	expected := [][]string{
+		{"usageType", "cost-2018-01", "variation-2018-01", "cost-2018-02", "variation-2018-02"},
+		{"a", "1", "0.000%", "2", "+100.000%"},
+		{"b", "10", "0.000%", "5", "-50.000%"},
+	}
+	if csv := cd.ToCSVable(); !reflect.DeepEqual(csv, expected) {
+		t.Errorf("CSV should be %v, is %v.", expected, csv)
+	}
+}
+
+func TestCostDiffStreamNDJSON(t *testing.T) {
+	cd := costDiff{
+		"b": {{"2018-01", 10, 0}},
+		"a": {{"2018-01", 1, 0}},
+	}
+	var usageTypes []string
+	cd.StreamNDJSON(func(v interface{}) error {
+		usageTypes = append(usageTypes, reflect.ValueOf(v).Field(0).String())
+		return nil
+	})
+	if !reflect.DeepEqual(usageTypes, []string{"a", "b"}) {
+		t.Errorf("Usage types should be streamed in order, were %v.", usageTypes)
+	}
+}
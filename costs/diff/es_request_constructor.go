@@ -18,6 +18,9 @@ import (
 	"time"
 
 	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/currency"
 )
 
 // aggregationMaxSize is the maximum size of an Elastic Search Aggregation
@@ -49,21 +52,27 @@ func createQueryTimeRange(durationBegin time.Time, durationEnd time.Time) *elast
 //	- client *elastic.Client : an instance of *elastic.Client that represent an Elastic Search client.
 //	- index string : The Elastic Search index on wich to execute the query. In this context the default value
 //	should be "awsdetailedlineitems"
+//	- typeFilter s3.LineItemTypeFilter : The types of the line items to take into account
+//	- costField string : The line item field holding the cost metric to sum, e.g. "unblendedCost"
+//	- conversion currency.Conversion : The conversion of the costs to the currency they are returned in
 // This function excepts arguments passed to it to be sanitize. If they are not, the following cases will make
 // it crash :
 //	- If the client is nil or malconfigured, it will crash
 //	- If the index is not an index present in the ES, it will crash
 func GetElasticSearchParams(accountList []string, durationBegin time.Time,
-	durationEnd time.Time, aggregationPeriod string, client *elastic.Client, index string) *elastic.SearchService {
+	durationEnd time.Time, aggregationPeriod string, client *elastic.Client, index string, typeFilter s3.LineItemTypeFilter, costField string, conversion currency.Conversion) *elastic.SearchService {
 	query := elastic.NewBoolQuery()
 	if len(accountList) > 0 {
 		query = query.Filter(createQueryAccountFilter(accountList))
 	}
 	query = query.Filter(createQueryTimeRange(durationBegin, durationEnd))
+	if typeQuery := typeFilter.Query(); typeQuery != nil {
+		query = query.Filter(typeQuery)
+	}
 	search := client.Search().Index(index).Size(0).Query(query)
 
 	search.Aggregation("usageType", elastic.NewTermsAggregation().Field("usageType").Size(aggregationMaxSize).
 		SubAggregation("dateAgg", elastic.NewDateHistogramAggregation().Field("usageStartDate").MinDocCount(0).ExtendedBounds(durationBegin, durationEnd).Interval(aggregationPeriod).
-			SubAggregation("cost", elastic.NewSumAggregation().Field("unblendedCost"))))
+			SubAggregation("cost", conversion.SumAggregation(costField))))
 	return search
 }
@@ -16,11 +16,14 @@
 package costs
 
 import (
-	"fmt"
 	"strings"
 	"time"
 
 	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/es"
 )
 
 // aggregationBuilder is an alias for the function type that is used in the
@@ -38,8 +41,8 @@ var paramNameToFuncPtr = map[string]aggregationBuilder{
 	"availabilityzone": createAggregationPerAvailabilityZone,
 	"region":           createAggregationPerRegion,
 	"account":          createAggregationPerAccount,
+	"lineitemtype":     createAggregationPerLineItemType,
 	"tag":              createAggregationPerTag,
-	"cost":             createCostSumAggregation,
 	"day":              createAggregationPerDay,
 	"week":             createAggregationPerWeek,
 	"month":            createAggregationPerMonth,
@@ -122,6 +125,18 @@ func createAggregationPerAccount(_ []string) []paramAggrAndName {
 	}
 }
 
+// createAggregationPerLineItemType creates and returns a new []paramAggrAndName of size 1 which creates a
+// bucket aggregation on the field 'lineItemType', separating usage from credits, refunds, taxes and fees
+func createAggregationPerLineItemType(_ []string) []paramAggrAndName {
+	return []paramAggrAndName{
+		paramAggrAndName{
+			name: "by-lineitemtype",
+			aggr: elastic.NewTermsAggregation().
+				Field("lineItemType").Size(aggregationMaxSize),
+		},
+	}
+}
+
 // createAggregationPerDay creates and returns a new []paramAggrAndName of size 1 which creates a
 // date histogram aggregation on the field 'usage_start_date' with a time range of a day
 func createAggregationPerDay(_ []string) []paramAggrAndName {
@@ -170,33 +185,71 @@ func createAggregationPerYear(_ []string) []paramAggrAndName {
 	}
 }
 
-// createAggregationPerTag creates and returns a new []paramAggrAndName of size 2 which consits
-// of two aggregations that are required for the tag param.
-// The first aggregation is a FilterAggregation on the field 'tag.key', and with a value of
-// the tag key passed in the parameter 'paramSplit' in the form "user:<TAG_KEY_VALUE>".
-// The second aggregation is a TermsAggregation that creates bucket aggregation on the field
-// 'tag.value'.
-// No SubAggregation is created in this function, as it needs to be created in the nestAggregation function
+// tagAggregation is the aggregation created for the 'tag:<TAG_KEY>' param.
+// Tags are nested documents, so the costs can not be broken down by a simple
+// TermsAggregation on their value. Instead, a FiltersAggregation splits the
+// documents having the tag from the others, with one filter for each. The
+// documents having the tag are then broken down by tag value through a
+// NestedAggregation, and brought back to the line item level through a
+// ReverseNestedAggregation before applying the SubAggregation. The layout of
+// the result is described in the es package.
+type tagAggregation struct {
+	key            string
+	subAggregation *paramAggrAndName
+}
+
+// newTagAggregation returns a new tagAggregation on the tag key passed to it.
+func newTagAggregation(key string) *tagAggregation {
+	return &tagAggregation{key: key}
+}
+
+// SubAggregation sets the aggregation applied to each tag value, as well as
+// to the documents not having the tag.
+func (a *tagAggregation) SubAggregation(name string, subAggregation elastic.Aggregation) *tagAggregation {
+	a.subAggregation = &paramAggrAndName{name: name, aggr: subAggregation}
+	return a
+}
+
+// Source returns the JSON-serializable data of the aggregation.
+func (a *tagAggregation) Source() (interface{}, error) {
+	hasTag := elastic.NewNestedQuery("tags", elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("tags.key", a.key)).
+		MustNot(elastic.NewTermQuery("tags.tag", "")))
+	reverseNested := elastic.NewReverseNestedAggregation()
+	filters := elastic.NewFiltersAggregation().
+		FilterWithName(es.TagTaggedBucketKey, hasTag).
+		FilterWithName(es.TagUntaggedBucketKey, elastic.NewBoolQuery().MustNot(hasTag))
+	if a.subAggregation != nil {
+		reverseNested = reverseNested.SubAggregation(a.subAggregation.name, a.subAggregation.aggr)
+		filters = filters.SubAggregation(a.subAggregation.name, a.subAggregation.aggr)
+	}
+	tagValues := elastic.NewNestedAggregation().Path("tags").
+		SubAggregation(es.TagKeyKey, elastic.NewFilterAggregation().
+			Filter(elastic.NewTermQuery("tags.key", a.key)).
+			SubAggregation(es.TagValueKey, elastic.NewTermsAggregation().
+				Field("tags.tag").Size(aggregationMaxSize).
+				SubAggregation(es.TagReverseNestedKey, reverseNested)))
+	return filters.SubAggregation(es.TagNestedKey, tagValues).Source()
+}
+
+// createAggregationPerTag creates and returns a new []paramAggrAndName of size 1 which creates a
+// tagAggregation on the tag key passed in the parameter 'paramSplit'.
 func createAggregationPerTag(paramSplit []string) []paramAggrAndName {
 	return []paramAggrAndName{
 		paramAggrAndName{
-			name: "by-tag_key",
-			aggr: elastic.NewFilterAggregation().
-				Filter(elastic.NewTermQuery("tag.key", fmt.Sprintf("user:%v", paramSplit[1])))},
-		paramAggrAndName{
-			name: "tag_value",
-			aggr: elastic.NewTermsAggregation().
-				Field("tag.value").Size(aggregationMaxSize)},
+			name: es.BucketPrefix + es.TagChildrenKind,
+			aggr: newTagAggregation(paramSplit[1]),
+		},
 	}
 }
 
 // createCostSumAggregation : Creates and return a new []paramAggrAndName of size 1, which creates a
-// SumAggregation on the field 'cost'
-func createCostSumAggregation(_ []string) []paramAggrAndName {
+// SumAggregation on the cost field, converted by the conversion
+func createCostSumAggregation(costField string, conversion currency.Conversion) []paramAggrAndName {
 	return []paramAggrAndName{
 		paramAggrAndName{
 			name: "value",
-			aggr: elastic.NewSumAggregation().Field("unblendedCost"),
+			aggr: conversion.SumAggregation(costField),
 		},
 	}
 }
@@ -213,8 +266,8 @@ func reverseAggregationArray(aggregationArray []paramAggrAndName) []paramAggrAnd
 // nestAggregation takes a slice of paramAggrAndName type, and will nest the different aggregations.
 // Aggregations are nested by creating a chain of SubAggregation
 // A type switch is required to simulate downcasting from the interface elastic.Aggregation.
-// Current types on the type switch are TermsAggregation, FilterAggregation, SumAggregation and
-// DateHistogramAggregation.
+// Current types on the type switch are TermsAggregation, FilterAggregation, DateHistogramAggregation
+// and tagAggregation.
 // If a new function creating a type that is not listed here is added to the paramNameToFuncPtr map
 // it should be added to the type switch, or the function will create bugged SubAggregations
 func nestAggregation(allAggrSlice []paramAggrAndName) elastic.Aggregation {
@@ -231,11 +284,28 @@ func nestAggregation(allAggrSlice []paramAggrAndName) elastic.Aggregation {
 		case *elastic.DateHistogramAggregation:
 			aggrBuff := assertedBaseAggr.SubAggregation(aggrToNest.name, aggrToNest.aggr)
 			aggrToNest = paramAggrAndName{name: baseAggr.name, aggr: aggrBuff}
+		case *tagAggregation:
+			aggrBuff := assertedBaseAggr.SubAggregation(aggrToNest.name, aggrToNest.aggr)
+			aggrToNest = paramAggrAndName{name: baseAggr.name, aggr: aggrBuff}
 		}
 	}
 	return aggrToNest.aggr
 }
 
+// createLineItemsQuery returns the query matching the line items of the
+// accounts, of the types and in the time range the costs are computed from.
+func createLineItemsQuery(accountList []string, durationBegin time.Time, durationEnd time.Time, typeFilter s3.LineItemTypeFilter) *elastic.BoolQuery {
+	query := elastic.NewBoolQuery()
+	if len(accountList) > 0 {
+		query = query.Filter(createQueryAccountFilter(accountList))
+	}
+	query = query.Filter(createQueryTimeRange(durationBegin, durationEnd))
+	if typeQuery := typeFilter.Query(); typeQuery != nil {
+		query = query.Filter(typeQuery)
+	}
+	return query
+}
+
 // GetElasticSearchParams is used to construct an ElasticSearch *elastic.SearchService used to perform a request on ES
 // It takes as paramters :
 // 	- accountList []string : A slice of strings representing aws account number, in the format of the field
@@ -249,15 +319,18 @@ func nestAggregation(allAggrSlice []paramAggrAndName) elastic.Aggregation {
 //		- "availabilityzone" : It will create a TermsAggregation on the field 'availability_zone'
 //		- "region" : It will create a TermsAggregation on the field 'region'
 //		- "account" : It will create a TermsAggregation on the field 'linked_account_id'
-//		- "tag:<TAG_KEY>" : It will create a FilterAggregation on the field 'tag.key',
-//		filtering on the value 'user:<TAG_KEY>'.
-//		It will then create a TermsAggregation on the field 'tag.value'
+//		- "lineitemtype" : It will create a TermsAggregation on the field 'lineItemType'
+//		- "tag:<TAG_KEY>" : It will create a tagAggregation, breaking the costs down by the values
+//		of the tag '<TAG_KEY>', with a separate bucket for the costs without this tag
 //		- "[day|week|month|year]": It will create a DateHistogramAggregation on the specified duration on
 //		the field 'usage_start_date'
 //	- client *elastic.Client : an instance of *elastic.Client that represent an Elastic Search client.
 //	It needs to be fully configured and ready to execute a client.Search()
 //	- index string : The Elastic Search index on wich to execute the query. In this context the default value
 //	should be "awsdetailedlineitems"
+//	- typeFilter s3.LineItemTypeFilter : The types of the line items to take into account
+//	- costField string : The line item field holding the cost metric to sum, e.g. "unblendedCost"
+//	- conversion currency.Conversion : The conversion of the costs to the currency they are summed in
 // This function excepts arguments passed to it to be sanitize. If they are not, the following cases will make
 // it crash :
 //	- For the 'tag:<TAG_KEY>' param, if the separator is not present, or if there is no key that is passed to it,
@@ -266,20 +339,16 @@ func nestAggregation(allAggrSlice []paramAggrAndName) elastic.Aggregation {
 //	- If the client is nil or malconfigured, it will crash
 //	- If the index is not an index present in the ES, it will crash
 func GetElasticSearchParams(accountList []string, durationBegin time.Time,
-	durationEnd time.Time, params []string, client *elastic.Client, index string) *elastic.SearchService {
-	query := elastic.NewBoolQuery()
-	if len(accountList) > 0 {
-		query = query.Filter(createQueryAccountFilter(accountList))
-	}
-	query = query.Filter(createQueryTimeRange(durationBegin, durationEnd))
+	durationEnd time.Time, params []string, client *elastic.Client, index string, typeFilter s3.LineItemTypeFilter, costField string, conversion currency.Conversion) *elastic.SearchService {
+	query := createLineItemsQuery(accountList, durationBegin, durationEnd, typeFilter)
 	search := client.Search().Index(index).Size(0).Query(query)
-	params = append(params, "cost")
 	var allAggregationSlice []paramAggrAndName
 	for _, paramName := range params {
 		paramNameSplit := strings.Split(paramName, ":")
 		paramAggr := paramNameToFuncPtr[paramNameSplit[0]](paramNameSplit)
 		allAggregationSlice = append(allAggregationSlice, paramAggr...)
 	}
+	allAggregationSlice = append(allAggregationSlice, createCostSumAggregation(costField, conversion)...)
 	aggregationParamName := allAggregationSlice[0].name
 	nestedAggregation := nestAggregation(allAggregationSlice)
 	search.Aggregation(aggregationParamName, nestedAggregation)
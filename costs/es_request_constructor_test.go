@@ -21,6 +21,9 @@ import (
 	"time"
 
 	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/currency"
 )
 
 func createAndConfigureTestClient(t *testing.T) *elastic.Client {
@@ -32,11 +35,11 @@ func createAndConfigureTestClient(t *testing.T) *elastic.Client {
 }
 
 func TestQueryAccountFiltersMultipleAccounts(t *testing.T) {
-	linkedAccountID := []int{
-		123456,
-		98765432,
+	linkedAccountID := []string{
+		"123456",
+		"98765432",
 	}
-	expectedResult := `{"terms":{"usageAccountId":[123456,98765432]}}`
+	expectedResult := `{"terms":{"usageAccountId":["123456","98765432"]}}`
 	res := createQueryAccountFilter(linkedAccountID)
 	src, err := res.Source()
 	if err != nil {
@@ -52,10 +55,10 @@ func TestQueryAccountFiltersMultipleAccounts(t *testing.T) {
 }
 
 func TestQueryAccountFiltersSingleAccount(t *testing.T) {
-	linkedAccountID := []int{
-		123456,
+	linkedAccountID := []string{
+		"123456",
 	}
-	expectedResult := `{"terms":{"usageAccountId":[123456]}}`
+	expectedResult := `{"terms":{"usageAccountId":["123456"]}}`
 	res := createQueryAccountFilter(linkedAccountID)
 	src, err := res.Source()
 	if err != nil {
@@ -73,7 +76,7 @@ func TestQueryAccountFiltersSingleAccount(t *testing.T) {
 func TestQueryTimeRange(t *testing.T) {
 	durationBegin, _ := time.Parse("2006-1-2 15:04", "2017-01-12 11:23")
 	durationEnd, _ := time.Parse("2006-1-2 15:04", "2017-05-23 11:23")
-	expectedResult := `{"range":{"usage_start_date":{"from":"2017-01-12T11:23:00Z","include_lower":true,"include_upper":true,"to":"2017-05-23T11:23:00Z"}}}`
+	expectedResult := `{"range":{"usageStartDate":{"from":"2017-01-12T11:23:00Z","include_lower":true,"include_upper":true,"to":"2017-05-23T11:23:00Z"}}}`
 
 	res := createQueryTimeRange(durationBegin, durationEnd)
 	src, err := res.Source()
@@ -91,7 +94,7 @@ func TestQueryTimeRange(t *testing.T) {
 
 func TestAggregationPerProduct(t *testing.T) {
 	res := createAggregationPerProduct([]string{""})
-	expectedResult := `{"terms":{"field":"product_name","size":2147483647}}`
+	expectedResult := `{"terms":{"field":"productCode","size":2147483647}}`
 	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
@@ -107,7 +110,7 @@ func TestAggregationPerProduct(t *testing.T) {
 
 func TestAggregationPerRegion(t *testing.T) {
 	res := createAggregationPerRegion([]string{""})
-	expectedResult := `{"terms":{"field":"availability_zone","size":2147483647}}`
+	expectedResult := `{"terms":{"field":"region","size":2147483647}}`
 	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
@@ -123,7 +126,7 @@ func TestAggregationPerRegion(t *testing.T) {
 
 func TestAggregationPerAccount(t *testing.T) {
 	res := createAggregationPerAccount([]string{""})
-	expectedResult := `{"terms":{"field":"linked_account_id","size":2147483647}}`
+	expectedResult := `{"terms":{"field":"usageAccountId","size":2147483647}}`
 	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
@@ -139,7 +142,7 @@ func TestAggregationPerAccount(t *testing.T) {
 
 func TestAggregationPerDay(t *testing.T) {
 	res := createAggregationPerDay([]string{""})
-	expectedResult := `{"date_histogram":{"field":"usage_start_date","interval":"day"}}`
+	expectedResult := `{"date_histogram":{"field":"usageStartDate","interval":"day","min_doc_count":0}}`
 	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
@@ -155,7 +158,7 @@ func TestAggregationPerDay(t *testing.T) {
 
 func TestAggregationPerMonth(t *testing.T) {
 	res := createAggregationPerMonth([]string{""})
-	expectedResult := `{"date_histogram":{"field":"usage_start_date","interval":"month"}}`
+	expectedResult := `{"date_histogram":{"field":"usageStartDate","interval":"month","min_doc_count":0}}`
 	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
@@ -170,8 +173,7 @@ func TestAggregationPerMonth(t *testing.T) {
 }
 
 func TestCostSumAggregation(t *testing.T) {
-	res := createCostSumAggregation([]string{""})
-	expectedResult := `{"sum":{"field":"cost"}}`
+	res := createCostSumAggregation("amortizedCost", currency.Conversion{Target: "EUR"})
 	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
@@ -180,14 +182,44 @@ func TestCostSumAggregation(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if string(jsonRes) != expectedResult {
-		t.Fatalf("Expected %v but got %v", expectedResult, string(jsonRes))
+	var sum struct {
+		Sum struct {
+			Field  string `json:"field"`
+			Script struct {
+				Lang   string                 `json:"lang"`
+				Params map[string]interface{} `json:"params"`
+			} `json:"script"`
+		} `json:"sum"`
+	}
+	if err := json.Unmarshal(jsonRes, &sum); err != nil {
+		t.Fatal(err)
+	}
+	if res[0].name != "value" {
+		t.Errorf("Expected aggregation name value but got %v", res[0].name)
+	}
+	if sum.Sum.Field != "" || sum.Sum.Script.Lang != "painless" {
+		t.Errorf("Expected a painless script summing the converted costs but got %v", string(jsonRes))
+	}
+	if sum.Sum.Script.Params["field"] != "amortizedCost" || sum.Sum.Script.Params["target"] != "EUR" {
+		t.Errorf("Expected script params for amortizedCost in EUR but got %v", sum.Sum.Script.Params)
+	}
+}
+
+// testCostSumAggregation sums the unblended cost without any currency
+// conversion, so that the nesting tests do not depend on the conversion
+// script.
+func testCostSumAggregation() []paramAggrAndName {
+	return []paramAggrAndName{
+		paramAggrAndName{
+			name: "value",
+			aggr: elastic.NewSumAggregation().Field("unblendedCost"),
+		},
 	}
 }
 
 func TestAggregationPerWeek(t *testing.T) {
 	res := createAggregationPerWeek([]string{""})
-	expectedResult := `{"date_histogram":{"field":"usage_start_date","interval":"week"}}`
+	expectedResult := `{"date_histogram":{"field":"usageStartDate","interval":"week","min_doc_count":0}}`
 	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
@@ -203,7 +235,7 @@ func TestAggregationPerWeek(t *testing.T) {
 
 func TestAggregationPerYear(t *testing.T) {
 	res := createAggregationPerYear([]string{""})
-	expectedResult := `{"date_histogram":{"field":"usage_start_date","interval":"year"}}`
+	expectedResult := `{"date_histogram":{"field":"usageStartDate","interval":"year","min_doc_count":0}}`
 	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
@@ -219,29 +251,17 @@ func TestAggregationPerYear(t *testing.T) {
 
 func TestAggregationPerTag(t *testing.T) {
 	res := createAggregationPerTag([]string{"tag", "test"})
-	expectedFirstResult := `{"filter":{"term":{"tag.key":"user:test"}}}`
-	expectedSecondResult := `{"terms":{"field":"tag.value","size":2147483647}}`
-	srcFirst, err := res[0].aggr.Source()
-	if err != nil {
-		t.Fatal(err)
-	}
-	srcSecond, err := res[1].aggr.Source()
-	if err != nil {
-		t.Fatal(err)
-	}
-	jsonResFirst, err := json.Marshal(srcFirst)
+	expectedResult := `{"aggregations":{"tag-nested":{"aggregations":{"tag-key":{"aggregations":{"tag-value":{"aggregations":{"tag-reverse":{"reverse_nested":{}}},"terms":{"field":"tags.tag","size":2147483647}}},"filter":{"term":{"tags.key":"test"}}}},"nested":{"path":"tags"}}},"filters":{"filters":{"(untagged)":{"bool":{"must_not":{"nested":{"path":"tags","query":{"bool":{"filter":{"term":{"tags.key":"test"}},"must_not":{"term":{"tags.tag":""}}}}}}}},"tagged":{"nested":{"path":"tags","query":{"bool":{"filter":{"term":{"tags.key":"test"}},"must_not":{"term":{"tags.tag":""}}}}}}}}}`
+	src, err := res[0].aggr.Source()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if string(jsonResFirst) != expectedFirstResult {
-		t.Fatalf("Expected %v but got %v", expectedFirstResult, string(jsonResFirst))
-	}
-	jsonResSecond, err := json.Marshal(srcSecond)
+	jsonRes, err := json.Marshal(src)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if string(jsonResSecond) != expectedSecondResult {
-		t.Fatalf("Expected %v but got %v", expectedSecondResult, string(jsonResSecond))
+	if string(jsonRes) != expectedResult {
+		t.Fatalf("Expected %v but got %v", expectedResult, string(jsonRes))
 	}
 }
 
@@ -276,7 +296,7 @@ func TestReverseAggregationArray(t *testing.T) {
 
 func TestAggregationNestingWithSingleElementSlice(t *testing.T) {
 	singleAggregationSlice := createAggregationPerAccount([]string{""})
-	expectedResult := `{"terms":{"field":"linked_account_id","size":2147483647}}`
+	expectedResult := `{"terms":{"field":"usageAccountId","size":2147483647}}`
 	res := nestAggregation(singleAggregationSlice)
 	src, err := res.Source()
 	if err != nil {
@@ -293,18 +313,93 @@ func TestAggregationNestingWithSingleElementSlice(t *testing.T) {
 
 func TestAggregationNestingWithCoupleElementsSlice(t *testing.T) {
 	coupleAggregationSlice := createAggregationPerTag([]string{"", "test"})
+	coupleAggregationSlice = append(coupleAggregationSlice, testCostSumAggregation()...)
 	expectedResult := `{
 	"aggregations": {
-		"tag_value": {
-			"terms": {
-				"field": "tag.value",
-				"size": 2147483647
+		"tag-nested": {
+			"aggregations": {
+				"tag-key": {
+					"aggregations": {
+						"tag-value": {
+							"aggregations": {
+								"tag-reverse": {
+									"aggregations": {
+										"value": {
+											"sum": {
+												"field": "unblendedCost"
+											}
+										}
+									},
+									"reverse_nested": {}
+								}
+							},
+							"terms": {
+								"field": "tags.tag",
+								"size": 2147483647
+							}
+						}
+					},
+					"filter": {
+						"term": {
+							"tags.key": "test"
+						}
+					}
+				}
+			},
+			"nested": {
+				"path": "tags"
+			}
+		},
+		"value": {
+			"sum": {
+				"field": "unblendedCost"
 			}
 		}
 	},
-	"filter": {
-		"term": {
-			"tag.key": "user:test"
+	"filters": {
+		"filters": {
+			"(untagged)": {
+				"bool": {
+					"must_not": {
+						"nested": {
+							"path": "tags",
+							"query": {
+								"bool": {
+									"filter": {
+										"term": {
+											"tags.key": "test"
+										}
+									},
+									"must_not": {
+										"term": {
+											"tags.tag": ""
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			},
+			"tagged": {
+				"nested": {
+					"path": "tags",
+					"query": {
+						"bool": {
+							"filter": {
+								"term": {
+									"tags.key": "test"
+								}
+							},
+							"must_not": {
+								"term": {
+									"tags.tag": ""
+								}
+							}
+						}
+					}
+				}
+			}
 		}
 	}
 }`
@@ -324,27 +419,112 @@ func TestAggregationNestingWithCoupleElementsSlice(t *testing.T) {
 
 func TestAggregationNestingWithFewElementsSlice(t *testing.T) {
 	fewAggregationSlice := createAggregationPerTag([]string{"", "test"})
-	buffAggregation := createCostSumAggregation([]string{""})
-	fewAggregationSlice = append(fewAggregationSlice, buffAggregation...)
+	fewAggregationSlice = append(fewAggregationSlice, createAggregationPerMonth([]string{""})...)
+	fewAggregationSlice = append(fewAggregationSlice, testCostSumAggregation()...)
 	expectedResult := `{
 	"aggregations": {
-		"tag_value": {
+		"by-month": {
 			"aggregations": {
-				"cost": {
+				"value": {
 					"sum": {
-						"field": "cost"
+						"field": "unblendedCost"
+					}
+				}
+			},
+			"date_histogram": {
+				"field": "usageStartDate",
+				"interval": "month",
+				"min_doc_count": 0
+			}
+		},
+		"tag-nested": {
+			"aggregations": {
+				"tag-key": {
+					"aggregations": {
+						"tag-value": {
+							"aggregations": {
+								"tag-reverse": {
+									"aggregations": {
+										"by-month": {
+											"aggregations": {
+												"value": {
+													"sum": {
+														"field": "unblendedCost"
+													}
+												}
+											},
+											"date_histogram": {
+												"field": "usageStartDate",
+												"interval": "month",
+												"min_doc_count": 0
+											}
+										}
+									},
+									"reverse_nested": {}
+								}
+							},
+							"terms": {
+								"field": "tags.tag",
+								"size": 2147483647
+							}
+						}
+					},
+					"filter": {
+						"term": {
+							"tags.key": "test"
+						}
 					}
 				}
 			},
-			"terms": {
-				"field": "tag.value",
-				"size": 2147483647
+			"nested": {
+				"path": "tags"
 			}
 		}
 	},
-	"filter": {
-		"term": {
-			"tag.key": "user:test"
+	"filters": {
+		"filters": {
+			"(untagged)": {
+				"bool": {
+					"must_not": {
+						"nested": {
+							"path": "tags",
+							"query": {
+								"bool": {
+									"filter": {
+										"term": {
+											"tags.key": "test"
+										}
+									},
+									"must_not": {
+										"term": {
+											"tags.tag": ""
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			},
+			"tagged": {
+				"nested": {
+					"path": "tags",
+					"query": {
+						"bool": {
+							"filter": {
+								"term": {
+									"tags.key": "test"
+								}
+							},
+							"must_not": {
+								"term": {
+									"tags.tag": ""
+								}
+							}
+						}
+					}
+				}
+			}
 		}
 	}
 }`
@@ -368,33 +548,102 @@ func TestAggregationNestingWithAllHandledElasticAggregationTypes(t *testing.T) {
 	allTypesSlice = append(allTypesSlice, createAggregationPerProduct([]string{""})...)
 	expectedResult := `{
 	"aggregations": {
-		"tag_key": {
+		"by-tag": {
 			"aggregations": {
-				"tag_value": {
+				"by-product": {
+					"terms": {
+						"field": "productCode",
+						"size": 2147483647
+					}
+				},
+				"tag-nested": {
 					"aggregations": {
-						"product": {
-							"terms": {
-								"field": "product_name",
-								"size": 2147483647
+						"tag-key": {
+							"aggregations": {
+								"tag-value": {
+									"aggregations": {
+										"tag-reverse": {
+											"aggregations": {
+												"by-product": {
+													"terms": {
+														"field": "productCode",
+														"size": 2147483647
+													}
+												}
+											},
+											"reverse_nested": {}
+										}
+									},
+									"terms": {
+										"field": "tags.tag",
+										"size": 2147483647
+									}
+								}
+							},
+							"filter": {
+								"term": {
+									"tags.key": "test"
+								}
 							}
 						}
 					},
-					"terms": {
-						"field": "tag.value",
-						"size": 2147483647
+					"nested": {
+						"path": "tags"
 					}
 				}
 			},
-			"filter": {
-				"term": {
-					"tag.key": "user:test"
+			"filters": {
+				"filters": {
+					"(untagged)": {
+						"bool": {
+							"must_not": {
+								"nested": {
+									"path": "tags",
+									"query": {
+										"bool": {
+											"filter": {
+												"term": {
+													"tags.key": "test"
+												}
+											},
+											"must_not": {
+												"term": {
+													"tags.tag": ""
+												}
+											}
+										}
+									}
+								}
+							}
+						}
+					},
+					"tagged": {
+						"nested": {
+							"path": "tags",
+							"query": {
+								"bool": {
+									"filter": {
+										"term": {
+											"tags.key": "test"
+										}
+									},
+									"must_not": {
+										"term": {
+											"tags.tag": ""
+										}
+									}
+								}
+							}
+						}
+					}
 				}
 			}
 		}
 	},
 	"date_histogram": {
-		"field": "usage_start_date",
-		"interval": "year"
+		"field": "usageStartDate",
+		"interval": "year",
+		"min_doc_count": 0
 	}
 }`
 	res := nestAggregation(allTypesSlice)
@@ -428,7 +677,7 @@ func TestElasticSearchParamWithNoResults(t *testing.T) {
 		"buckets": []
 	}
 }`
-	searchService := GetElasticSearchParams(accountList, durationBegin, durationEnd, params, client, index)
+	searchService := GetElasticSearchParams(accountList, durationBegin, durationEnd, params, client, index, s3.LineItemTypeFilter{}, "unblendedCost", currency.Conversion{})
 	res, err := searchService.Do(context.Background())
 	if err != nil {
 		t.Fatal(err)
@@ -537,7 +786,7 @@ func TestElasticSearchParamWithFewResultsAndNoAggregationNesting(t *testing.T) {
 		]
 	}
 }`
-	searchService := GetElasticSearchParams(accountList, durationBegin, durationEnd, params, client, index)
+	searchService := GetElasticSearchParams(accountList, durationBegin, durationEnd, params, client, index, s3.LineItemTypeFilter{}, "unblendedCost", currency.Conversion{})
 	res, err := searchService.Do(context.Background())
 	if err != nil {
 		t.Fatal(err)
@@ -718,7 +967,7 @@ func TestElasticSearchParamWithFewResultsAndNesting(t *testing.T) {
 		]
 	}
 }`
-	searchService := GetElasticSearchParams(accountList, durationBegin, durationEnd, params, client, index)
+	searchService := GetElasticSearchParams(accountList, durationBegin, durationEnd, params, client, index, s3.LineItemTypeFilter{}, "unblendedCost", currency.Conversion{})
 	res, err := searchService.Do(context.Background())
 	if err != nil {
 		t.Fatal(err)
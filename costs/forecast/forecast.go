@@ -0,0 +1,282 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package forecast projects the costs of the upcoming days or months from
+// their recent history.
+package forecast
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/costs"
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/users"
+)
+
+const (
+	// GranularityDay projects the cost of each day.
+	GranularityDay = "day"
+	// GranularityMonth projects the cost of each month.
+	GranularityMonth = "month"
+
+	// historyDays is the number of days of history the projection is based
+	// on.
+	historyDays = 90
+
+	dayFormat = "2006-01-02"
+)
+
+// timeCriteria are the criteria which can not break a forecast down since
+// it is already broken down by day or month.
+var timeCriteria = map[string]bool{
+	"year":  true,
+	"month": true,
+	"week":  true,
+	"day":   true,
+}
+
+type (
+	// ForecastPoint is the projected cost of a day or a month. Lower and
+	// Upper bound its 95% confidence interval.
+	ForecastPoint struct {
+		Date  string  `json:"date"`
+		Cost  float64 `json:"cost"`
+		Lower float64 `json:"lower"`
+		Upper float64 `json:"upper"`
+	}
+
+	// ForecastSeries is the projection of the costs matching a key for each
+	// criterion.
+	ForecastSeries struct {
+		Keys   map[string]string `json:"keys"`
+		Points []ForecastPoint   `json:"points"`
+	}
+
+	// Forecast is the projection of the costs broken down by Criteria.
+	// RecurringFeesExcluded tells whether the recurring fees of the
+	// reservations were taken out of the history and added back for the days
+	// they are still due, which is only possible when the costs are broken
+	// down by account or product.
+	Forecast struct {
+		Criteria              []string         `json:"criteria"`
+		Granularity           string           `json:"granularity"`
+		RecurringFeesExcluded bool             `json:"recurringFeesExcluded"`
+		Series                []ForecastSeries `json:"series"`
+	}
+
+	// dailySeries is the daily history of the costs matching keys.
+	dailySeries struct {
+		keys  map[string]string
+		costs map[string]float64
+	}
+)
+
+// ToCSVable generates the CSV content from a Forecast, with one row per
+// series and point.
+func (f Forecast) ToCSVable() [][]string {
+	header := append(append([]string{}, f.Criteria...), "date", "cost", "lower", "upper")
+	csv := [][]string{header}
+	for _, series := range f.Series {
+		for _, point := range series.Points {
+			row := make([]string, 0, len(header))
+			for _, criterion := range f.Criteria {
+				row = append(row, series.Keys[criterion])
+			}
+			row = append(row, point.Date,
+				fmt.Sprintf("%f", point.Cost),
+				fmt.Sprintf("%f", point.Lower),
+				fmt.Sprintf("%f", point.Upper))
+			csv = append(csv, row)
+		}
+	}
+	return csv
+}
+
+// validateForecastCriteria checks the criteria are valid cost criteria
+// other than time criteria.
+func validateForecastCriteria(criteria []string) error {
+	if err := costs.ValidateCriteriaParam(costs.EsQueryParams{AggregationParams: criteria}); err != nil {
+		return err
+	}
+	for _, criterion := range criteria {
+		if timeCriteria[criterion] {
+			return fmt.Errorf("Error parsing criterion : %s can not be forecast", criterion)
+		}
+	}
+	return nil
+}
+
+// GetForecast projects the costs of the accounts broken down by criteria for
+// the periods days or months starting today.
+func GetForecast(ctx context.Context, tx *sql.Tx, user users.User, accountList []string, criteria []string, granularity string, periods int) (Forecast, int, error) {
+	forecast := Forecast{
+		Criteria:    criteria,
+		Granularity: granularity,
+		Series:      []ForecastSeries{},
+	}
+	accountsAndIndexes, returnCode, err := es.GetAccountsAndIndexes(accountList, user, tx, s3.IndexPrefixLineItem)
+	if err != nil {
+		return forecast, returnCode, err
+	}
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		return forecast, http.StatusInternalServerError, err
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	params := costs.EsQueryParams{
+		DateBegin:         today.AddDate(0, 0, -historyDays),
+		DateEnd:           today.Add(-time.Nanosecond),
+		AccountList:       accountsAndIndexes.Accounts,
+		IndexList:         accountsAndIndexes.Indexes,
+		AggregationParams: append(append([]string{}, criteria...), "day"),
+		Conversion:        conversion,
+	}
+	doc, returnCode, err := costs.MakeElasticSearchRequestAndParseIt(ctx, params)
+	if err != nil {
+		if returnCode == http.StatusOK {
+			return forecast, returnCode, nil
+		}
+		return forecast, returnCode, err
+	}
+	fees := []recurringFee{}
+	if canAttributeRecurringFees(criteria) {
+		if fees, err = getRecurringFees(ctx, accountsAndIndexes.Accounts, user, tx); err != nil {
+			return forecast, http.StatusInternalServerError, err
+		}
+		for i := range fees {
+			// Reservation charges are expressed in the base currency.
+			if fees[i].daily, err = conversion.Convert(fees[i].daily, currency.Base, today); err != nil {
+				return forecast, http.StatusInternalServerError, err
+			}
+		}
+		forecast.RecurringFeesExcluded = true
+	}
+	for _, series := range getDailySeries(criteria, doc) {
+		forecast.Series = append(forecast.Series, ForecastSeries{
+			Keys:   series.keys,
+			Points: projectSeries(series, fees, today, granularity, periods),
+		})
+	}
+	return forecast, http.StatusOK, nil
+}
+
+// getDailySeries flattens a costs document broken down by criteria then by
+// day into one daily series per combination of keys, sorted by keys.
+func getDailySeries(criteria []string, doc es.SimplifiedCostsDocument) []dailySeries {
+	series := []dailySeries{}
+	var walk func(depth int, keys map[string]string, doc es.SimplifiedCostsDocument)
+	walk = func(depth int, keys map[string]string, doc es.SimplifiedCostsDocument) {
+		if depth == len(criteria) {
+			s := dailySeries{keys: keys, costs: make(map[string]float64)}
+			for _, day := range doc.Children {
+				s.costs[strings.Split(day.Key, "T")[0]] += day.Value
+			}
+			series = append(series, s)
+			return
+		}
+		for _, child := range doc.Children {
+			childKeys := make(map[string]string, len(keys)+1)
+			for k, v := range keys {
+				childKeys[k] = v
+			}
+			childKeys[criteria[depth]] = child.Key
+			walk(depth+1, childKeys, child)
+		}
+	}
+	walk(0, map[string]string{}, doc)
+	sort.Slice(series, func(i, j int) bool {
+		for _, criterion := range criteria {
+			if series[i].keys[criterion] != series[j].keys[criterion] {
+				return series[i].keys[criterion] < series[j].keys[criterion]
+			}
+		}
+		return false
+	})
+	return series
+}
+
+// projectSeries fits a linear model to the history of a series, from its
+// first day with costs to the day before today, without its recurring fees.
+// It then projects the costs for the periods days or months starting today,
+// adding back the recurring fees still due. The costs already spent this
+// month are part of the projection of the current month.
+func projectSeries(series dailySeries, fees []recurringFee, today time.Time, granularity string, periods int) []ForecastPoint {
+	history := []float64{}
+	spentThisMonth := 0.0
+	monthBegin := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for day := today.AddDate(0, 0, -historyDays); day.Before(today); day = day.AddDate(0, 0, 1) {
+		cost, ok := series.costs[day.Format(dayFormat)]
+		if len(history) == 0 && (!ok || cost == 0) {
+			continue
+		}
+		if !day.Before(monthBegin) {
+			spentThisMonth += cost
+		}
+		history = append(history, math.Max(0, cost-getSeriesRecurringFee(fees, series.keys, day)))
+	}
+	model := fitLinearModel(history)
+	projectDay := func(day time.Time) (cost, standardError, fee float64) {
+		x := float64(len(history)) + day.Sub(today).Hours()/24
+		fee = getSeriesRecurringFee(fees, series.keys, day)
+		return math.Max(0, model.predict(x)) + fee, model.standardError(x), fee
+	}
+	points := make([]ForecastPoint, 0, periods)
+	if granularity == GranularityDay {
+		for i := 0; i < periods; i++ {
+			day := today.AddDate(0, 0, i)
+			cost, se, fee := projectDay(day)
+			points = append(points, newForecastPoint(day, cost, se, fee))
+		}
+		return points
+	}
+	for i := 0; i < periods; i++ {
+		begin := monthBegin.AddDate(0, i, 0)
+		end := begin.AddDate(0, 1, 0)
+		cost, variance, floor := 0.0, 0.0, 0.0
+		if i == 0 {
+			cost, floor = spentThisMonth, spentThisMonth
+		}
+		for day := begin; day.Before(end); day = day.AddDate(0, 0, 1) {
+			if day.Before(today) {
+				continue
+			}
+			dayCost, se, fee := projectDay(day)
+			cost += dayCost
+			variance += se * se
+			floor += fee
+		}
+		points = append(points, newForecastPoint(begin, cost, math.Sqrt(variance), floor))
+	}
+	return points
+}
+
+// newForecastPoint builds a ForecastPoint whose lower bound can not be
+// below floor, the part of the cost which is certain.
+func newForecastPoint(date time.Time, cost, standardError, floor float64) ForecastPoint {
+	return ForecastPoint{
+		Date:  date.Format(dayFormat),
+		Cost:  cost,
+		Lower: math.Max(floor, cost-confidenceZ*standardError),
+		Upper: cost + confidenceZ*standardError,
+	}
+}
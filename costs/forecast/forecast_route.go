@@ -0,0 +1,110 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package forecast
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+)
+
+// forecastQueryArgs allows to get required queryArgs params
+var forecastQueryArgs = []routes.QueryArg{
+	routes.AwsAccountsOptionalQueryArg,
+	routes.QueryArg{
+		Name:        "by",
+		Description: "Criteria for the ES aggregation, comma separated. Possible values are account, product, region, availabilityzone, tag:<TAG_KEY>",
+		Type:        routes.QueryArgStringSlice{},
+		Optional:    false,
+	},
+	routes.QueryArg{
+		Name:        "granularity",
+		Description: "Granularity of the projection, either day or month. Defaults to month.",
+		Type:        routes.QueryArgString{},
+		Optional:    true,
+	},
+	routes.QueryArg{
+		Name:        "periods",
+		Description: "Number of days or months to project, starting with the current one. Defaults to 30 days or 3 months.",
+		Type:        routes.QueryArgInt{},
+		Optional:    true,
+	},
+}
+
+// defaultPeriods is the number of periods projected by default for each
+// granularity.
+var defaultPeriods = map[string]int{
+	GranularityDay:   30,
+	GranularityMonth: 3,
+}
+
+// maxPeriods is the maximum number of periods which can be projected for
+// each granularity.
+var maxPeriods = map[string]int{
+	GranularityDay:   366,
+	GranularityMonth: 12,
+}
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getForecast).With(
+			db.RequestTransaction{Db: db.Db},
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.QueryArgs(forecastQueryArgs),
+			routes.Documentation{
+				Summary:     "get the costs forecast",
+				Description: "Responds with the projected costs of the upcoming days or months, with their 95% confidence interval, based on the last 90 days of costs. When the costs are only broken down by account or product, the recurring fees of the EC2 and RDS reservations are projected separately until the reservations expire.",
+			},
+		),
+	}.H().Register("/costs/forecast")
+}
+
+// getForecast returns the costs forecast based on the query params, in JSON
+// format.
+func getForecast(request *http.Request, a routes.Arguments) (int, interface{}) {
+	user := a[users.AuthenticatedUser].(users.User)
+	tx := a[db.Transaction].(*sql.Tx)
+	accountList := []string{}
+	if a[forecastQueryArgs[0]] != nil {
+		accountList = a[forecastQueryArgs[0]].([]string)
+	}
+	criteria := a[forecastQueryArgs[1]].([]string)
+	if err := validateForecastCriteria(criteria); err != nil {
+		return http.StatusBadRequest, err
+	}
+	granularity := GranularityMonth
+	if a[forecastQueryArgs[2]] != nil {
+		granularity = a[forecastQueryArgs[2]].(string)
+	}
+	if _, ok := defaultPeriods[granularity]; !ok {
+		return http.StatusBadRequest, errors.New("Granularity must be either day or month.")
+	}
+	periods := defaultPeriods[granularity]
+	if a[forecastQueryArgs[3]] != nil {
+		periods = a[forecastQueryArgs[3]].(int)
+	}
+	if periods < 1 || periods > maxPeriods[granularity] {
+		return http.StatusBadRequest, errors.New("Periods is out of range.")
+	}
+	forecast, returnCode, err := GetForecast(request.Context(), tx, user, accountList, criteria, granularity, periods)
+	if err != nil {
+		return returnCode, err
+	}
+	return http.StatusOK, forecast
+}
@@ -0,0 +1,150 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package forecast
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/trackit/trackit-server/es"
+)
+
+func TestFitLinearModelExactLine(t *testing.T) {
+	model := fitLinearModel([]float64{1, 3, 5, 7, 9})
+	if math.Abs(model.slope-2) > 1e-9 || math.Abs(model.intercept-1) > 1e-9 {
+		t.Fatalf("Expected slope 2 and intercept 1, got %f and %f", model.slope, model.intercept)
+	}
+	if model.standardError(10) != 0 {
+		t.Errorf("Expected no uncertainty on an exact line, got %f", model.standardError(10))
+	}
+	if prediction := model.predict(10); math.Abs(prediction-21) > 1e-9 {
+		t.Errorf("Expected 21, got %f", prediction)
+	}
+}
+
+func TestFitLinearModelUncertaintyGrows(t *testing.T) {
+	model := fitLinearModel([]float64{10, 12, 9, 11, 10, 13, 9, 11})
+	near, far := model.standardError(8), model.standardError(40)
+	if near <= 0 || far <= near {
+		t.Errorf("Expected the uncertainty to grow with the horizon, got %f then %f", near, far)
+	}
+}
+
+func TestFitLinearModelFewValues(t *testing.T) {
+	model := fitLinearModel([]float64{4, 8})
+	if model.predict(5) != 6 || model.standardError(5) != 0 {
+		t.Errorf("Expected a flat model at the mean, got %f ± %f", model.predict(5), model.standardError(5))
+	}
+}
+
+func TestGetDailySeries(t *testing.T) {
+	doc := es.SimplifiedCostsDocument{
+		ChildrenKind: "product",
+		Children: []es.SimplifiedCostsDocument{
+			{
+				Key:          "AmazonS3",
+				ChildrenKind: "day",
+				Children: []es.SimplifiedCostsDocument{
+					{Key: "2018-05-01T00:00:00.000Z", HasValue: true, Value: 2},
+				},
+			},
+			{
+				Key:          "AmazonEC2",
+				ChildrenKind: "day",
+				Children: []es.SimplifiedCostsDocument{
+					{Key: "2018-05-01T00:00:00.000Z", HasValue: true, Value: 1},
+					{Key: "2018-05-02T00:00:00.000Z", HasValue: true, Value: 3},
+				},
+			},
+		},
+	}
+	series := getDailySeries([]string{"product"}, doc)
+	if len(series) != 2 {
+		t.Fatalf("Expected 2 series, got %d", len(series))
+	}
+	if series[0].keys["product"] != "AmazonEC2" || !reflect.DeepEqual(series[0].costs, map[string]float64{"2018-05-01": 1, "2018-05-02": 3}) {
+		t.Errorf("Unexpected first series %v", series[0])
+	}
+	if series[1].keys["product"] != "AmazonS3" {
+		t.Errorf("Unexpected second series %v", series[1])
+	}
+}
+
+// makeConstantSeries creates a series costing cost every day of the history
+// before today.
+func makeConstantSeries(keys map[string]string, today time.Time, cost float64) dailySeries {
+	series := dailySeries{keys: keys, costs: make(map[string]float64)}
+	for day := today.AddDate(0, 0, -historyDays); day.Before(today); day = day.AddDate(0, 0, 1) {
+		series.costs[day.Format(dayFormat)] = cost
+	}
+	return series
+}
+
+func TestProjectSeriesRecurringFees(t *testing.T) {
+	today := time.Date(2018, time.May, 15, 0, 0, 0, 0, time.UTC)
+	keys := map[string]string{"product": productEc2}
+	fees := []recurringFee{
+		{account: "123456789012", product: productEc2, begin: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2018, time.May, 17, 0, 0, 0, 0, time.UTC), daily: 5},
+		{account: "123456789012", product: productRds, begin: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), daily: 100},
+	}
+	points := projectSeries(makeConstantSeries(keys, today, 15), fees, today, GranularityDay, 4)
+	expected := []float64{15, 15, 10, 10}
+	for i, point := range points {
+		if math.Abs(point.Cost-expected[i]) > 1e-9 {
+			t.Errorf("Expected %f on day %d, got %f", expected[i], i, point.Cost)
+		}
+	}
+	if points[0].Date != "2018-05-15" {
+		t.Errorf("Expected the first point to be today, got %s", points[0].Date)
+	}
+}
+
+func TestProjectSeriesMonthly(t *testing.T) {
+	today := time.Date(2018, time.May, 15, 0, 0, 0, 0, time.UTC)
+	keys := map[string]string{"product": productEc2}
+	fees := []recurringFee{
+		{product: productEc2, begin: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC), end: time.Date(2018, time.May, 17, 0, 0, 0, 0, time.UTC), daily: 5},
+	}
+	points := projectSeries(makeConstantSeries(keys, today, 15), fees, today, GranularityMonth, 2)
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(points))
+	}
+	if points[0].Date != "2018-05-01" || math.Abs(points[0].Cost-390) > 1e-9 {
+		t.Errorf("Expected 390 for 2018-05-01, got %f for %s", points[0].Cost, points[0].Date)
+	}
+	if points[1].Date != "2018-06-01" || math.Abs(points[1].Cost-300) > 1e-9 {
+		t.Errorf("Expected 300 for 2018-06-01, got %f for %s", points[1].Cost, points[1].Date)
+	}
+}
+
+func TestCanAttributeRecurringFees(t *testing.T) {
+	if !canAttributeRecurringFees([]string{"account", "product"}) {
+		t.Errorf("Expected fees to be attributable by account and product")
+	}
+	if canAttributeRecurringFees([]string{"product", "tag:env"}) {
+		t.Errorf("Expected fees not to be attributable by tag")
+	}
+}
+
+func TestValidateForecastCriteria(t *testing.T) {
+	if err := validateForecastCriteria([]string{"product", "tag:env"}); err != nil {
+		t.Errorf("Unexpected error %s", err.Error())
+	}
+	if err := validateForecastCriteria([]string{"product", "month"}); err == nil {
+		t.Errorf("Expected an error for a time criterion")
+	}
+}
@@ -0,0 +1,83 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package forecast
+
+import (
+	"math"
+)
+
+// confidenceZ is the standard score of the 95% confidence intervals.
+const confidenceZ = 1.96
+
+// linearModel is a least squares linear regression of a daily cost series
+// against the index of the day.
+type linearModel struct {
+	n         int
+	intercept float64
+	slope     float64
+	meanX     float64
+	sxx       float64
+	sigma     float64
+}
+
+// fitLinearModel fits a linearModel to values. With fewer than three values
+// the trend can not be estimated, so the model is flat at their mean and has
+// no uncertainty.
+func fitLinearModel(values []float64) linearModel {
+	m := linearModel{n: len(values)}
+	if m.n == 0 {
+		return m
+	}
+	var sumX, sumY float64
+	for i, y := range values {
+		sumX += float64(i)
+		sumY += y
+	}
+	m.meanX = sumX / float64(m.n)
+	meanY := sumY / float64(m.n)
+	if m.n < 3 {
+		m.intercept = meanY
+		return m
+	}
+	var sxy float64
+	for i, y := range values {
+		dx := float64(i) - m.meanX
+		m.sxx += dx * dx
+		sxy += dx * (y - meanY)
+	}
+	m.slope = sxy / m.sxx
+	m.intercept = meanY - m.slope*m.meanX
+	var sse float64
+	for i, y := range values {
+		residual := y - m.predict(float64(i))
+		sse += residual * residual
+	}
+	m.sigma = math.Sqrt(sse / float64(m.n-2))
+	return m
+}
+
+// predict returns the expected value at index x.
+func (m linearModel) predict(x float64) float64 {
+	return m.intercept + m.slope*x
+}
+
+// standardError returns the standard error of a new observation at index x.
+func (m linearModel) standardError(x float64) float64 {
+	if m.n < 3 {
+		return 0
+	}
+	dx := x - m.meanX
+	return m.sigma * math.Sqrt(1+1/float64(m.n)+dx*dx/m.sxx)
+}
@@ -0,0 +1,144 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package forecast
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/trackit/trackit-server/usageReports/riEc2"
+	"github.com/trackit/trackit-server/usageReports/riRds"
+	"github.com/trackit/trackit-server/users"
+)
+
+const (
+	productEc2 = "AmazonEC2"
+	productRds = "AmazonRDS"
+)
+
+// recurringFee is the daily fee of a reservation, which is billed whether
+// the reservation is used or not until it expires.
+type recurringFee struct {
+	account string
+	product string
+	begin   time.Time
+	end     time.Time
+	daily   float64
+}
+
+// activeOn checks whether the fee is billed on day.
+func (f recurringFee) activeOn(day time.Time) bool {
+	return !day.Before(f.begin.Truncate(24*time.Hour)) && day.Before(f.end)
+}
+
+// getDailyChargeAmount converts the amount of a recurring charge to a daily
+// amount. Charges with an unknown frequency are ignored.
+func getDailyChargeAmount(amount float64, frequency string) float64 {
+	switch frequency {
+	case "Hourly":
+		return amount * 24
+	case "Daily":
+		return amount
+	default:
+		return 0
+	}
+}
+
+// getRecurringFees retrieves the recurring fees of the EC2 and RDS
+// reservations of the accounts.
+func getRecurringFees(ctx context.Context, accountList []string, user users.User, tx *sql.Tx) ([]recurringFee, error) {
+	date := time.Now().UTC()
+	fees := []recurringFee{}
+	returnCode, ec2Reservations, err := riEc2.GetReservedInstancesData(ctx, riEc2.ReservedInstancesQueryParams{
+		AccountList: accountList,
+		Date:        date,
+	}, user, tx)
+	if err != nil && returnCode != http.StatusOK {
+		return nil, err
+	}
+	for _, report := range ec2Reservations {
+		fees = append(fees, getEc2RecurringFee(report))
+	}
+	returnCode, rdsReservations, err := riRds.GetReservedInstancesData(ctx, riRds.ReservedInstancesQueryParams{
+		AccountList: accountList,
+		Date:        date,
+	}, user, tx)
+	if err != nil && returnCode != http.StatusOK {
+		return nil, err
+	}
+	for _, report := range rdsReservations {
+		fees = append(fees, getRdsRecurringFee(report))
+	}
+	return fees, nil
+}
+
+// getEc2RecurringFee builds the recurringFee of an EC2 reservation.
+func getEc2RecurringFee(report riEc2.ReservationReport) recurringFee {
+	fee := recurringFee{
+		account: report.Account,
+		product: productEc2,
+		begin:   report.Reservation.Start,
+		end:     report.Reservation.End,
+	}
+	for _, charge := range report.Reservation.RecurringCharges {
+		fee.daily += getDailyChargeAmount(charge.Amount, charge.Frequency) * float64(report.Reservation.InstanceCount)
+	}
+	return fee
+}
+
+// getRdsRecurringFee builds the recurringFee of an RDS reservation, whose
+// duration is in seconds.
+func getRdsRecurringFee(report riRds.ReservationReport) recurringFee {
+	fee := recurringFee{
+		account: report.Account,
+		product: productRds,
+		begin:   report.Reservation.StartTime,
+		end:     report.Reservation.StartTime.Add(time.Duration(report.Reservation.Duration) * time.Second),
+	}
+	for _, charge := range report.Reservation.RecurringCharges {
+		fee.daily += getDailyChargeAmount(charge.Amount, charge.Frequency) * float64(report.Reservation.DBInstanceCount)
+	}
+	return fee
+}
+
+// canAttributeRecurringFees checks whether the fees of the reservations can
+// be attributed to the series built from criteria. Reservations are only
+// known by account and product.
+func canAttributeRecurringFees(criteria []string) bool {
+	for _, criterion := range criteria {
+		if criterion != "account" && criterion != "product" {
+			return false
+		}
+	}
+	return true
+}
+
+// getSeriesRecurringFee returns the sum of the fees billed on day to the
+// series identified by keys.
+func getSeriesRecurringFee(fees []recurringFee, keys map[string]string, day time.Time) float64 {
+	var total float64
+	for _, fee := range fees {
+		if account, ok := keys["account"]; ok && account != fee.account {
+			continue
+		} else if product, ok := keys["product"]; ok && product != fee.product {
+			continue
+		} else if fee.activeOn(day) {
+			total += fee.daily
+		}
+	}
+	return total
+}
@@ -0,0 +1,167 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package lineitems exports the raw line items of the cost and usage reports.
+package lineitems
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/routes"
+)
+
+const (
+	// scrollSize is the number of line items fetched from ElasticSearch at
+	// once.
+	scrollSize = 1000
+	// scrollKeepAlive is how long ElasticSearch keeps the scroll context
+	// between two pages.
+	scrollKeepAlive = "1m"
+)
+
+// csvHeader is the header of the CSV export of line items.
+var csvHeader = []string{
+	"lineItemId",
+	"invoiceId",
+	"usageAccountId",
+	"lineItemType",
+	"usageStartDate",
+	"usageEndDate",
+	"productCode",
+	"usageType",
+	"operation",
+	"availabilityZone",
+	"region",
+	"resourceId",
+	"usageAmount",
+	"serviceCode",
+	"currencyCode",
+	"unblendedCost",
+	"taxType",
+}
+
+// Export is a line items export. Line items are read from ElasticSearch page
+// by page as they are written, so that exports of any size can be streamed
+// without being held in memory.
+type Export struct {
+	ctx    context.Context
+	scroll *elastic.ScrollService
+	first  *elastic.SearchResult
+}
+
+// NewExport starts an export of the line items of accounts, stored in
+// indexes, used between begin and end. The first page is fetched right away
+// so that errors can be reported before the response is started.
+func NewExport(ctx context.Context, accounts, indexes []string, begin, end time.Time) (Export, error) {
+	accountsFilter := make([]interface{}, len(accounts))
+	for i, account := range accounts {
+		accountsFilter[i] = account
+	}
+	query := elastic.NewBoolQuery().Filter(
+		elastic.NewTermsQuery("usageAccountId", accountsFilter...),
+		elastic.NewRangeQuery("usageStartDate").From(begin).To(end),
+	)
+	scroll := es.Client.Scroll(strings.Join(indexes, ",")).
+		Type(s3.TypeLineItem).
+		Query(query).
+		Sort("usageStartDate", true).
+		Size(scrollSize).
+		KeepAlive(scrollKeepAlive)
+	first, err := scroll.Do(ctx)
+	if err == io.EOF {
+		err = nil
+	}
+	return Export{ctx, scroll, first}, err
+}
+
+// ContentTypes restricts line items exports to streamed content types.
+func (e Export) ContentTypes() []string {
+	return []string{routes.ContentTypeCsv, routes.ContentTypeNdjson}
+}
+
+// StreamCSV writes the line items as CSV, one line item per row.
+func (e Export) StreamCSV(write func([]string) error) error {
+	if err := write(csvHeader); err != nil {
+		e.clear()
+		return err
+	}
+	return e.forEach(func(li s3.LineItem) error {
+		return write([]string{
+			li.LineItemId,
+			li.InvoiceId,
+			li.UsageAccountId,
+			li.LineItemType,
+			li.UsageStartDate,
+			li.UsageEndDate,
+			li.ProductCode,
+			li.UsageType,
+			li.Operation,
+			li.AvailabilityZone,
+			li.Region,
+			li.ResourceId,
+			li.UsageAmount,
+			li.ServiceCode,
+			li.CurrencyCode,
+			li.UnblendedCost,
+			li.TaxType,
+		})
+	})
+}
+
+// StreamNDJSON writes the line items as newline delimited JSON, one line item
+// per line.
+func (e Export) StreamNDJSON(write func(interface{}) error) error {
+	return e.forEach(func(li s3.LineItem) error {
+		return write(li)
+	})
+}
+
+// forEach calls f for each line item of the export, fetching the pages as
+// needed, then releases the scroll context.
+func (e Export) forEach(f func(s3.LineItem) error) error {
+	defer e.clear()
+	res := e.first
+	for res != nil {
+		for _, hit := range res.Hits.Hits {
+			var li s3.LineItem
+			if err := json.Unmarshal(*hit.Source, &li); err != nil {
+				return err
+			}
+			if err := f(li); err != nil {
+				return err
+			}
+		}
+		var err error
+		if res, err = e.scroll.Do(e.ctx); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clear releases the scroll context of the export. It is not tied to the
+// request context, which may be cancelled already.
+func (e Export) clear() {
+	e.scroll.Clear(context.Background())
+}
@@ -0,0 +1,89 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package lineitems
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/trackit/jsonlog"
+	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+)
+
+// lineItemsQueryArgs are the query args of the line items export.
+var lineItemsQueryArgs = []routes.QueryArg{
+	routes.AwsAccountsOptionalQueryArg,
+	routes.DateBeginQueryArg,
+	routes.DateEndQueryArg,
+}
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getLineItems).With(
+			db.RequestTransaction{Db: db.Db},
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.QueryArgs(lineItemsQueryArgs),
+			routes.Documentation{
+				Summary:     "export the line items",
+				Description: "Responds with the line items of the cost and usage reports used between the begin and end dates, as CSV or newline delimited JSON depending on the Accept header, or with a 406 status if neither is accepted. The export is streamed.",
+			},
+		),
+	}.H().Register("/costs/lineitems")
+}
+
+// getLineItems is a route handler which exports the line items of the
+// caller's AWS accounts.
+func getLineItems(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	user := a[users.AuthenticatedUser].(users.User)
+	tx := a[db.Transaction].(*sql.Tx)
+	accounts := []string{}
+	if a[lineItemsQueryArgs[0]] != nil {
+		accounts = a[lineItemsQueryArgs[0]].([]string)
+	}
+	begin := a[lineItemsQueryArgs[1]].(time.Time)
+	end := a[lineItemsQueryArgs[2]].(time.Time).Add(24*time.Hour - time.Second)
+	if end.Before(begin) {
+		return http.StatusBadRequest, errors.New("The end date must not be before the begin date.")
+	}
+	// The content type is negotiated before the export opens its scroll, which
+	// would otherwise be left open on a 406 response.
+	if offers := (Export{}).ContentTypes(); !routes.AcceptsContentTypes(r, offers) {
+		return http.StatusNotAcceptable, fmt.Errorf("Acceptable content types are %s.", strings.Join(offers, ", "))
+	}
+	accountsAndIndexes, returnCode, err := es.GetAccountsAndIndexes(accounts, user, tx, s3.IndexPrefixLineItem)
+	if err != nil {
+		return returnCode, err
+	}
+	export, err := NewExport(r.Context(), accountsAndIndexes.Accounts, accountsAndIndexes.Indexes, begin, end)
+	if err != nil && !elastic.IsNotFound(err) {
+		l.Error("Failed to export line items.", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to export line items.")
+	}
+	return http.StatusOK, export
+}
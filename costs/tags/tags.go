@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/es"
 	"github.com/trackit/trackit-server/routes"
@@ -65,20 +66,26 @@ var tagsValuesQueryArgs = []routes.QueryArg{
 	},
 	routes.QueryArg{
 		Name:        "by",
-		Description: "Criteria for the ES aggregation: product, availabilityzone, region or account.",
+		Description: "Criteria for the ES aggregation: product, availabilityzone, region, account or lineitemtype.",
 		Type:        routes.QueryArgString{},
 		Optional:    false,
 	},
+	routes.CostTypeOptionalQueryArg,
+	routes.LineItemTypesIncludeOptionalQueryArg,
+	routes.LineItemTypesExcludeOptionalQueryArg,
 }
 
 // tagsValuesQueryParams will store the parsed query params for /tags/values endpoint
 type tagsValuesQueryParams struct {
-	AccountList []string  `json:"awsAccounts"`
-	IndexList   []string  `json:"indexes"`
-	DateBegin   time.Time `json:"begin"`
-	DateEnd     time.Time `json:"end"`
-	TagsKeys    []string  `json:"keys"`
-	By          string    `json:"by"`
+	AccountList   []string              `json:"awsAccounts"`
+	IndexList     []string              `json:"indexes"`
+	DateBegin     time.Time             `json:"begin"`
+	DateEnd       time.Time             `json:"end"`
+	TagsKeys      []string              `json:"keys"`
+	By            string                `json:"by"`
+	LineItemTypes s3.LineItemTypeFilter `json:"-"`
+	CostField     string                `json:"-"`
+	Conversion    currency.Conversion   `json:"-"`
 }
 
 // getTagsValues returns tags and their values (cost) based on the query params, in JSON format.
@@ -108,6 +115,18 @@ func getTagsValues(request *http.Request, a routes.Arguments) (int, interface{})
 	if getTagsValuesFilter(parsedParams.By).Filter == "error" {
 		return http.StatusBadRequest, errors.New("Invalid filter: " + parsedParams.By)
 	}
+	var costType string
+	if a[tagsValuesQueryArgs[5]] != nil {
+		costType = a[tagsValuesQueryArgs[5]].(string)
+	}
+	if parsedParams.CostField, err = s3.CostTypeField(costType); err != nil {
+		return http.StatusBadRequest, err
+	} else if parsedParams.LineItemTypes, err = s3.LineItemTypeFilterFromArguments(a); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if parsedParams.Conversion, err = currency.ForUser(tx, user); err != nil {
+		return http.StatusInternalServerError, errors.New("could not get exchange rates")
+	}
 	return getTagsValuesWithParsedParams(request.Context(), parsedParams)
 }
 
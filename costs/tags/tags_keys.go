@@ -81,7 +81,7 @@ func makeElasticSearchRequestForTagsKeys(ctx context.Context, params tagsKeysQue
 	search := client.Search().Index(index).Size(0).Query(query)
 	search.Aggregation("data", elastic.NewNestedAggregation().Path("tags").
 		SubAggregation("keys", elastic.NewTermsAggregation().Field("tags.key").Size(maxAggregationSize)))
-	res, err := search.Do(ctx)
+	res, err := es.DoSearch(ctx, "costs/tags/keys", search)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -129,19 +129,19 @@ func makeElasticSearchRequestForTagsValues(ctx context.Context, params tagsValue
 	index := strings.Join(params.IndexList, ",")
 	aggregation := elastic.NewReverseNestedAggregation().
 		SubAggregation("filter", elastic.NewTermsAggregation().Field(filter.Filter).Size(maxAggregationSize).
-			SubAggregation("cost", elastic.NewSumAggregation().Field("unblendedCost")))
+			SubAggregation("cost", params.Conversion.SumAggregation(params.CostField)))
 	if filter.Type == "time" {
 		aggregation = elastic.NewReverseNestedAggregation().
 			SubAggregation("filter", elastic.NewDateHistogramAggregation().
 				Field("usageStartDate").MinDocCount(0).Interval(filter.Filter).
-					SubAggregation("cost", elastic.NewSumAggregation().Field("unblendedCost")))
+					SubAggregation("cost", params.Conversion.SumAggregation(params.CostField)))
 	}
 	search := client.Search().Index(index).Size(0).Query(query)
 	search.Aggregation("data", elastic.NewNestedAggregation().Path("tags").
 		SubAggregation("keys", elastic.NewTermsAggregation().Field("tags.key").Size(maxAggregationSize).
 			SubAggregation("tags", elastic.NewTermsAggregation().Field("tags.tag").Size(maxAggregationSize).
 				SubAggregation("rev", aggregation))))
-	res, err := search.Do(ctx)
+	res, err := es.DoSearch(ctx, "costs/tags/values", search)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -170,6 +170,9 @@ func getTagsValuesQuery(params tagsValuesQueryParams) *elastic.BoolQuery {
 	}
 	query = query.Filter(elastic.NewRangeQuery("usageStartDate").
 		From(params.DateBegin).To(params.DateEnd))
+	if typeQuery := params.LineItemTypes.Query(); typeQuery != nil {
+		query = query.Filter(typeQuery)
+	}
 	return query
 }
 
@@ -189,6 +192,7 @@ func getTagsValuesFilter(filter string) FilterType {
 		"region":           {"region",          "term"},
 		"account":          {"usageAccountId",  "term"},
 		"availabilityzone": {"availabilityZone","term"},
+		"lineitemtype":     {"lineItemType",    "term"},
 		"day":              {"day",             "time"},
 		"week":             {"week",            "time"},
 		"month":            {"month",           "time"},
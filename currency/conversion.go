@@ -0,0 +1,156 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package currency
+
+import (
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/users"
+)
+
+// Conversion converts the costs aggregated by ElasticSearch queries to a
+// target currency. Its zero value converts to Base without any rate, so that
+// costs in other currencies make the query fail rather than being summed as
+// they are. Currencies lists the currencies of the line items to sum, as set
+// by WithCurrencies; when it is nil they are unknown and every line item is
+// converted.
+type Conversion struct {
+	Target     string
+	Rates      Rates
+	Currencies []string
+}
+
+// maxCurrencies is the maximum number of currencies CurrenciesAggregation
+// lists. Line items are seldom billed in more than a couple of them.
+const maxCurrencies = 100
+
+// costScript is the painless script returning the cost of a line item in the
+// target currency, converted at the line item's usage start date. Line items
+// ingested before a cost field existed fall back to their unblended cost. Its
+// parameters are built by Conversion.script.
+const costScript = `
+double rateAt(def rates, String base, String currency, long date) {
+	if (currency == base) {
+		return 1.0;
+	}
+	def points = rates[currency];
+	if (points == null || points.isEmpty()) {
+		throw new IllegalArgumentException('No exchange rate for currency ' + currency + '.');
+	}
+	double rate = points[0][1];
+	for (def point : points) {
+		if (point[0] > date) {
+			break;
+		}
+		rate = point[1];
+	}
+	return rate;
+}
+double cost = 0;
+if (doc.containsKey(params.field) && !doc[params.field].empty) {
+	cost = doc[params.field].value;
+} else if (!doc['unblendedCost'].empty) {
+	cost = doc['unblendedCost'].value;
+}
+String currency = doc['currencyCode'].empty || doc['currencyCode'].value == '' ? params.base : doc['currencyCode'].value;
+if (cost == 0 || currency == params.target) {
+	return cost;
+}
+long date = doc['usageStartDate'].date.getMillis();
+return cost * rateAt(params.rates, params.base, currency, date) / rateAt(params.rates, params.base, params.target, date);
+`
+
+// NewConversion returns a conversion to a currency, with the exchange rates
+// stored in the database. An empty target stands for Base.
+func NewConversion(db models.XODB, target string) (Conversion, error) {
+	rates, err := GetRates(db)
+	if err != nil {
+		return Conversion{}, err
+	} else if !rates.Has(target) {
+		return Conversion{}, ErrNoRate{target}
+	}
+	return Conversion{Target: code(target), Rates: rates}, nil
+}
+
+// ForUser returns the conversion to the display currency of a user.
+func ForUser(db models.XODB, user users.User) (Conversion, error) {
+	return NewConversion(db, user.Currency)
+}
+
+// CurrenciesAggregation returns an aggregation listing the currencies of
+// line items, the ones without a currency code being listed as an empty
+// string. The keys of its buckets are meant to be passed to WithCurrencies.
+func CurrenciesAggregation() *elastic.TermsAggregation {
+	return elastic.NewTermsAggregation().Field("currencyCode").Missing("").Size(maxCurrencies)
+}
+
+// WithCurrencies returns the conversion of line items in the currencies
+// listed by a CurrenciesAggregation.
+func (c Conversion) WithCurrencies(currencies []string) Conversion {
+	c.Currencies = append([]string{}, currencies...)
+	return c
+}
+
+// needed returns whether some line items may not be in the target currency.
+func (c Conversion) needed() bool {
+	if c.Currencies == nil {
+		return true
+	}
+	for _, currency := range c.Currencies {
+		if code(currency) != code(c.Target) {
+			return true
+		}
+	}
+	return false
+}
+
+// SumAggregation returns an aggregation summing a cost field of line items
+// in the target currency. When the line items are all in the target
+// currency, the unblended cost is summed as it is. Otherwise, and for the
+// other cost fields which older line items lack, a script converts each line
+// item, falling back to its unblended cost.
+func (c Conversion) SumAggregation(field string) *elastic.SumAggregation {
+	if !c.needed() && field == "unblendedCost" {
+		return elastic.NewSumAggregation().Field(field)
+	}
+	return elastic.NewSumAggregation().Script(c.script(field))
+}
+
+// script builds the script converting the cost field of line items.
+func (c Conversion) script(field string) *elastic.Script {
+	rates := make(map[string]interface{}, len(c.Rates))
+	for currency, rs := range c.Rates {
+		points := make([][]interface{}, len(rs))
+		for i, r := range rs {
+			points[i] = []interface{}{r.Date.UnixNano() / int64(time.Millisecond), r.Rate}
+		}
+		rates[currency] = points
+	}
+	return elastic.NewScript(costScript).Lang("painless").Params(map[string]interface{}{
+		"field":  field,
+		"base":   Base,
+		"target": code(c.Target),
+		"rates":  rates,
+	})
+}
+
+// Convert converts an amount in a currency to the target currency, at a
+// date.
+func (c Conversion) Convert(amount float64, from string, date time.Time) (float64, error) {
+	return c.Rates.Convert(amount, from, c.Target, date)
+}
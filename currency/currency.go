@@ -0,0 +1,131 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package currency converts costs between currencies with dated exchange
+// rates, so that costs billed in different currencies are never summed as
+// they are.
+package currency
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/trackit/trackit-server/models"
+)
+
+// Base is the currency exchange rates are expressed against. Costs without a
+// currency code are considered to be in Base.
+const Base = "USD"
+
+type (
+	// Rate is the value of one unit of a currency in Base, from a date on.
+	Rate struct {
+		Date time.Time `json:"date"`
+		Rate float64   `json:"rate"`
+	}
+
+	// Rates holds the rates of currencies, sorted by date, indexed by
+	// currency code. Base has no rates.
+	Rates map[string][]Rate
+
+	// ErrNoRate is returned when a currency has no exchange rate.
+	ErrNoRate struct {
+		Currency string
+	}
+)
+
+var codeRegexp = regexp.MustCompile(`^[A-Z]{3}$`)
+
+func (e ErrNoRate) Error() string {
+	return fmt.Sprintf("No exchange rate for currency %s.", e.Currency)
+}
+
+// ValidCode returns whether a string is an ISO 4217 currency code, e.g. EUR.
+func ValidCode(code string) bool {
+	return codeRegexp.MatchString(code)
+}
+
+// GetRates retrieves all the exchange rates from the database.
+func GetRates(db models.XODB) (Rates, error) {
+	dbRates, err := models.ExchangeRates(db)
+	if err != nil {
+		return nil, err
+	}
+	rates := make(Rates)
+	for _, dbRate := range dbRates {
+		rates.Add(dbRate.Currency, Rate{dbRate.Date, dbRate.Rate})
+	}
+	return rates, nil
+}
+
+// Add adds a rate to a currency, keeping the currency's rates sorted.
+func (r Rates) Add(currency string, rate Rate) {
+	rates := append(r[currency], rate)
+	sort.SliceStable(rates, func(i, j int) bool {
+		return rates[i].Date.Before(rates[j].Date)
+	})
+	r[currency] = rates
+}
+
+// Has returns whether costs in a currency can be converted.
+func (r Rates) Has(currency string) bool {
+	return code(currency) == Base || len(r[currency]) > 0
+}
+
+// At returns the value of one unit of a currency in Base at a date. It is the
+// latest rate set at or before the date, or the earliest rate for dates
+// before the first one.
+func (r Rates) At(currency string, date time.Time) (float64, error) {
+	if code(currency) == Base {
+		return 1, nil
+	}
+	rates := r[currency]
+	if len(rates) == 0 {
+		return 0, ErrNoRate{currency}
+	}
+	rate := rates[0].Rate
+	for _, dr := range rates[1:] {
+		if dr.Date.After(date) {
+			break
+		}
+		rate = dr.Rate
+	}
+	return rate, nil
+}
+
+// Convert converts an amount from a currency to another at a date.
+func (r Rates) Convert(amount float64, from, to string, date time.Time) (float64, error) {
+	if code(from) == code(to) || amount == 0 {
+		return amount, nil
+	}
+	fromRate, err := r.At(from, date)
+	if err != nil {
+		return 0, err
+	}
+	toRate, err := r.At(to, date)
+	if err != nil {
+		return 0, err
+	}
+	return amount * fromRate / toRate, nil
+}
+
+// code returns a currency code, or Base if it is empty.
+func code(currency string) string {
+	if currency == "" {
+		return Base
+	}
+	return currency
+}
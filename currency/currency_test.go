@@ -0,0 +1,150 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package currency
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, _ := time.Parse("2006-01-02", s)
+	return d
+}
+
+func testRates() Rates {
+	rates := make(Rates)
+	rates.Add("EUR", Rate{date("2018-02-01"), 1.25})
+	rates.Add("EUR", Rate{date("2018-01-01"), 1.2})
+	rates.Add("GBP", Rate{date("2018-01-01"), 1.5})
+	return rates
+}
+
+func TestRatesAt(t *testing.T) {
+	rates := testRates()
+	for _, c := range []struct {
+		currency string
+		date     string
+		expected float64
+	}{
+		{"EUR", "2017-06-01", 1.2},
+		{"EUR", "2018-01-15", 1.2},
+		{"EUR", "2018-02-01", 1.25},
+		{"EUR", "2019-01-01", 1.25},
+		{"USD", "2018-01-15", 1},
+		{"", "2018-01-15", 1},
+	} {
+		if rate, err := rates.At(c.currency, date(c.date)); err != nil {
+			t.Errorf("Rate of %q at %s should not fail: %s", c.currency, c.date, err.Error())
+		} else if rate != c.expected {
+			t.Errorf("Rate of %q at %s should be %f, is %f.", c.currency, c.date, c.expected, rate)
+		}
+	}
+	if _, err := rates.At("JPY", date("2018-01-15")); err != (ErrNoRate{"JPY"}) {
+		t.Errorf("Rate of JPY should fail with ErrNoRate, got %v.", err)
+	}
+}
+
+func TestRatesConvert(t *testing.T) {
+	rates := testRates()
+	for _, c := range []struct {
+		amount   float64
+		from     string
+		to       string
+		expected float64
+	}{
+		{10, "EUR", "USD", 12},
+		{12, "USD", "EUR", 10},
+		{10, "GBP", "EUR", 12.5},
+		{10, "", "USD", 10},
+		{0, "JPY", "USD", 0},
+	} {
+		if converted, err := rates.Convert(c.amount, c.from, c.to, date("2018-01-15")); err != nil {
+			t.Errorf("Converting %f %s to %s should not fail: %s", c.amount, c.from, c.to, err.Error())
+		} else if converted != c.expected {
+			t.Errorf("Converting %f %s to %s should give %f, gave %f.", c.amount, c.from, c.to, c.expected, converted)
+		}
+	}
+	if _, err := rates.Convert(10, "JPY", "USD", date("2018-01-15")); err == nil {
+		t.Errorf("Converting from a currency without rates should fail.")
+	}
+}
+
+func TestRatesHas(t *testing.T) {
+	rates := testRates()
+	for currency, expected := range map[string]bool{"": true, "USD": true, "EUR": true, "JPY": false} {
+		if rates.Has(currency) != expected {
+			t.Errorf("Has(%q) should be %t.", currency, expected)
+		}
+	}
+}
+
+func TestReadRatesCsv(t *testing.T) {
+	csv := "currency,date,rate\neur,2018-01-01,1.2\nGBP, 2018-01-01 ,1.5\n"
+	expected := []DatedRate{
+		{"EUR", "2018-01-01", 1.2},
+		{"GBP", "2018-01-01", 1.5},
+	}
+	if rates, err := ReadRatesCsv(strings.NewReader(csv)); err != nil {
+		t.Errorf("Reading rates should not fail: %s", err.Error())
+	} else if !reflect.DeepEqual(rates, expected) {
+		t.Errorf("Rates should be %v, are %v.", expected, rates)
+	}
+	if _, err := ReadRatesCsv(strings.NewReader("EUR,2018-01-01,1.2\nEUR,2018-02-01,abc\n")); err == nil {
+		t.Errorf("Reading an invalid rate should fail.")
+	}
+}
+
+func TestValidateRates(t *testing.T) {
+	if err := ValidateRates([]DatedRate{{"EUR", "2018-01-01", 1.2}}); err != nil {
+		t.Errorf("Valid rates should pass: %s", err.Error())
+	}
+	for _, rate := range []DatedRate{
+		{"EUR", "01/01/2018", 1.2},
+		{"USD", "2018-01-01", 1},
+		{"euro", "2018-01-01", 1.2},
+		{"EUR", "2018-01-01", 0},
+	} {
+		if ValidateRates([]DatedRate{rate}) == nil {
+			t.Errorf("Rate %v should be invalid.", rate)
+		}
+	}
+}
+
+func TestSumAggregation(t *testing.T) {
+	for _, c := range []struct {
+		conversion Conversion
+		field      string
+		script     bool
+	}{
+		{Conversion{Target: "EUR"}, "unblendedCost", true},
+		{Conversion{}.WithCurrencies([]string{""}), "unblendedCost", false},
+		{Conversion{Target: "EUR"}.WithCurrencies([]string{"EUR"}), "unblendedCost", false},
+		{Conversion{Target: "EUR"}.WithCurrencies([]string{"EUR", "USD"}), "unblendedCost", true},
+		{Conversion{Target: "EUR"}.WithCurrencies([]string{"EUR"}), "amortizedCost", true},
+	} {
+		src, err := c.conversion.SumAggregation(c.field).Source()
+		if err != nil {
+			t.Fatalf("Building the aggregation should not fail: %s", err.Error())
+		}
+		raw, _ := json.Marshal(src)
+		if script := strings.Contains(string(raw), `"script"`); script != c.script {
+			t.Errorf("Summing %s with %v should use a script: %t, got %s.", c.field, c.conversion.Currencies, c.script, raw)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package currency
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+)
+
+// dateFormat is the format of the dates of exchange rates.
+const dateFormat = "2006-01-02"
+
+type (
+	// CurrencyBody is the body required to select the display currency.
+	CurrencyBody struct {
+		Currency string `json:"currency" req:"nonzero"`
+	}
+
+	// CurrencyResponse is the body sent by the display currency routes.
+	// Available lists the currencies which can be selected.
+	CurrencyResponse struct {
+		Currency  string   `json:"currency"`
+		Available []string `json:"available"`
+	}
+
+	// DatedRate is the rate of a currency from a date on, as it is sent to
+	// the exchange rates route or read from a file.
+	DatedRate struct {
+		Currency string  `json:"currency" req:"nonzero"`
+		Date     string  `json:"date"     req:"nonzero"`
+		Rate     float64 `json:"rate"     req:"nonzero"`
+	}
+
+	// ExchangeRatesBody is the body required to set exchange rates.
+	ExchangeRatesBody struct {
+		Rates []DatedRate `json:"rates" req:"nonzero"`
+	}
+)
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getCurrency).With(
+			db.RequestTransaction{db.Db},
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.Documentation{
+				Summary:     "get the display currency",
+				Description: "Responds with the currency costs are converted to, along with the currencies which can be selected.",
+			},
+		),
+		http.MethodPost: routes.H(postCurrency).With(
+			db.RequestTransaction{db.Db},
+			users.RequireAuthenticatedUser{users.ViewerCannot},
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{CurrencyBody{"EUR"}},
+			routes.Documentation{
+				Summary:     "select the display currency",
+				Description: "Selects the currency costs are converted to. The currency must have an exchange rate.",
+			},
+		),
+	}.H().Register("/user/currency")
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getExchangeRates).With(
+			routes.Documentation{
+				Summary:     "get the exchange rates",
+				Description: "Responds with the rates of the currencies against " + Base + ", by currency. This route is restricted to administrators.",
+			},
+		),
+		http.MethodPost: routes.H(postExchangeRates).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{ExchangeRatesBody{[]DatedRate{{"EUR", "2018-01-01", 1.2}}}},
+			routes.Documentation{
+				Summary:     "set exchange rates",
+				Description: "Sets the rates of currencies against " + Base + " from a date on, replacing those already set for the same dates. This route is restricted to administrators.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		users.RequireAuthenticatedUser{users.ViewerCannot},
+		users.RequireAdminUser{},
+	).Register("/admin/exchange-rates")
+}
+
+// getCurrency is a route handler which returns the display currency of the
+// user.
+func getCurrency(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	user := a[users.AuthenticatedUser].(users.User)
+	tx := a[db.Transaction].(*sql.Tx)
+	rates, err := GetRates(tx)
+	if err != nil {
+		l.Error("Failed to get exchange rates.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to get currency.")
+	}
+	return http.StatusOK, currencyResponse(user.Currency, rates)
+}
+
+// postCurrency is a route handler which selects the display currency of the
+// user.
+func postCurrency(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body CurrencyBody
+	routes.MustRequestBody(a, &body)
+	user := a[users.AuthenticatedUser].(users.User)
+	tx := a[db.Transaction].(*sql.Tx)
+	rates, err := GetRates(tx)
+	if err != nil {
+		l.Error("Failed to get exchange rates.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to update currency.")
+	}
+	body.Currency = strings.ToUpper(body.Currency)
+	if !ValidCode(body.Currency) || !rates.Has(body.Currency) {
+		return http.StatusBadRequest, ErrNoRate{body.Currency}
+	}
+	dbUser, err := models.UserByID(tx, user.Id)
+	if err != nil {
+		l.Error("Failed to get user.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to update currency.")
+	}
+	dbUser.Currency = body.Currency
+	if err := dbUser.Save(tx); err != nil {
+		l.Error("Failed to save currency.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to update currency.")
+	}
+	return http.StatusOK, currencyResponse(body.Currency, rates)
+}
+
+// currencyResponse builds the response of the display currency routes.
+func currencyResponse(currency string, rates Rates) CurrencyResponse {
+	available := []string{Base}
+	for c := range rates {
+		if c != Base {
+			available = append(available, c)
+		}
+	}
+	sort.Strings(available[1:])
+	return CurrencyResponse{code(currency), available}
+}
+
+// getExchangeRates is a route handler which returns all the exchange rates.
+func getExchangeRates(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	rates, err := GetRates(tx)
+	if err != nil {
+		l.Error("Failed to get exchange rates.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to get exchange rates.")
+	}
+	return http.StatusOK, rates
+}
+
+// postExchangeRates is a route handler which sets exchange rates.
+func postExchangeRates(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body ExchangeRatesBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	if err := ValidateRates(body.Rates); err != nil {
+		return http.StatusBadRequest, err
+	} else if err := SaveRates(tx, body.Rates); err != nil {
+		l.Error("Failed to save exchange rates.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to save exchange rates.")
+	}
+	rates, err := GetRates(tx)
+	if err != nil {
+		l.Error("Failed to get exchange rates.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to get exchange rates.")
+	}
+	return http.StatusOK, rates
+}
+
+// ValidateRates checks exchange rates before they are saved.
+func ValidateRates(rates []DatedRate) error {
+	for _, rate := range rates {
+		if _, err := time.Parse(dateFormat, rate.Date); err != nil {
+			return fmt.Errorf("Invalid date %q, expected YYYY-MM-DD.", rate.Date)
+		} else if !ValidCode(rate.Currency) || rate.Currency == Base {
+			return fmt.Errorf("Invalid currency %q.", rate.Currency)
+		} else if rate.Rate <= 0 {
+			return fmt.Errorf("Invalid rate for currency %s.", rate.Currency)
+		}
+	}
+	return nil
+}
+
+// SaveRates validates and saves exchange rates. Rates already set for the
+// same currency and date are replaced.
+func SaveRates(db models.XODB, rates []DatedRate) error {
+	if err := ValidateRates(rates); err != nil {
+		return err
+	}
+	for _, rate := range rates {
+		date, _ := time.Parse(dateFormat, rate.Date)
+		if err := models.SaveExchangeRate(db, rate.Currency, date, rate.Rate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadRatesCsv reads exchange rates from CSV records of a currency code, a
+// date formatted as YYYY-MM-DD and the value of one unit of the currency in
+// Base. A header line is skipped.
+func ReadRatesCsv(reader io.Reader) ([]DatedRate, error) {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rates := make([]DatedRate, 0, len(records))
+	for i, record := range records {
+		if len(record) != 3 {
+			return nil, fmt.Errorf("line %d: expected 3 fields, got %d", i+1, len(record))
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil && i == 0 {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate: %s", i+1, err.Error())
+		}
+		rates = append(rates, DatedRate{
+			Currency: strings.ToUpper(strings.TrimSpace(record[0])),
+			Date:     strings.TrimSpace(record[1]),
+			Rate:     rate,
+		})
+	}
+	return rates, nil
+}
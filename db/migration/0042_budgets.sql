@@ -0,0 +1,41 @@
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE budget (
+	id           INTEGER      NOT NULL AUTO_INCREMENT,
+	created      TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id      INTEGER      NOT NULL,
+	name         VARCHAR(255) NOT NULL,
+	amount       DOUBLE       NOT NULL,
+	period       VARCHAR(16)  NOT NULL,
+	aws_accounts BLOB         NULL DEFAULT NULL,
+	product      VARCHAR(255) NOT NULL DEFAULT "",
+	tag_key      VARCHAR(255) NOT NULL DEFAULT "",
+	tag_value    VARCHAR(255) NOT NULL DEFAULT "",
+	thresholds   VARCHAR(255) NOT NULL DEFAULT "",
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE TABLE budget_alert (
+	id           INTEGER      NOT NULL AUTO_INCREMENT,
+	created      TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	budget_id    INTEGER      NOT NULL,
+	period_begin DATETIME     NOT NULL,
+	threshold    INTEGER      NOT NULL,
+	kind         VARCHAR(16)  NOT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT UNIQUE (budget_id, period_begin, threshold, kind),
+	CONSTRAINT foreign_budget FOREIGN KEY (budget_id) REFERENCES budget(id) ON DELETE CASCADE
+);
@@ -0,0 +1,28 @@
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE user ADD token_generation INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE user_refresh_token (
+	id         INTEGER   NOT NULL AUTO_INCREMENT,
+	created    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id    INTEGER   NOT NULL,
+	token_hash CHAR(64)  NOT NULL,
+	generation INTEGER   NOT NULL,
+	expires    DATETIME  NOT NULL,
+	used       BOOLEAN   NOT NULL DEFAULT 0,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT unique_token_hash UNIQUE KEY (token_hash),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
@@ -0,0 +1,37 @@
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE user ADD viewers_require_two_factor BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE TABLE user_two_factor (
+	id           INTEGER     NOT NULL AUTO_INCREMENT,
+	created      TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id      INTEGER     NOT NULL,
+	secret       VARCHAR(64) NOT NULL,
+	enabled      BOOLEAN     NOT NULL DEFAULT 0,
+	last_counter BIGINT      NOT NULL DEFAULT 0,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT unique_user UNIQUE KEY (user_id),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE TABLE user_recovery_code (
+	id        INTEGER   NOT NULL AUTO_INCREMENT,
+	created   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id   INTEGER   NOT NULL,
+	code_hash CHAR(64)  NOT NULL,
+	used      BOOLEAN   NOT NULL DEFAULT 0,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
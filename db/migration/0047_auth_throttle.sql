@@ -0,0 +1,21 @@
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE auth_throttle (
+	throttle_key VARCHAR(255) NOT NULL,
+	failures     INTEGER      NOT NULL DEFAULT 0,
+	window_start DATETIME     NOT NULL,
+	locked_until DATETIME     NULL DEFAULT NULL,
+	CONSTRAINT PRIMARY KEY (throttle_key)
+);
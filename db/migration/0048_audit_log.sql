@@ -0,0 +1,33 @@
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE audit_log (
+	id             INTEGER      NOT NULL AUTO_INCREMENT,
+	created        TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	owner_user_id  INTEGER      NOT NULL,
+	aws_account_id INTEGER          NULL DEFAULT NULL,
+	actor_user_id  INTEGER          NULL DEFAULT NULL,
+	actor_email    VARCHAR(254) NOT NULL DEFAULT "",
+	action         VARCHAR(64)  NOT NULL,
+	target_type    VARCHAR(64)  NOT NULL DEFAULT "",
+	target_id      INTEGER          NULL DEFAULT NULL,
+	request_id     VARCHAR(64)  NOT NULL DEFAULT "",
+	ip             VARCHAR(64)  NOT NULL DEFAULT "",
+	before_payload BLOB             NULL DEFAULT NULL,
+	after_payload  BLOB             NULL DEFAULT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	INDEX owner_user (owner_user_id, id),
+	INDEX aws_account (aws_account_id, id),
+	CONSTRAINT foreign_owner_user FOREIGN KEY (owner_user_id) REFERENCES user(id) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE budget ADD currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE promotional_credit ADD currency CHAR(3) NOT NULL DEFAULT 'USD';
+UPDATE budget JOIN user ON user.id = budget.user_id SET budget.currency = user.currency;
+UPDATE promotional_credit JOIN user ON user.id = promotional_credit.user_id SET promotional_credit.currency = user.currency;
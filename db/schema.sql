@@ -900,3 +900,393 @@ CREATE TABLE aws_pricing (
 	CONSTRAINT PRIMARY KEY (id),
 	CONSTRAINT UNIQUE KEY (product)
 );
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE user ADD (
+  anomalies_detector VARCHAR(255) NULL DEFAULT NULL
+);
+
+ALTER TABLE aws_account ADD (
+  anomalies_detector VARCHAR(255) NULL DEFAULT NULL
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE budget (
+	id           INTEGER      NOT NULL AUTO_INCREMENT,
+	created      TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id      INTEGER      NOT NULL,
+	name         VARCHAR(255) NOT NULL,
+	amount       DOUBLE       NOT NULL,
+	period       VARCHAR(16)  NOT NULL,
+	aws_accounts BLOB         NULL DEFAULT NULL,
+	product      VARCHAR(255) NOT NULL DEFAULT "",
+	tag_key      VARCHAR(255) NOT NULL DEFAULT "",
+	tag_value    VARCHAR(255) NOT NULL DEFAULT "",
+	thresholds   VARCHAR(255) NOT NULL DEFAULT "",
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE TABLE budget_alert (
+	id           INTEGER      NOT NULL AUTO_INCREMENT,
+	created      TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	budget_id    INTEGER      NOT NULL,
+	period_begin DATETIME     NOT NULL,
+	threshold    INTEGER      NOT NULL,
+	kind         VARCHAR(16)  NOT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT UNIQUE (budget_id, period_begin, threshold, kind),
+	CONSTRAINT foreign_budget FOREIGN KEY (budget_id) REFERENCES budget(id) ON DELETE CASCADE
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE periodic_task_run (
+	id         INTEGER      NOT NULL AUTO_INCREMENT,
+	created    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	task       VARCHAR(255) NOT NULL,
+	worker_id  VARCHAR(255) NOT NULL,
+	triggered  BOOLEAN      NOT NULL DEFAULT 0,
+	started    DATETIME     NOT NULL,
+	duration   DOUBLE       NOT NULL,
+	error      TEXT         NOT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	INDEX task_started (task, started)
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE user_api_token (
+	id         INTEGER      NOT NULL AUTO_INCREMENT,
+	created    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id    INTEGER      NOT NULL,
+	name       VARCHAR(255) NOT NULL,
+	token_hash CHAR(64)     NOT NULL,
+	read_only  BOOLEAN      NOT NULL DEFAULT 0,
+	expires    DATETIME     NULL DEFAULT NULL,
+	last_used  DATETIME     NULL DEFAULT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT unique_token_hash UNIQUE KEY (token_hash),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE user ADD token_generation INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE user_refresh_token (
+	id         INTEGER   NOT NULL AUTO_INCREMENT,
+	created    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id    INTEGER   NOT NULL,
+	token_hash CHAR(64)  NOT NULL,
+	generation INTEGER   NOT NULL,
+	expires    DATETIME  NOT NULL,
+	used       BOOLEAN   NOT NULL DEFAULT 0,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT unique_token_hash UNIQUE KEY (token_hash),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE user ADD viewers_require_two_factor BOOLEAN NOT NULL DEFAULT 0;
+
+CREATE TABLE user_two_factor (
+	id           INTEGER     NOT NULL AUTO_INCREMENT,
+	created      TIMESTAMP   NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id      INTEGER     NOT NULL,
+	secret       VARCHAR(64) NOT NULL,
+	enabled      BOOLEAN     NOT NULL DEFAULT 0,
+	last_counter BIGINT      NOT NULL DEFAULT 0,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT unique_user UNIQUE KEY (user_id),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE TABLE user_recovery_code (
+	id        INTEGER   NOT NULL AUTO_INCREMENT,
+	created   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id   INTEGER   NOT NULL,
+	code_hash CHAR(64)  NOT NULL,
+	used      BOOLEAN   NOT NULL DEFAULT 0,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE auth_throttle (
+	throttle_key VARCHAR(255) NOT NULL,
+	failures     INTEGER      NOT NULL DEFAULT 0,
+	window_start DATETIME     NOT NULL,
+	locked_until DATETIME     NULL DEFAULT NULL,
+	CONSTRAINT PRIMARY KEY (throttle_key)
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE audit_log (
+	id             INTEGER      NOT NULL AUTO_INCREMENT,
+	created        TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	owner_user_id  INTEGER      NOT NULL,
+	aws_account_id INTEGER          NULL DEFAULT NULL,
+	actor_user_id  INTEGER          NULL DEFAULT NULL,
+	actor_email    VARCHAR(254) NOT NULL DEFAULT "",
+	action         VARCHAR(64)  NOT NULL,
+	target_type    VARCHAR(64)  NOT NULL DEFAULT "",
+	target_id      INTEGER          NULL DEFAULT NULL,
+	request_id     VARCHAR(64)  NOT NULL DEFAULT "",
+	ip             VARCHAR(64)  NOT NULL DEFAULT "",
+	before_payload BLOB             NULL DEFAULT NULL,
+	after_payload  BLOB             NULL DEFAULT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	INDEX owner_user (owner_user_id, id),
+	INDEX aws_account (aws_account_id, id),
+	CONSTRAINT foreign_owner_user FOREIGN KEY (owner_user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE user ADD currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+CREATE TABLE exchange_rate (
+	id       INTEGER NOT NULL AUTO_INCREMENT,
+	currency CHAR(3) NOT NULL,
+	date     DATE    NOT NULL,
+	rate     DOUBLE  NOT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT unique_currency_date UNIQUE KEY (currency, date)
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE promotional_credit (
+	id         INTEGER      NOT NULL AUTO_INCREMENT,
+	created    TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id    INTEGER      NOT NULL,
+	name       VARCHAR(255) NOT NULL,
+	amount     DOUBLE       NOT NULL,
+	begin      DATE         NOT NULL,
+	expiration DATE         NOT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE aws_bill_repository ADD endpoint          VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE aws_bill_repository ADD access_key_id     VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE aws_bill_repository ADD secret_access_key VARCHAR(255) NOT NULL DEFAULT '';
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE user_api_token ADD generation INTEGER NOT NULL DEFAULT 0;
+
+UPDATE user_api_token JOIN user ON user.id = user_api_token.user_id
+	SET user_api_token.generation = user.token_generation;
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+CREATE TABLE user_oidc_identity (
+	id      INTEGER      NOT NULL AUTO_INCREMENT,
+	created TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	user_id INTEGER      NOT NULL,
+	issuer  VARCHAR(255) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	CONSTRAINT PRIMARY KEY (id),
+	CONSTRAINT unique_issuer_subject UNIQUE KEY (issuer, subject),
+	CONSTRAINT foreign_user FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE audit_log DROP FOREIGN KEY foreign_owner_user;
+
+--   Copyright 2018 MSolution.IO
+--
+--   Licensed under the Apache License, Version 2.0 (the "License");
+--   you may not use this file except in compliance with the License.
+--   You may obtain a copy of the License at
+--
+--       http://www.apache.org/licenses/LICENSE-2.0
+--
+--   Unless required by applicable law or agreed to in writing, software
+--   distributed under the License is distributed on an "AS IS" BASIS,
+--   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+--   See the License for the specific language governing permissions and
+--   limitations under the License.
+
+ALTER TABLE budget ADD currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE promotional_credit ADD currency CHAR(3) NOT NULL DEFAULT 'USD';
+UPDATE budget JOIN user ON user.id = budget.user_id SET budget.currency = user.currency;
+UPDATE promotional_credit JOIN user ON user.id = promotional_credit.user_id SET promotional_credit.currency = user.currency;
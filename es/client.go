@@ -15,6 +15,7 @@
 package es
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -32,6 +33,8 @@ import (
 
 var Client *elastic.Client
 
+var ErrNoClient = errors.New("ElasticSearch client is not connected")
+
 const (
 	retryCount   = 15
 	retrySeconds = 2
@@ -54,6 +57,19 @@ func init() {
 	logger.Error("Failed to connect to ElasticSearch database. Not retrying.", nil)
 }
 
+// ClusterHealth returns the health status of the ElasticSearch cluster: green,
+// yellow or red.
+func ClusterHealth(ctx context.Context) (string, error) {
+	if Client == nil {
+		return "", ErrNoClient
+	}
+	res, err := Client.ClusterHealth().Do(ctx)
+	if err != nil {
+		return "", err
+	}
+	return res.Status, nil
+}
+
 // getElasticSearchConfig retrieves the elastic.ClientOptionFunc required to
 // correctly configure the server's ElasticSearch client.
 func getElasticSearchConfig() []elastic.ClientOptionFunc {
@@ -0,0 +1,71 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package es
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/metrics"
+)
+
+var (
+	queryDuration = metrics.NewHistogram(
+		"trackit_es_query_duration_seconds",
+		"Time taken by ElasticSearch search queries, by query.",
+		nil,
+		"query",
+	)
+	queryErrorsTotal = metrics.NewCounter(
+		"trackit_es_query_errors_total",
+		"Number of failed ElasticSearch search queries, by query.",
+		"query",
+	)
+	bulkIndexedTotal = metrics.NewCounter(
+		"trackit_es_bulk_documents_indexed_total",
+		"Number of documents successfully indexed by bulk processors, by processor.",
+		"processor",
+	)
+	bulkFailedTotal = metrics.NewCounter(
+		"trackit_es_bulk_documents_failed_total",
+		"Number of documents bulk processors failed to index, by processor.",
+		"processor",
+	)
+)
+
+// DoSearch runs a search and records its duration and outcome as metrics
+// labelled with query, which should identify the call site, e.g. "costs".
+func DoSearch(ctx context.Context, query string, s *elastic.SearchService) (*elastic.SearchResult, error) {
+	start := time.Now()
+	res, err := s.Do(ctx)
+	queryDuration.Observe(time.Since(start).Seconds(), query)
+	if err != nil {
+		queryErrorsTotal.Inc(query)
+	}
+	return res, err
+}
+
+// RecordBulk records the outcome of a bulk request made by a bulk processor.
+// It is meant to be called from the bulk processor's After callback.
+func RecordBulk(processor string, reqs []elastic.BulkableRequest, resp *elastic.BulkResponse, err error) {
+	if err != nil || resp == nil {
+		bulkFailedTotal.Add(float64(len(reqs)), processor)
+	} else {
+		bulkIndexedTotal.Add(float64(len(resp.Succeeded())), processor)
+		bulkFailedTotal.Add(float64(len(resp.Failed())), processor)
+	}
+}
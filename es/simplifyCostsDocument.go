@@ -67,6 +67,24 @@ const (
 	BucketKeyAsStringKey = "key_as_string"
 	BucketValueKey       = "value"
 	BucketValueValueKey  = "value"
+	BucketDocCountKey    = "doc_count"
+)
+
+// The 'tag:<TAG_KEY>' criterion does not create a simple terms aggregation,
+// since tags are nested documents. Its aggregation is named after
+// TagChildrenKind and holds two keyed buckets: TagTaggedBucketKey for the
+// documents having the tag and TagUntaggedBucketKey for the others. The
+// tagged bucket breaks the costs down by tag value under
+// TagNestedKey.TagKeyKey.TagValueKey, each value holding the next
+// aggregations under TagReverseNestedKey.
+const (
+	TagChildrenKind      = "tag"
+	TagTaggedBucketKey   = "tagged"
+	TagUntaggedBucketKey = "(untagged)"
+	TagNestedKey         = "tag-nested"
+	TagKeyKey            = "tag-key"
+	TagValueKey          = "tag-value"
+	TagReverseNestedKey  = "tag-reverse"
 )
 
 var (
@@ -160,6 +178,8 @@ func getChildren(ctx context.Context, doc bucket) (string, []SimplifiedCostsDocu
 		logger.Error("Failed to get child key.", err.Error())
 		logger.Debug("Document is.", doc)
 		return "", nil, err
+	} else if childKey == TagChildrenKind {
+		return getTagChildren(ctx, doc)
 	} else if childAgg, ok := doc[BucketPrefix+childKey].(map[string]interface{}); !ok {
 		logger.Error(fmt.Sprintf("Failed to get buckets: value under '%s' is not an aggregation.", childKey), nil)
 		logger.Debug("Document is.", doc)
@@ -189,6 +209,74 @@ func getChildren(ctx context.Context, doc bucket) (string, []SimplifiedCostsDocu
 	}
 }
 
+// getTagChildren flattens the aggregation created for the 'tag:<TAG_KEY>'
+// criterion into one child per tag value, plus one child for the documents
+// which do not have the tag.
+func getTagChildren(ctx context.Context, doc bucket) (string, []SimplifiedCostsDocument, error) {
+	var logger = jsonlog.LoggerFromContextOrDefault(ctx)
+	tagged, okTagged := getBucket(doc, BucketPrefix+TagChildrenKind, AggBucketKey, TagTaggedBucketKey)
+	untagged, okUntagged := getBucket(doc, BucketPrefix+TagChildrenKind, AggBucketKey, TagUntaggedBucketKey)
+	tagValues, okTagValues := getBucket(tagged, TagNestedKey, TagKeyKey, TagValueKey)
+	if !okTagged || !okUntagged || !okTagValues {
+		logger.Error("Failed to get buckets: tag aggregation is malformed.", nil)
+		logger.Debug("Document is.", doc)
+		return "", nil, ErrFailedJsonParsing
+	}
+	children, ok := tagValues[AggBucketKey].([]interface{})
+	if !ok {
+		logger.Error(fmt.Sprintf("Failed to get buckets: value under '%s' is not a slice.", TagValueKey), nil)
+		logger.Debug("Document is.", doc)
+		return "", nil, ErrFailedJsonParsing
+	}
+	cs := make([]SimplifiedCostsDocument, 0, len(children)+1)
+	for _, child := range children {
+		tchild, ok := child.(bucket)
+		if !ok {
+			logger.Error(fmt.Sprintf("Child under '%s' is not a bucket.", TagValueKey), nil)
+			logger.Debug("Document is.", doc)
+			return "", nil, ErrFailedJsonParsing
+		}
+		key, err := getKey(tchild)
+		if err != nil {
+			return "", nil, err
+		}
+		reverseNested, ok := tchild[TagReverseNestedKey].(bucket)
+		if !ok {
+			logger.Error(fmt.Sprintf("Child under '%s' has no '%s' aggregation.", TagValueKey, TagReverseNestedKey), nil)
+			logger.Debug("Document is.", doc)
+			return "", nil, ErrFailedJsonParsing
+		}
+		scd, err := simplifyCostsDocumentRec(ctx, reverseNested, true)
+		if err != nil {
+			return "", nil, err
+		}
+		scd.Key = key
+		cs = append(cs, scd)
+	}
+	if docCount, _ := untagged[BucketDocCountKey].(float64); docCount > 0 {
+		scd, err := simplifyCostsDocumentRec(ctx, untagged, true)
+		if err != nil {
+			return "", nil, err
+		}
+		scd.Key = TagUntaggedBucketKey
+		cs = append(cs, scd)
+	}
+	return TagChildrenKind, cs, nil
+}
+
+// getBucket follows a path of keys in a document and returns the bucket at
+// its end, if any.
+func getBucket(doc bucket, path ...string) (bucket, bool) {
+	for _, key := range path {
+		next, ok := doc[key].(bucket)
+		if !ok {
+			return nil, false
+		}
+		doc = next
+	}
+	return doc, true
+}
+
 func getChildKey(ctx context.Context, doc map[string]interface{}) (string, error) {
 	var childKey string
 	for k := range doc {
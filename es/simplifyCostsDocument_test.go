@@ -15,6 +15,7 @@
 package es
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 )
@@ -129,3 +130,48 @@ func TestToJsonableSimpleTwoLevelsResults(t *testing.T) {
 		t.Fatalf("Expected %s but got %s", expectedResult, string(marshalled))
 	}
 }
+
+func TestSimplifyCostsDocumentTagAggregation(t *testing.T) {
+	rawDocument := json.RawMessage(`{
+	"buckets": {
+		"tagged": {
+			"doc_count": 3,
+			"value": {"value": 30},
+			"tag-nested": {
+				"doc_count": 6,
+				"tag-key": {
+					"doc_count": 3,
+					"tag-value": {
+						"doc_count_error_upper_bound": 0,
+						"sum_other_doc_count": 0,
+						"buckets": [
+							{"key": "backend", "doc_count": 2, "tag-reverse": {"doc_count": 2, "value": {"value": 20}}},
+							{"key": "frontend", "doc_count": 1, "tag-reverse": {"doc_count": 1, "value": {"value": 10}}}
+						]
+					}
+				}
+			}
+		},
+		"(untagged)": {
+			"doc_count": 1,
+			"value": {"value": 5},
+			"tag-nested": {"doc_count": 0}
+		}
+	}
+}`)
+	scd, err := simplifyCostsDocumentWithSingleAggregation(context.Background(), "by-tag", &rawDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedResult := `{
+	"tag": {
+		"(untagged)": 5,
+		"backend": 20,
+		"frontend": 10
+	}
+}`
+	marshalled, _ := json.MarshalIndent(scd.ToJsonable(), "", "\t")
+	if string(marshalled) != expectedResult {
+		t.Fatalf("Expected %s but got %s", expectedResult, string(marshalled))
+	}
+}
@@ -15,6 +15,7 @@
 package es
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/trackit/trackit-server/users"
@@ -31,3 +32,25 @@ func IndexNameForUser(u users.User, p string) string {
 func IndexNameForUserId(i int, p string) string {
 	return fmt.Sprintf("%06d-%s", i, p)
 }
+
+// DeleteIndexesForUserId deletes the indexes of a user with the given
+// prefixes. Indexes which do not exist are ignored.
+func DeleteIndexesForUserId(ctx context.Context, i int, prefixes ...string) error {
+	if Client == nil {
+		return ErrNoClient
+	}
+	indexes := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		index := IndexNameForUserId(i, p)
+		if exists, err := Client.IndexExists(index).Do(ctx); err != nil {
+			return err
+		} else if exists {
+			indexes = append(indexes, index)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	_, err := Client.DeleteIndex(indexes...).Do(ctx)
+	return err
+}
@@ -0,0 +1,298 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package metrics exposes counters and histograms in the Prometheus text
+// format. Metrics are created once, usually as package variables, and are
+// registered to DefaultRegistry which is served by Handler.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the default histogram buckets, in seconds. They suit
+// the duration of HTTP requests and database queries.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// collector is a metric family which can write itself in the text format.
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families to be exposed together. It may be used in
+// parallel.
+type Registry struct {
+	mutex      sync.RWMutex
+	collectors []collector
+}
+
+// DefaultRegistry is the Registry metrics are registered to on creation.
+var DefaultRegistry = &Registry{}
+
+// register adds a collector to the Registry. Registering two metrics with the
+// same name is a programming error and panics.
+func (r *Registry) register(c collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, rc := range r.collectors {
+		if rc.name() == c.name() {
+			panic(fmt.Sprintf("metrics: %s registered twice", c.name()))
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteTo writes all the metrics of the Registry in the text format, sorted
+// by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.RLock()
+	collectors := make([]collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mutex.RUnlock()
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].name() < collectors[j].name()
+	})
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler returns an http.Handler serving the metrics of DefaultRegistry.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		DefaultRegistry.WriteTo(w)
+	})
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// family holds what is common to all kinds of metrics: their name, help,
+// label names and the values of the series, keyed by label values.
+type family struct {
+	metricName string
+	help       string
+	labels     []string
+	mutex      sync.Mutex
+	series     map[string]*series
+}
+
+// series is a single time series of a family.
+type series struct {
+	labelValues []string
+	value       float64
+	buckets     []uint64
+	count       uint64
+}
+
+// init sets a family up with its name, help and label names.
+func (f *family) init(name, help string, labels []string) {
+	f.metricName = name
+	f.help = help
+	f.labels = labels
+	f.series = make(map[string]*series)
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// getSeries returns the series for labelValues, creating it if needed. The
+// caller must hold f.mutex.
+func (f *family) getSeries(labelValues []string, buckets int) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.metricName, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{
+			labelValues: append([]string(nil), labelValues...),
+			buckets:     make([]uint64, buckets),
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// sortedSeries returns the series of the family sorted by label values. The
+// caller must hold f.mutex.
+func (f *family) sortedSeries() []*series {
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	res := make([]*series, len(keys))
+	for i, k := range keys {
+		res[i] = f.series[k]
+	}
+	return res
+}
+
+// writeHeader writes the HELP and TYPE lines of the family.
+func (f *family) writeHeader(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, kind)
+}
+
+// writeSample writes a sample line. extraName and extraValue, if not empty,
+// are an additional label such as a histogram's "le".
+func (f *family) writeSample(w *bufio.Writer, suffix string, labelValues []string, extraName, extraValue string, value float64) {
+	w.WriteString(f.metricName)
+	w.WriteString(suffix)
+	if len(labelValues) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, v := range labelValues {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", f.labels[i], escapeLabelValue(v))
+		}
+		if extraName != "" {
+			if len(labelValues) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+// Counter is a metric whose value only goes up, such as a count of events.
+type Counter struct {
+	family
+}
+
+// NewCounter creates a Counter and registers it to DefaultRegistry. Each
+// observation must provide a value for every label.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{}
+	c.init(name, help, labels)
+	DefaultRegistry.register(c)
+	return c
+}
+
+// Add adds v, which must not be negative, to the counter for labelValues.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.getSeries(labelValues, 0).value += v
+}
+
+// Inc adds one to the counter for labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writeHeader(w, "counter")
+	for _, s := range c.sortedSeries() {
+		c.writeSample(w, "", s.labelValues, "", "", s.value)
+	}
+}
+
+// Histogram is a metric counting observations in buckets, such as the
+// durations of requests.
+type Histogram struct {
+	family
+	upperBounds []float64
+}
+
+// NewHistogram creates a Histogram and registers it to DefaultRegistry.
+// buckets are the sorted upper bounds of the buckets; DefaultBuckets is used
+// if nil. Each observation must provide a value for every label.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	h := &Histogram{upperBounds: buckets}
+	h.init(name, help, labels)
+	DefaultRegistry.register(h)
+	return h
+}
+
+// Observe adds an observation of v to the histogram for labelValues.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s := h.getSeries(labelValues, len(h.upperBounds))
+	for i, ub := range h.upperBounds {
+		if v <= ub {
+			s.buckets[i]++
+		}
+	}
+	s.value += v
+	s.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.writeHeader(w, "histogram")
+	for _, s := range h.sortedSeries() {
+		for i, ub := range h.upperBounds {
+			h.writeSample(w, "_bucket", s.labelValues, "le", formatFloat(ub), float64(s.buckets[i]))
+		}
+		h.writeSample(w, "_bucket", s.labelValues, "le", "+Inf", float64(s.count))
+		h.writeSample(w, "_sum", s.labelValues, "", "", s.value)
+		h.writeSample(w, "_count", s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// formatFloat formats a sample value as expected by the text format.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
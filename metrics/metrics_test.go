@@ -0,0 +1,78 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	c := NewCounter("test_counter_total", "A counter\nfor tests.", "route", "status")
+	c.Inc("/a", "200")
+	c.Add(2, "/a", "200")
+	c.Inc(`/"b"`, "500")
+	var buf bytes.Buffer
+	DefaultRegistry.WriteTo(&buf)
+	expected := `# HELP test_counter_total A counter\nfor tests.
+# TYPE test_counter_total counter
+test_counter_total{route="/\"b\"",status="500"} 1
+test_counter_total{route="/a",status="200"} 3
+`
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected output to contain:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "A histogram.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(2)
+	var buf bytes.Buffer
+	DefaultRegistry.WriteTo(&buf)
+	expected := `# HELP test_duration_seconds A histogram.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{le="0.1"} 1
+test_duration_seconds_bucket{le="1"} 2
+test_duration_seconds_bucket{le="+Inf"} 3
+test_duration_seconds_sum 2.55
+test_duration_seconds_count 3
+`
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected output to contain:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	c := NewCounter("test_labels_total", "Labels.", "a")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Observation with the wrong label count should panic, didn't.")
+		}
+	}()
+	c.Inc("x", "y")
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content type should be %q, is %q.", ContentType, ct)
+	}
+}
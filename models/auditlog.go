@@ -0,0 +1,71 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package models contains the types for schema 'trackit'.
+package models
+
+import (
+	"strings"
+)
+
+// AuditLogsForOwnerOrAwsAccounts returns, most recent first, at most limit
+// AuditLogs owned by a user or about one of the AWS accounts, whose IDs are
+// below beforeID. All IDs are considered if beforeID is zero.
+func AuditLogsForOwnerOrAwsAccounts(db XODB, ownerUserID int, awsAccountIDs []int, beforeID int, limit int) ([]*AuditLog, error) {
+	var err error
+
+	// sql query
+	args := []interface{}{ownerUserID}
+	scope := `owner_user_id = ?`
+	if len(awsAccountIDs) > 0 {
+		placeholders := make([]string, len(awsAccountIDs))
+		for i, id := range awsAccountIDs {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+		scope += ` OR aws_account_id IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	args = append(args, beforeID, beforeID, limit)
+	sqlstr := `SELECT ` +
+		`id, created, owner_user_id, aws_account_id, actor_user_id, actor_email, action, target_type, target_id, request_id, ip, before_payload, after_payload ` +
+		`FROM trackit.audit_log ` +
+		`WHERE (` + scope + `) AND (? = 0 OR id < ?) ` +
+		`ORDER BY id DESC LIMIT ?`
+
+	// run query
+	XOLog(sqlstr, args...)
+	q, err := db.Query(sqlstr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	// load results
+	res := []*AuditLog{}
+	for q.Next() {
+		al := AuditLog{
+			_exists: true,
+		}
+
+		// scan
+		err = q.Scan(&al.ID, &al.Created, &al.OwnerUserID, &al.AwsAccountID, &al.ActorUserID, &al.ActorEmail, &al.Action, &al.TargetType, &al.TargetID, &al.RequestID, &al.IP, &al.BeforePayload, &al.AfterPayload)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &al)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,72 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package models contains the types for schema 'trackit'.
+package models
+
+import (
+	"time"
+)
+
+// FailAuthThrottle counts a failed attempt for a key, creating its
+// AuthThrottle if needed. Failures are counted from scratch if the window
+// started before windowCutoff. The key is locked out until lockedUntil once it
+// reached maxFailures. It is a single statement so that concurrent attempts
+// are all counted.
+func FailAuthThrottle(db XODB, throttleKey string, maxFailures int, now, windowCutoff, lockedUntil time.Time) error {
+	var err error
+
+	// sql query, MySQL assigns columns from left to right so locked_until
+	// sees the updated failures
+	const sqlstr = `INSERT INTO trackit.auth_throttle (` +
+		`throttle_key, failures, window_start, locked_until` +
+		`) VALUES (` +
+		`?, 1, ?, IF(1 >= ?, ?, NULL)` +
+		`) ON DUPLICATE KEY UPDATE ` +
+		`failures = IF(window_start < ?, 1, failures + 1), ` +
+		`window_start = IF(window_start < ?, VALUES(window_start), window_start), ` +
+		`locked_until = IF(failures >= ?, ?, locked_until)`
+
+	// run query
+	XOLog(sqlstr, throttleKey, now, maxFailures, lockedUntil, windowCutoff, windowCutoff, maxFailures, lockedUntil)
+	_, err = db.Exec(sqlstr, throttleKey, now, maxFailures, lockedUntil, windowCutoff, windowCutoff, maxFailures, lockedUntil)
+	return err
+}
+
+// DeleteAuthThrottleByThrottleKey deletes the AuthThrottle of a key, if any.
+func DeleteAuthThrottleByThrottleKey(db XODB, throttleKey string) error {
+	var err error
+
+	// sql query
+	const sqlstr = `DELETE FROM trackit.auth_throttle WHERE throttle_key = ?`
+
+	// run query
+	XOLog(sqlstr, throttleKey)
+	_, err = db.Exec(sqlstr, throttleKey)
+	return err
+}
+
+// DeleteExpiredAuthThrottles deletes the AuthThrottles whose window started
+// before the date parameter and which are not locked out after it.
+func DeleteExpiredAuthThrottles(db XODB, date time.Time) error {
+	var err error
+
+	// sql query
+	const sqlstr = `DELETE FROM trackit.auth_throttle WHERE window_start < ? AND (locked_until IS NULL OR locked_until < ?)`
+
+	// run query
+	XOLog(sqlstr, date, date)
+	_, err = db.Exec(sqlstr, date, date)
+	return err
+}
@@ -0,0 +1,70 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package models contains the types for schema 'trackit'.
+package models
+
+import "time"
+
+// AllBudgets retrieves all the rows from 'trackit.budget'.
+func AllBudgets(db XODB) ([]*Budget, error) {
+	const sqlstr = `SELECT ` +
+		`id, user_id, name, amount, period, aws_accounts, product, tag_key, tag_value, thresholds, currency ` +
+		`FROM trackit.budget`
+	XOLog(sqlstr)
+	q, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+	res := []*Budget{}
+	for q.Next() {
+		b := Budget{
+			_exists: true,
+		}
+		err = q.Scan(&b.ID, &b.UserID, &b.Name, &b.Amount, &b.Period, &b.AwsAccounts, &b.Product, &b.TagKey, &b.TagValue, &b.Thresholds, &b.Currency)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &b)
+	}
+	return res, nil
+}
+
+// BudgetAlertsByBudgetIDPeriodBegin retrieves the alerts already sent for a
+// budget during the period starting at periodBegin.
+func BudgetAlertsByBudgetIDPeriodBegin(db XODB, budgetID int, periodBegin time.Time) ([]*BudgetAlert, error) {
+	const sqlstr = `SELECT ` +
+		`id, budget_id, period_begin, threshold, kind ` +
+		`FROM trackit.budget_alert ` +
+		`WHERE budget_id = ? AND period_begin = ?`
+	XOLog(sqlstr, budgetID, periodBegin)
+	q, err := db.Query(sqlstr, budgetID, periodBegin)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+	res := []*BudgetAlert{}
+	for q.Next() {
+		ba := BudgetAlert{
+			_exists: true,
+		}
+		err = q.Scan(&ba.ID, &ba.BudgetID, &ba.PeriodBegin, &ba.Threshold, &ba.Kind)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &ba)
+	}
+	return res, nil
+}
@@ -17,14 +17,15 @@ package models
 
 import "time"
 
-// IsAnomalyAlreadyEmailed checks if an anomaly has already been sent.
-func IsAnomalyAlreadyEmailed(db XODB, awsAccountId int, product string, date time.Time) (bool, error) {
+// IsAnomalyAlreadyEmailed checks if an anomaly has already been sent to a
+// recipient.
+func IsAnomalyAlreadyEmailed(db XODB, awsAccountId int, product string, recipient string, date time.Time) (bool, error) {
 	const sqlstr = `SELECT ` +
 		`id, aws_account_id, product, recipient, date ` +
 		`FROM trackit.emailed_anomaly ` +
-		`WHERE aws_account_id = ? AND product = ? AND date = ?`
-	XOLog(sqlstr, awsAccountId, product, date)
-	q, err := db.Query(sqlstr, awsAccountId, product, date)
+		`WHERE aws_account_id = ? AND product = ? AND recipient = ? AND date = ?`
+	XOLog(sqlstr, awsAccountId, product, recipient, date)
+	q, err := db.Query(sqlstr, awsAccountId, product, recipient, date)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,75 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package models contains the types for schema 'trackit'.
+package models
+
+import (
+	"time"
+)
+
+// ExchangeRates returns all the ExchangeRates, sorted by currency and date.
+func ExchangeRates(db XODB) ([]*ExchangeRate, error) {
+	var err error
+
+	// sql query
+	const sqlstr = `SELECT ` +
+		`id, currency, date, rate ` +
+		`FROM trackit.exchange_rate ` +
+		`ORDER BY currency, date`
+
+	// run query
+	XOLog(sqlstr)
+	q, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	// load results
+	res := []*ExchangeRate{}
+	for q.Next() {
+		er := ExchangeRate{
+			_exists: true,
+		}
+
+		// scan
+		err = q.Scan(&er.ID, &er.Currency, &er.Date, &er.Rate)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, &er)
+	}
+
+	return res, nil
+}
+
+// SaveExchangeRate inserts the rate of a currency at a date, or replaces it
+// if one was already set.
+func SaveExchangeRate(db XODB, currency string, date time.Time, rate float64) error {
+	var err error
+
+	// sql query
+	const sqlstr = `INSERT INTO trackit.exchange_rate (` +
+		`currency, date, rate` +
+		`) VALUES (` +
+		`?, ?, ?` +
+		`) ON DUPLICATE KEY UPDATE rate = VALUES(rate)`
+
+	// run query
+	XOLog(sqlstr, currency, date, rate)
+	_, err = db.Exec(sqlstr, currency, date, rate)
+	return err
+}
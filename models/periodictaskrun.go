@@ -0,0 +1,70 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package models contains the types for schema 'trackit'.
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LastPeriodicTaskRuns retrieves the limit most recent runs of periodic tasks,
+// most recent first. If task is not empty, only the runs of this task are
+// retrieved.
+func LastPeriodicTaskRuns(db XODB, task string, limit int) ([]*PeriodicTaskRun, error) {
+	const sqlstr = `SELECT ` +
+		`id, task, worker_id, triggered, started, duration, error ` +
+		`FROM trackit.periodic_task_run ` +
+		`WHERE ? = "" OR task = ? ` +
+		`ORDER BY started DESC LIMIT ?`
+	XOLog(sqlstr, task, task, limit)
+	q, err := db.Query(sqlstr, task, task, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+	res := []*PeriodicTaskRun{}
+	for q.Next() {
+		ptr := PeriodicTaskRun{
+			_exists: true,
+		}
+		err = q.Scan(&ptr.ID, &ptr.Task, &ptr.WorkerID, &ptr.Triggered, &ptr.Started, &ptr.Duration, &ptr.Error)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &ptr)
+	}
+	return res, nil
+}
+
+// DeleteOldPeriodicTaskRuns deletes the runs of a periodic task which are
+// older than its keep most recent runs.
+func DeleteOldPeriodicTaskRuns(db XODB, task string, keep int) error {
+	const selectstr = `SELECT started ` +
+		`FROM trackit.periodic_task_run ` +
+		`WHERE task = ? ` +
+		`ORDER BY started DESC LIMIT 1 OFFSET ?`
+	XOLog(selectstr, task, keep-1)
+	var oldestKept time.Time
+	if err := db.QueryRow(selectstr, task, keep-1).Scan(&oldestKept); err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	const sqlstr = `DELETE FROM trackit.periodic_task_run WHERE task = ? AND started < ?`
+	XOLog(sqlstr, task, oldestKept)
+	_, err := db.Exec(sqlstr, task, oldestKept)
+	return err
+}
@@ -11,15 +11,19 @@ import (
 
 // User represents a row from 'trackit.user'.
 type User struct {
-	ID                     int            `json:"id"`                       // id
-	Email                  string         `json:"email"`                    // email
-	Auth                   string         `json:"auth"`                     // auth
-	NextExternal           sql.NullString `json:"next_external"`            // next_external
-	ParentUserID           sql.NullInt64  `json:"parent_user_id"`           // parent_user_id
-	AwsCustomerIdentifier  string         `json:"aws_customer_identifier"`  // aws_customer_identifier
-	AwsCustomerEntitlement bool           `json:"aws_customer_entitlement"` // aws_customer_entitlement
-	NextUpdateEntitlement  time.Time      `json:"next_update_entitlement"`  // next_update_entitlement
-	AnomaliesFilters       []byte         `json:"anomalies_filters"`        // anomalies_filters
+	ID                      int            `json:"id"`                         // id
+	Email                   string         `json:"email"`                      // email
+	Auth                    string         `json:"auth"`                       // auth
+	NextExternal            sql.NullString `json:"next_external"`              // next_external
+	ParentUserID            sql.NullInt64  `json:"parent_user_id"`             // parent_user_id
+	AwsCustomerIdentifier   string         `json:"aws_customer_identifier"`    // aws_customer_identifier
+	AwsCustomerEntitlement  bool           `json:"aws_customer_entitlement"`   // aws_customer_entitlement
+	NextUpdateEntitlement   time.Time      `json:"next_update_entitlement"`    // next_update_entitlement
+	AnomaliesFilters        []byte         `json:"anomalies_filters"`          // anomalies_filters
+	AnomaliesDetector       sql.NullString `json:"anomalies_detector"`         // anomalies_detector
+	TokenGeneration         int            `json:"token_generation"`           // token_generation
+	ViewersRequireTwoFactor bool           `json:"viewers_require_two_factor"` // viewers_require_two_factor
+	Currency                string         `json:"currency"`                   // currency
 
 	// xo fields
 	_exists, _deleted bool
@@ -46,14 +50,14 @@ func (u *User) Insert(db XODB) error {
 
 	// sql insert query, primary key provided by autoincrement
 	const sqlstr = `INSERT INTO trackit.user (` +
-		`email, auth, next_external, parent_user_id, aws_customer_identifier, aws_customer_entitlement, next_update_entitlement, anomalies_filters` +
+		`email, auth, next_external, parent_user_id, aws_customer_identifier, aws_customer_entitlement, next_update_entitlement, anomalies_filters, anomalies_detector, token_generation, viewers_require_two_factor, currency` +
 		`) VALUES (` +
-		`?, ?, ?, ?, ?, ?, ?, ?` +
+		`?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?` +
 		`)`
 
 	// run query
-	XOLog(sqlstr, u.Email, u.Auth, u.NextExternal, u.ParentUserID, u.AwsCustomerIdentifier, u.AwsCustomerEntitlement, u.NextUpdateEntitlement, u.AnomaliesFilters)
-	res, err := db.Exec(sqlstr, u.Email, u.Auth, u.NextExternal, u.ParentUserID, u.AwsCustomerIdentifier, u.AwsCustomerEntitlement, u.NextUpdateEntitlement, u.AnomaliesFilters)
+	XOLog(sqlstr, u.Email, u.Auth, u.NextExternal, u.ParentUserID, u.AwsCustomerIdentifier, u.AwsCustomerEntitlement, u.NextUpdateEntitlement, u.AnomaliesFilters, u.AnomaliesDetector, u.TokenGeneration, u.ViewersRequireTwoFactor, u.Currency)
+	res, err := db.Exec(sqlstr, u.Email, u.Auth, u.NextExternal, u.ParentUserID, u.AwsCustomerIdentifier, u.AwsCustomerEntitlement, u.NextUpdateEntitlement, u.AnomaliesFilters, u.AnomaliesDetector, u.TokenGeneration, u.ViewersRequireTwoFactor, u.Currency)
 	if err != nil {
 		return err
 	}
@@ -87,12 +91,12 @@ func (u *User) Update(db XODB) error {
 
 	// sql query
 	const sqlstr = `UPDATE trackit.user SET ` +
-		`email = ?, auth = ?, next_external = ?, parent_user_id = ?, aws_customer_identifier = ?, aws_customer_entitlement = ?, next_update_entitlement = ?, anomalies_filters = ?` +
+		`email = ?, auth = ?, next_external = ?, parent_user_id = ?, aws_customer_identifier = ?, aws_customer_entitlement = ?, next_update_entitlement = ?, anomalies_filters = ?, anomalies_detector = ?, token_generation = ?, viewers_require_two_factor = ?, currency = ?` +
 		` WHERE id = ?`
 
 	// run query
-	XOLog(sqlstr, u.Email, u.Auth, u.NextExternal, u.ParentUserID, u.AwsCustomerIdentifier, u.AwsCustomerEntitlement, u.NextUpdateEntitlement, u.AnomaliesFilters, u.ID)
-	_, err = db.Exec(sqlstr, u.Email, u.Auth, u.NextExternal, u.ParentUserID, u.AwsCustomerIdentifier, u.AwsCustomerEntitlement, u.NextUpdateEntitlement, u.AnomaliesFilters, u.ID)
+	XOLog(sqlstr, u.Email, u.Auth, u.NextExternal, u.ParentUserID, u.AwsCustomerIdentifier, u.AwsCustomerEntitlement, u.NextUpdateEntitlement, u.AnomaliesFilters, u.AnomaliesDetector, u.TokenGeneration, u.ViewersRequireTwoFactor, u.Currency, u.ID)
+	_, err = db.Exec(sqlstr, u.Email, u.Auth, u.NextExternal, u.ParentUserID, u.AwsCustomerIdentifier, u.AwsCustomerEntitlement, u.NextUpdateEntitlement, u.AnomaliesFilters, u.AnomaliesDetector, u.TokenGeneration, u.ViewersRequireTwoFactor, u.Currency, u.ID)
 	return err
 }
 
@@ -150,7 +154,7 @@ func UsersByParentUserID(db XODB, parentUserID sql.NullInt64) ([]*User, error) {
 
 	// sql query
 	const sqlstr = `SELECT ` +
-		`id, email, auth, next_external, parent_user_id, aws_customer_identifier, aws_customer_entitlement, next_update_entitlement, anomalies_filters ` +
+		`id, email, auth, next_external, parent_user_id, aws_customer_identifier, aws_customer_entitlement, next_update_entitlement, anomalies_filters, anomalies_detector, token_generation, viewers_require_two_factor, currency ` +
 		`FROM trackit.user ` +
 		`WHERE parent_user_id = ?`
 
@@ -170,7 +174,7 @@ func UsersByParentUserID(db XODB, parentUserID sql.NullInt64) ([]*User, error) {
 		}
 
 		// scan
-		err = q.Scan(&u.ID, &u.Email, &u.Auth, &u.NextExternal, &u.ParentUserID, &u.AwsCustomerIdentifier, &u.AwsCustomerEntitlement, &u.NextUpdateEntitlement, &u.AnomaliesFilters)
+		err = q.Scan(&u.ID, &u.Email, &u.Auth, &u.NextExternal, &u.ParentUserID, &u.AwsCustomerIdentifier, &u.AwsCustomerEntitlement, &u.NextUpdateEntitlement, &u.AnomaliesFilters, &u.AnomaliesDetector, &u.TokenGeneration, &u.ViewersRequireTwoFactor, &u.Currency)
 		if err != nil {
 			return nil, err
 		}
@@ -189,7 +193,7 @@ func UserByEmail(db XODB, email string) (*User, error) {
 
 	// sql query
 	const sqlstr = `SELECT ` +
-		`id, email, auth, next_external, parent_user_id, aws_customer_identifier, aws_customer_entitlement, next_update_entitlement, anomalies_filters ` +
+		`id, email, auth, next_external, parent_user_id, aws_customer_identifier, aws_customer_entitlement, next_update_entitlement, anomalies_filters, anomalies_detector, token_generation, viewers_require_two_factor, currency ` +
 		`FROM trackit.user ` +
 		`WHERE email = ?`
 
@@ -199,7 +203,7 @@ func UserByEmail(db XODB, email string) (*User, error) {
 		_exists: true,
 	}
 
-	err = db.QueryRow(sqlstr, email).Scan(&u.ID, &u.Email, &u.Auth, &u.NextExternal, &u.ParentUserID, &u.AwsCustomerIdentifier, &u.AwsCustomerEntitlement, &u.NextUpdateEntitlement, &u.AnomaliesFilters)
+	err = db.QueryRow(sqlstr, email).Scan(&u.ID, &u.Email, &u.Auth, &u.NextExternal, &u.ParentUserID, &u.AwsCustomerIdentifier, &u.AwsCustomerEntitlement, &u.NextUpdateEntitlement, &u.AnomaliesFilters, &u.AnomaliesDetector, &u.TokenGeneration, &u.ViewersRequireTwoFactor, &u.Currency)
 	if err != nil {
 		return nil, err
 	}
@@ -215,7 +219,7 @@ func UserByID(db XODB, id int) (*User, error) {
 
 	// sql query
 	const sqlstr = `SELECT ` +
-		`id, email, auth, next_external, parent_user_id, aws_customer_identifier, aws_customer_entitlement, next_update_entitlement, anomalies_filters ` +
+		`id, email, auth, next_external, parent_user_id, aws_customer_identifier, aws_customer_entitlement, next_update_entitlement, anomalies_filters, anomalies_detector, token_generation, viewers_require_two_factor, currency ` +
 		`FROM trackit.user ` +
 		`WHERE id = ?`
 
@@ -225,7 +229,7 @@ func UserByID(db XODB, id int) (*User, error) {
 		_exists: true,
 	}
 
-	err = db.QueryRow(sqlstr, id).Scan(&u.ID, &u.Email, &u.Auth, &u.NextExternal, &u.ParentUserID, &u.AwsCustomerIdentifier, &u.AwsCustomerEntitlement, &u.NextUpdateEntitlement, &u.AnomaliesFilters)
+	err = db.QueryRow(sqlstr, id).Scan(&u.ID, &u.Email, &u.Auth, &u.NextExternal, &u.ParentUserID, &u.AwsCustomerIdentifier, &u.AwsCustomerEntitlement, &u.NextUpdateEntitlement, &u.AnomaliesFilters, &u.AnomaliesDetector, &u.TokenGeneration, &u.ViewersRequireTwoFactor, &u.Currency)
 	if err != nil {
 		return nil, err
 	}
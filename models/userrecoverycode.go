@@ -0,0 +1,29 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package models contains the types for schema 'trackit'.
+package models
+
+// DeleteUserRecoveryCodesByUserID deletes all the UserRecoveryCodes of a user.
+func DeleteUserRecoveryCodesByUserID(db XODB, userID int) error {
+	var err error
+
+	// sql query
+	const sqlstr = `DELETE FROM trackit.user_recovery_code WHERE user_id = ?`
+
+	// run query
+	XOLog(sqlstr, userID)
+	_, err = db.Exec(sqlstr, userID)
+	return err
+}
@@ -0,0 +1,48 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+// Package models contains the types for schema 'trackit'.
+package models
+
+import (
+	"time"
+)
+
+// DeleteExpiredUserRefreshTokens deletes the UserRefreshTokens of a user which
+// expired before the date parameter.
+func DeleteExpiredUserRefreshTokens(db XODB, userID int, date time.Time) error {
+	var err error
+
+	// sql query
+	const sqlstr = `DELETE FROM trackit.user_refresh_token WHERE user_id = ? AND expires < ?`
+
+	// run query
+	XOLog(sqlstr, userID, date)
+	_, err = db.Exec(sqlstr, userID, date)
+	return err
+}
+
+// ClaimUserRefreshToken marks a UserRefreshToken as used if it was not
+// already. It returns false if the token was used, possibly by a concurrent
+// transaction.
+func ClaimUserRefreshToken(db XODB, id int) (bool, error) {
+	const sqlstr = `UPDATE trackit.user_refresh_token SET used = 1 WHERE id = ? AND used = 0`
+	XOLog(sqlstr, id)
+	res, err := db.Exec(sqlstr, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
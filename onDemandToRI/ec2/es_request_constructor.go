@@ -0,0 +1,55 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package onDemandToRiEc2
+
+import (
+	"gopkg.in/olivere/elastic.v5"
+)
+
+const maxAggregationSize = 0x7FFFFFFF
+
+// createQueryAccountFilterOdToRiEc2 creates and return a new *elastic.TermsQuery on the accountList array
+func createQueryAccountFilterOdToRiEc2(accountList []string) *elastic.TermsQuery {
+	accountListFormatted := make([]interface{}, len(accountList))
+	for i, v := range accountList {
+		accountListFormatted[i] = v
+	}
+	return elastic.NewTermsQuery("account", accountListFormatted...)
+}
+
+// getElasticSearchOdToRiEc2Params is used to construct an ElasticSearch *elastic.SearchService used to perform a request on ES
+// It takes as parameters :
+// 	- params OdToRiEc2QueryParams : contains the list of accounts
+//	- client *elastic.Client : an instance of *elastic.Client that represent an Elastic Search client.
+//	It needs to be fully configured and ready to execute a client.Search()
+//	- index string : The Elastic Search index on which to execute the query. In this context the default value
+//	should be "od-to-ri-ec2-reports"
+// The latest report of each account is retrieved. The region, platform and saving filters are
+// applied on the result: the instances are nested documents, so filtering them in the query
+// would only select whole reports, not the instances within them.
+// This function excepts arguments passed to it to be sanitize. If they are not, the following cases will make
+// it crash :
+//	- If the client is nil or malconfigured, it will crash
+//	- If the index is not an index present in the ES, it will crash
+func getElasticSearchOdToRiEc2Params(params OdToRiEc2QueryParams, client *elastic.Client, index string) *elastic.SearchService {
+	query := elastic.NewBoolQuery()
+	if len(params.AccountList) > 0 {
+		query = query.Filter(createQueryAccountFilterOdToRiEc2(params.AccountList))
+	}
+	search := client.Search().Index(index).Size(0).Query(query)
+	search.Aggregation("accounts", elastic.NewTermsAggregation().Field("account").Size(maxAggregationSize).
+		SubAggregation("reports", elastic.NewTopHitsAggregation().Sort("reportDate", false).Size(1)))
+	return search
+}
@@ -0,0 +1,199 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package onDemandToRiEc2
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackit/jsonlog"
+	"gopkg.in/olivere/elastic.v5"
+
+	terrors "github.com/trackit/trackit-server/errors"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/users"
+)
+
+type (
+	// OdToRiEc2Reports is a list of on demand to reserved instances EC2 reports.
+	// It can be returned as JSON or CSV by a route.
+	OdToRiEc2Reports []OdToRiEc2Report
+
+	// responseOdToRiEc2 allows to parse the ES response for the latest reports
+	responseOdToRiEc2 struct {
+		Buckets []struct {
+			Reports struct {
+				Hits struct {
+					Hits []struct {
+						Report OdToRiEc2Report `json:"_source"`
+					} `json:"hits"`
+				} `json:"hits"`
+			} `json:"reports"`
+		} `json:"buckets"`
+	}
+)
+
+// ToCSVable generates the CSV content from OdToRiEc2Reports, with a line per
+// unreserved instance type.
+func (reports OdToRiEc2Reports) ToCSVable() [][]string {
+	formatCost := func(cost float64) string {
+		return strconv.FormatFloat(cost, 'f', 2, 64)
+	}
+	csv := [][]string{{
+		"account",
+		"reportDate",
+		"region",
+		"instanceType",
+		"platform",
+		"instanceCount",
+		"onDemandMonthlyCost",
+		"reservationType",
+		"oneYearReservationMonthlyCost",
+		"oneYearReservationSaving",
+		"threeYearsReservationMonthlyCost",
+		"threeYearsReservationSaving",
+	}}
+	for _, report := range reports {
+		for _, instance := range report.Instances {
+			csv = append(csv, []string{
+				report.Account,
+				report.ReportDate.Format(time.RFC3339),
+				instance.Region,
+				instance.Type,
+				instance.Platform,
+				strconv.Itoa(instance.InstanceCount),
+				formatCost(instance.OnDemand.Monthly.Total),
+				instance.Reservation.Type,
+				formatCost(instance.Reservation.OneYear.Monthly.Total),
+				formatCost(instance.Reservation.OneYear.Saving.Total),
+				formatCost(instance.Reservation.ThreeYear.Monthly.Total),
+				formatCost(instance.Reservation.ThreeYear.Saving.Total),
+			})
+		}
+	}
+	return csv
+}
+
+// makeElasticSearchRequest prepares and run an ES request
+// based on the OdToRiEc2QueryParams and search params
+// It will return the data, an http status code (as int) and an error.
+// Because an error can be generated, but is not critical and is not needed to be known by
+// the user (e.g if the index does not exists because it was not yet indexed ) the error will
+// be returned, but instead of having a 500 status code, it will return the provided status code
+// with empty data
+func makeElasticSearchRequest(ctx context.Context, parsedParams OdToRiEc2QueryParams,
+	esSearchParams func(OdToRiEc2QueryParams, *elastic.Client, string) *elastic.SearchService) (*elastic.SearchResult, int, error) {
+	l := jsonlog.LoggerFromContextOrDefault(ctx)
+	index := strings.Join(parsedParams.IndexList, ",")
+	searchService := esSearchParams(
+		parsedParams,
+		es.Client,
+		index,
+	)
+	res, err := es.DoSearch(ctx, "onDemandToRI/ec2", searchService)
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
+				"index": index,
+				"error": err.Error(),
+			})
+			return nil, http.StatusOK, terrors.GetErrorMessage(ctx, err)
+		} else if cast, ok := err.(*elastic.Error); ok && cast.Details.Type == "search_phase_execution_exception" {
+			l.Error("Error while getting data from ES", map[string]interface{}{
+				"type":  fmt.Sprintf("%T", err),
+				"error": err,
+			})
+		} else {
+			l.Error("Query execution failed", map[string]interface{}{"error": err.Error()})
+		}
+		return nil, http.StatusInternalServerError, terrors.GetErrorMessage(ctx, err)
+	}
+	return res, http.StatusOK, nil
+}
+
+// prepareResponseOdToRiEc2 parses the results from elasticsearch and returns the latest report of each account
+func prepareResponseOdToRiEc2(ctx context.Context, res *elastic.SearchResult) (OdToRiEc2Reports, error) {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	var parsedReports responseOdToRiEc2
+	reports := make(OdToRiEc2Reports, 0)
+	err := json.Unmarshal(*res.Aggregations["accounts"], &parsedReports)
+	if err != nil {
+		logger.Error("Error while unmarshaling ES OdToRiEc2 response", err)
+		return nil, err
+	}
+	for _, account := range parsedReports.Buckets {
+		for _, report := range account.Reports.Hits.Hits {
+			reports = append(reports, report.Report)
+		}
+	}
+	return reports, nil
+}
+
+// filterOdToRiEc2Report only keeps the instances of a report matching the region,
+// platform and saving filters, and computes the totals of the report again.
+func filterOdToRiEc2Report(report OdToRiEc2Report, params OdToRiEc2QueryParams) OdToRiEc2Report {
+	filtered := OdToRiEc2Report{
+		Account:    report.Account,
+		ReportDate: report.ReportDate,
+		Instances:  []InstancesSpecs{},
+	}
+	for _, instance := range report.Instances {
+		if (params.Region != "" && instance.Region != params.Region) ||
+			(params.Platform != "" && instance.Platform != params.Platform) ||
+			instance.Reservation.OneYear.Saving.Total < params.MinSaving {
+			continue
+		}
+		filtered.OnDemand.MonthlyTotal += instance.OnDemand.Monthly.Total
+		filtered.OnDemand.OneYearTotal += instance.OnDemand.OneYear.Total
+		filtered.OnDemand.ThreeYearsTotal += instance.OnDemand.ThreeYears.Total
+		filtered.Reservation.OneYear.MonthlyTotal += instance.Reservation.OneYear.Monthly.Total
+		filtered.Reservation.OneYear.GlobalTotal += instance.Reservation.OneYear.Monthly.Total * 12.0
+		filtered.Reservation.OneYear.SavingTotal += instance.Reservation.OneYear.Saving.Total
+		filtered.Reservation.ThreeYear.MonthlyTotal += instance.Reservation.ThreeYear.Monthly.Total
+		filtered.Reservation.ThreeYear.GlobalTotal += instance.Reservation.ThreeYear.Monthly.Total * 36.0
+		filtered.Reservation.ThreeYear.SavingTotal += instance.Reservation.ThreeYear.Saving.Total
+		filtered.Instances = append(filtered.Instances, instance)
+	}
+	return filtered
+}
+
+// GetOdToRiEc2Reports gets the latest on demand to reserved instances EC2 report of
+// each account of the user, filtered by the query params
+func GetOdToRiEc2Reports(ctx context.Context, parsedParams OdToRiEc2QueryParams, user users.User, tx *sql.Tx) (int, OdToRiEc2Reports, error) {
+	accountsAndIndexes, returnCode, err := es.GetAccountsAndIndexes(parsedParams.AccountList, user, tx, IndexPrefixOdToRiEC2Report)
+	if err != nil {
+		return returnCode, nil, err
+	}
+	parsedParams.AccountList = accountsAndIndexes.Accounts
+	parsedParams.IndexList = accountsAndIndexes.Indexes
+	res, returnCode, err := makeElasticSearchRequest(ctx, parsedParams, getElasticSearchOdToRiEc2Params)
+	if err != nil {
+		return returnCode, nil, err
+	}
+	reports, err := prepareResponseOdToRiEc2(ctx, res)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	for i, report := range reports {
+		reports[i] = filterOdToRiEc2Report(report, parsedParams)
+	}
+	return http.StatusOK, reports, nil
+}
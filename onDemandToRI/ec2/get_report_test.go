@@ -0,0 +1,126 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package onDemandToRiEc2
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// makeInstancesSpecs creates the costs of an unreserved instance type.
+func makeInstancesSpecs(region, platform string, count int, onDemand, oneYear, threeYears float64) InstancesSpecs {
+	var instance InstancesSpecs
+	instance.Region = region
+	instance.Type = "m4.large"
+	instance.Platform = platform
+	instance.InstanceCount = count
+	instance.OnDemand.Monthly.Total = onDemand
+	instance.OnDemand.OneYear.Total = onDemand * 12
+	instance.OnDemand.ThreeYears.Total = onDemand * 36
+	instance.Reservation.Type = "standard"
+	instance.Reservation.OneYear.Monthly.Total = oneYear
+	instance.Reservation.OneYear.Saving.Total = (onDemand - oneYear) * 12
+	instance.Reservation.ThreeYear.Monthly.Total = threeYears
+	instance.Reservation.ThreeYear.Saving.Total = (onDemand - threeYears) * 36
+	return instance
+}
+
+var testOdToRiEc2Report = OdToRiEc2Report{
+	Account:    "123456789012",
+	ReportDate: time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC),
+	Instances: []InstancesSpecs{
+		makeInstancesSpecs("US East (N. Virginia)", "Linux", 2, 100, 60, 40),
+		makeInstancesSpecs("US East (N. Virginia)", "Windows", 1, 200, 190, 150),
+		makeInstancesSpecs("EU (Ireland)", "Linux", 3, 50, 30, 20),
+	},
+}
+
+func TestFilterOdToRiEc2Report(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		params   OdToRiEc2QueryParams
+		expected []int
+	}{
+		{"no filter", OdToRiEc2QueryParams{}, []int{0, 1, 2}},
+		{"region", OdToRiEc2QueryParams{Region: "US East (N. Virginia)"}, []int{0, 1}},
+		{"platform", OdToRiEc2QueryParams{Platform: "Linux"}, []int{0, 2}},
+		{"region and platform", OdToRiEc2QueryParams{Region: "EU (Ireland)", Platform: "Windows"}, []int{}},
+		{"saving", OdToRiEc2QueryParams{MinSaving: 200}, []int{0, 2}},
+	} {
+		filtered := filterOdToRiEc2Report(testOdToRiEc2Report, c.params)
+		expected := OdToRiEc2Report{
+			Account:    testOdToRiEc2Report.Account,
+			ReportDate: testOdToRiEc2Report.ReportDate,
+			Instances:  []InstancesSpecs{},
+		}
+		for _, i := range c.expected {
+			instance := testOdToRiEc2Report.Instances[i]
+			expected.Instances = append(expected.Instances, instance)
+			expected.OnDemand.MonthlyTotal += instance.OnDemand.Monthly.Total
+			expected.OnDemand.OneYearTotal += instance.OnDemand.OneYear.Total
+			expected.OnDemand.ThreeYearsTotal += instance.OnDemand.ThreeYears.Total
+			expected.Reservation.OneYear.MonthlyTotal += instance.Reservation.OneYear.Monthly.Total
+			expected.Reservation.OneYear.GlobalTotal += instance.Reservation.OneYear.Monthly.Total * 12
+			expected.Reservation.OneYear.SavingTotal += instance.Reservation.OneYear.Saving.Total
+			expected.Reservation.ThreeYear.MonthlyTotal += instance.Reservation.ThreeYear.Monthly.Total
+			expected.Reservation.ThreeYear.GlobalTotal += instance.Reservation.ThreeYear.Monthly.Total * 36
+			expected.Reservation.ThreeYear.SavingTotal += instance.Reservation.ThreeYear.Saving.Total
+		}
+		if !reflect.DeepEqual(filtered, expected) {
+			t.Errorf("%s: filtered report should be %+v, is %+v instead.", c.name, expected, filtered)
+		}
+	}
+	if len(testOdToRiEc2Report.Instances) != 3 {
+		t.Errorf("Filtering should not modify the report.")
+	}
+}
+
+func TestOdToRiEc2ReportsToCSVable(t *testing.T) {
+	other := OdToRiEc2Report{
+		Account:    "210987654321",
+		ReportDate: time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC),
+		Instances:  []InstancesSpecs{makeInstancesSpecs("EU (Paris)", "Linux", 1, 33.333, 20.5, 10)},
+	}
+	empty := OdToRiEc2Report{Account: "111111111111"}
+	csv := OdToRiEc2Reports{testOdToRiEc2Report, empty, other}.ToCSVable()
+	if len(csv) != 5 {
+		t.Fatalf("CSV should have 5 lines, has %d instead.", len(csv))
+	}
+	for i, line := range csv {
+		if len(line) != len(csv[0]) {
+			t.Errorf("Line %d should have %d columns, has %d instead.", i, len(csv[0]), len(line))
+		}
+	}
+	if expected := []string{
+		"210987654321",
+		"2018-03-01T00:00:00Z",
+		"EU (Paris)",
+		"m4.large",
+		"Linux",
+		"1",
+		"33.33",
+		"standard",
+		"20.50",
+		"154.00",
+		"10.00",
+		"839.99",
+	}; !reflect.DeepEqual(csv[4], expected) {
+		t.Errorf("Last line should be %v, is %v instead.", expected, csv[4])
+	}
+	if csv[1][0] != "123456789012" || csv[3][2] != "EU (Ireland)" || csv[3][5] != "3" {
+		t.Errorf("Lines should follow the reports and their instances, are %v instead.", csv[1:4])
+	}
+}
@@ -0,0 +1,111 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package onDemandToRiEc2
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+)
+
+type (
+	// OdToRiEc2QueryParams will store the parsed query params
+	OdToRiEc2QueryParams struct {
+		AccountList []string
+		IndexList   []string
+		Region      string
+		Platform    string
+		MinSaving   float64
+	}
+)
+
+var (
+	// odToRiEc2RegionQueryArg allows to filter the recommendations by region
+	odToRiEc2RegionQueryArg = routes.QueryArg{
+		Name:        "region",
+		Type:        routes.QueryArgString{},
+		Description: "Only keep the recommendations for this region",
+		Optional:    true,
+	}
+
+	// odToRiEc2PlatformQueryArg allows to filter the recommendations by platform
+	odToRiEc2PlatformQueryArg = routes.QueryArg{
+		Name:        "platform",
+		Type:        routes.QueryArgString{},
+		Description: "Only keep the recommendations for this platform",
+		Optional:    true,
+	}
+
+	// odToRiEc2MinSavingQueryArg allows to filter out the recommendations saving too little
+	odToRiEc2MinSavingQueryArg = routes.QueryArg{
+		Name:        "min-saving",
+		Type:        routes.QueryArgFloat{},
+		Description: "Only keep the recommendations saving at least this amount of dollars over a year with a one year reservation",
+		Optional:    true,
+	}
+
+	// odToRiEc2QueryArgs allows to get required queryArgs params
+	odToRiEc2QueryArgs = []routes.QueryArg{
+		routes.AwsAccountsOptionalQueryArg,
+		odToRiEc2RegionQueryArg,
+		odToRiEc2PlatformQueryArg,
+		odToRiEc2MinSavingQueryArg,
+	}
+)
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getOdToRiEc2Recommendations).With(
+			db.RequestTransaction{Db: db.Db},
+			users.RequireAuthenticatedUser{users.ViewerAsParent},
+			routes.QueryArgs(odToRiEc2QueryArgs),
+			routes.Documentation{
+				Summary:     "get the EC2 reservation recommendations",
+				Description: "Responds with the latest on demand to reserved instances EC2 report of each account, listing the unreserved instances and the savings reservations would allow",
+			},
+		),
+	}.H().Register("/ri/ec2/recommendations")
+}
+
+// getOdToRiEc2Recommendations returns the latest on demand to reserved instances EC2 report
+// of each account based on the query params, in JSON or CSV format.
+func getOdToRiEc2Recommendations(request *http.Request, a routes.Arguments) (int, interface{}) {
+	user := a[users.AuthenticatedUser].(users.User)
+	tx := a[db.Transaction].(*sql.Tx)
+	parsedParams := OdToRiEc2QueryParams{
+		AccountList: []string{},
+	}
+	if a[routes.AwsAccountsOptionalQueryArg] != nil {
+		parsedParams.AccountList = a[routes.AwsAccountsOptionalQueryArg].([]string)
+	}
+	if a[odToRiEc2RegionQueryArg] != nil {
+		parsedParams.Region = a[odToRiEc2RegionQueryArg].(string)
+	}
+	if a[odToRiEc2PlatformQueryArg] != nil {
+		parsedParams.Platform = a[odToRiEc2PlatformQueryArg].(string)
+	}
+	if a[odToRiEc2MinSavingQueryArg] != nil {
+		parsedParams.MinSaving = a[odToRiEc2MinSavingQueryArg].(float64)
+	}
+	returnCode, reports, err := GetOdToRiEc2Reports(request.Context(), parsedParams, user, tx)
+	if err != nil {
+		return returnCode, err
+	} else {
+		return returnCode, reports
+	}
+}
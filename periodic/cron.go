@@ -0,0 +1,212 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package periodic
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes when a task should run.
+type Schedule interface {
+	// Next returns the first time strictly after t at which the task
+	// should run. A zero time means the task should never run again.
+	Next(t time.Time) time.Time
+}
+
+// every is a Schedule running a task at a fixed period.
+type every time.Duration
+
+// Every returns a Schedule running a task every period p.
+func Every(p time.Duration) Schedule {
+	return every(p)
+}
+
+// Next implements Schedule.
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// String returns the period of the Schedule.
+func (e every) String() string {
+	return time.Duration(e).String()
+}
+
+// cronSchedule is a Schedule built from a cron expression. Each field is a
+// bit set of the values it matches.
+type cronSchedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// cronField describes the range of values of a field of a cron expression.
+type cronField struct {
+	name     string
+	min, max uint
+}
+
+var cronFields = [5]cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// cronDescriptors are the shorthands accepted in place of a cron expression.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit is how far in the future cronSchedule.Next looks for a
+// matching time before giving up.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+var ErrInvalidCron = errors.New("invalid cron expression")
+
+// ParseCron parses a standard five fields cron expression (minute, hour, day
+// of month, month and day of week) or one of the @yearly, @monthly, @weekly,
+// @daily and @hourly descriptors. Fields accept '*', values, ranges, lists
+// and steps such as "*/15" or "1-5". Day of week 0 and 7 are Sunday. As in
+// cron, when both the day of month and the day of week are restricted, a
+// time matching either of them matches. Times are in UTC.
+func ParseCron(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if descriptor, ok := cronDescriptors[strings.TrimSpace(expr)]; ok {
+		fields = strings.Fields(descriptor)
+	}
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("%s: expected %d fields, got %d", ErrInvalidCron, len(cronFields), len(fields))
+	}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	// Sunday can be written as 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return cronSchedule{
+		expr:          strings.TrimSpace(expr),
+		minute:        sets[0],
+		hour:          sets[1],
+		dom:           sets[2],
+		month:         sets[3],
+		dow:           sets[4],
+		domRestricted: fields[2] != "*",
+		dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+// MustParseCron is like ParseCron but panics if the expression is invalid.
+// It simplifies the registration of tasks with constant schedules.
+func MustParseCron(expr string) Schedule {
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		panic(err)
+	}
+	return schedule
+}
+
+// parseCronField parses a comma separated field of a cron expression into a
+// bit set.
+func parseCronField(field string, desc cronField) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, uint(1)
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || s == 0 {
+				return 0, fmt.Errorf("%s: bad step in %s field %q", ErrInvalidCron, desc.name, field)
+			}
+			rangePart, step = part[:i], uint(s)
+		}
+		low, high := desc.min, desc.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			l, err := strconv.ParseUint(bounds[0], 10, 8)
+			if err != nil {
+				return 0, fmt.Errorf("%s: bad value in %s field %q", ErrInvalidCron, desc.name, field)
+			}
+			low, high = uint(l), uint(l)
+			if len(bounds) == 2 {
+				h, err := strconv.ParseUint(bounds[1], 10, 8)
+				if err != nil {
+					return 0, fmt.Errorf("%s: bad range in %s field %q", ErrInvalidCron, desc.name, field)
+				}
+				high = uint(h)
+			} else if step != 1 {
+				high = desc.max
+			}
+		}
+		if low < desc.min || high > desc.max || low > high {
+			return 0, fmt.Errorf("%s: %s field %q out of range", ErrInvalidCron, desc.name, field)
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// Next implements Schedule.
+func (c cronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		} else if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		} else if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		} else if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+		} else {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// String returns the cron expression of the Schedule.
+func (c cronSchedule) String() string {
+	return c.expr
+}
+
+// matchesDay checks whether the day of t matches the day of month and day of
+// week fields.
+func (c cronSchedule) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
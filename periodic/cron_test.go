@@ -0,0 +1,69 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package periodic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@never",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("Expression %q should be invalid.", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2018, time.March, 14, 10, 27, 42, 0, time.UTC) // Wednesday
+	for _, c := range []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2018, time.March, 14, 10, 28, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, time.March, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2018, time.March, 15, 3, 0, 0, 0, time.UTC)},
+		{"30 9-17/4 * * *", time.Date(2018, time.March, 14, 13, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2018, time.March, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2018, time.March, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 0 20 * 5", time.Date(2018, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"15,45 10 * * *", time.Date(2018, time.March, 14, 10, 45, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2018, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		s, err := ParseCron(c.expr)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %s", c.expr, err)
+		} else if next := s.Next(from); !next.Equal(c.expected) {
+			t.Errorf("Next time for %q should be %s, is %s.", c.expr, c.expected, next)
+		}
+	}
+}
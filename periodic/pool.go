@@ -0,0 +1,106 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package periodic
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Pool bounds the number of jobs running at once. It may be used in
+// parallel.
+type Pool struct {
+	slots  chan struct{}
+	mutex  sync.Mutex
+	closed bool
+	jobs   sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewPool creates a Pool running at most size jobs at once.
+func NewPool(size int) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pool{
+		slots:  make(chan struct{}, size),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// TryGo runs job in its own goroutine if the Pool has a free slot and is not
+// shut down, and returns whether it did. It never blocks. The job's context
+// holds the values of ctx, but is only cancelled when the Pool's shutdown
+// times out.
+func (p *Pool) TryGo(ctx context.Context, job func(context.Context)) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return false
+	}
+	select {
+	case p.slots <- struct{}{}:
+		p.jobs.Add(1)
+		go func() {
+			defer p.jobs.Done()
+			defer func() { <-p.slots }()
+			job(poolContext{p.ctx, ctx})
+		}()
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops the Pool from accepting jobs and waits for the running ones
+// to return. If ctx is done first, the contexts of the running jobs are
+// cancelled and ctx's error is returned.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+	done := make(chan struct{})
+	go func() {
+		p.jobs.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	p.cancel()
+	return err
+}
+
+// poolContext is the context of a job run by a Pool. Its cancellation
+// follows the Pool's while its values are those of the context the job was
+// submitted with.
+type poolContext struct {
+	pool   context.Context
+	values context.Context
+}
+
+func (c poolContext) Deadline() (time.Time, bool) { return c.pool.Deadline() }
+func (c poolContext) Done() <-chan struct{}       { return c.pool.Done() }
+func (c poolContext) Err() error                  { return c.pool.Err() }
+func (c poolContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
@@ -0,0 +1,100 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package periodic
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoolBoundsRunningJobs(t *testing.T) {
+	p := NewPool(2)
+	release := make(chan struct{})
+	done := make(chan struct{})
+	job := func(context.Context) {
+		<-release
+		done <- struct{}{}
+	}
+	if !p.TryGo(context.Background(), job) || !p.TryGo(context.Background(), job) {
+		t.Fatalf("Pool should accept as many jobs as its size.")
+	}
+	if p.TryGo(context.Background(), job) {
+		t.Fatalf("Full pool should refuse jobs.")
+	}
+	release <- struct{}{}
+	<-done
+	accepted := false
+	for deadline := time.Now().Add(time.Second); !accepted && time.Now().Before(deadline); {
+		accepted = p.TryGo(context.Background(), job)
+	}
+	if !accepted {
+		t.Errorf("Pool should accept a job once a slot is freed.")
+	}
+	close(release)
+	<-done
+	<-done
+}
+
+func TestPoolShutdownWaitsForJobs(t *testing.T) {
+	p := NewPool(2)
+	type key struct{}
+	var done bool
+	var value interface{}
+	started := make(chan struct{})
+	p.TryGo(context.WithValue(context.Background(), key{}, "value"), func(ctx context.Context) {
+		value = ctx.Value(key{})
+		close(started)
+		select {
+		case <-time.After(100 * time.Millisecond):
+			done = true
+		case <-ctx.Done():
+		}
+	})
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown should succeed, failed with %q.", err)
+	}
+	if !done {
+		t.Errorf("Shutdown should wait for the running job, didn't.")
+	}
+	if value != "value" {
+		t.Errorf("Job context should hold the values it was submitted with, holds %v.", value)
+	}
+	if p.TryGo(context.Background(), func(context.Context) {}) {
+		t.Errorf("Shut down pool should refuse jobs.")
+	}
+}
+
+func TestPoolShutdownTimeout(t *testing.T) {
+	p := NewPool(1)
+	c := make(chan error, 1)
+	p.TryGo(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		c <- ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown should fail with %q, failed with %q.", context.DeadlineExceeded, err)
+	}
+	select {
+	case <-c:
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("Job context should be cancelled after shutdown timeout, isn't.")
+	}
+}
@@ -0,0 +1,112 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package periodic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrTaskNotFound     = errors.New("task not found")
+	ErrTaskRunning      = errors.New("task is already running")
+	ErrSchedulerStopped = errors.New("scheduler is not ticking")
+)
+
+// TaskStatus is the state of a registered task, as exposed to operators.
+type TaskStatus struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Jitter       string     `json:"jitter"`
+	Overlap      string     `json:"overlap"`
+	Running      int        `json:"running"`
+	LastStart    *time.Time `json:"lastStart"`
+	LastDuration float64    `json:"lastDuration"`
+	LastError    string     `json:"lastError"`
+	NextRun      *time.Time `json:"nextRun"`
+}
+
+// String returns the name of the OverlapPolicy.
+func (o OverlapPolicy) String() string {
+	switch o {
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueue:
+		return "queue"
+	case OverlapAllow:
+		return "allow"
+	default:
+		return fmt.Sprintf("OverlapPolicy(%d)", uint(o))
+	}
+}
+
+// Tasks returns the status of every task registered to the Scheduler, in
+// registration order. LastDuration is in seconds.
+func (s *Scheduler) Tasks() []TaskStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	statuses := make([]TaskStatus, len(s.registrations))
+	for i, r := range s.registrations {
+		statuses[i] = r.status()
+	}
+	return statuses
+}
+
+// Trigger runs a task immediately, regardless of its schedule but according
+// to its overlap policy. ErrTaskRunning is returned if the run is dropped
+// because the task is still running.
+func (s *Scheduler) Trigger(name string) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if !s.running {
+		return ErrSchedulerStopped
+	}
+	for _, r := range s.registrations {
+		if r.Name == name {
+			if r.trigger(s.ctx, runRequest{date: time.Now(), triggered: true}) {
+				return nil
+			}
+			return ErrTaskRunning
+		}
+	}
+	return ErrTaskNotFound
+}
+
+// status builds the TaskStatus of a taskRegistration.
+func (t *taskRegistration) status() TaskStatus {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	status := TaskStatus{
+		Name:     t.Name,
+		Schedule: fmt.Sprint(t.options.Schedule),
+		Jitter:   t.options.Jitter.String(),
+		Overlap:  t.options.Overlap.String(),
+		Running:  t.running,
+	}
+	if t.lastRun != nil {
+		lastStart := t.lastRun.Start
+		status.LastStart = &lastStart
+		status.LastDuration = t.lastRun.Duration.Seconds()
+		if t.lastRun.Error != nil {
+			status.LastError = t.lastRun.Error.Error()
+		}
+	}
+	if !t.nextRun.IsZero() {
+		nextRun := t.nextRun
+		status.NextRun = &nextRun
+	}
+	return status
+}
@@ -0,0 +1,94 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package periodic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTriggerRunsTask(t *testing.T) {
+	var s Scheduler
+	c := make(chan TaskRun, 1)
+	s.OnRunEnd = func(r TaskRun) { c <- r }
+	s.Register(func(_ context.Context) error {
+		return errors.New("failure")
+	}, time.Hour, "Hourly")
+	if err := s.Trigger("Hourly"); err != ErrSchedulerStopped {
+		t.Errorf("Trigger on stopped scheduler should fail with %q, failed with %q.", ErrSchedulerStopped, err)
+	}
+	s.Start()
+	defer s.Stop()
+	if err := s.Trigger("Daily"); err != ErrTaskNotFound {
+		t.Errorf("Trigger of unknown task should fail with %q, failed with %q.", ErrTaskNotFound, err)
+	}
+	if err := s.Trigger("Hourly"); err != nil {
+		t.Errorf("Trigger should succeed, failed with %q.", err)
+	}
+	select {
+	case r := <-c:
+		if r.Name != "Hourly" || !r.Triggered || r.Error == nil {
+			t.Errorf("Unexpected run %#v.", r)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("Triggered task should run, didn't.")
+	}
+}
+
+func TestTriggerRunningTask(t *testing.T) {
+	var s Scheduler
+	c := make(chan int)
+	s.Register(sleepingTask(c, 100*time.Millisecond), time.Hour, "Sleeping")
+	s.Start()
+	defer s.Stop()
+	if err := s.Trigger("Sleeping"); err != nil {
+		t.Errorf("Trigger should succeed, failed with %q.", err)
+	}
+	<-c
+	if err := s.Trigger("Sleeping"); err != ErrTaskRunning {
+		t.Errorf("Trigger of running task should fail with %q, failed with %q.", ErrTaskRunning, err)
+	}
+}
+
+func TestTasksStatus(t *testing.T) {
+	var s Scheduler
+	c := make(chan TaskRun, 1)
+	s.OnRunEnd = func(r TaskRun) { c <- r }
+	s.RegisterWithOptions(func(_ context.Context) error {
+		return errors.New("failure")
+	}, "Cron", TaskOptions{Schedule: MustParseCron("0 4 * * *"), Overlap: OverlapQueue})
+	s.Start()
+	defer s.Stop()
+	s.Trigger("Cron")
+	<-c
+	// The ticker computes the next run in its own goroutine.
+	time.Sleep(10 * time.Millisecond)
+	statuses := s.Tasks()
+	if len(statuses) != 1 {
+		t.Fatalf("Expected %d statuses, got %d.", 1, len(statuses))
+	}
+	status := statuses[0]
+	if status.Name != "Cron" || status.Schedule != "0 4 * * *" || status.Overlap != "queue" {
+		t.Errorf("Unexpected status %#v.", status)
+	}
+	if status.LastStart == nil || status.LastError != "failure" {
+		t.Errorf("Status should describe the last run, is %#v.", status)
+	}
+	if status.NextRun == nil || status.NextRun.UTC().Hour() != 4 {
+		t.Errorf("Next run should be at 4 AM, is %v.", status.NextRun)
+	}
+}
@@ -18,6 +18,7 @@ package periodic
 
 import (
 	"context"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -31,6 +32,10 @@ type contextKey uint
 // taskSignal is the type for signals sent to task tickers.
 type taskSignal uint
 
+// OverlapPolicy decides what happens when a task is due while its previous
+// run is still going.
+type OverlapPolicy uint
+
 const (
 	Hourly      = 1 * time.Hour
 	Daily       = 24 * time.Hour
@@ -45,16 +50,61 @@ const (
 	taskStop = taskSignal(iota)
 )
 
-// Task is a task that can be scheduled.
+const (
+	// OverlapSkip drops a run if the previous one is still going. This is
+	// the default.
+	OverlapSkip = OverlapPolicy(iota)
+	// OverlapQueue delays a run until the previous one is over. At most
+	// one run is queued: runs due while one is already queued are dropped.
+	OverlapQueue
+	// OverlapAllow starts runs regardless of the previous ones.
+	OverlapAllow
+)
+
+// Task is a task that can be scheduled. The context it receives is cancelled
+// when the Scheduler stops.
 type Task func(context.Context) error
 
+// TaskOptions describes when and how a registered task runs.
+type TaskOptions struct {
+	// Schedule computes the times at which the task runs.
+	Schedule Schedule
+	// Jitter is the upper bound of a random delay added to each run, so
+	// that servers sharing a schedule don't all run at once.
+	Jitter time.Duration
+	// Overlap decides what happens when a run is due while the previous
+	// one is still going.
+	Overlap OverlapPolicy
+}
+
+// TaskRun describes a finished run of a task.
+type TaskRun struct {
+	Name      string
+	Triggered bool
+	Start     time.Time
+	Duration  time.Duration
+	Error     error
+}
+
+// runRequest is a request for a task to run, either because it is due or
+// because it was triggered.
+type runRequest struct {
+	date      time.Time
+	triggered bool
+}
+
 // taskRegistration is a task registration that may or may not be ticking.
 type taskRegistration struct {
-	Name    string `json:"name"`
-	task    Task
-	Period  time.Duration
-	ticker  *time.Ticker
-	control chan taskSignal
+	Name      string `json:"name"`
+	task      Task
+	options   TaskOptions
+	scheduler *Scheduler
+	control   chan taskSignal
+	mutex     sync.Mutex
+	running   int
+	queued    *runRequest
+	nextRun   time.Time
+	lastRun   *TaskRun
 }
 
 // Scheduler runs registered periodic tasks. Its zero value is a valid
@@ -62,8 +112,14 @@ type taskRegistration struct {
 // parallel.
 type Scheduler struct {
 	running       bool
-	registrations []taskRegistration
+	registrations []*taskRegistration
 	mutex         sync.RWMutex
+	ctx           context.Context
+	cancel        context.CancelFunc
+	runs          sync.WaitGroup
+	// OnRunEnd, if set, is called after each run of a task. It must be
+	// set before the Scheduler is started.
+	OnRunEnd func(TaskRun)
 }
 
 // Ticking returns whether the Scheduler is currently ticking.
@@ -73,18 +129,27 @@ func (s *Scheduler) Ticking() bool {
 	return s.running
 }
 
-// Register registers a Task to the Scheduler to be run at period p. If the
-// Scheduler is ticking, the task starts ticking immediately.
+// Register registers a Task to the Scheduler to be run at period p, skipping
+// runs while the previous one is still going. If the Scheduler is ticking,
+// the task starts ticking immediately.
 func (s *Scheduler) Register(t Task, p time.Duration, n string) {
-	r := taskRegistration{
-		task:   t,
-		Period: p,
-		Name:   n,
+	s.RegisterWithOptions(t, n, TaskOptions{Schedule: Every(p)})
+}
+
+// RegisterWithOptions registers a Task to the Scheduler to be run as
+// described by o. If the Scheduler is ticking, the task starts ticking
+// immediately.
+func (s *Scheduler) RegisterWithOptions(t Task, n string, o TaskOptions) {
+	r := &taskRegistration{
+		task:      t,
+		options:   o,
+		scheduler: s,
+		Name:      n,
 	}
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	if s.running {
-		r.start()
+		r.start(s.ctx)
 	}
 	s.registrations = append(s.registrations, r)
 }
@@ -97,82 +162,208 @@ func (s *Scheduler) Start() {
 	if s.running {
 		jsonlog.Error("Attempt to start already started scheduler. Ignoring.", nil)
 	} else {
-		for i := range s.registrations {
-			s.registrations[i].start()
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+		for _, r := range s.registrations {
+			r.start(s.ctx)
 		}
 		s.running = true
 	}
 }
 
-// Stop stops a Scheduler. Stopping an already stopped scheduler is
-// functionally a noop, though an error will be logged.
+// Stop stops a Scheduler and cancels the contexts of the running tasks
+// without waiting for them to return. Stopping an already stopped scheduler
+// is functionally a noop.
 func (s *Scheduler) Stop() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	s.stopTicking()
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// Shutdown stops a Scheduler and waits for the running tasks to return. If
+// ctx is done first, the contexts of the running tasks are cancelled and
+// ctx's error is returned.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.stopTicking()
+	cancel := s.cancel
+	s.mutex.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if cancel != nil {
+		cancel()
+	}
+	return err
+}
+
+// stopTicking stops the tickers of all registrations. The caller must hold
+// s.mutex.
+func (s *Scheduler) stopTicking() {
 	if s.running {
-		for i := range s.registrations {
-			s.registrations[i].stop()
+		for _, r := range s.registrations {
+			r.stop()
 		}
 		s.running = false
 	}
 }
 
 // start starts a taskRegistration, having it tick and run its task
-// periodically.
-func (t *taskRegistration) start() {
+// periodically. Runs get a context derived from ctx.
+func (t *taskRegistration) start(ctx context.Context) {
 	if t.control == nil {
-		t.ticker = time.NewTicker(t.Period)
 		t.control = make(chan taskSignal)
-		go t.tick()
+		go t.tick(ctx)
 	} else {
 		jsonlog.Error("Attempt to start already started task. Ignoring.", t)
 	}
 }
 
-// run runs the taskRegistration's task in the current goroutine.
-func (t *taskRegistration) run(d time.Time) error {
-	ctx := context.Background()
-	ctx = context.WithValue(ctx, TaskTime, d)
-	return t.task(ctx)
+// run runs the taskRegistration's task in the current goroutine and records
+// the outcome of the run.
+func (t *taskRegistration) run(ctx context.Context, r runRequest) error {
+	ctx = context.WithValue(ctx, TaskTime, r.date)
+	start := time.Now()
+	err := t.task(ctx)
+	run := TaskRun{
+		Name:      t.Name,
+		Triggered: r.triggered,
+		Start:     start,
+		Duration:  time.Since(start),
+		Error:     err,
+	}
+	t.mutex.Lock()
+	t.lastRun = &run
+	t.mutex.Unlock()
+	if t.scheduler.OnRunEnd != nil {
+		t.scheduler.OnRunEnd(run)
+	}
+	return err
 }
 
-// getTickerChan gets the taskRegistration's ticker channel, creating it if it
-// doesn’t exist yet. This is the channel where a time.Ticker outputs to run
-// the periodic tasks.
-func (t *taskRegistration) getTickerChan() <-chan time.Time {
-	c := t.ticker
-	if c == nil {
-		return nil
-	} else {
-		return c.C
+// next returns the next time the task is due after the previous due time
+// last. Due times missed while the process was not running, e.g. suspended,
+// are not caught up on.
+func (t *taskRegistration) next(last time.Time) time.Time {
+	next := t.options.Schedule.Next(last)
+	if now := time.Now(); !next.IsZero() && next.Before(now) {
+		next = t.options.Schedule.Next(now)
+	}
+	return next
+}
+
+// jitter returns a random delay bounded by the task's jitter.
+func (t *taskRegistration) jitter() time.Duration {
+	if t.options.Jitter <= 0 {
+		return 0
 	}
+	return time.Duration(rand.Int63n(int64(t.options.Jitter)))
 }
 
-// tick starts periodic tasks in response to ticks from t.ticker. The tasks are
-// started in their own goroutine using t.run.
-func (t *taskRegistration) tick() {
+// tick starts periodic tasks when they are due according to their schedule.
+// The tasks are started in their own goroutine using t.trigger.
+func (t *taskRegistration) tick(ctx context.Context) {
+	last := time.Now()
 	for {
+		var timer *time.Timer
+		var due <-chan time.Time
+		next := t.next(last)
+		if !next.IsZero() {
+			delay := time.Until(next) + t.jitter()
+			timer = time.NewTimer(delay)
+			due = timer.C
+			t.setNextRun(time.Now().Add(delay))
+		} else {
+			t.setNextRun(time.Time{})
+		}
 		select {
-		case d := <-t.getTickerChan():
-			go t.run(d)
+		case d := <-due:
+			last = next
+			t.trigger(ctx, runRequest{date: d})
 		case s := <-t.control:
+			if timer != nil {
+				timer.Stop()
+			}
 			switch s {
 			case taskStop:
+				t.setNextRun(time.Time{})
 				close(t.control)
 				t.control = nil
-				t.ticker.Stop()
-				t.ticker = nil
 				return
 			}
 		}
 	}
 }
 
-// stop stops a taskRegistration from ticking. Currently running tasks are not
-// cancelled.
+// setNextRun sets the time at which the task is expected to run next.
+func (t *taskRegistration) setNextRun(d time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.nextRun = d
+}
+
+// trigger starts a run of the task in its own goroutine, unless the overlap
+// policy says otherwise. It returns false if the run was dropped.
+func (t *taskRegistration) trigger(ctx context.Context, r runRequest) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.running > 0 {
+		switch t.options.Overlap {
+		case OverlapSkip:
+			jsonlog.DefaultLogger.Warning("Task is still running. Skipping run.", map[string]interface{}{"name": t.Name})
+			return false
+		case OverlapQueue:
+			if t.queued == nil {
+				t.queued = &r
+				return true
+			}
+			jsonlog.DefaultLogger.Warning("Task already has a queued run. Skipping run.", map[string]interface{}{"name": t.Name})
+			return false
+		}
+	}
+	t.running++
+	t.scheduler.runs.Add(1)
+	go t.runQueue(ctx, r)
+	return true
+}
+
+// runQueue runs the task, then the run that was queued while it was running,
+// if any.
+func (t *taskRegistration) runQueue(ctx context.Context, r runRequest) {
+	defer t.scheduler.runs.Done()
+	for {
+		t.run(ctx, r)
+		t.mutex.Lock()
+		queued := t.queued
+		t.queued = nil
+		if queued == nil {
+			t.running--
+			t.mutex.Unlock()
+			return
+		}
+		t.mutex.Unlock()
+		r = *queued
+	}
+}
+
+// stop stops a taskRegistration from ticking and drops its queued run.
+// Currently running tasks are not waited for.
 func (t *taskRegistration) stop() {
 	if t.control != nil {
 		t.control <- taskStop
+		t.mutex.Lock()
+		t.queued = nil
+		t.mutex.Unlock()
 	} else {
 		jsonlog.Error("Attempt to stop an already stopped task. Ignoring.", t)
 	}
@@ -110,3 +110,153 @@ out:
 		t.Errorf("Expected task count to be %#v, is %#v.", be, b)
 	}
 }
+
+func sleepingTask(c chan<- int, d time.Duration) Task {
+	return func(_ context.Context) error {
+		c <- 0
+		time.Sleep(d)
+		return nil
+	}
+}
+
+func countRuns(c <-chan int, d time.Duration) (a int) {
+	e := time.After(d)
+	for {
+		select {
+		case <-c:
+			a++
+		case <-e:
+			return
+		}
+	}
+}
+
+func TestOverlapSkip(t *testing.T) {
+	var s Scheduler
+	c := make(chan int)
+	s.RegisterWithOptions(
+		sleepingTask(c, 250*time.Millisecond),
+		"Skipping",
+		TaskOptions{Schedule: Every(100 * time.Millisecond), Overlap: OverlapSkip},
+	)
+	s.Start()
+	a := countRuns(c, 650*time.Millisecond)
+	s.Stop()
+	if a != 2 {
+		t.Errorf("Task should run %d times, ran %d times.", 2, a)
+	}
+}
+
+func TestOverlapQueue(t *testing.T) {
+	var s Scheduler
+	c := make(chan int)
+	s.RegisterWithOptions(
+		sleepingTask(c, 250*time.Millisecond),
+		"Queueing",
+		TaskOptions{Schedule: Every(100 * time.Millisecond), Overlap: OverlapQueue},
+	)
+	s.Start()
+	a := countRuns(c, 650*time.Millisecond)
+	s.Stop()
+	if a != 3 {
+		t.Errorf("Task should run %d times, ran %d times.", 3, a)
+	}
+}
+
+func TestOverlapAllow(t *testing.T) {
+	var s Scheduler
+	c := make(chan int)
+	s.RegisterWithOptions(
+		sleepingTask(c, 250*time.Millisecond),
+		"Overlapping",
+		TaskOptions{Schedule: Every(100 * time.Millisecond), Overlap: OverlapAllow},
+	)
+	s.Start()
+	a := countRuns(c, 650*time.Millisecond)
+	s.Stop()
+	if a != 6 {
+		t.Errorf("Task should run %d times, ran %d times.", 6, a)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	var s Scheduler
+	c := make(chan int)
+	s.RegisterWithOptions(
+		messageTask(c, 0),
+		"Jittering",
+		TaskOptions{Schedule: Every(100 * time.Millisecond), Jitter: 50 * time.Millisecond},
+	)
+	s.Start()
+	a := countRuns(c, 470*time.Millisecond)
+	s.Stop()
+	if a != 4 {
+		t.Errorf("Task should run %d times, ran %d times.", 4, a)
+	}
+}
+
+func TestStopCancelsTasks(t *testing.T) {
+	var s Scheduler
+	c := make(chan error)
+	s.Register(func(ctx context.Context) error {
+		<-ctx.Done()
+		c <- ctx.Err()
+		return nil
+	}, 50*time.Millisecond, "Cancellable")
+	s.Start()
+	time.Sleep(75 * time.Millisecond)
+	s.Stop()
+	select {
+	case err := <-c:
+		if err != context.Canceled {
+			t.Errorf("Task context error should be %q, is %q.", context.Canceled, err)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("Task context should be cancelled on stop, isn't.")
+	}
+}
+
+func TestShutdownWaitsForTasks(t *testing.T) {
+	var s Scheduler
+	var done bool
+	s.Register(func(ctx context.Context) error {
+		select {
+		case <-time.After(100 * time.Millisecond):
+			done = true
+		case <-ctx.Done():
+		}
+		return nil
+	}, 50*time.Millisecond, "Graceful")
+	s.Start()
+	time.Sleep(75 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown should succeed, failed with %q.", err)
+	}
+	if !done {
+		t.Errorf("Shutdown should wait for the running task, didn't.")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	var s Scheduler
+	c := make(chan error, 1)
+	s.Register(func(ctx context.Context) error {
+		<-ctx.Done()
+		c <- ctx.Err()
+		return nil
+	}, 50*time.Millisecond, "Stubborn")
+	s.Start()
+	time.Sleep(75 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown should fail with %q, failed with %q.", context.DeadlineExceeded, err)
+	}
+	select {
+	case <-c:
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("Task context should be cancelled after shutdown timeout, isn't.")
+	}
+}
@@ -70,7 +70,7 @@ func makeElasticSearchPluginsRequest(ctx context.Context, parsedParams pluginsQu
 		es.Client,
 		index,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "plugins", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists : "+index, err)
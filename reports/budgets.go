@@ -0,0 +1,140 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package reports
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/aws"
+	"github.com/trackit/trackit-server/costs/budgets"
+	"github.com/trackit/trackit-server/users"
+)
+
+var budgetsFormat = []cell{
+	newCell("Name").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Period").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Start").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("End").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Accounts").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Product").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Tag").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Amount").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Spent").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Spent (%)").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Forecast").addStyle(textCenter, textBold, backgroundGrey),
+	newCell("Forecast (%)").addStyle(textCenter, textBold, backgroundGrey),
+}
+
+func formatBudgetStatus(status budgets.BudgetStatus) []cell {
+	accounts := "All"
+	if len(status.AwsAccounts) > 0 {
+		accounts = strings.Join(status.AwsAccounts, ";")
+	}
+	product := "All"
+	if status.Product != "" {
+		product = status.Product
+	}
+	tag := ""
+	if status.TagKey != "" {
+		tag = status.TagKey + ":" + status.TagValue
+	}
+	forecastPercentage := newCell(status.ForecastPercentage / 100)
+	if status.ForecastPercentage >= 100 {
+		forecastPercentage.addStyle(backgroundRed)
+	} else {
+		forecastPercentage.addStyle(backgroundGreen)
+	}
+	return []cell{
+		newCell(status.Name),
+		newCell(status.Period),
+		newCell(status.PeriodBegin.Format("2006-01-02")),
+		newCell(status.PeriodEnd.Format("2006-01-02")),
+		newCell(accounts),
+		newCell(product),
+		newCell(tag),
+		newCell(status.Amount),
+		newCell(status.Actual),
+		newCell(status.ActualPercentage / 100),
+		newCell(status.Forecast),
+		forecastPercentage,
+	}
+}
+
+// isBudgetInReport checks whether a budget covers at least one of the
+// accounts of the report.
+func isBudgetInReport(budget budgets.Budget, identities []string) bool {
+	if len(budget.AwsAccounts) == 0 {
+		return true
+	}
+	for _, account := range budget.AwsAccounts {
+		for _, identity := range identities {
+			if account == identity {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getBudgetsReport(ctx context.Context, aas []aws.AwsAccount, date time.Time, tx *sql.Tx) (data [][]cell, err error) {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+
+	data = make([][]cell, 0)
+	data = append(data, budgetsFormat)
+
+	if date.IsZero() {
+		date = time.Now().UTC()
+	} else {
+		date = time.Date(date.Year(), date.Month()+1, 0, 23, 59, 59, 999999999, date.Location()).UTC()
+	}
+
+	if len(aas) < 1 {
+		err = errors.New("Missing AWS Account for Budgets Report")
+		return
+	}
+
+	identities := getIdentities(aas)
+
+	user, err := users.GetUserWithId(tx, aas[0].UserId)
+	if err != nil {
+		return
+	}
+
+	logger.Debug("Getting Budgets Report for accounts", map[string]interface{}{
+		"accounts": aas,
+	})
+	userBudgets, err := budgets.GetBudgetsForUser(tx, user)
+	if err != nil {
+		return
+	}
+
+	for _, budget := range userBudgets {
+		if !isBudgetInReport(budget, identities) {
+			continue
+		}
+		status, err := budgets.GetBudgetStatus(ctx, tx, user, budget, date)
+		if err != nil {
+			return data, err
+		}
+		data = append(data, formatBudgetStatus(status))
+	}
+	return
+}
@@ -0,0 +1,62 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package reports
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/trackit/trackit-server/awsSession"
+	"github.com/trackit/trackit-server/config"
+)
+
+// DeleteAccountReports deletes the spreadsheets stored for an AWS account in
+// the reports bucket. It does nothing if the bucket is not configured.
+func DeleteAccountReports(ctx context.Context, aaId int) error {
+	if config.ReportsBucket == "" {
+		return nil
+	}
+	svc := s3.New(awsSession.Session)
+	var deleteErr error
+	err := svc.ListObjectsPagesWithContext(ctx, &s3.ListObjectsInput{
+		Bucket: aws.String(config.ReportsBucket),
+		Prefix: aws.String(fmt.Sprintf("%d/", aaId)),
+	}, func(p *s3.ListObjectsOutput, lastPage bool) bool {
+		if len(p.Contents) == 0 {
+			return true
+		}
+		objects := make([]*s3.ObjectIdentifier, len(p.Contents))
+		for i, o := range p.Contents {
+			objects[i] = &s3.ObjectIdentifier{Key: o.Key}
+		}
+		res, err := svc.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(config.ReportsBucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			deleteErr = err
+		} else if len(res.Errors) > 0 {
+			deleteErr = fmt.Errorf("failed to delete report %s: %s", aws.StringValue(res.Errors[0].Key), aws.StringValue(res.Errors[0].Message))
+		}
+		return deleteErr == nil
+	})
+	if err != nil {
+		return err
+	}
+	return deleteErr
+}
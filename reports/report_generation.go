@@ -74,6 +74,11 @@ var modules = []module{
 		Function:  getCostDiff,
 		ErrorName: "CostDifferentiatorError",
 	},
+	{
+		Name:      "Budgets Report",
+		Function:  getBudgetsReport,
+		ErrorName: "budgetsReportError",
+	},
 }
 
 func GenerateReport(ctx context.Context, aa aws.AwsAccount, date time.Time) (errs map[string]error) {
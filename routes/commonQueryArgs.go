@@ -66,6 +66,18 @@ var (
 		Description: "The ID for a bill repository.",
 	}
 
+	// CostTypeOptionalQueryArg allows to get the cost metric to sum in the URL
+	// Parameters with routes.QueryArgs. This cost type will be a String
+	// stored in the routes.Arguments map with itself for key.
+	// CostTypeOptionalQueryArg is optional and will not panic if no query
+	// argument is found.
+	CostTypeOptionalQueryArg = QueryArg{
+		Name:        "cost-type",
+		Type:        QueryArgString{},
+		Description: "Cost metric to sum. Possible values are unblended (default), blended, amortized, net-unblended, net-amortized",
+		Optional:    true,
+	}
+
 	// DateQueryArg allows to get the iso8601 date in the URL
 	// Parameters with routes.QueryArgs. This date will be a
 	// time.Time stored in the routes.Arguments map with itself for key.
@@ -116,6 +128,30 @@ var (
 		Optional:    false,
 	}
 
+	// LineItemTypesExcludeOptionalQueryArg allows to get the line item types
+	// to leave out in the URL Parameters with routes.QueryArgs. These types
+	// will be a slice of String stored in the routes.Arguments map with
+	// itself for key. LineItemTypesExcludeOptionalQueryArg is optional and
+	// will not panic if no query argument is found.
+	LineItemTypesExcludeOptionalQueryArg = QueryArg{
+		Name:        "exclude-types",
+		Type:        QueryArgStringSlice{},
+		Description: "Comma separated line item types to leave out, e.g. Credit,Refund,Tax",
+		Optional:    true,
+	}
+
+	// LineItemTypesIncludeOptionalQueryArg allows to get the only line item
+	// types to take into account in the URL Parameters with routes.QueryArgs.
+	// These types will be a slice of String stored in the routes.Arguments
+	// map with itself for key. LineItemTypesIncludeOptionalQueryArg is
+	// optional and will not panic if no query argument is found.
+	LineItemTypesIncludeOptionalQueryArg = QueryArg{
+		Name:        "include-types",
+		Type:        QueryArgStringSlice{},
+		Description: "Comma separated line item types to take into account, e.g. Usage,DiscountedUsage. All of them by default",
+		Optional:    true,
+	}
+
 	// ShareIdQueryArg allows to get the DB id for an Shared access in the URL Parameters
 	// with routes.QueryArgs. This Shared ID will be an Uint stored
 	// in the routes.Arguments map with itself for key.
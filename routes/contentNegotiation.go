@@ -0,0 +1,136 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	ContentTypeJson   = "application/json"
+	ContentTypeCsv    = "text/csv"
+	ContentTypeNdjson = "application/x-ndjson"
+	ContentTypeExcel  = "application/vnd.ms-excel"
+)
+
+// mediaRange is a media range from an Accept header, with its quality.
+type mediaRange struct {
+	mainType string
+	subType  string
+	quality  float64
+}
+
+// specificity ranks how specific a media range is: "*/*" is less specific
+// than "text/*", itself less specific than "text/csv".
+func (mr mediaRange) specificity() int {
+	if mr.mainType == "*" {
+		return 0
+	} else if mr.subType == "*" {
+		return 1
+	}
+	return 2
+}
+
+// matches checks whether a media range matches a content type.
+func (mr mediaRange) matches(contentType string) bool {
+	mainType, subType := splitMediaType(contentType)
+	return (mr.mainType == "*" || mr.mainType == mainType) &&
+		(mr.subType == "*" || mr.subType == subType)
+}
+
+// splitMediaType splits a media type into its type and subtype, lowercased.
+func splitMediaType(mediaType string) (string, string) {
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(mediaType)), "/", 2)
+	if len(parts) != 2 {
+		return parts[0], ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
+
+// parseAccept parses the values of Accept headers as described in RFC 7231,
+// section 5.3.2. Malformed media ranges and quality values are ignored.
+func parseAccept(headers []string) []mediaRange {
+	var ranges []mediaRange
+	for _, header := range headers {
+		for _, element := range strings.Split(header, ",") {
+			if mr, ok := parseMediaRange(element); ok {
+				ranges = append(ranges, mr)
+			}
+		}
+	}
+	return ranges
+}
+
+// parseMediaRange parses a single media range with its parameters.
+func parseMediaRange(element string) (mediaRange, bool) {
+	params := strings.Split(element, ";")
+	mainType, subType := splitMediaType(params[0])
+	if mainType == "" || subType == "" || (mainType == "*" && subType != "*") {
+		return mediaRange{}, false
+	}
+	mr := mediaRange{mainType, subType, 1}
+	for _, param := range params[1:] {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) == 2 && strings.ToLower(strings.TrimSpace(kv[0])) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil || q < 0 || q > 1 {
+				return mediaRange{}, false
+			}
+			mr.quality = q
+			// Parameters after the quality are accept extensions.
+			break
+		}
+	}
+	return mr, true
+}
+
+// negotiateContentType picks the content type a response should be written
+// as, among offers, according to the Accept headers of the request. Offers
+// are in order of preference: the first one is used if the client has no
+// preference and wins ties. The boolean is false if the client accepts none
+// of the offers.
+func negotiateContentType(accept []string, offers []string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return offers[0], true
+	}
+	var best string
+	var bestQuality float64
+	for _, offer := range offers {
+		quality, specificity := 0.0, -1
+		for _, mr := range ranges {
+			if mr.matches(offer) && mr.specificity() > specificity {
+				quality, specificity = mr.quality, mr.specificity()
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = offer, quality
+		}
+	}
+	return best, bestQuality > 0
+}
+
+// AcceptsContentTypes checks whether the client accepts one of the offered
+// content types, so that handlers whose output is costly to prepare can
+// respond with a 406 status before preparing it.
+func AcceptsContentTypes(r *http.Request, offers []string) bool {
+	_, ok := negotiateContentType(r.Header["Accept"], offers)
+	return ok
+}
@@ -0,0 +1,154 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{ContentTypeJson, ContentTypeCsv, ContentTypeNdjson}
+	for _, c := range []struct {
+		accept   []string
+		expected string
+		ok       bool
+	}{
+		{nil, ContentTypeJson, true},
+		{[]string{""}, ContentTypeJson, true},
+		{[]string{"*/*"}, ContentTypeJson, true},
+		{[]string{"text/csv"}, ContentTypeCsv, true},
+		{[]string{"TEXT/CSV; charset=utf-8"}, ContentTypeCsv, true},
+		{[]string{"text/*"}, ContentTypeCsv, true},
+		{[]string{"application/json;q=0.5, text/csv"}, ContentTypeCsv, true},
+		{[]string{"application/json;q=0.5", "text/csv;q=0.4"}, ContentTypeJson, true},
+		{[]string{"application/x-ndjson;q=0.9, */*;q=0.1"}, ContentTypeNdjson, true},
+		{[]string{"*/*;q=0.8, application/json;q=0"}, ContentTypeCsv, true},
+		{[]string{"text/csv;q=0.5, text/csv;q=1"}, ContentTypeCsv, true},
+		{[]string{"application/xml"}, "", false},
+		{[]string{"text/csv;q=0"}, "", false},
+		{[]string{"text/csv;q=2"}, ContentTypeJson, true},
+		{[]string{"*/csv"}, ContentTypeJson, true},
+	} {
+		contentType, ok := negotiateContentType(c.accept, offers)
+		if contentType != c.expected || ok != c.ok {
+			t.Errorf("Accept %q should negotiate (%q, %v), negotiated (%q, %v).", c.accept, c.expected, c.ok, contentType, ok)
+		}
+	}
+}
+
+func TestAcceptsContentTypes(t *testing.T) {
+	offers := []string{ContentTypeCsv, ContentTypeNdjson}
+	for _, c := range []struct {
+		accept   []string
+		expected bool
+	}{
+		{nil, true},
+		{[]string{"application/x-ndjson"}, true},
+		{[]string{"application/json"}, false},
+		{[]string{"application/json, text/csv;q=0"}, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header["Accept"] = c.accept
+		if ok := AcceptsContentTypes(r, offers); ok != c.expected {
+			t.Errorf("Accept %q should be acceptable: %v, is %v.", c.accept, c.expected, ok)
+		}
+	}
+}
+
+type testStreamer [][]string
+
+func (ts testStreamer) StreamCSV(write func([]string) error) error {
+	for _, row := range ts {
+		if err := write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts testStreamer) StreamNDJSON(write func(interface{}) error) error {
+	for _, row := range ts {
+		if err := write(map[string]string{row[0]: row[1]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts testStreamer) ContentTypes() []string {
+	return []string{ContentTypeCsv, ContentTypeNdjson}
+}
+
+func serveWithAccept(output interface{}, status int, accept string) *httptest.ResponseRecorder {
+	h := H(func(_ *http.Request, _ Arguments) (int, interface{}) { return status, output })
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	response := httptest.NewRecorder()
+	h.ServeHTTP(response, request)
+	return response
+}
+
+func TestServeHttpStreams(t *testing.T) {
+	output := testStreamer{{"a", "1"}, {"b", "2"}}
+	for _, c := range []struct {
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"", http.StatusOK, "text/csv; charset=utf-8", "a,1\nb,2\n"},
+		{"text/csv", http.StatusOK, "text/csv; charset=utf-8", "a,1\nb,2\n"},
+		{"application/x-ndjson", http.StatusOK, "application/x-ndjson; charset=utf-8", "{\"a\":\"1\"}\n{\"b\":\"2\"}\n"},
+		{"application/json", http.StatusNotAcceptable, "application/json; charset=utf-8", ""},
+	} {
+		response := serveWithAccept(output, http.StatusOK, c.accept)
+		if response.Code != c.status {
+			t.Errorf("Status for %q should be %d, is %d.", c.accept, c.status, response.Code)
+		}
+		if ct := response.Header().Get("Content-Type"); ct != c.contentType {
+			t.Errorf("Content type for %q should be %q, is %q.", c.accept, c.contentType, ct)
+		}
+		if c.body != "" && response.Body.String() != c.body {
+			t.Errorf("Body for %q should be %q, is %q.", c.accept, c.body, response.Body.String())
+		}
+	}
+}
+
+func TestServeHttpNotAcceptable(t *testing.T) {
+	response := serveWithAccept(getFooResponse, http.StatusOK, "text/csv")
+	if response.Code != http.StatusNotAcceptable {
+		t.Errorf("Status should be %d, is %d.", http.StatusNotAcceptable, response.Code)
+	}
+	var body errorBody
+	if err := json.Unmarshal(response.Body.Bytes(), &body); err != nil || body.Error == "" {
+		t.Errorf("Body should be a JSON error, is %q.", response.Body.String())
+	}
+}
+
+func TestServeHttpErrorsAsJson(t *testing.T) {
+	response := serveWithAccept(errorBody{errors.New("nope").Error()}, http.StatusNotFound, "text/csv")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Status should be %d, is %d.", http.StatusNotFound, response.Code)
+	}
+	if ct := response.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("Content type should be JSON, is %q.", ct)
+	}
+}
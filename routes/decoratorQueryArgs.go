@@ -48,6 +48,10 @@ type (
 	// interface.
 	QueryArgUint struct{}
 
+	// QueryArgFloat denotes a float64 query argument. It fulfills the
+	// QueryParser interface.
+	QueryArgFloat struct{}
+
 	// QueryArgString denotes a string query argument. It fulfills the QueryParser
 	// interface.
 	QueryArgString struct{}
@@ -96,6 +100,7 @@ type (
 func (d QueryArgBool) FormatName() string        { return "bool" }
 func (d QueryArgInt) FormatName() string         { return "int" }
 func (d QueryArgUint) FormatName() string        { return "uint" }
+func (d QueryArgFloat) FormatName() string       { return "float64" }
 func (d QueryArgString) FormatName() string      { return "string" }
 func (d QueryArgIntSlice) FormatName() string    { return "[]int" }
 func (d QueryArgUintSlice) FormatName() string   { return "[]uint" }
@@ -133,6 +138,15 @@ func (QueryArgUint) QueryParse(val string) (interface{}, error) {
 	return nil, errors.New("must be a uint")
 }
 
+// QueryParse parses a float64. A nil error indicates a success. With this
+// func, QueryArgFloat fulfills QueryArgType.
+func (QueryArgFloat) QueryParse(val string) (interface{}, error) {
+	if f, err := strconv.ParseFloat(val, 64); err == nil {
+		return f, nil
+	}
+	return nil, errors.New("must be a float")
+}
+
 // QueryParse parses a string. A nil error indicates a success. With this func,
 // QueryArgString fulfills QueryArgType.
 func (QueryArgString) QueryParse(val string) (interface{}, error) {
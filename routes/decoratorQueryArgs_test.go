@@ -26,6 +26,7 @@ var (
 	QueryArgTestBool           = QueryArg{"testBool", "Test boolean", QueryArgBool{}, false}
 	QueryArgTestInt            = QueryArg{"testInt", "Test signed integer", QueryArgInt{}, false}
 	QueryArgTestUint           = QueryArg{"testUint", "Test unsigned integer", QueryArgUint{}, false}
+	QueryArgTestFloat          = QueryArg{"testFloat", "Test float", QueryArgFloat{}, false}
 	QueryArgTestString         = QueryArg{"testString", "Test string", QueryArgString{}, false}
 	QueryArgTestOptionalString = QueryArg{"testString", "Test string", QueryArgString{}, true}
 	QueryArgTestIntSlice       = QueryArg{"testIntSlice", "Test signed integer slice", QueryArgIntSlice{}, false}
@@ -162,6 +163,42 @@ func TestRightUintArg(t *testing.T) {
 	}
 }
 
+func TestRightFloatArg(t *testing.T) {
+	h := H(argHandler).With(
+		QueryArgs{QueryArgTestFloat},
+	)
+	request := httptest.NewRequest("GET", "/test?testFloat=42.5", nil)
+	response := httptest.NewRecorder()
+	status, body := h.Func(response, request, Arguments{})
+	if status != 200 {
+		t.Errorf("Expected 200. Got %d (%s)", status, body)
+	} else if args, ok := body.(Arguments); !ok {
+		t.Errorf("Expected type Arguments.")
+	} else if testFloat, ok := args[QueryArgTestFloat]; !ok {
+		t.Errorf("testFloat not in the arguments.")
+	} else if testFloat.(float64) != 42.5 {
+		t.Errorf("testFloat: Expected 42.5. Got %v", testFloat)
+	}
+}
+
+const testBadFloatArgExpectedError = `query arg 'testFloat': must be a float`
+
+func TestBadFloatArg(t *testing.T) {
+	h := H(argHandler).With(
+		QueryArgs{QueryArgTestFloat},
+	)
+	request := httptest.NewRequest("GET", "/test?testFloat=forty-two", nil)
+	response := httptest.NewRecorder()
+	status, body := h.Func(response, request, Arguments{})
+	if status != 400 {
+		t.Errorf("Expected 400. Got %d (%s)", status, body)
+	} else if err, ok := body.(error); !ok {
+		t.Errorf("Expected error.")
+	} else if err.Error() != testBadFloatArgExpectedError {
+		t.Errorf("Expected (%v). Got (%v)", testBadFloatArgExpectedError, err.Error())
+	}
+}
+
 func TestOptionalStringArg(t *testing.T) {
 	h := H(argHandler).With(
 		QueryArgs{QueryArgTestOptionalString},
@@ -0,0 +1,182 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routes
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/config"
+)
+
+// RateLimitStore stores the failed attempts counted by RateLimit. It should
+// be shared by all backends so that limits hold across them.
+type RateLimitStore interface {
+	// LockedUntil returns the time until which a key is locked out. It is
+	// the zero time if the key is not locked out.
+	LockedUntil(ctx context.Context, key string) (time.Time, error)
+	// Fail records a failed attempt for a key. Attempts older than window
+	// are forgotten. The key is locked out for lockout once it reached
+	// maxFailures.
+	Fail(ctx context.Context, key string, maxFailures int, window, lockout time.Duration) error
+	// Reset forgets the failed attempts of a key.
+	Reset(ctx context.Context, key string) error
+}
+
+// RateLimitKey is one of the keys RateLimit counts failed attempts by.
+type RateLimitKey struct {
+	// Name is prefixed to the keys so that keys of different kinds do not
+	// collide.
+	Name string
+	// Key returns the key of a request. Requests with an empty key are not
+	// limited.
+	Key func(*http.Request, Arguments) string
+	// MaxFailures is the number of failed attempts after which the key is
+	// locked out.
+	MaxFailures int
+	// ResetOnSuccess, if set, forgets the failed attempts of the key when
+	// an attempt succeeds.
+	ResetOnSuccess bool
+}
+
+// RateLimit is a decorator which locks keys out after too many failed
+// attempts at a handler, answering 429 until the lockout ends without calling
+// the handler. An attempt failed if the handler answered 401, 403 or 404, or
+// for any attempt if CountAll is set, which suits handlers whose responses do
+// not tell whether they succeeded. A failure to reach the store does not
+// prevent requests from being handled.
+type RateLimit struct {
+	Store    RateLimitStore
+	Keys     []RateLimitKey
+	Window   time.Duration
+	Lockout  time.Duration
+	CountAll bool
+}
+
+const (
+	// ErrRateLimited is returned to clients that were locked out. It does
+	// not tell which key was locked out so that it cannot be used to find
+	// out whether an account exists.
+	ErrRateLimited = constError("Too many failed attempts. Try again later.")
+	TagRateLimit   = "ratelimit"
+)
+
+func (rl RateLimit) Decorate(h Handler) Handler {
+	h.Func = rl.getFunc(h.Func)
+	h.Documentation = rl.getDocumentation(h.Documentation)
+	return h
+}
+
+// getFunc builds the handler function for RateLimit.Decorate.
+func (rl RateLimit) getFunc(hf HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, a Arguments) (int, interface{}) {
+		ctx := r.Context()
+		logger := jsonlog.LoggerFromContextOrDefault(ctx)
+		keys := rl.requestKeys(r, a)
+		if until := rl.lockedUntil(ctx, keys); !until.IsZero() {
+			retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+			logger.Warning("Request was rate limited.", map[string]interface{}{
+				"keys":  keys,
+				"until": until,
+			})
+			return http.StatusTooManyRequests, ErrRateLimited
+		}
+		status, output := hf(w, r, a)
+		failed := rl.CountAll || status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusNotFound
+		for i, key := range keys {
+			var err error
+			if key == "" {
+				continue
+			} else if failed {
+				err = rl.Store.Fail(ctx, key, rl.Keys[i].MaxFailures, rl.Window, rl.Lockout)
+			} else if status < http.StatusBadRequest && rl.Keys[i].ResetOnSuccess {
+				err = rl.Store.Reset(ctx, key)
+			}
+			if err != nil {
+				logger.Error("Failed to record attempt.", map[string]interface{}{
+					"key":   key,
+					"error": err.Error(),
+				})
+			}
+		}
+		return status, output
+	}
+}
+
+// requestKeys returns the prefixed keys of a request, in the order of
+// rl.Keys. Keys are empty for requests that are not limited by them.
+func (rl RateLimit) requestKeys(r *http.Request, a Arguments) []string {
+	keys := make([]string, len(rl.Keys))
+	for i, rlk := range rl.Keys {
+		if key := rlk.Key(r, a); key != "" {
+			keys[i] = rlk.Name + ":" + key
+		}
+	}
+	return keys
+}
+
+// lockedUntil returns the latest time until which one of the keys is locked
+// out, or the zero time if none is.
+func (rl RateLimit) lockedUntil(ctx context.Context, keys []string) (until time.Time) {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	now := time.Now()
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		keyUntil, err := rl.Store.LockedUntil(ctx, key)
+		if err != nil {
+			logger.Error("Failed to get lockout.", map[string]interface{}{
+				"key":   key,
+				"error": err.Error(),
+			})
+		} else if keyUntil.After(now) && keyUntil.After(until) {
+			until = keyUntil
+		}
+	}
+	return
+}
+
+func (rl RateLimit) getDocumentation(hd HandlerDocumentation) HandlerDocumentation {
+	if hd.Tags == nil {
+		hd.Tags = make(Tags)
+	}
+	hd.Tags[TagRateLimit] = []string{rl.Window.String(), rl.Lockout.String()}
+	return hd
+}
+
+// RemoteAddress returns the address of the client which sent a request,
+// without its port. If config.TrustForwardedFor is set, it is read from the
+// last X-Forwarded-For entry, which was added by the load balancer.
+func RemoteAddress(r *http.Request) string {
+	if config.TrustForwardedFor {
+		if forwarded := r.Header["X-Forwarded-For"]; len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			return strings.TrimSpace(entries[len(entries)-1])
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
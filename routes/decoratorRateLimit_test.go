@@ -0,0 +1,125 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// memoryRateLimitStore is a RateLimitStore which ignores windows.
+type memoryRateLimitStore struct {
+	failures    map[string]int
+	lockedUntil map[string]time.Time
+}
+
+func newMemoryRateLimitStore() memoryRateLimitStore {
+	return memoryRateLimitStore{
+		failures:    make(map[string]int),
+		lockedUntil: make(map[string]time.Time),
+	}
+}
+
+func (s memoryRateLimitStore) LockedUntil(_ context.Context, key string) (time.Time, error) {
+	return s.lockedUntil[key], nil
+}
+
+func (s memoryRateLimitStore) Fail(_ context.Context, key string, maxFailures int, _, lockout time.Duration) error {
+	s.failures[key]++
+	if s.failures[key] >= maxFailures {
+		s.lockedUntil[key] = time.Now().Add(lockout)
+	}
+	return nil
+}
+
+func (s memoryRateLimitStore) Reset(_ context.Context, key string) error {
+	delete(s.failures, key)
+	return nil
+}
+
+func rateLimitTestHandler(store RateLimitStore) Handler {
+	return H(func(r *http.Request, _ Arguments) (int, interface{}) {
+		if r.URL.Query().Get("password") == "good" {
+			return http.StatusOK, nil
+		}
+		return http.StatusForbidden, nil
+	}).With(RateLimit{
+		Store: store,
+		Keys: []RateLimitKey{
+			{
+				Name:           "user",
+				Key:            func(r *http.Request, _ Arguments) string { return r.URL.Query().Get("user") },
+				MaxFailures:    3,
+				ResetOnSuccess: true,
+			},
+			{
+				Name:        "address",
+				Key:         func(r *http.Request, _ Arguments) string { return RemoteAddress(r) },
+				MaxFailures: 5,
+			},
+		},
+		Window:  time.Minute,
+		Lockout: time.Minute,
+	})
+}
+
+func rateLimitTestRequest(h Handler, query string) (int, http.Header) {
+	request := httptest.NewRequest(http.MethodPost, "/?"+query, nil)
+	response := httptest.NewRecorder()
+	s, _ := h.Func(response, request, Arguments{})
+	return s, response.Header()
+}
+
+func TestRateLimitLocksKeyOut(t *testing.T) {
+	h := rateLimitTestHandler(newMemoryRateLimitStore())
+	for i := 0; i < 3; i++ {
+		if s, _ := rateLimitTestRequest(h, "user=foo&password=bad"); s != http.StatusForbidden {
+			t.Fatalf("Attempt %d should have status %d, has %d instead.", i, http.StatusForbidden, s)
+		}
+	}
+	s, header := rateLimitTestRequest(h, "user=foo&password=good")
+	if s != http.StatusTooManyRequests {
+		t.Errorf("Status should be %d, is %d instead.", http.StatusTooManyRequests, s)
+	}
+	if header.Get("Retry-After") != "60" {
+		t.Errorf("Retry-After should be 60, is %q instead.", header.Get("Retry-After"))
+	}
+	if s, _ := rateLimitTestRequest(h, "user=bar&password=good"); s != http.StatusOK {
+		t.Errorf("Other keys should not be locked out, status is %d.", s)
+	}
+}
+
+func TestRateLimitResetOnSuccess(t *testing.T) {
+	store := newMemoryRateLimitStore()
+	h := rateLimitTestHandler(store)
+	rateLimitTestRequest(h, "user=foo&password=bad")
+	rateLimitTestRequest(h, "user=foo&password=bad")
+	rateLimitTestRequest(h, "user=foo&password=good")
+	if f := store.failures["user:foo"]; f != 0 {
+		t.Errorf("User failures should have been reset, are %d instead.", f)
+	}
+	if f := store.failures["address:192.0.2.1"]; f != 2 {
+		t.Errorf("Address failures should not have been reset, are %d instead.", f)
+	}
+	for i := 0; i < 3; i++ {
+		rateLimitTestRequest(h, "user=bar&password=bad")
+	}
+	if s, _ := rateLimitTestRequest(h, "user=baz&password=good"); s != http.StatusTooManyRequests {
+		t.Errorf("Address should be locked out, status is %d.", s)
+	}
+}
@@ -93,6 +93,7 @@ func (rb RequestBody) getDocumentation(hd HandlerDocumentation) HandlerDocumenta
 			Description: rb.getSchemaString(),
 		},
 	}
+	hd.requestBody = reflect.TypeOf(rb.Example)
 	return hd
 }
 
@@ -15,6 +15,7 @@
 package routes
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/satori/go.uuid"
@@ -39,3 +40,10 @@ func (_ RequestId) getFunc(hf HandlerFunc) HandlerFunc {
 		return hf(w, r, a)
 	}
 }
+
+// RequestIdFromContext returns the request ID added by RequestId to a
+// request's context. It is empty if there is none.
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(contextKeyRequestId).(string)
+	return requestId
+}
@@ -0,0 +1,83 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/trackit/trackit-server/metrics"
+)
+
+var (
+	requestsTotal = metrics.NewCounter(
+		"trackit_http_requests_total",
+		"Number of HTTP requests handled, by route pattern, method and status.",
+		"pattern", "method", "status",
+	)
+	requestDuration = metrics.NewHistogram(
+		"trackit_http_request_duration_seconds",
+		"Time taken to handle HTTP requests, by route pattern, method and status.",
+		nil,
+		"pattern", "method", "status",
+	)
+
+	// standardMethods are the methods requests are labelled with. Any other
+	// method is labelled as otherMethod, so that clients can not create
+	// series at will.
+	standardMethods = map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodPost:    true,
+		http.MethodPut:     true,
+		http.MethodPatch:   true,
+		http.MethodDelete:  true,
+		http.MethodConnect: true,
+		http.MethodOptions: true,
+		http.MethodTrace:   true,
+	}
+)
+
+// otherMethod is the method label of the requests with a non-standard method.
+const otherMethod = "other"
+
+// RequestMetrics is a decorator which counts and times the requests to a
+// route. Pattern is the pattern the route is registered at, which is used
+// rather than the URL to keep the number of series bounded.
+type RequestMetrics struct {
+	Pattern string
+}
+
+func (rm RequestMetrics) Decorate(h Handler) Handler {
+	h.Func = rm.getFunc(h.Func)
+	return h
+}
+
+// getFunc builds the route handler function for RequestMetrics.Decorate.
+func (rm RequestMetrics) getFunc(hf HandlerFunc) HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, a Arguments) (int, interface{}) {
+		start := time.Now()
+		status, response := hf(w, r, a)
+		statusString := strconv.Itoa(status)
+		method := r.Method
+		if !standardMethods[method] {
+			method = otherMethod
+		}
+		requestsTotal.Inc(rm.Pattern, method, statusString)
+		requestDuration.Observe(time.Since(start).Seconds(), rm.Pattern, method, statusString)
+		return status, response
+	}
+}
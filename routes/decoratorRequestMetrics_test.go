@@ -0,0 +1,62 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routes
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/trackit/trackit-server/metrics"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	h := H(getFoo).With(
+		RequestMetrics{"/foo/metrics"},
+	)
+	for i := 0; i < 2; i++ {
+		request := httptest.NewRequest(http.MethodGet, "/foo/metrics?bar=baz", nil)
+		h.Func(httptest.NewRecorder(), request, Arguments{})
+	}
+	var buf bytes.Buffer
+	metrics.DefaultRegistry.WriteTo(&buf)
+	expected := []string{
+		`trackit_http_requests_total{pattern="/foo/metrics",method="GET",status="200"} 2`,
+		`trackit_http_request_duration_seconds_count{pattern="/foo/metrics",method="GET",status="200"} 2`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(buf.String(), e) {
+			t.Errorf("Metrics should contain '%s', don't.", e)
+		}
+	}
+}
+
+func TestRequestMetricsOtherMethod(t *testing.T) {
+	h := H(getFoo).With(
+		RequestMetrics{"/foo/other"},
+	)
+	request := httptest.NewRequest("BREW", "/foo/other", nil)
+	h.Func(httptest.NewRecorder(), request, Arguments{})
+	var buf bytes.Buffer
+	metrics.DefaultRegistry.WriteTo(&buf)
+	if e := `trackit_http_requests_total{pattern="/foo/other",method="other",status="200"} 1`; !strings.Contains(buf.String(), e) {
+		t.Errorf("Metrics should contain '%s', don't.", e)
+	}
+	if strings.Contains(buf.String(), `method="BREW"`) {
+		t.Errorf("Metrics should not contain the non-standard method.")
+	}
+}
@@ -16,6 +16,7 @@ package routes
 
 import (
 	"net/http"
+	"reflect"
 )
 
 // Tags is a map of tags on a documentation.
@@ -34,6 +35,9 @@ type HandlerDocumentationBody struct {
 type HandlerDocumentation struct {
 	HandlerDocumentationBody
 	Components map[string]HandlerDocumentation `json:"components,omitempty"`
+	// requestBody is the type of the request body expected by the handler,
+	// if any. It is used to build the OpenAPI specification.
+	requestBody reflect.Type
 }
 
 const (
@@ -0,0 +1,282 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routes
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/trackit/trackit-server/util/req"
+)
+
+const (
+	// openApiVersion is the version of the OpenAPI specification the
+	// generated document conforms to.
+	openApiVersion = "3.0.0"
+	// openApiSecurityScheme is the name of the security scheme used by
+	// operations requiring an authenticated user.
+	openApiSecurityScheme = "userAuth"
+	// tagRequireUserAuthentication is the tag users.RequireAuthenticatedUser
+	// sets on the handlers it decorates. Package users depends on package
+	// routes, hence the copy.
+	tagRequireUserAuthentication = "require:userauth"
+
+	openApiDescription = "Get the api's documentation as an OpenAPI 3 " +
+		"specification. It is generated from the same metadata as /docs " +
+		"and can be used to generate typed API clients."
+	openApiSummary = "get the api's openapi specification"
+)
+
+type (
+	// OpenApiDocument is the root of an OpenAPI 3 specification.
+	OpenApiDocument struct {
+		OpenApi    string                                 `json:"openapi"`
+		Info       OpenApiInfo                            `json:"info"`
+		Paths      map[string]map[string]OpenApiOperation `json:"paths"`
+		Components OpenApiComponents                      `json:"components"`
+	}
+
+	// OpenApiInfo holds the metadata of an OpenAPI specification.
+	OpenApiInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	// OpenApiComponents holds the reusable objects of an OpenAPI
+	// specification.
+	OpenApiComponents struct {
+		SecuritySchemes map[string]OpenApiSecurityScheme `json:"securitySchemes"`
+	}
+
+	// OpenApiSecurityScheme describes how clients authenticate.
+	OpenApiSecurityScheme struct {
+		Type        string `json:"type"`
+		In          string `json:"in"`
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+	}
+
+	// OpenApiOperation describes a single method on a path.
+	OpenApiOperation struct {
+		OperationId string                     `json:"operationId"`
+		Summary     string                     `json:"summary,omitempty"`
+		Description string                     `json:"description,omitempty"`
+		Parameters  []OpenApiParameter         `json:"parameters,omitempty"`
+		RequestBody *OpenApiRequestBody        `json:"requestBody,omitempty"`
+		Responses   map[string]OpenApiResponse `json:"responses"`
+		Security    []map[string][]string      `json:"security,omitempty"`
+	}
+
+	// OpenApiParameter describes a query parameter of an operation.
+	OpenApiParameter struct {
+		Name        string                 `json:"name"`
+		In          string                 `json:"in"`
+		Description string                 `json:"description,omitempty"`
+		Required    bool                   `json:"required"`
+		Style       string                 `json:"style,omitempty"`
+		Explode     *bool                  `json:"explode,omitempty"`
+		Schema      map[string]interface{} `json:"schema"`
+	}
+
+	// OpenApiRequestBody describes the body of a request.
+	OpenApiRequestBody struct {
+		Required bool                        `json:"required"`
+		Content  map[string]OpenApiMediaType `json:"content"`
+	}
+
+	// OpenApiMediaType describes the content of a body for a media type.
+	OpenApiMediaType struct {
+		Schema map[string]interface{} `json:"schema"`
+	}
+
+	// OpenApiResponse describes a response of an operation.
+	OpenApiResponse struct {
+		Description string `json:"description"`
+	}
+)
+
+var openApiHandler = MethodMuxer{
+	http.MethodGet: H(getOpenApi).With(Documentation{
+		Summary:     openApiSummary,
+		Description: openApiDescription,
+	}),
+}.H()
+
+// OpenApiHandler returns a Handler which responds to http.MethodGet requests
+// with an OpenAPI 3 specification of all registered routes.
+func OpenApiHandler() Handler {
+	return openApiHandler
+}
+
+func getOpenApi(_ *http.Request, _ Arguments) (int, interface{}) {
+	return http.StatusOK, BuildOpenApi(RegisteredHandlers)
+}
+
+// BuildOpenApi builds an OpenAPI 3 specification from the documentation of a
+// list of registered handlers. Each method component of a handler's
+// documentation becomes an operation. Handlers which are not method muxers
+// are documented as a single GET operation.
+func BuildOpenApi(rhs []RegisteredHandler) OpenApiDocument {
+	doc := OpenApiDocument{
+		OpenApi: openApiVersion,
+		Info: OpenApiInfo{
+			Title:   "TrackIt API",
+			Version: "1",
+		},
+		Paths: make(map[string]map[string]OpenApiOperation),
+		Components: OpenApiComponents{
+			SecuritySchemes: map[string]OpenApiSecurityScheme{
+				openApiSecurityScheme: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Authorization",
+					Description: "Token obtained from /user/login.",
+				},
+			},
+		},
+	}
+	for _, rh := range rhs {
+		operations := make(map[string]OpenApiOperation)
+		for component, hd := range rh.Handler.Documentation.Components {
+			if strings.HasPrefix(component, "method:") {
+				method := strings.TrimPrefix(component, "method:")
+				operations[strings.ToLower(method)] = buildOpenApiOperation(rh.Pattern, method, rh.Handler.Documentation, hd)
+			}
+		}
+		if len(operations) == 0 {
+			operations["get"] = buildOpenApiOperation(rh.Pattern, http.MethodGet, HandlerDocumentation{}, rh.Handler.Documentation)
+		}
+		doc.Paths[rh.Pattern] = operations
+	}
+	return doc
+}
+
+// buildOpenApiOperation builds an operation from the documentation of a method
+// handler. Tags set on the enclosing handler, e.g. by decorators applied on
+// the MethodMuxer, apply to the operation too.
+func buildOpenApiOperation(pattern, method string, parent, hd HandlerDocumentation) OpenApiOperation {
+	tags := mergeTags(parent.Tags, hd.Tags)
+	op := OpenApiOperation{
+		OperationId: openApiOperationId(pattern, method),
+		Summary:     hd.Summary,
+		Description: hd.Description,
+		Responses: map[string]OpenApiResponse{
+			"200": {Description: "success"},
+		},
+	}
+	op.Parameters = append(op.Parameters, buildOpenApiParameters(tags[TagRequiredQueryArg], true)...)
+	op.Parameters = append(op.Parameters, buildOpenApiParameters(tags[TagOptionalQueryArg], false)...)
+	if hd.requestBody != nil {
+		op.RequestBody = &OpenApiRequestBody{
+			Required: true,
+			Content: map[string]OpenApiMediaType{
+				ContentTypeJson: {Schema: req.GetJsonSchema(hd.requestBody)},
+			},
+		}
+	}
+	if len(op.Parameters) > 0 || op.RequestBody != nil {
+		op.Responses["400"] = OpenApiResponse{Description: "invalid request"}
+	}
+	if _, ok := tags[tagRequireUserAuthentication]; ok {
+		op.Security = []map[string][]string{{openApiSecurityScheme: {}}}
+		op.Responses["401"] = OpenApiResponse{Description: "authentication required"}
+	}
+	if _, ok := tags[TagRateLimit]; ok {
+		op.Responses["429"] = OpenApiResponse{Description: "too many failed attempts"}
+	}
+	return op
+}
+
+// mergeTags merges two sets of tags into a new one.
+func mergeTags(ts ...Tags) Tags {
+	merged := make(Tags)
+	for _, t := range ts {
+		for k, v := range t {
+			merged[k] = append(merged[k], v...)
+		}
+	}
+	return merged
+}
+
+// buildOpenApiParameters builds query parameters from the documentation tags
+// set by QueryArgs, formatted as "name:type:description".
+func buildOpenApiParameters(args []string, required bool) []OpenApiParameter {
+	params := make([]OpenApiParameter, 0, len(args))
+	for _, arg := range args {
+		parts := strings.SplitN(arg, ":", 3)
+		if len(parts) < 2 {
+			continue
+		}
+		param := OpenApiParameter{
+			Name:     parts[0],
+			In:       "query",
+			Required: required,
+			Schema:   openApiSchemaForFormat(parts[1]),
+		}
+		if len(parts) == 3 {
+			param.Description = parts[2]
+		}
+		if param.Schema["type"] == "array" {
+			explode := false
+			param.Style = "form"
+			param.Explode = &explode
+		}
+		params = append(params, param)
+	}
+	sort.SliceStable(params, func(i, j int) bool {
+		return params[i].Required && !params[j].Required
+	})
+	return params
+}
+
+// openApiSchemaForFormat returns the schema of a query argument from the name
+// returned by its QueryParser's FormatName.
+func openApiSchemaForFormat(format string) map[string]interface{} {
+	if strings.HasPrefix(format, "[]") {
+		return map[string]interface{}{
+			"type":  "array",
+			"items": openApiSchemaForFormat(strings.TrimPrefix(format, "[]")),
+		}
+	}
+	switch format {
+	case "bool":
+		return map[string]interface{}{"type": "boolean"}
+	case "int":
+		return map[string]interface{}{"type": "integer"}
+	case "uint":
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case "float64":
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case "time.Time":
+		return map[string]interface{}{"type": "string", "format": "date"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// openApiOperationId builds a unique operation ID from a method and a path,
+// e.g. "getCostsDiff" for GET /costs/diff.
+func openApiOperationId(pattern, method string) string {
+	id := strings.ToLower(method)
+	words := strings.FieldsFunc(pattern, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		id += strings.ToUpper(w[:1]) + w[1:]
+	}
+	return id
+}
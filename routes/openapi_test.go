@@ -0,0 +1,72 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+type testOpenApiBody struct {
+	Name string `json:"name" req:"nonzero"`
+}
+
+func TestOpenApi(t *testing.T) {
+	MethodMuxer{
+		http.MethodGet: H(getOpenApi).With(
+			Documentation{Summary: "list things"},
+			QueryArgs{
+				QueryArg{Name: "begin", Type: QueryArgDate{}, Description: "begin: date"},
+				QueryArg{Name: "ids", Type: QueryArgUintSlice{}, Optional: true},
+			},
+		),
+		http.MethodPost: H(getOpenApi).With(
+			RequestBody{testOpenApiBody{"thing"}},
+		),
+	}.H().With(Documentation{
+		Tags: Tags{tagRequireUserAuthentication: []string{"."}},
+	}).Register("/things/list")
+	OpenApiHandler().Register("/docs/openapi.json")
+	defer resetRegisteredHandlers()
+	doc := BuildOpenApi(RegisteredHandlers)
+	get := doc.Paths["/things/list"]["get"]
+	if get.OperationId != "getThingsList" {
+		t.Errorf("Operation ID should be getThingsList, is %s instead.", get.OperationId)
+	}
+	if len(get.Parameters) != 2 {
+		t.Fatalf("GET should have 2 parameters, has %d instead.", len(get.Parameters))
+	}
+	if p := get.Parameters[0]; p.Name != "begin" || !p.Required || p.Schema["format"] != "date" || p.Description != "begin: date" {
+		t.Errorf("Unexpected parameter %#v.", p)
+	}
+	if p := get.Parameters[1]; p.Name != "ids" || p.Required || p.Schema["type"] != "array" {
+		t.Errorf("Unexpected parameter %#v.", p)
+	}
+	if len(get.Security) != 1 {
+		t.Errorf("GET should require authentication.")
+	}
+	post := doc.Paths["/things/list"]["post"]
+	if post.RequestBody == nil {
+		t.Fatalf("POST should have a request body.")
+	}
+	body, _ := json.Marshal(post.RequestBody.Content[ContentTypeJson].Schema)
+	if expected := `{"properties":{"name":{"type":"string"}},"required":["name"],"type":"object"}`; string(body) != expected {
+		t.Errorf("Body schema should be %s, is %s instead.", expected, string(body))
+	}
+	if docs := doc.Paths["/docs/openapi.json"]["get"]; docs.Security != nil || docs.Summary != openApiSummary {
+		t.Errorf("Unexpected operation %#v.", docs)
+	}
+}
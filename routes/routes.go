@@ -21,6 +21,8 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/trackit/jsonlog"
+
 	"github.com/trackit/trackit-server/config"
 )
 
@@ -65,55 +67,166 @@ type xlsGenerator interface {
 	GetFileName() string
 }
 
+// CsvStreamer is an interface for any type that can write itself as CSV row
+// by row, so that large exports need not be held in memory. It is preferred
+// to csvGenerator.
+type CsvStreamer interface {
+	StreamCSV(write func(row []string) error) error
+}
+
+// NdjsonStreamer is an interface for any type that can write itself as
+// newline delimited JSON, one value per line.
+type NdjsonStreamer interface {
+	StreamNDJSON(write func(value interface{}) error) error
+}
+
+// contentTyper is an interface for any type that restricts the content types
+// it can be written as, e.g. because it can only be streamed.
+type contentTyper interface {
+	ContentTypes() []string
+}
+
+// streamFlushRows is the number of rows after which a streamed response is
+// flushed to the client.
+const streamFlushRows = 256
+
 func resetRegisteredHandlers() {
 	RegisteredHandlers = RegisteredHandlers[:0]
 }
 
-// BUG(zanchi-r): We do not support content type negociation
-// Only a single type without quality factor should be specified in the Accept header
-// Example : "Accept: text/csv"
-// If an unsupported type is specified an empty body will be returned
+// ServeHTTP runs the handler and writes its output in the content type
+// negotiated with the client from the Accept header. JSON is preferred.
+// Outputs can also be written as CSV if they implement csvGenerator or
+// CsvStreamer, as NDJSON if they implement NdjsonStreamer and as an Excel
+// file if they implement xlsGenerator. A 406 status is sent if the client
+// accepts none of these. Error responses are always written as JSON.
 func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	arguments := make(Arguments)
 	status, output := h.Func(w, r, arguments)
-	acceptType := "*/*"
-	if len(r.Header["Accept"]) > 0 {
-		acceptType = r.Header["Accept"][0]
+	w.Header().Add("Vary", "Accept")
+	if status >= http.StatusBadRequest {
+		writeJson(w, status, output)
+		return
 	}
-	// If no specific accept type is specified we use application/json by default
-	if strings.HasPrefix(acceptType, "*/*") {
-		acceptType = "application/json"
+	offers := getContentTypeOffers(output)
+	contentType, ok := negotiateContentType(r.Header["Accept"], offers)
+	if !ok {
+		writeJson(w, http.StatusNotAcceptable, errorBody{fmt.Sprintf("Acceptable content types are %s.", strings.Join(offers, ", "))})
+		return
 	}
-	w.Header()["Content-Type"] = []string{fmt.Sprintf("%s; charset=utf-8", acceptType)}
-	switch acceptType {
-	case "application/json":
+	var err error
+	switch contentType {
+	case ContentTypeJson:
+		writeJson(w, status, output)
+	case ContentTypeCsv:
+		err = writeCsv(w, status, output)
+	case ContentTypeNdjson:
+		err = writeNdjson(w, status, output)
+	case ContentTypeExcel:
+		outputGen := output.(xlsGenerator)
+		setContentType(w, ContentTypeExcel)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", outputGen.GetFileName()))
 		w.WriteHeader(status)
-		e := json.NewEncoder(w)
-		if config.PrettyJsonResponses {
-			e.SetIndent("", "\t")
-		}
-		e.Encode(output)
-	case "text/csv":
-		w.Header().Set("Content-Disposition", "attachment; filename=trackit.csv")
-		w.WriteHeader(status)
-		if outputGen, ok := output.(csvGenerator); ok {
-			csvWriter := csv.NewWriter(w)
-			csvWriter.WriteAll(outputGen.ToCSVable())
-		} else {
-			// TODO: if the data do not implement the csvGenerator interface, try to generate it by reflection
-		}
-	case "application/vnd.ms-excel":
-		if outputGen, ok := output.(xlsGenerator); ok {
-			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", outputGen.GetFileName()))
-			w.WriteHeader(status)
-			w.Write(outputGen.GetFileContent())
-		} else {
-			if status == http.StatusOK {
-				w.WriteHeader(http.StatusNotImplemented)
-			} else {
-				w.WriteHeader(status)
+		w.Write(outputGen.GetFileContent())
+	}
+	if err != nil {
+		jsonlog.LoggerFromContextOrDefault(r.Context()).Error("Failed to stream response.", err.Error())
+	}
+}
+
+// getContentTypeOffers lists the content types an output can be written as,
+// in order of preference.
+func getContentTypeOffers(output interface{}) []string {
+	if ct, ok := output.(contentTyper); ok {
+		return ct.ContentTypes()
+	}
+	offers := []string{ContentTypeJson}
+	_, isCsvGenerator := output.(csvGenerator)
+	_, isCsvStreamer := output.(CsvStreamer)
+	if output == nil || isCsvGenerator || isCsvStreamer {
+		offers = append(offers, ContentTypeCsv)
+	}
+	if _, ok := output.(NdjsonStreamer); ok || output == nil {
+		offers = append(offers, ContentTypeNdjson)
+	}
+	if _, ok := output.(xlsGenerator); ok {
+		offers = append(offers, ContentTypeExcel)
+	}
+	return offers
+}
+
+// setContentType sets the Content-Type header of a text response.
+func setContentType(w http.ResponseWriter, contentType string) {
+	w.Header().Set("Content-Type", fmt.Sprintf("%s; charset=utf-8", contentType))
+}
+
+// writeJson writes the output as a JSON response.
+func writeJson(w http.ResponseWriter, status int, output interface{}) {
+	setContentType(w, ContentTypeJson)
+	w.WriteHeader(status)
+	e := json.NewEncoder(w)
+	if config.PrettyJsonResponses {
+		e.SetIndent("", "\t")
+	}
+	e.Encode(output)
+}
+
+// writeCsv writes the output as a CSV response, streaming it if possible.
+func writeCsv(w http.ResponseWriter, status int, output interface{}) error {
+	setContentType(w, ContentTypeCsv)
+	w.Header().Set("Content-Disposition", "attachment; filename=trackit.csv")
+	w.WriteHeader(status)
+	csvWriter := csv.NewWriter(w)
+	switch output := output.(type) {
+	case CsvStreamer:
+		rows := 0
+		err := output.StreamCSV(func(row []string) error {
+			if err := csvWriter.Write(row); err != nil {
+				return err
+			}
+			if rows++; rows%streamFlushRows == 0 {
+				csvWriter.Flush()
+				flush(w)
 			}
+			return nil
+		})
+		csvWriter.Flush()
+		if err == nil {
+			err = csvWriter.Error()
 		}
+		return err
+	case csvGenerator:
+		return csvWriter.WriteAll(output.ToCSVable())
+	}
+	return nil
+}
+
+// writeNdjson writes the output as a newline delimited JSON response,
+// streaming it.
+func writeNdjson(w http.ResponseWriter, status int, output interface{}) error {
+	setContentType(w, ContentTypeNdjson)
+	w.WriteHeader(status)
+	if output, ok := output.(NdjsonStreamer); ok {
+		e := json.NewEncoder(w)
+		values := 0
+		return output.StreamNDJSON(func(value interface{}) error {
+			if err := e.Encode(value); err != nil {
+				return err
+			}
+			if values++; values%streamFlushRows == 0 {
+				flush(w)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// flush sends what was written so far to the client, if the ResponseWriter
+// allows it.
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
 	}
 }
 
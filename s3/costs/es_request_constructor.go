@@ -19,6 +19,8 @@ import (
 	"time"
 
 	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/currency"
 )
 
 // aggregationMaxSize is the maximum size of an Elastic Search Aggregation
@@ -52,12 +54,14 @@ func createQueryTimeRange(durationBegin time.Time, durationEnd time.Time) *elast
 //	It needs to be fully configured and ready to execute a client.Search()
 //	- index string : The Elastic Search index on wich to execute the query. In this context the default value
 //	should be "awsdetailedlineitems"
+//	- costField string : The line item field holding the cost metric to sum, e.g. "unblendedCost"
+//	- conversion currency.Conversion : The conversion of the costs to the currency they are summed in
 // This function excepts arguments passed to it to be sanitize. If they are not, the following cases will make
 // it crash :
 //	- If the client is nil or malconfigured, it will crash
 //	- If the index is not an index present in the ES, it will crash
 func GetS3UsageAndCostElasticSearchParams(accountList []string, durationBegin time.Time,
-	durationEnd time.Time, filters []esFilter, client *elastic.Client, index string) *elastic.SearchService {
+	durationEnd time.Time, filters []esFilter, client *elastic.Client, index string, costField string, conversion currency.Conversion) *elastic.SearchService {
 	query := elastic.NewBoolQuery()
 	if len(accountList) > 0 {
 		query = query.Filter(createQueryAccountFilter(accountList))
@@ -71,6 +75,6 @@ func GetS3UsageAndCostElasticSearchParams(accountList []string, durationBegin ti
 
 	search.Aggregation("buckets", elastic.NewTermsAggregation().Field("resourceId").Size(aggregationMaxSize).
 		SubAggregation("usage", elastic.NewSumAggregation().Field("usageAmount")).
-		SubAggregation("cost", elastic.NewSumAggregation().Field("unblendedCost")))
+		SubAggregation("cost", conversion.SumAggregation(costField)))
 	return search
 }
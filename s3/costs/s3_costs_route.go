@@ -26,6 +26,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 	"github.com/trackit/trackit-server/aws/s3"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/errors"
 	"github.com/trackit/trackit-server/es"
@@ -39,6 +40,8 @@ type esQueryParams struct {
 	dateEnd     time.Time
 	accountList []string
 	indexList   []string
+	costField   string
+	conversion  currency.Conversion
 }
 
 // esFilter represents an elasticsearch filter
@@ -80,6 +83,7 @@ func init() {
 			routes.QueryArgs{routes.AwsAccountsOptionalQueryArg},
 			routes.QueryArgs{routes.DateBeginQueryArg},
 			routes.QueryArgs{routes.DateEndQueryArg},
+			routes.QueryArgs{routes.CostTypeOptionalQueryArg},
 		),
 	}.H().Register("/s3/costs")
 }
@@ -110,8 +114,10 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams esQueryParams,
 		esFilters,
 		es.Client,
 		index,
+		parsedParams.costField,
+		parsedParams.conversion,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "s3/costs", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -145,6 +151,13 @@ func getS3CostData(request *http.Request, a routes.Arguments) (int, interface{})
 	}
 	var err error
 	var returnCode int
+	var costType string
+	if a[routes.CostTypeOptionalQueryArg] != nil {
+		costType = a[routes.CostTypeOptionalQueryArg].(string)
+	}
+	if parsedParams.costField, err = s3.CostTypeField(costType); err != nil {
+		return http.StatusBadRequest, err
+	}
 	tx := a[db.Transaction].(*sql.Tx)
 	accountsAndIndexes, returnCode, err := es.GetAccountsAndIndexes(parsedParams.accountList, user, tx, s3.IndexPrefixLineItem)
 	if err != nil {
@@ -152,6 +165,10 @@ func getS3CostData(request *http.Request, a routes.Arguments) (int, interface{})
 	}
 	parsedParams.accountList = accountsAndIndexes.Accounts
 	parsedParams.indexList = accountsAndIndexes.Indexes
+	if parsedParams.conversion, err = currency.ForUser(tx, user); err != nil {
+		jsonlog.LoggerFromContextOrDefault(request.Context()).Error("Failed to get exchange rates.", err.Error())
+		return http.StatusInternalServerError, fmt.Errorf("could not get exchange rates")
+	}
 	var components = [...]struct {
 		k  string
 		sr *elastic.SearchResult
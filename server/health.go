@@ -0,0 +1,95 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/routes"
+)
+
+const (
+	// readinessTimeout is the time given to each dependency to answer the
+	// readiness check.
+	readinessTimeout = 5 * time.Second
+
+	checkOk          = "ok"
+	checkUnavailable = "unavailable"
+)
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getHealthz).With(
+			routes.Documentation{
+				Summary:     "check the server is alive",
+				Description: "Responds with 200 as long as the process serves requests.",
+			},
+		),
+	}.H().Register("/healthz")
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getReadyz).With(
+			routes.Documentation{
+				Summary:     "check the server is ready",
+				Description: "Responds with 200 if the SQL database answers and the ElasticSearch cluster is not red, 503 otherwise or while the server is shutting down. The body gives the outcome of each check, either ok or unavailable.",
+			},
+		),
+	}.H().Register("/readyz")
+}
+
+// getHealthz is a route handler which reports the process is alive.
+func getHealthz(r *http.Request, a routes.Arguments) (int, interface{}) {
+	return http.StatusOK, map[string]string{"status": checkOk}
+}
+
+// getReadyz is a route handler which reports whether the server can serve
+// requests, checking its dependencies. The reasons of failed checks are
+// logged rather than responded with, as the route is not authenticated.
+func getReadyz(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	status := http.StatusOK
+	checks := map[string]string{
+		"server": checkOk,
+		"sql":    checkOk,
+	}
+	if isShuttingDown() {
+		status = http.StatusServiceUnavailable
+		checks["server"] = checkUnavailable
+	}
+	if err := db.Db.PingContext(ctx); err != nil {
+		l.Warning("SQL database is not ready.", err.Error())
+		status = http.StatusServiceUnavailable
+		checks["sql"] = checkUnavailable
+	}
+	if health, err := es.ClusterHealth(ctx); err != nil {
+		l.Warning("ElasticSearch cluster is not ready.", err.Error())
+		status = http.StatusServiceUnavailable
+		checks["elasticsearch"] = checkUnavailable
+	} else if health == "red" {
+		l.Warning("ElasticSearch cluster is not ready.", map[string]interface{}{"health": health})
+		status = http.StatusServiceUnavailable
+		checks["elasticsearch"] = checkUnavailable
+	} else {
+		checks["elasticsearch"] = checkOk
+	}
+	return status, checks
+}
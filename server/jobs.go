@@ -0,0 +1,225 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/periodic"
+)
+
+// dueJob is a task run for each row of a view listing the AWS accounts or
+// the users due for an update. A row is due when the date in column of
+// table is in the past.
+type dueJob struct {
+	name   string
+	view   string
+	table  string
+	column string
+	// interval is the time after which the row is due again once the job
+	// ran. Zero means the job schedules its next run itself.
+	interval time.Duration
+	run      func(context.Context, int) error
+}
+
+// dueJobs are the per-account and per-user jobs run by the periodic tasks.
+var dueJobs = []dueJob{
+	{
+		name:   "process-account",
+		view:   "aws_account_due_update",
+		table:  "aws_account",
+		column: "next_update",
+		run:    ingestDataForAccount,
+	},
+	{
+		name:   "process-account-plugins",
+		view:   "aws_account_plugins_due_update",
+		table:  "aws_account",
+		column: "next_update_plugins",
+		run:    preparePluginsProcessingForAccount,
+	},
+	{
+		name:     "anomalies-detection",
+		view:     "anomalies_detection_due_update",
+		table:    "aws_account",
+		column:   "next_update_anomalies_detection",
+		interval: 24 * time.Hour,
+		run:      processAnomaliesForAccount,
+	},
+	{
+		name:     "generate-spreadsheet",
+		view:     "aws_account_spreadsheets_reports_due_update",
+		table:    "aws_account",
+		column:   "next_spreadsheet_report_generation",
+		interval: 24 * time.Hour,
+		run: func(ctx context.Context, aaId int) error {
+			return generateReport(ctx, aaId, time.Time{})
+		},
+	},
+	{
+		name:     "check-user-entitlement",
+		view:     "user_entitlement_due_update",
+		table:    "user",
+		column:   "next_update_entitlement",
+		interval: 24 * time.Hour,
+		run:      checkEntitlementForUser,
+	},
+}
+
+// jobsPool bounds the number of due jobs running at once in the process.
+var jobsPool *periodic.Pool
+
+// scheduleDueJobs registers a periodic task for each due job.
+func scheduleDueJobs() {
+	jobsPool = periodic.NewPool(config.JobsWorkers)
+	for _, job := range dueJobs {
+		sched.Register(job.task, config.JobsPollPeriod, job.name)
+	}
+}
+
+// task runs the job for the rows which are due, as long as jobsPool has free
+// workers. Rows left over are picked up at the next run. Each row is claimed
+// before the job runs so that no other backend runs it at the same time. The
+// jobs outlive the task: they are drained by jobsPool when shutting down.
+func (j dueJob) task(ctx context.Context) error {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	ids, err := j.getDueIds(db.Db)
+	if err != nil {
+		logger.Error("Failed to list rows due for an update.", map[string]interface{}{
+			"job":   j.name,
+			"error": err.Error(),
+		})
+		return err
+	}
+	for i, id := range ids {
+		id := id
+		if !jobsPool.TryGo(ctx, func(ctx context.Context) { j.claimAndRun(ctx, id) }) {
+			logger.Debug("No worker available, postponing jobs.", map[string]interface{}{
+				"job":       j.name,
+				"postponed": len(ids) - i,
+			})
+			break
+		}
+	}
+	return nil
+}
+
+// claimAndRun runs the job for a row if it can be claimed, then schedules
+// its next run. A job cancelled before it could start doesn't claim its row,
+// and one cancelled while running gives its claim up so that another backend
+// can run it without waiting for the lease to expire.
+func (j dueJob) claimAndRun(ctx context.Context, id int) {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	if ctx.Err() != nil {
+		return
+	} else if claimed, err := j.claim(db.Db, id); err != nil {
+		logger.Error("Failed to claim row for job.", map[string]interface{}{
+			"job":   j.name,
+			"id":    id,
+			"error": err.Error(),
+		})
+		return
+	} else if !claimed {
+		return
+	}
+	logger.Info("Running job.", map[string]interface{}{
+		"job": j.name,
+		"id":  id,
+	})
+	if err := j.run(ctx, id); err != nil {
+		logger.Error("Job failed.", map[string]interface{}{
+			"job":   j.name,
+			"id":    id,
+			"error": err.Error(),
+		})
+	}
+	if ctx.Err() != nil {
+		if err := j.unclaim(db.Db, id); err != nil {
+			logger.Error("Failed to give up claim of interrupted job.", map[string]interface{}{
+				"job":   j.name,
+				"id":    id,
+				"error": err.Error(),
+			})
+		}
+	} else if j.interval != 0 {
+		if err := j.release(db.Db, id); err != nil {
+			logger.Error("Failed to schedule next run of job.", map[string]interface{}{
+				"job":   j.name,
+				"id":    id,
+				"error": err.Error(),
+			})
+		}
+	}
+}
+
+// getDueIds returns the IDs of the rows due for the job.
+func (j dueJob) getDueIds(db *sql.DB) ([]int, error) {
+	sqlstr := fmt.Sprintf(`SELECT id FROM %s`, j.view)
+	rows, err := db.Query(sqlstr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// claim pushes the next update of a row back by config.JobsClaimLease if it
+// is still due, and returns whether it did. Only one backend can claim a
+// row, and a row whose job never completed is due again once the lease
+// expired.
+func (j dueJob) claim(db *sql.DB, id int) (bool, error) {
+	sqlstr := fmt.Sprintf(`UPDATE %s SET
+		%s=?
+	WHERE id=? AND %s<=NOW()`, j.table, j.column, j.column)
+	res, err := db.Exec(sqlstr, time.Now().Add(config.JobsClaimLease), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected == 1, err
+}
+
+// release schedules the next run of the job for a row.
+func (j dueJob) release(db *sql.DB, id int) error {
+	sqlstr := fmt.Sprintf(`UPDATE %s SET
+		%s=?
+	WHERE id=?`, j.table, j.column)
+	_, err := db.Exec(sqlstr, time.Now().Add(j.interval), id)
+	return err
+}
+
+// unclaim makes a row claimed by the job due again at once.
+func (j dueJob) unclaim(db *sql.DB, id int) error {
+	sqlstr := fmt.Sprintf(`UPDATE %s SET
+		%s=NOW()
+	WHERE id=?`, j.table, j.column)
+	_, err := db.Exec(sqlstr, id)
+	return err
+}
@@ -0,0 +1,239 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/metrics"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/periodic"
+	"github.com/trackit/trackit-server/routes"
+	"github.com/trackit/trackit-server/users"
+)
+
+const (
+	// defaultRunsLimit is the default number of runs returned by
+	// getSchedulerRuns.
+	defaultRunsLimit = 50
+	// maxRunsLimit is the maximum number of runs returned by
+	// getSchedulerRuns.
+	maxRunsLimit = 1000
+	// keptRunsPerTask is the number of runs of each periodic task kept in
+	// the database. Older runs are deleted as new ones are saved.
+	keptRunsPerTask = maxRunsLimit
+)
+
+// SchedulerRun is a run of a periodic task as returned by getSchedulerRuns.
+type SchedulerRun struct {
+	Task      string    `json:"task"`
+	WorkerId  string    `json:"workerId"`
+	Triggered bool      `json:"triggered"`
+	Started   time.Time `json:"started"`
+	Duration  float64   `json:"duration"`
+	Error     string    `json:"error"`
+}
+
+// taskQueryArg allows to get the name of a periodic task in the URL
+// parameters.
+var taskQueryArg = routes.QueryArg{
+	Name:        "task",
+	Type:        routes.QueryArgString{},
+	Description: "The name of the periodic task.",
+}
+
+// runsLimitQueryArg allows to get the maximum number of runs to return in the
+// URL parameters.
+var runsLimitQueryArg = routes.QueryArg{
+	Name:        "limit",
+	Type:        routes.QueryArgInt{},
+	Description: "The maximum number of runs to return. Defaults to 50, at most 1000.",
+	Optional:    true,
+}
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getSchedulerTasks).With(
+			routes.Documentation{
+				Summary:     "list the periodic tasks",
+				Description: "Responds with the periodic tasks registered on the backend answering the request: their schedule, their last run and their next expected run. The last duration is in seconds.",
+			},
+		),
+		http.MethodPost: routes.H(triggerSchedulerTask).With(
+			routes.QueryArgs{taskQueryArg},
+			routes.Documentation{
+				Summary:     "trigger a periodic task",
+				Description: "Runs a periodic task immediately on the backend answering the request, according to the task's overlap policy.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		users.RequireAuthenticatedUser{users.ViewerCannot},
+		users.RequireAdminUser{},
+		routes.Documentation{
+			Summary:     "interact with the periodic tasks",
+			Description: "Periodic tasks are run by each backend according to their schedule. These routes are restricted to administrators.",
+		},
+	).Register("/admin/scheduler")
+
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getSchedulerRuns).With(
+			routes.QueryArgs{
+				routes.QueryArg{
+					Name:        taskQueryArg.Name,
+					Type:        taskQueryArg.Type,
+					Description: "The name of the periodic task. All tasks are included if left empty.",
+					Optional:    true,
+				},
+				runsLimitQueryArg,
+			},
+			routes.Documentation{
+				Summary:     "get the history of the periodic tasks",
+				Description: "Responds with the most recent runs of the periodic tasks on all backends, most recent first. The duration is in seconds and the error is empty for successful runs.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		users.RequireAuthenticatedUser{users.ViewerCannot},
+		users.RequireAdminUser{},
+	).Register("/admin/scheduler/runs")
+}
+
+// getSchedulerTasks is a route handler which returns the status of the
+// periodic tasks.
+func getSchedulerTasks(r *http.Request, a routes.Arguments) (int, interface{}) {
+	return http.StatusOK, sched.Tasks()
+}
+
+// triggerSchedulerTask is a route handler which runs a periodic task
+// immediately.
+func triggerSchedulerTask(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	task := a[taskQueryArg].(string)
+	user := a[users.AuthenticatedUser].(users.User)
+	switch err := sched.Trigger(task); err {
+	case nil:
+		l.Info("Periodic task triggered.", map[string]interface{}{
+			"task":   task,
+			"userId": user.Id,
+		})
+		return http.StatusOK, nil
+	case periodic.ErrTaskNotFound:
+		return http.StatusNotFound, err
+	case periodic.ErrTaskRunning:
+		return http.StatusConflict, err
+	case periodic.ErrSchedulerStopped:
+		return http.StatusServiceUnavailable, err
+	default:
+		l.Error("Failed to trigger periodic task.", map[string]interface{}{
+			"task":  task,
+			"error": err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to trigger periodic task.")
+	}
+}
+
+// getSchedulerRuns is a route handler which returns the most recent runs of
+// the periodic tasks.
+func getSchedulerRuns(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	task, _ := a[taskQueryArg].(string)
+	limit := defaultRunsLimit
+	if v, ok := a[runsLimitQueryArg].(int); ok {
+		limit = v
+	}
+	if limit <= 0 || limit > maxRunsLimit {
+		return http.StatusBadRequest, errors.New("The limit must be between 1 and 1000.")
+	}
+	dbRuns, err := models.LastPeriodicTaskRuns(tx, task, limit)
+	if err != nil {
+		l.Error("Failed to get periodic task runs.", map[string]interface{}{
+			"task":  task,
+			"error": err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to get periodic task runs.")
+	}
+	runs := make([]SchedulerRun, len(dbRuns))
+	for i, dbRun := range dbRuns {
+		runs[i] = SchedulerRun{
+			Task:      dbRun.Task,
+			WorkerId:  dbRun.WorkerID,
+			Triggered: dbRun.Triggered,
+			Started:   dbRun.Started,
+			Duration:  dbRun.Duration,
+			Error:     dbRun.Error,
+		}
+	}
+	return http.StatusOK, runs
+}
+
+var (
+	periodicTaskRunsTotal = metrics.NewCounter(
+		"trackit_periodic_task_runs_total",
+		"Number of runs of periodic tasks, by task and outcome: success or failure.",
+		"task", "outcome",
+	)
+	periodicTaskDuration = metrics.NewHistogram(
+		"trackit_periodic_task_duration_seconds",
+		"Time taken by runs of periodic tasks, by task.",
+		[]float64{1, 10, 60, 300, 900, 1800, 3600, 7200, 14400},
+		"task",
+	)
+)
+
+// onPeriodicTaskRunEnd records a finished run of a periodic task.
+func onPeriodicTaskRunEnd(run periodic.TaskRun) {
+	outcome := "success"
+	if run.Error != nil {
+		outcome = "failure"
+	}
+	periodicTaskRunsTotal.Inc(run.Name, outcome)
+	periodicTaskDuration.Observe(run.Duration.Seconds(), run.Name)
+	savePeriodicTaskRun(run)
+}
+
+// savePeriodicTaskRun persists a run of a periodic task so operators can see
+// failures without going through the logs, and deletes the runs of the task
+// past the keptRunsPerTask most recent ones.
+func savePeriodicTaskRun(run periodic.TaskRun) {
+	dbRun := models.PeriodicTaskRun{
+		Task:      run.Name,
+		WorkerID:  backendId,
+		Triggered: run.Triggered,
+		Started:   run.Start,
+		Duration:  run.Duration.Seconds(),
+	}
+	if run.Error != nil {
+		dbRun.Error = run.Error.Error()
+	}
+	if err := dbRun.Insert(db.Db); err != nil {
+		jsonlog.DefaultLogger.Error("Failed to save periodic task run.", map[string]interface{}{
+			"task":  run.Name,
+			"error": err.Error(),
+		})
+	} else if err := models.DeleteOldPeriodicTaskRuns(db.Db, run.Name, keptRunsPerTask); err != nil {
+		jsonlog.DefaultLogger.Error("Failed to delete old periodic task runs.", map[string]interface{}{
+			"task":  run.Name,
+			"error": err.Error(),
+		})
+	}
+}
@@ -0,0 +1,56 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/periodic"
+)
+
+// This test is intended to be run against an empty database with the schema
+// already in place.
+func TestDeleteOldPeriodicTaskRuns(t *testing.T) {
+	start := time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		savePeriodicTaskRun(periodic.TaskRun{Name: "test-pruned", Start: start.Add(time.Duration(i) * time.Hour)})
+	}
+	savePeriodicTaskRun(periodic.TaskRun{Name: "test-kept", Start: start})
+	if err := models.DeleteOldPeriodicTaskRuns(db.Db, "test-pruned", 3); err != nil {
+		t.Fatalf("Error should be nil, is \"%s\" instead.", err.Error())
+	}
+	runs, err := models.LastPeriodicTaskRuns(db.Db, "test-pruned", maxRunsLimit)
+	if err != nil {
+		t.Fatalf("Error should be nil, is \"%s\" instead.", err.Error())
+	} else if len(runs) != 3 {
+		t.Fatalf("3 runs should be kept, %d are instead.", len(runs))
+	}
+	for i, run := range runs {
+		if expected := start.Add(time.Duration(4-i) * time.Hour); !run.Started.Equal(expected) {
+			t.Errorf("Run %d should have started at %s, started at %s instead.", i, expected, run.Started)
+		}
+	}
+	if err := models.DeleteOldPeriodicTaskRuns(db.Db, "test-pruned", 5); err != nil {
+		t.Errorf("Keeping more runs than there are: error should be nil, is \"%s\" instead.", err.Error())
+	}
+	if runs, err := models.LastPeriodicTaskRuns(db.Db, "test-kept", maxRunsLimit); err != nil {
+		t.Fatalf("Error should be nil, is \"%s\" instead.", err.Error())
+	} else if len(runs) != 1 {
+		t.Errorf("Runs of other tasks should be kept, %d are instead.", len(runs))
+	}
+}
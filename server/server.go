@@ -25,14 +25,20 @@ import (
 	"github.com/satori/go.uuid"
 	"github.com/trackit/jsonlog"
 
+	_ "github.com/trackit/trackit-server/audit/route"
 	_ "github.com/trackit/trackit-server/aws"
 	_ "github.com/trackit/trackit-server/aws/routes"
 	_ "github.com/trackit/trackit-server/aws/s3"
 	"github.com/trackit/trackit-server/config"
 	_ "github.com/trackit/trackit-server/costs"
 	_ "github.com/trackit/trackit-server/costs/anomalies"
+	_ "github.com/trackit/trackit-server/costs/budgets"
+	_ "github.com/trackit/trackit-server/costs/credits"
 	_ "github.com/trackit/trackit-server/costs/diff"
+	_ "github.com/trackit/trackit-server/costs/forecast"
+	_ "github.com/trackit/trackit-server/costs/lineitems"
 	_ "github.com/trackit/trackit-server/costs/tags"
+	"github.com/trackit/trackit-server/metrics"
 	"github.com/trackit/trackit-server/periodic"
 	_ "github.com/trackit/trackit-server/plugins"
 	_ "github.com/trackit/trackit-server/reports"
@@ -47,6 +53,7 @@ import (
 	_ "github.com/trackit/trackit-server/usageReports/riEc2"
 	_ "github.com/trackit/trackit-server/usageReports/riRds"
 	_ "github.com/trackit/trackit-server/users"
+	_ "github.com/trackit/trackit-server/users/deletion"
 	_ "github.com/trackit/trackit-server/users/shared_account"
 )
 
@@ -70,6 +77,8 @@ var tasks = map[string]func(context.Context) error{
 	"update-aws-identity":         taskUpdateAwsIdentity,
 	"check-cost":                  taskCheckCost,
 	"fetch-pricings":              taskFetchPricings,
+	"check-budgets":               taskCheckBudgets,
+	"load-exchange-rates":         taskLoadExchangeRates,
 }
 
 // dockerHostnameRe matches the value of the HOSTNAME environment variable when
@@ -99,7 +108,14 @@ func main() {
 var sched periodic.Scheduler
 
 func schedulePeriodicTasks() {
+	sched.OnRunEnd = onPeriodicTaskRunEnd
 	sched.Register(taskIngestDue, 10*time.Minute, "ingest-due-updates")
+	sched.Register(taskCheckBudgets, periodic.Hourly, "check-budgets")
+	sched.RegisterWithOptions(taskFetchPricings, "fetch-pricings", periodic.TaskOptions{
+		Schedule: periodic.MustParseCron("0 4 * * *"),
+		Jitter:   30 * time.Minute,
+	})
+	scheduleDueJobs()
 	sched.Start()
 }
 
@@ -110,9 +126,22 @@ func taskServer(ctx context.Context) error {
 		schedulePeriodicTasks()
 		logger.Info("Scheduled periodic tasks.", nil)
 	}
+	if config.MetricsAddress != "" {
+		go serveMetrics(ctx)
+	}
+	server := &http.Server{Addr: config.HttpAddress}
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- shutdownOnSignal(server)
+	}()
 	logger.Info(fmt.Sprintf("Listening on %s.", config.HttpAddress), nil)
-	err := http.ListenAndServe(config.HttpAddress, nil)
-	logger.Error("Server stopped.", err.Error())
+	err := server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		logger.Error("Server stopped.", err.Error())
+		return err
+	}
+	err = <-shutdown
+	logger.Info("Server stopped.", nil)
 	return err
 }
 
@@ -132,16 +161,29 @@ func initializeHandlers() {
 	}
 	logger := jsonlog.DefaultLogger
 	routes.DocumentationHandler().Register("/docs")
+	routes.OpenApiHandler().Register("/docs/openapi.json")
 	for _, rh := range routes.RegisteredHandlers {
 		applyDecoratorsAndHandle(rh.Pattern, rh.Handler, globalDecorators)
 		logger.Info(fmt.Sprintf("Registered route %s.", rh.Pattern), nil)
 	}
 }
 
+// serveMetrics serves the metrics on their own listener, so that they can be
+// kept off the network the API is exposed to.
+func serveMetrics(ctx context.Context) {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	logger.Info(fmt.Sprintf("Serving metrics on %s.", config.MetricsAddress), nil)
+	if err := http.ListenAndServe(config.MetricsAddress, mux); err != nil {
+		logger.Error("Metrics server stopped.", err.Error())
+	}
+}
+
 // applyDecoratorsAndHandle applies a list of decorators to a handler and
-// registers it.
+// registers it. Every handler is instrumented with routes.RequestMetrics.
 func applyDecoratorsAndHandle(p string, h routes.Handler, ds []routes.Decorator) {
-	h = h.With(ds...)
+	h = h.With(ds...).With(routes.RequestMetrics{p})
 	http.Handle(p, h)
 }
 
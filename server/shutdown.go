@@ -0,0 +1,97 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/es"
+)
+
+// shuttingDown is set to 1 once the server has been asked to stop, so that
+// it is no longer reported as ready.
+var shuttingDown int32
+
+// isShuttingDown returns whether the server has been asked to stop.
+func isShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) != 0
+}
+
+// jobsReleaseGrace is the time left to the jobs cancelled by a shutdown
+// timeout to give their claims up before the databases are closed.
+const jobsReleaseGrace = 5 * time.Second
+
+// closeConnections closes the connections to the databases.
+var closeConnections = func() {
+	if es.Client != nil {
+		es.Client.Stop()
+	}
+	if err := db.Db.Close(); err != nil {
+		jsonlog.DefaultLogger.Error("Failed to close SQL database.", err.Error())
+	}
+}
+
+// shutdownOnSignal waits for SIGTERM or SIGINT, then stops the server
+// gracefully.
+func shutdownOnSignal(server *http.Server) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	s := <-signals
+	signal.Stop(signals)
+	jsonlog.DefaultLogger.Info("Shutting down.", map[string]interface{}{
+		"signal":  s.String(),
+		"timeout": config.ShutdownTimeout.String(),
+	})
+	return shutdown(server, config.ShutdownTimeout)
+}
+
+// shutdown stops the server: it stops accepting connections, waits for
+// in-flight requests, running periodic tasks and the jobs they started for
+// at most timeout, then closes the connections to the databases.
+func shutdown(server *http.Server, timeout time.Duration) error {
+	atomic.StoreInt32(&shuttingDown, 1)
+	logger := jsonlog.DefaultLogger
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != nil {
+		logger.Error("Failed to wait for in-flight requests.", err.Error())
+	}
+	if serr := sched.Shutdown(ctx); serr != nil {
+		logger.Error("Failed to wait for running periodic tasks.", serr.Error())
+		err = serr
+	}
+	if jobsPool != nil {
+		if jerr := jobsPool.Shutdown(ctx); jerr != nil {
+			logger.Error("Failed to wait for running jobs.", jerr.Error())
+			err = jerr
+			grace, cancel := context.WithTimeout(context.Background(), jobsReleaseGrace)
+			defer cancel()
+			jobsPool.Shutdown(grace)
+		}
+	}
+	closeConnections()
+	return err
+}
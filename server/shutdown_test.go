@@ -0,0 +1,63 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/trackit/trackit-server/periodic"
+)
+
+// drainOrder runs job on a fresh jobsPool, shuts the server down with
+// timeout and returns whether the job had returned when the connections to
+// the databases were closed.
+func drainOrder(t *testing.T, timeout time.Duration, job func(context.Context)) bool {
+	defer func(pool *periodic.Pool, close func()) {
+		jobsPool, closeConnections = pool, close
+	}(jobsPool, closeConnections)
+	jobsPool = periodic.NewPool(1)
+	returned := make(chan struct{})
+	if !jobsPool.TryGo(context.Background(), func(ctx context.Context) {
+		defer close(returned)
+		job(ctx)
+	}) {
+		t.Fatalf("Pool should accept a job.")
+	}
+	var drained bool
+	closeConnections = func() {
+		select {
+		case <-returned:
+			drained = true
+		default:
+		}
+	}
+	shutdown(&http.Server{}, timeout)
+	return drained
+}
+
+func TestShutdownDrainsJobsBeforeClosing(t *testing.T) {
+	if !drainOrder(t, time.Second, func(context.Context) { time.Sleep(100 * time.Millisecond) }) {
+		t.Errorf("Connections should be closed once the running jobs returned, were closed before.")
+	}
+}
+
+func TestShutdownTimeoutLetsJobsReleaseBeforeClosing(t *testing.T) {
+	if !drainOrder(t, 50*time.Millisecond, func(ctx context.Context) { <-ctx.Done() }) {
+		t.Errorf("Connections should be closed once the cancelled jobs returned, were closed before.")
+	}
+}
@@ -53,6 +53,7 @@ func processAnomaliesForAccount(ctx context.Context, aaId int) (err error) {
 	var aa aws.AwsAccount
 	var dbaa *models.AwsAccount
 	var lastUpdate time.Time
+	var detectorName string
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
 	defer func() {
 		if tx != nil {
@@ -66,8 +67,10 @@ func processAnomaliesForAccount(ctx context.Context, aaId int) (err error) {
 	if tx, err = db.Db.BeginTx(ctx, nil); err != nil {
 	} else if dbaa, err = models.AwsAccountByID(tx, aaId); err != nil {
 	} else if aa = aws.AwsAccountFromDbAwsAccount(*dbaa); err != nil {
-	} else if lastUpdate, err = anomalies.RunAnomaliesDetection(aa, dbaa.LastAnomaliesUpdate, ctx); err == nil {
-		err = registerAnomaliesUpdate(tx, lastUpdate, aa.Id)
+	} else if detectorName, err = anomalies.GetAwsAccountDetectorName(tx, dbaa); err != nil {
+	} else if lastUpdate, err = anomalies.RunAnomaliesDetection(aa, dbaa.LastAnomaliesUpdate, detectorName, ctx); err != nil {
+	} else if err = registerAnomaliesUpdate(tx, lastUpdate, aa.Id); err == nil {
+		notifyAnomaliesForAccount(ctx, tx, aa, lastUpdate)
 	}
 	if err != nil && !elastic.IsNotFound(err) {
 		logger.Error("Failed to detect anomalies.", map[string]interface{}{
@@ -78,6 +81,18 @@ func processAnomaliesForAccount(ctx context.Context, aaId int) (err error) {
 	return
 }
 
+// notifyAnomaliesForAccount emails the new anomalies of an AwsAccount. A
+// failure is logged but does not prevent the detection from being registered.
+func notifyAnomaliesForAccount(ctx context.Context, tx *sql.Tx, aa aws.AwsAccount, lastUpdate time.Time) {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	if err := anomalies.NotifyAnomalies(ctx, tx, aa, lastUpdate); err != nil && !elastic.IsNotFound(err) {
+		logger.Error("Failed to email anomalies.", map[string]interface{}{
+			"awsAccountId": aa.Id,
+			"error":        err.Error(),
+		})
+	}
+}
+
 func registerAnomaliesUpdate(tx *sql.Tx, lastUpdate time.Time, aaId int) error {
 	dbaa, err := models.AwsAccountByID(tx, aaId)
 	if err != nil {
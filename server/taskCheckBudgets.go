@@ -0,0 +1,37 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/costs/budgets"
+	"github.com/trackit/trackit-server/db"
+)
+
+// taskCheckBudgets evaluates all the budgets and emails their owners when
+// their thresholds are reached.
+func taskCheckBudgets(ctx context.Context) error {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	logger.Debug("Running task 'check-budgets'.", nil)
+	err := budgets.CheckBudgets(ctx, db.Db, time.Now().UTC())
+	if err != nil {
+		logger.Error("Failed to check budgets.", err.Error())
+	}
+	return err
+}
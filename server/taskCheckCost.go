@@ -19,6 +19,7 @@ import (
 	"database/sql"
 	"errors"
 	"flag"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +32,7 @@ import (
 	taws "github.com/trackit/trackit-server/aws"
 	"github.com/trackit/trackit-server/aws/s3"
 	"github.com/trackit/trackit-server/costs"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/es"
 )
@@ -109,12 +111,18 @@ func getCostFromES(ctx context.Context, aa taws.AwsAccount, intervalBegin, inter
 	accountList := []string{aa.AwsIdentity}
 	aggregationParams := []string{"month"}
 	indexList := []string{es.IndexNameForUserId(aa.UserId, s3.IndexPrefixLineItem)}
+	// The cost explorer reports costs in the base currency.
+	conversion, err := currency.NewConversion(db.Db, currency.Base)
+	if err != nil {
+		return es.SimplifiedCostsDocument{}, http.StatusInternalServerError, err
+	}
 	params := costs.EsQueryParams{
 		DateBegin:         intervalBegin,
 		DateEnd:           intervalEnd,
 		AccountList:       accountList,
 		IndexList:         indexList,
 		AggregationParams: aggregationParams,
+		Conversion:        conversion,
 	}
 	return costs.MakeElasticSearchRequestAndParseIt(ctx, params)
 }
@@ -45,19 +45,26 @@ func taskCheckEntitlement(ctx context.Context) error {
 	} else if userId, err := strconv.Atoi(args[0]); err != nil {
 		return err
 	} else {
-		customer, err := models.UserByID(db.Db, userId)
+		return checkEntitlementForUser(ctx, userId)
+	}
+}
+
+// checkEntitlementForUser checks the entitlement of a user if they are an AWS
+// Marketplace customer.
+func checkEntitlementForUser(ctx context.Context, userId int) error {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	customer, err := models.UserByID(db.Db, userId)
+	if err != nil {
+		logger.Error("Error while getting cursomer infos", err)
+		return err
+	} else if customer.AwsCustomerIdentifier == "" {
+		logger.Info("No AWS customer identifier", err)
+		return nil
+	} else {
+		err = checkUserEntitlement(ctx, customer.AwsCustomerIdentifier, userId)
 		if err != nil {
-			logger.Error("Error while getting cursomer infos", err)
+			logger.Error("Error occured while checking user entitlement", err)
 			return err
-		} else if customer.AwsCustomerIdentifier == "" {
-			logger.Info("No AWS customer identifier", err)
-			return nil
-		} else {
-			err = checkUserEntitlement(ctx, customer.AwsCustomerIdentifier, userId)
-			if err != nil {
-				logger.Error("Error occured while checking user entitlement", err)
-				return err
-			}
 		}
 	}
 	return nil
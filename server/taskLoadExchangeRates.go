@@ -0,0 +1,71 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/currency"
+	"github.com/trackit/trackit-server/db"
+)
+
+// taskLoadExchangeRates loads the exchange rates listed in a CSV file, whose
+// path is given as argument, into the database.
+func taskLoadExchangeRates(ctx context.Context) (err error) {
+	args := flag.Args()
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	logger.Debug("Running task 'load-exchange-rates'.", map[string]interface{}{
+		"args": args,
+	})
+	if len(args) != 1 {
+		return errors.New("taskLoadExchangeRates requires a file path argument")
+	}
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	rates, err := currency.ReadRatesCsv(file)
+	if err != nil {
+		logger.Error("Failed to read exchange rates.", err.Error())
+		return
+	}
+	var tx *sql.Tx
+	defer func() {
+		if tx != nil {
+			if err != nil {
+				tx.Rollback()
+			} else {
+				tx.Commit()
+			}
+		}
+	}()
+	if tx, err = db.Db.BeginTx(ctx, nil); err != nil {
+		logger.Error("Failed to initiate sql transaction", err.Error())
+	} else if err = currency.SaveRates(tx, rates); err != nil {
+		logger.Error("Failed to save exchange rates.", err.Error())
+	} else {
+		logger.Info("Loaded exchange rates.", map[string]interface{}{
+			"count": len(rates),
+		})
+	}
+	return
+}
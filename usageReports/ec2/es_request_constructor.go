@@ -18,6 +18,8 @@ import (
 	"time"
 
 	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/currency"
 )
 
 const maxAggregationSize = 0x7FFFFFFF
@@ -113,11 +115,12 @@ func createQueryAccountFilterBill(accountList []string) *elastic.TermsQuery {
 //	- client *elastic.Client : an instance of *elastic.Client that represent an Elastic Search client.
 //	It needs to be fully configured and ready to execute a client.Search()
 //	- index string : The Elastic Search index on which to execute the query
+//	- conversion currency.Conversion : The conversion of the costs to the currency they are summed in
 // This function excepts arguments passed to it to be sanitize. If they are not, the following cases will make
 // it crash :
 //	- If the client is nil or malconfigured, it will crash
 //	- If the index is not an index present in the ES, it will crash
-func getElasticSearchCostParams(params Ec2QueryParams, client *elastic.Client, index string) *elastic.SearchService {
+func getElasticSearchCostParams(params Ec2QueryParams, client *elastic.Client, index string, conversion currency.Conversion) *elastic.SearchService {
 	query := elastic.NewBoolQuery()
 	if len(params.AccountList) > 0 {
 		query = query.Filter(createQueryAccountFilterBill(params.AccountList))
@@ -129,6 +132,6 @@ func getElasticSearchCostParams(params Ec2QueryParams, client *elastic.Client, i
 	search := client.Search().Index(index).Size(0).Query(query)
 	search.Aggregation("accounts", elastic.NewTermsAggregation().Field("usageAccountId").Size(maxAggregationSize).
 		SubAggregation("instances", elastic.NewTermsAggregation().Field("resourceId").Size(maxAggregationSize).
-			SubAggregation("cost", elastic.NewSumAggregation().Field("unblendedCost"))))
+			SubAggregation("cost", conversion.SumAggregation("unblendedCost"))))
 	return search
 }
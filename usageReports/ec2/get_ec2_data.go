@@ -26,6 +26,7 @@ import (
 	"github.com/trackit/jsonlog"
 
 	"github.com/trackit/trackit-server/aws/usageReports/ec2"
+	"github.com/trackit/trackit-server/currency"
 	terrors "github.com/trackit/trackit-server/errors"
 	"github.com/trackit/trackit-server/es"
 	"github.com/trackit/trackit-server/users"
@@ -47,7 +48,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams Ec2QueryParams,
 		es.Client,
 		index,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "usageReports/ec2", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -69,7 +70,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams Ec2QueryParams,
 }
 
 // GetEc2MonthlyInstances does an elastic request and returns an array of instances monthly report based on query params
-func GetEc2MonthlyInstances(ctx context.Context, params Ec2QueryParams) (int, []InstanceReport, error) {
+func GetEc2MonthlyInstances(ctx context.Context, params Ec2QueryParams, user users.User, tx *sql.Tx) (int, []InstanceReport, error) {
 	res, returnCode, err := makeElasticSearchRequest(ctx, params, getElasticSearchEc2MonthlyParams)
 	if err != nil {
 		return returnCode, nil, err
@@ -80,6 +81,12 @@ func GetEc2MonthlyInstances(ctx context.Context, params Ec2QueryParams) (int, []
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
 	}
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	} else if err := convertMonthlyCosts(instances, conversion); err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
 	return http.StatusOK, instances, nil
 }
 
@@ -97,7 +104,13 @@ func GetEc2DailyInstances(ctx context.Context, params Ec2QueryParams, user users
 	}
 	params.AccountList = accountsAndIndexes.Accounts
 	params.IndexList = accountsAndIndexes.Indexes
-	costRes, _, _ := makeElasticSearchRequest(ctx, params, getElasticSearchCostParams)
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	costRes, _, _ := makeElasticSearchRequest(ctx, params, func(params Ec2QueryParams, client *elastic.Client, index string) *elastic.SearchService {
+		return getElasticSearchCostParams(params, client, index, conversion)
+	})
 	instances, err := prepareResponseEc2Daily(ctx, res, costRes)
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
@@ -113,7 +126,7 @@ func GetEc2Data(ctx context.Context, parsedParams Ec2QueryParams, user users.Use
 	}
 	parsedParams.AccountList = accountsAndIndexes.Accounts
 	parsedParams.IndexList = accountsAndIndexes.Indexes
-	returnCode, monthlyInstances, err := GetEc2MonthlyInstances(ctx, parsedParams)
+	returnCode, monthlyInstances, err := GetEc2MonthlyInstances(ctx, parsedParams, user, tx)
 	if err != nil {
 		return returnCode, nil, err
 	} else if monthlyInstances != nil && len(monthlyInstances) > 0 {
@@ -26,6 +26,7 @@ import (
 
 	"github.com/trackit/trackit-server/aws/usageReports"
 	"github.com/trackit/trackit-server/aws/usageReports/ec2"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/errors"
 )
 
@@ -259,3 +260,16 @@ func prepareResponseEc2Unused(params Ec2UnusedQueryParams, instances []InstanceR
 	}
 	return http.StatusOK, unusedInstances, nil
 }
+
+// convertMonthlyCosts converts the costs of monthly reports, which are stored
+// in currency.Base, with conversion.
+func convertMonthlyCosts(instances []InstanceReport, conversion currency.Conversion) (err error) {
+	for _, instance := range instances {
+		for k, cost := range instance.Instance.Costs {
+			if instance.Instance.Costs[k], err = conversion.Convert(cost, currency.Base, instance.ReportDate); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
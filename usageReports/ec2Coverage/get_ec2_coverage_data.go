@@ -46,7 +46,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams Ec2CoverageQuery
 		es.Client,
 		index,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "usageReports/ec2Coverage", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
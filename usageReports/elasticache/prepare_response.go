@@ -26,6 +26,7 @@ import (
 
 	"github.com/trackit/trackit-server/aws/usageReports"
 	"github.com/trackit/trackit-server/aws/usageReports/elasticache"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/errors"
 )
 
@@ -225,3 +226,16 @@ func prepareResponseElastiCacheUnused(params ElastiCacheUnusedQueryParams, insta
 	}
 	return http.StatusOK, unusedInstances, nil
 }
+
+// convertMonthlyCosts converts the costs of monthly reports, which are stored
+// in currency.Base, with conversion.
+func convertMonthlyCosts(instances []InstanceReport, conversion currency.Conversion) (err error) {
+	for _, instance := range instances {
+		for k, cost := range instance.Instance.Costs {
+			if instance.Instance.Costs[k], err = conversion.Convert(cost, currency.Base, instance.ReportDate); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -26,6 +26,7 @@ import (
 	"gopkg.in/olivere/elastic.v5"
 
 	tes "github.com/trackit/trackit-server/aws/usageReports/es"
+	"github.com/trackit/trackit-server/currency"
 	terrors "github.com/trackit/trackit-server/errors"
 	"github.com/trackit/trackit-server/es"
 	"github.com/trackit/trackit-server/users"
@@ -47,7 +48,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams EsQueryParams,
 		es.Client,
 		index,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "usageReports/es", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -69,7 +70,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams EsQueryParams,
 }
 
 // GetEsMonthlyDomains does an elastic request and returns an array of domains monthly report based on query params
-func GetEsMonthlyDomains(ctx context.Context, params EsQueryParams) (int, []DomainReport, error) {
+func GetEsMonthlyDomains(ctx context.Context, params EsQueryParams, user users.User, tx *sql.Tx) (int, []DomainReport, error) {
 	res, returnCode, err := makeElasticSearchRequest(ctx, params, getElasticSearchEsMonthlyParams)
 	if err != nil {
 		return returnCode, nil, err
@@ -80,6 +81,12 @@ func GetEsMonthlyDomains(ctx context.Context, params EsQueryParams) (int, []Doma
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
 	}
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	} else if err := convertMonthlyCosts(domains, conversion); err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
 	return http.StatusOK, domains, nil
 }
 
@@ -97,7 +104,13 @@ func GetEsDailyDomains(ctx context.Context, params EsQueryParams, user users.Use
 	}
 	params.AccountList = accountsAndIndexes.Accounts
 	params.IndexList = accountsAndIndexes.Indexes
-	costRes, _, _ := makeElasticSearchRequest(ctx, params, getElasticSearchCostParams)
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	costRes, _, _ := makeElasticSearchRequest(ctx, params, func(params EsQueryParams, client *elastic.Client, index string) *elastic.SearchService {
+		return getElasticSearchCostParams(params, client, index, conversion)
+	})
 	domains, err := prepareResponseEsDaily(ctx, res, costRes)
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
@@ -113,7 +126,7 @@ func GetEsData(ctx context.Context, parsedParams EsQueryParams, user users.User,
 	}
 	parsedParams.AccountList = accountsAndIndexes.Accounts
 	parsedParams.IndexList = accountsAndIndexes.Indexes
-	returnCode, monthlyDomains, err := GetEsMonthlyDomains(ctx, parsedParams)
+	returnCode, monthlyDomains, err := GetEsMonthlyDomains(ctx, parsedParams, user, tx)
 	if err != nil {
 		return returnCode, nil, err
 	} else if monthlyDomains != nil && len(monthlyDomains) > 0 {
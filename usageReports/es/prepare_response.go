@@ -25,6 +25,7 @@ import (
 
 	"github.com/trackit/trackit-server/aws/usageReports"
 	"github.com/trackit/trackit-server/aws/usageReports/es"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/errors"
 )
 
@@ -219,3 +220,16 @@ func prepareResponseEsUnused(params EsUnusedQueryParams, domains []DomainReport)
 	}
 	return http.StatusOK, unusedDomains, nil
 }
+
+// convertMonthlyCosts converts the costs of monthly reports, which are stored
+// in currency.Base, with conversion.
+func convertMonthlyCosts(domains []DomainReport, conversion currency.Conversion) (err error) {
+	for _, domain := range domains {
+		for k, cost := range domain.Domain.Costs {
+			if domain.Domain.Costs[k], err = conversion.Convert(cost, currency.Base, domain.ReportDate); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
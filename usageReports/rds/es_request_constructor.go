@@ -18,6 +18,8 @@ import (
 	"time"
 
 	"gopkg.in/olivere/elastic.v5"
+
+	"github.com/trackit/trackit-server/currency"
 )
 
 const maxAggregationSize = 0x7FFFFFFF
@@ -114,11 +116,12 @@ func createQueryAccountFilterBill(accountList []string) *elastic.TermsQuery {
 //	It needs to be fully configured and ready to execute a client.Search()
 //	- index string : The Elastic Search index on wich to execute the query. In this context the default value
 //	should be "rds-reports"
+//	- conversion currency.Conversion : The conversion of the costs to the currency they are summed in
 // This function excepts arguments passed to it to be sanitize. If they are not, the following cases will make
 // it crash :
 //	- If the client is nil or malconfigured, it will crash
 //	- If the index is not an index present in the ES, it will crash
-func getElasticSearchCostParams(params RdsQueryParams, client *elastic.Client, index string) *elastic.SearchService {
+func getElasticSearchCostParams(params RdsQueryParams, client *elastic.Client, index string, conversion currency.Conversion) *elastic.SearchService {
 	query := elastic.NewBoolQuery()
 	if len(params.AccountList) > 0 {
 		query = query.Filter(createQueryAccountFilterBill(params.AccountList))
@@ -130,6 +133,6 @@ func getElasticSearchCostParams(params RdsQueryParams, client *elastic.Client, i
 	search := client.Search().Index(index).Size(0).Query(query)
 	search.Aggregation("accounts", elastic.NewTermsAggregation().Field("usageAccountId").Size(maxAggregationSize).
 		SubAggregation("instances", elastic.NewTermsAggregation().Field("resourceId").Size(maxAggregationSize).
-			SubAggregation("cost", elastic.NewSumAggregation().Field("unblendedCost"))))
+			SubAggregation("cost", conversion.SumAggregation("unblendedCost"))))
 	return search
 }
@@ -26,6 +26,7 @@ import (
 	"gopkg.in/olivere/elastic.v5"
 
 	"github.com/trackit/trackit-server/aws/usageReports/rds"
+	"github.com/trackit/trackit-server/currency"
 	terrors "github.com/trackit/trackit-server/errors"
 	"github.com/trackit/trackit-server/es"
 	"github.com/trackit/trackit-server/users"
@@ -47,7 +48,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams RdsQueryParams,
 		es.Client,
 		index,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "usageReports/rds", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -69,7 +70,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams RdsQueryParams,
 }
 
 // GetRdsMonthlyInstances does an elastic request and returns an array of instances monthly report based on query params
-func GetRdsMonthlyInstances(ctx context.Context, params RdsQueryParams) (int, []InstanceReport, error) {
+func GetRdsMonthlyInstances(ctx context.Context, params RdsQueryParams, user users.User, tx *sql.Tx) (int, []InstanceReport, error) {
 	res, returnCode, err := makeElasticSearchRequest(ctx, params, getElasticSearchRdsMonthlyParams)
 	if err != nil {
 		return returnCode, nil, err
@@ -80,6 +81,12 @@ func GetRdsMonthlyInstances(ctx context.Context, params RdsQueryParams) (int, []
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
 	}
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	} else if err := convertMonthlyCosts(instances, conversion); err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
 	return http.StatusOK, instances, nil
 }
 
@@ -97,7 +104,13 @@ func GetRdsDailyInstances(ctx context.Context, params RdsQueryParams, user users
 	}
 	params.AccountList = accountsAndIndexes.Accounts
 	params.IndexList = accountsAndIndexes.Indexes
-	costRes, _, _ := makeElasticSearchRequest(ctx, params, getElasticSearchCostParams)
+	conversion, err := currency.ForUser(tx, user)
+	if err != nil {
+		return http.StatusInternalServerError, nil, err
+	}
+	costRes, _, _ := makeElasticSearchRequest(ctx, params, func(params RdsQueryParams, client *elastic.Client, index string) *elastic.SearchService {
+		return getElasticSearchCostParams(params, client, index, conversion)
+	})
 	instances, err := prepareResponseRdsDaily(ctx, res, costRes)
 	if err != nil {
 		return http.StatusInternalServerError, nil, err
@@ -113,7 +126,7 @@ func GetRdsData(ctx context.Context, parsedParams RdsQueryParams, user users.Use
 	}
 	parsedParams.AccountList = accountsAndIndexes.Accounts
 	parsedParams.IndexList = accountsAndIndexes.Indexes
-	returnCode, monthlyInstances, err := GetRdsMonthlyInstances(ctx, parsedParams)
+	returnCode, monthlyInstances, err := GetRdsMonthlyInstances(ctx, parsedParams, user, tx)
 	if err != nil {
 		return returnCode, nil, err
 	} else if monthlyInstances != nil && len(monthlyInstances) > 0 {
@@ -25,6 +25,7 @@ import (
 	"gopkg.in/olivere/elastic.v5"
 
 	"github.com/trackit/trackit-server/aws/usageReports/rds"
+	"github.com/trackit/trackit-server/currency"
 	"github.com/trackit/trackit-server/errors"
 	"github.com/trackit/trackit-server/aws/usageReports"
 )
@@ -227,3 +228,16 @@ func prepareResponseRdsUnused(params RdsUnusedQueryParams, instances []InstanceR
 	}
 	return http.StatusOK, unusedInstances, nil
 }
+
+// convertMonthlyCosts converts the costs of monthly reports, which are stored
+// in currency.Base, with conversion.
+func convertMonthlyCosts(instances []InstanceReport, conversion currency.Conversion) (err error) {
+	for _, instance := range instances {
+		for k, cost := range instance.Instance.Costs {
+			if instance.Instance.Costs[k], err = conversion.Convert(cost, currency.Base, instance.ReportDate); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
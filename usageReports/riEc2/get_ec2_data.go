@@ -47,7 +47,7 @@ func makeElasticSearchRequest(ctx context.Context, parsedParams ReservedInstance
 		es.Client,
 		index,
 	)
-	res, err := searchService.Do(ctx)
+	res, err := es.DoSearch(ctx, "usageReports/riEc2", searchService)
 	if err != nil {
 		if elastic.IsNotFound(err) {
 			l.Warning("Query execution failed, ES index does not exists", map[string]interface{}{
@@ -0,0 +1,71 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/routes"
+)
+
+const (
+	TagRequireAdminUser = "require:admin"
+)
+
+var ErrNotAdmin = errors.New("This action is restricted to administrators.")
+
+// RequireAdminUser restricts a route to the users listed with the
+// -admin-email flag. It must be used after RequireAuthenticatedUser.
+type RequireAdminUser struct{}
+
+// IsAdmin returns whether a user is an administrator of the platform.
+func IsAdmin(user User) bool {
+	for _, email := range config.AdminEmails {
+		if strings.EqualFold(email, user.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d RequireAdminUser) Decorate(h routes.Handler) routes.Handler {
+	h.Func = d.getFunc(h.Func)
+	h.Documentation = d.getDocumentation(h.Documentation)
+	return h
+}
+
+func (_ RequireAdminUser) getFunc(hf routes.HandlerFunc) routes.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, a routes.Arguments) (int, interface{}) {
+		user, ok := a[AuthenticatedUser].(User)
+		if !ok {
+			return http.StatusUnauthorized, ErrMissingToken
+		}
+		if !IsAdmin(user) {
+			return http.StatusForbidden, ErrNotAdmin
+		}
+		return hf(w, r, a)
+	}
+}
+
+func (_ RequireAdminUser) getDocumentation(hd routes.HandlerDocumentation) routes.HandlerDocumentation {
+	if hd.Tags == nil {
+		hd.Tags = make(routes.Tags)
+	}
+	hd.Tags[TagRequireAdminUser] = []string{"admin"}
+	return hd
+}
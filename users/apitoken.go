@@ -0,0 +1,213 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/trackit/trackit-server/models"
+)
+
+const (
+	// apiTokenPrefix prefixes every API token so they can be told apart
+	// from JWTs and spotted by secret scanners.
+	apiTokenPrefix = "trackit_"
+	// apiTokenEntropy is the number of random bytes in an API token.
+	apiTokenEntropy = 32
+	// apiTokenLastUsedResolution is the minimum delay between two updates
+	// of an API token's last use date, to avoid a write on every request.
+	apiTokenLastUsedResolution = time.Minute
+)
+
+var (
+	ErrApiTokenNotFound = errors.New("API token not found")
+	ErrApiTokenExpired  = errors.New("API token is expired")
+	ErrReadOnlyApiToken = errors.New("This action is unavailable to read-only API tokens.")
+)
+
+// ApiToken is a long-lived credential a user can create to call the API from
+// scripts. Only a hash of the token is stored: its value is only known at
+// creation.
+type ApiToken struct {
+	Id       int        `json:"id"`
+	Name     string     `json:"name"`
+	ReadOnly bool       `json:"readOnly"`
+	Created  time.Time  `json:"created"`
+	Expires  *time.Time `json:"expires"`
+	LastUsed *time.Time `json:"lastUsed"`
+}
+
+// apiTokenFromDbApiToken builds an ApiToken from its database representation.
+func apiTokenFromDbApiToken(dbToken models.UserAPIToken) ApiToken {
+	token := ApiToken{
+		Id:       dbToken.ID,
+		Name:     dbToken.Name,
+		ReadOnly: dbToken.ReadOnly,
+		Created:  dbToken.Created,
+	}
+	if dbToken.Expires.Valid {
+		expires := dbToken.Expires.Time
+		token.Expires = &expires
+	}
+	if dbToken.LastUsed.Valid {
+		lastUsed := dbToken.LastUsed.Time
+		token.LastUsed = &lastUsed
+	}
+	return token
+}
+
+// nullTimeFromPointer builds a mysql.NullTime which is null iff t is nil.
+func nullTimeFromPointer(t *time.Time) mysql.NullTime {
+	if t == nil {
+		return mysql.NullTime{}
+	}
+	return mysql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// isApiToken checks whether a token from an Authorization header is an API
+// token rather than a JWT.
+func isApiToken(tokenString string) bool {
+	return strings.HasPrefix(tokenString, apiTokenPrefix)
+}
+
+// generateOpaqueToken generates a new random token with a prefix.
+func generateOpaqueToken(prefix string) (string, error) {
+	var random [apiTokenEntropy]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(random[:]), nil
+}
+
+// generateApiToken generates a new random API token.
+func generateApiToken() (string, error) {
+	return generateOpaqueToken(apiTokenPrefix)
+}
+
+// hashApiToken hashes an API or refresh token for storage. These tokens have
+// enough entropy for a fast hash to be adequate.
+func hashApiToken(tokenString string) string {
+	hash := sha256.Sum256([]byte(tokenString))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateApiToken creates an API token for a user. The token's value is
+// returned along with it and cannot be retrieved later. Like the other tokens,
+// it is only valid for the user's current token generation.
+func CreateApiToken(tx *sql.Tx, user User, name string, readOnly bool, expires *time.Time) (ApiToken, string, error) {
+	tokenString, err := generateApiToken()
+	if err != nil {
+		return ApiToken{}, "", err
+	}
+	dbToken := models.UserAPIToken{
+		Created:    time.Now().UTC(),
+		UserID:     user.Id,
+		Name:       name,
+		TokenHash:  hashApiToken(tokenString),
+		ReadOnly:   readOnly,
+		Expires:    nullTimeFromPointer(expires),
+		Generation: user.tokenGeneration,
+	}
+	if err := dbToken.Insert(tx); err != nil {
+		return ApiToken{}, "", err
+	}
+	return apiTokenFromDbApiToken(dbToken), tokenString, nil
+}
+
+// GetApiTokensForUser returns the API tokens of a user.
+func GetApiTokensForUser(tx *sql.Tx, user User) ([]ApiToken, error) {
+	dbTokens, err := models.UserAPITokensByUserID(tx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]ApiToken, len(dbTokens))
+	for i := range dbTokens {
+		tokens[i] = apiTokenFromDbApiToken(*dbTokens[i])
+	}
+	return tokens, nil
+}
+
+// getDbApiTokenForUser retrieves an API token by its ID, ensuring it belongs
+// to the user.
+func getDbApiTokenForUser(tx *sql.Tx, user User, tokenId int) (*models.UserAPIToken, error) {
+	dbToken, err := models.UserAPITokenByID(tx, tokenId)
+	if err == sql.ErrNoRows || (err == nil && dbToken.UserID != user.Id) {
+		return nil, ErrApiTokenNotFound
+	}
+	return dbToken, err
+}
+
+// UpdateApiToken updates the name, scope and expiry of a user's API token.
+func UpdateApiToken(tx *sql.Tx, user User, tokenId int, name string, readOnly bool, expires *time.Time) (ApiToken, error) {
+	dbToken, err := getDbApiTokenForUser(tx, user, tokenId)
+	if err != nil {
+		return ApiToken{}, err
+	}
+	dbToken.Name = name
+	dbToken.ReadOnly = readOnly
+	dbToken.Expires = nullTimeFromPointer(expires)
+	if err := dbToken.Update(tx); err != nil {
+		return ApiToken{}, err
+	}
+	return apiTokenFromDbApiToken(*dbToken), nil
+}
+
+// DeleteApiToken revokes a user's API token.
+func DeleteApiToken(tx *sql.Tx, user User, tokenId int) error {
+	dbToken, err := getDbApiTokenForUser(tx, user, tokenId)
+	if err != nil {
+		return err
+	}
+	return dbToken.Delete(tx)
+}
+
+// testApiToken checks whether an API token is valid and retrieves the owning
+// User and the token if it is. The token's last use date is updated.
+func testApiToken(tx *sql.Tx, tokenString string) (User, ApiToken, error) {
+	dbToken, err := models.UserAPITokenByTokenHash(tx, hashApiToken(tokenString))
+	if err == sql.ErrNoRows {
+		return User{}, ApiToken{}, ErrCannotReadToken
+	} else if err != nil {
+		return User{}, ApiToken{}, err
+	}
+	now := time.Now().UTC()
+	if dbToken.Expires.Valid && !now.Before(dbToken.Expires.Time) {
+		return User{}, ApiToken{}, ErrApiTokenExpired
+	}
+	user, err := GetUserWithId(tx, dbToken.UserID)
+	if err != nil {
+		return user, ApiToken{}, err
+	} else if dbToken.Generation != user.tokenGeneration {
+		return User{}, ApiToken{}, ErrRevokedToken
+	} else if !user.AwsCustomerEntitlement {
+		return user, ApiToken{}, ErrMarketplaceInvalidToken
+	}
+	if !dbToken.LastUsed.Valid || now.Sub(dbToken.LastUsed.Time) >= apiTokenLastUsedResolution {
+		dbToken.LastUsed = mysql.NullTime{Time: now, Valid: true}
+		if err := dbToken.Update(tx); err != nil {
+			return user, ApiToken{}, err
+		}
+	}
+	return user, apiTokenFromDbApiToken(*dbToken), nil
+}
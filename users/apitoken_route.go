@@ -0,0 +1,211 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/routes"
+)
+
+var (
+	ErrApiTokenCannotManage = errors.New("API tokens cannot be used to manage API tokens.")
+)
+
+type (
+	// apiTokenBody is the body required by postApiToken and patchApiToken.
+	apiTokenBody struct {
+		Name     string     `json:"name" req:"nonzero"`
+		ReadOnly bool       `json:"readOnly"`
+		Expires  *time.Time `json:"expires"`
+	}
+
+	// createdApiToken is the response of postApiToken. It is the only
+	// time the token's value is shown.
+	createdApiToken struct {
+		ApiToken
+		Token string `json:"token"`
+	}
+)
+
+// apiTokenIdQueryArg allows to get the ID of an API token in the URL
+// parameters.
+var apiTokenIdQueryArg = routes.QueryArg{
+	Name:        "token-id",
+	Type:        routes.QueryArgInt{},
+	Description: "The ID of the API token.",
+}
+
+func init() {
+	expires := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	exampleBody := apiTokenBody{
+		Name:     "BI dashboard",
+		ReadOnly: true,
+		Expires:  &expires,
+	}
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getApiTokens).With(
+			routes.Documentation{
+				Summary:     "get the API tokens",
+				Description: "Responds with the API tokens of the user. Their values are not included.",
+			},
+		),
+		http.MethodPost: routes.H(postApiToken).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{exampleBody},
+			routes.Documentation{
+				Summary:     "create an API token",
+				Description: "Creates an API token and responds with its value, which cannot be retrieved later. A read-only token can only be used for GET requests. A token without an expiry date is valid until it is deleted.",
+			},
+		),
+		http.MethodPatch: routes.H(patchApiToken).With(
+			routes.RequestContentType{"application/json"},
+			routes.QueryArgs{apiTokenIdQueryArg},
+			routes.RequestBody{exampleBody},
+			routes.Documentation{
+				Summary:     "edit an API token",
+				Description: "Replaces the name, scope and expiry date of an API token.",
+			},
+		),
+		http.MethodDelete: routes.H(deleteApiToken).With(
+			routes.QueryArgs{apiTokenIdQueryArg},
+			routes.Documentation{
+				Summary:     "revoke an API token",
+				Description: "Deletes an API token, which cannot be used anymore.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		RequireAuthenticatedUser{ViewerAsSelf},
+		routes.Documentation{
+			Summary:     "interact with the API tokens",
+			Description: "API tokens are long-lived credentials for scripts and BI tools. They are used in the Authorization header in place of the token returned by /user/login.",
+		},
+	).Register("/user/tokens")
+}
+
+// forbidApiTokenManagement prevents API tokens from managing API tokens, so a
+// leaked token cannot be used to create new ones.
+func forbidApiTokenManagement(a routes.Arguments) (int, error) {
+	if _, ok := a[AuthenticatedApiToken]; ok {
+		return http.StatusForbidden, ErrApiTokenCannotManage
+	}
+	return http.StatusOK, nil
+}
+
+// validateApiTokenBody checks the expiry date of an API token body is in the
+// future.
+func validateApiTokenBody(body apiTokenBody) error {
+	if body.Expires != nil && !body.Expires.After(time.Now()) {
+		return errors.New("Expiry date must be in the future.")
+	}
+	return nil
+}
+
+// getApiTokens is a route handler which returns the caller's API tokens.
+func getApiTokens(r *http.Request, a routes.Arguments) (int, interface{}) {
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	tokens, err := GetApiTokensForUser(tx, user)
+	if err != nil {
+		l.Error("Failed to get API tokens.", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to retrieve API tokens.")
+	}
+	return http.StatusOK, tokens
+}
+
+// postApiToken is a route handler which lets the user create an API token.
+func postApiToken(r *http.Request, a routes.Arguments) (int, interface{}) {
+	if code, err := forbidApiTokenManagement(a); err != nil {
+		return code, err
+	}
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body apiTokenBody
+	routes.MustRequestBody(a, &body)
+	if err := validateApiTokenBody(body); err != nil {
+		return http.StatusBadRequest, err
+	}
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	token, tokenString, err := CreateApiToken(tx, user, body.Name, body.ReadOnly, body.Expires)
+	if err != nil {
+		l.Error("Failed to create API token.", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to create API token.")
+	}
+	return http.StatusOK, createdApiToken{token, tokenString}
+}
+
+// patchApiToken is a route handler which lets the user edit an API token.
+func patchApiToken(r *http.Request, a routes.Arguments) (int, interface{}) {
+	if code, err := forbidApiTokenManagement(a); err != nil {
+		return code, err
+	}
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	var body apiTokenBody
+	routes.MustRequestBody(a, &body)
+	if err := validateApiTokenBody(body); err != nil {
+		return http.StatusBadRequest, err
+	}
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	tokenId := a[apiTokenIdQueryArg].(int)
+	token, err := UpdateApiToken(tx, user, tokenId, body.Name, body.ReadOnly, body.Expires)
+	if err == ErrApiTokenNotFound {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		l.Error("Failed to update API token.", map[string]interface{}{
+			"userId":  user.Id,
+			"tokenId": tokenId,
+			"error":   err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to update API token.")
+	}
+	return http.StatusOK, token
+}
+
+// deleteApiToken is a route handler which lets the user revoke an API token.
+func deleteApiToken(r *http.Request, a routes.Arguments) (int, interface{}) {
+	if code, err := forbidApiTokenManagement(a); err != nil {
+		return code, err
+	}
+	l := jsonlog.LoggerFromContextOrDefault(r.Context())
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	tokenId := a[apiTokenIdQueryArg].(int)
+	if err := DeleteApiToken(tx, user, tokenId); err == ErrApiTokenNotFound {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		l.Error("Failed to delete API token.", map[string]interface{}{
+			"userId":  user.Id,
+			"tokenId": tokenId,
+			"error":   err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to delete API token.")
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,73 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"context"
+	"testing"
+
+	"github.com/trackit/trackit-server/db"
+)
+
+func TestGenerateApiToken(t *testing.T) {
+	token, err := generateApiToken()
+	if err != nil {
+		t.Fatalf("Error should be nil, is '%s' instead.", err.Error())
+	}
+	if !isApiToken(token) {
+		t.Errorf("Token %q should be recognized as an API token.", token)
+	}
+	if other, _ := generateApiToken(); other == token {
+		t.Errorf("Two generated tokens should differ.")
+	}
+	if hash := hashApiToken(token); len(hash) != 64 || hash != hashApiToken(token) {
+		t.Errorf("Hash should be a stable 64 characters string, is %q instead.", hash)
+	}
+}
+
+func TestJwtIsNotApiToken(t *testing.T) {
+	jwt := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9.e30.t-IDcSemACt8x4iTMCda8Yhe3iZaWbvV5XKSTbuAn0M"
+	if isApiToken(jwt) {
+		t.Errorf("JWT should not be recognized as an API token.")
+	}
+}
+
+// This test is intended to be run against an empty database with the schema
+// already in place.
+func TestApiTokenRevokedWithGeneration(t *testing.T) {
+	ctx := context.Background()
+	user, err := CreateUserWithPassword(ctx, db.Db, "apitoken.revoked@example.trackit.io", "apiTokenPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	defer tx.Rollback()
+	_, tokenString, err := CreateApiToken(tx, user, "script", false, nil)
+	if err != nil {
+		t.Fatalf("Creating API token: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if _, _, err := testApiToken(tx, tokenString); err != nil {
+		t.Fatalf("Testing API token: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if err := RevokeTokens(tx, user.Id); err != nil {
+		t.Fatalf("Revoking tokens: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if _, _, err := testApiToken(tx, tokenString); err != ErrRevokedToken {
+		t.Errorf("Testing revoked API token: error should be \"%s\", instead is \"%v\".", ErrRevokedToken.Error(), err)
+	}
+}
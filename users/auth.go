@@ -37,6 +37,7 @@ var (
 	ErrMissingToken            = errors.New("missing or duplicate token")
 	ErrFailedToValidateToken   = errors.New("failed to validate token")
 	ErrMarketplaceInvalidToken = errors.New("failed to validate marketplace token")
+	ErrRevokedToken            = errors.New("token was revoked")
 )
 
 // getPasswordHash generates a hash string for a given password.
@@ -52,22 +53,25 @@ func passwordMatchesHash(password, hash string) error {
 
 // jwtClaims represents the JWT claims used by this software, as a structure.
 type jwtClaims struct {
-	Issuer    string `json:"iss"`
-	NotBefore int64  `json:"nbf"`
-	Expires   int64  `json:"exp"`
-	Subject   int    `json:"sub"`
-	User      User   `json:"usr"`
+	Issuer     string `json:"iss"`
+	NotBefore  int64  `json:"nbf"`
+	Expires    int64  `json:"exp"`
+	Subject    int    `json:"sub"`
+	Generation int    `json:"gen"`
+	User       User   `json:"usr"`
 	jwt.StandardClaims
 }
 
-// generateToken generates a valid JWT token for a given user.
+// generateToken generates a valid JWT token for a given user. The token is
+// only valid until the user's token generation changes.
 func generateToken(user User) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwtClaims{
-		Issuer:    jwtIssuer,
-		NotBefore: time.Now().Add(-1 * time.Hour).Unix(),
-		Expires:   time.Now().Add(60 * 24 * time.Hour).Unix(),
-		Subject:   user.Id,
-		User:      user,
+		Issuer:     jwtIssuer,
+		NotBefore:  time.Now().Add(-1 * time.Hour).Unix(),
+		Expires:    time.Now().Add(config.AccessTokenLifetime).Unix(),
+		Subject:    user.Id,
+		Generation: user.tokenGeneration,
+		User:       user,
 	})
 	return token.SignedString([]byte(jwtSecret))
 }
@@ -101,6 +105,8 @@ func testToken(tx *sql.Tx, tokenString string) (User, error) {
 				user, err = GetUserWithId(tx, userId)
 				if !user.AwsCustomerEntitlement {
 					err = ErrMarketplaceInvalidToken
+				} else if err == nil && claims.Generation != user.tokenGeneration {
+					err = ErrRevokedToken
 				}
 			} else {
 				err = ErrInvalidClaims
@@ -0,0 +1,46 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/trackit/trackit-server/config"
+)
+
+func TestGenerateTokenCarriesGeneration(t *testing.T) {
+	user := User{Id: 42, Email: "example@example.com", tokenGeneration: 3}
+	tokenString, err := generateToken(user)
+	if err != nil {
+		t.Fatalf("Error should be nil, is '%s' instead.", err.Error())
+	}
+	token, err := jwt.ParseWithClaims(tokenString, &jwtClaims{}, getTokenSigningKey)
+	if err != nil {
+		t.Fatalf("Error should be nil, is '%s' instead.", err.Error())
+	}
+	claims := token.Claims.(*jwtClaims)
+	if claims.Generation != 3 {
+		t.Errorf("Generation should be 3, is %d instead.", claims.Generation)
+	}
+	if expires := time.Unix(claims.Expires, 0); expires.After(time.Now().Add(config.AccessTokenLifetime)) {
+		t.Errorf("Token should expire within %s, expires at %s instead.", config.AccessTokenLifetime, expires)
+	}
+	if !areClaimsValid(*claims) {
+		t.Errorf("Claims should be valid.")
+	}
+}
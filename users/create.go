@@ -28,6 +28,7 @@ import (
 	"github.com/satori/go.uuid"
 	"github.com/trackit/jsonlog"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/config"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/mail"
@@ -70,6 +71,13 @@ func init() {
 				Description: "Responds with the currently authenticated user's data.",
 			},
 		),
+		http.MethodDelete: routes.H(deleteCurrentUser).With(
+			RequireAuthenticatedUser{ViewerCannot},
+			routes.Documentation{
+				Summary:     "delete the current user",
+				Description: "Deletes the current user along with their viewers, AWS accounts, bill repositories, shares, budgets, anomaly filters and snoozes, ElasticSearch data and stored spreadsheets. This cannot be undone.",
+			},
+		),
 	}.H().With(
 		db.RequestTransaction{db.Db},
 		routes.Documentation{
@@ -215,6 +223,16 @@ func createViewerUser(request *http.Request, a routes.Arguments) (int, interface
 		logger.Error("Failed to insert viewer password token in database.", err.Error())
 		return 500, errors.New("Failed to create viewer password token")
 	}
+	err = audit.Record(request, tx, audit.Entry{
+		Action:     audit.ActionViewerCreate,
+		OwnerId:    currentUser.Id,
+		TargetType: audit.TargetUser,
+		TargetId:   viewerUser.Id,
+		After:      viewerUser,
+	})
+	if err != nil {
+		return 500, errors.New("Failed to create viewer user.")
+	}
 	mailSubject := "Your TrackIt viewer password"
 	mailBody := fmt.Sprintf("Please follow this link to create your password: https://re.trackit.io/reset/%d/%s.", dbForgottenPassword.ID, token)
 	err = mail.SendMail(viewerUser.Email, mailSubject, mailBody, request.Context())
@@ -21,6 +21,7 @@ import (
 
 	"github.com/trackit/jsonlog"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/routes"
 )
@@ -34,6 +35,7 @@ type viewerHandling uint
 
 const (
 	AuthenticatedUser            = authenticatedUserArgumentKey(iota)
+	AuthenticatedApiToken        = authenticatedUserArgumentKey(iota)
 	TagRequireUserAuthentication = "require:userauth"
 )
 
@@ -56,9 +58,18 @@ func (d RequireAuthenticatedUser) getFunc(hf routes.HandlerFunc) routes.HandlerF
 		tx := a[db.Transaction].(*sql.Tx)
 		if auth != nil && len(auth) == 1 {
 			tokenString := auth[0]
-			if user, err := testToken(tx, tokenString); err == nil {
+			var user User
+			var err error
+			if isApiToken(tokenString) {
+				user, err = testApiTokenForRequest(tx, tokenString, r, a)
+			} else {
+				user, err = testToken(tx, tokenString)
+			}
+			if err == nil {
 				return d.handleWithAuthenticatedUser(user, tx, hf, w, r, a)
-			} else if err != ErrCannotReadToken && err != ErrInvalidClaims && err != ErrMarketplaceInvalidToken{
+			} else if err == ErrReadOnlyApiToken {
+				return http.StatusForbidden, err
+			} else if err != ErrCannotReadToken && err != ErrInvalidClaims && err != ErrMarketplaceInvalidToken && err != ErrApiTokenExpired && err != ErrRevokedToken {
 				logger.Error("Abnormal authentication failure.", map[string]interface{}{
 					"error": err.Error(),
 					"user":  user.Email,
@@ -75,6 +86,7 @@ func (d RequireAuthenticatedUser) getFunc(hf routes.HandlerFunc) routes.HandlerF
 }
 
 func (d RequireAuthenticatedUser) handleWithAuthenticatedUser(user User, tx *sql.Tx, hf routes.HandlerFunc, w http.ResponseWriter, r *http.Request, a routes.Arguments) (int, interface{}) {
+	r = r.WithContext(audit.ContextWithActor(r.Context(), audit.Actor{user.Id, user.Email}))
 	switch d.ViewerHandling {
 	case ViewerAsParent:
 		if user.ParentId != nil {
@@ -95,6 +107,20 @@ func (d RequireAuthenticatedUser) handleWithAuthenticatedUser(user User, tx *sql
 	return hf(w, r, a)
 }
 
+// testApiTokenForRequest authenticates a request with an API token. Read-only
+// tokens are only accepted for safe methods. The token is stored in the
+// arguments under AuthenticatedApiToken.
+func testApiTokenForRequest(tx *sql.Tx, tokenString string, r *http.Request, a routes.Arguments) (User, error) {
+	user, token, err := testApiToken(tx, tokenString)
+	if err != nil {
+		return user, err
+	} else if token.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return user, ErrReadOnlyApiToken
+	}
+	a[AuthenticatedApiToken] = token
+	return user, nil
+}
+
 func (_ RequireAuthenticatedUser) getDocumentation(hd routes.HandlerDocumentation) routes.HandlerDocumentation {
 	if hd.Tags == nil {
 		hd.Tags = make(routes.Tags)
@@ -0,0 +1,147 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/audit"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/routes"
+)
+
+// DeletionHook cleans up the data of a user and their viewers that is not
+// stored in the database. Hooks are run by DeleteUser before the user is
+// deleted from the database.
+type DeletionHook func(ctx context.Context, tx *sql.Tx, user User, viewers []User) error
+
+var (
+	deletionHooks []DeletionHook
+
+	// userIdQueryArg is the ID of the user to delete.
+	userIdQueryArg = routes.QueryArg{
+		Name:        "user-id",
+		Type:        routes.QueryArgInt{},
+		Description: "The ID of the user to delete.",
+	}
+
+	ErrApiTokenCannotDelete = errors.New("API tokens cannot be used to delete a user.")
+)
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodDelete: routes.H(deleteUser).With(
+			routes.QueryArgs{userIdQueryArg},
+			routes.Documentation{
+				Summary:     "delete a user",
+				Description: "Deletes a user along with all of their data, as DELETE /user does. This route is restricted to administrators.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		RequireAuthenticatedUser{ViewerCannot},
+		RequireAdminUser{},
+	).Register("/admin/users")
+}
+
+// RegisterDeletionHook adds a hook to be run when a user is deleted. It is
+// expected to be called from init functions.
+func RegisterDeletionHook(h DeletionHook) {
+	deletionHooks = append(deletionHooks, h)
+}
+
+// DeleteUser deletes a user, their viewers and all of their data. The deletion
+// hooks are run first, then the user is deleted from the database in tx. If a
+// hook fails, nothing is deleted from the database, so that the deletion can
+// be retried.
+func DeleteUser(ctx context.Context, tx *sql.Tx, user User) error {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	viewers, err := GetUsersByParent(ctx, tx, user)
+	if err != nil {
+		return err
+	}
+	for _, h := range deletionHooks {
+		if err = h(ctx, tx, user, viewers); err != nil {
+			return err
+		}
+	}
+	if err = user.Delete(tx); err != nil {
+		logger.Error("Failed to delete user from database.", err.Error())
+		return err
+	}
+	logger.Info("User deleted.", map[string]interface{}{
+		"user":    user,
+		"viewers": len(viewers),
+	})
+	return nil
+}
+
+// deleteCurrentUser is a route handler which deletes the user making the
+// request.
+func deleteCurrentUser(request *http.Request, a routes.Arguments) (int, interface{}) {
+	if _, ok := a[AuthenticatedApiToken]; ok {
+		return http.StatusForbidden, ErrApiTokenCannotDelete
+	}
+	user := a[AuthenticatedUser].(User)
+	tx := a[db.Transaction].(*sql.Tx)
+	if err := DeleteUser(request.Context(), tx, user); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to delete user.")
+	} else if err := recordUserDeletion(request, tx, user); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to delete user.")
+	}
+	return http.StatusOK, nil
+}
+
+// deleteUser is a route handler which lets administrators delete any user.
+func deleteUser(request *http.Request, a routes.Arguments) (int, interface{}) {
+	logger := jsonlog.LoggerFromContextOrDefault(request.Context())
+	admin := a[AuthenticatedUser].(User)
+	tx := a[db.Transaction].(*sql.Tx)
+	user, err := GetUserWithId(tx, a[userIdQueryArg].(int))
+	if err == ErrUserNotFound {
+		return http.StatusNotFound, err
+	} else if err != nil {
+		logger.Error("Failed to get user.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to delete user.")
+	}
+	if err := DeleteUser(request.Context(), tx, user); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to delete user.")
+	} else if err := recordUserDeletion(request, tx, user); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to delete user.")
+	}
+	logger.Info("User deleted by administrator.", map[string]interface{}{
+		"admin": admin,
+		"user":  user,
+	})
+	return http.StatusOK, nil
+}
+
+// recordUserDeletion records the deletion of a user in their audit log, which
+// is kept after they are deleted. It should be given the transaction the user
+// was deleted in.
+func recordUserDeletion(request *http.Request, tx *sql.Tx, user User) error {
+	return audit.Record(request, tx, audit.Entry{
+		Action:     audit.ActionUserDelete,
+		OwnerId:    user.Id,
+		TargetType: audit.TargetUser,
+		TargetId:   user.Id,
+		Before:     user,
+	})
+}
@@ -0,0 +1,62 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/trackit/trackit-server/audit"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+)
+
+// This test is intended to be run against an empty database with the schema
+// already in place.
+func TestDeleteUserKeepsAuditLog(t *testing.T) {
+	ctx := context.Background()
+	user, err := CreateUserWithPassword(ctx, db.Db, "deleted.audit@example.trackit.io", "deletedPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	request := httptest.NewRequest(http.MethodDelete, "/user", nil)
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if err := recordLogin(request, tx, audit.ActionLogin, user, audit.Actor{user.Id, user.Email}, "password"); err != nil {
+		tx.Rollback()
+		t.Fatalf("Recording login: error should be nil, instead is \"%s\".", err.Error())
+	} else if err := DeleteUser(ctx, tx, user); err != nil {
+		tx.Rollback()
+		t.Fatalf("Deleting user: error should be nil, instead is \"%s\".", err.Error())
+	} else if err := recordUserDeletion(request, tx, user); err != nil {
+		tx.Rollback()
+		t.Fatalf("Recording deletion: error should be nil, instead is \"%s\".", err.Error())
+	} else if err := tx.Commit(); err != nil {
+		t.Fatalf("Committing transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	entries, err := models.AuditLogsForOwnerOrAwsAccounts(db.Db, user.Id, nil, 0, 10)
+	if err != nil {
+		t.Fatalf("Getting audit log: error should be nil, instead is \"%s\".", err.Error())
+	} else if len(entries) != 2 {
+		t.Fatalf("Audit log should have 2 entries, has %d instead.", len(entries))
+	}
+	if entries[0].Action != audit.ActionUserDelete || entries[1].Action != audit.ActionLogin {
+		t.Errorf("Audit log should be [%s %s], is [%s %s] instead.", audit.ActionUserDelete, audit.ActionLogin, entries[0].Action, entries[1].Action)
+	}
+}
@@ -0,0 +1,90 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package deletion
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/anomaliesDetection"
+	"github.com/trackit/trackit-server/aws/usageReports/ec2"
+	"github.com/trackit/trackit-server/aws/usageReports/ec2Coverage"
+	"github.com/trackit/trackit-server/aws/usageReports/elasticache"
+	tes "github.com/trackit/trackit-server/aws/usageReports/es"
+	"github.com/trackit/trackit-server/aws/usageReports/lambda"
+	"github.com/trackit/trackit-server/aws/usageReports/rds"
+	"github.com/trackit/trackit-server/aws/usageReports/riEc2"
+	"github.com/trackit/trackit-server/aws/usageReports/riRdS"
+	"github.com/trackit/trackit-server/es"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/onDemandToRI/ec2"
+	core "github.com/trackit/trackit-server/plugins/account/core"
+	"github.com/trackit/trackit-server/reports"
+	"github.com/trackit/trackit-server/users"
+)
+
+// userIndexPrefixes are the prefixes of the ElasticSearch indexes created for
+// each user.
+var userIndexPrefixes = []string{
+	es.IndexPrefixLineItems,
+	anomalies.IndexPrefixAnomaliesDetection,
+	ec2.IndexPrefixEC2Report,
+	ec2Coverage.IndexPrefixEC2CoverageReport,
+	elasticache.IndexPrefixElastiCacheReport,
+	tes.IndexPrefixESReport,
+	lambda.IndexPrefixLambdaReport,
+	rds.IndexPrefixRDSReport,
+	riEc2.IndexPrefixReservedInstancesReport,
+	riRdS.IndexPrefixReservedRDSReport,
+	onDemandToRiEc2.IndexPrefixOdToRiEC2Report,
+	core.IndexPrefixAccountPlugin,
+}
+
+func init() {
+	users.RegisterDeletionHook(deleteUserData)
+}
+
+// deleteUserData deletes the data of a user and their viewers which is not
+// stored in the database: their ElasticSearch indexes and the spreadsheets of
+// their AWS accounts. The database rows are deleted by users.DeleteUser.
+func deleteUserData(ctx context.Context, tx *sql.Tx, user users.User, viewers []users.User) error {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	dbAwsAccounts, err := models.AwsAccountsByUserID(tx, user.Id)
+	if err != nil {
+		logger.Error("Failed to get user's AWS accounts.", err.Error())
+		return err
+	}
+	for _, u := range append([]users.User{user}, viewers...) {
+		if err = es.DeleteIndexesForUserId(ctx, u.Id, userIndexPrefixes...); err != nil {
+			logger.Error("Failed to delete user's ElasticSearch indexes.", map[string]interface{}{
+				"userId": u.Id,
+				"error":  err.Error(),
+			})
+			return err
+		}
+	}
+	for _, dbAwsAccount := range dbAwsAccounts {
+		if err = reports.DeleteAccountReports(ctx, dbAwsAccount.ID); err != nil {
+			logger.Error("Failed to delete AWS account's reports.", map[string]interface{}{
+				"awsAccountId": dbAwsAccount.ID,
+				"error":        err.Error(),
+			})
+			return err
+		}
+	}
+	return nil
+}
@@ -20,7 +20,9 @@ import (
 	"net/http"
 
 	"github.com/trackit/jsonlog"
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
 	"github.com/trackit/trackit-server/routes"
 )
 
@@ -32,8 +34,19 @@ type loginRequestBody struct {
 
 // loginResponseBody is the response body in case LogIn succeeds.
 type loginResponseBody struct {
-	User  User   `json:"user"`
-	Token string `json:"token"`
+	User          User     `json:"user"`
+	Token         string   `json:"token"`
+	RefreshToken  string   `json:"refreshToken"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+// loginChallengeResponseBody is the response body in case the user's password
+// is correct but a second factor is needed. EnrollmentRequired is set when
+// the user must enroll two-factor authentication before logging in.
+type loginChallengeResponseBody struct {
+	TwoFactorRequired  bool   `json:"twoFactorRequired"`
+	EnrollmentRequired bool   `json:"enrollmentRequired"`
+	Challenge          string `json:"challenge"`
 }
 
 func init() {
@@ -41,15 +54,23 @@ func init() {
 		http.MethodPost: routes.H(logIn).With(
 			routes.RequestContentType{"application/json"},
 			routes.RequestBody{loginRequestBody{"example@example.com", "pA55w0rd"}},
+			loginRateLimit(loginRequestEmail),
 			db.RequestTransaction{db.Db},
 			routes.Documentation{
 				Summary:     "log in as a user",
-				Description: "Logs a user in based on an e-mail/password couple and returns a JWT token and the user's data.",
+				Description: "Logs a user in based on an e-mail/password couple and returns a short-lived JWT token, a refresh token and the user's data. If the user uses two-factor authentication, a challenge to send to /user/login/two-factor along with a code is returned instead. Too many failed attempts on an e-mail or from an address lock them out for a while.",
 			},
 		),
 	}.H().Register("/user/login")
 }
 
+// loginRequestEmail returns the email of a login request, for rate limiting.
+func loginRequestEmail(a routes.Arguments) string {
+	var body loginRequestBody
+	routes.MustRequestBody(a, &body)
+	return body.Email
+}
+
 // LogIn handles users attempting to log in. It shall return a valid token the
 // caller can then use to call other routes.
 func logIn(request *http.Request, a routes.Arguments) (int, interface{}) {
@@ -69,31 +90,88 @@ func logInWithValidBody(request *http.Request, body loginRequestBody, tx *sql.Tx
 			logger.Warning("AWS entitlement failure.", user)
 			return 403, errors.New("Please check your AWS marketplace subscription.")
 		} else {
-			return logAuthenticatedUserIn(request, user)
+			return logInOrChallenge(request, tx, user, "password")
 		}
 	} else {
 		logger.Warning("Authentication failure.", struct {
 			Email string `json:"user"`
 		}{user.Email})
+		if user.Id != 0 {
+			// The request's transaction is rolled back, so the failure is
+			// recorded outside of it.
+			recordLogin(request, db.Db, audit.ActionLoginFailure, user, audit.Actor{}, "password")
+		}
 		return 403, errors.New("The username or password is incorrect. Try again.")
 	}
 }
 
-// logAuthenticatedUserIn generates a token for a user that's already been
-// authenticated.
-func logAuthenticatedUserIn(request *http.Request, user User) (int, interface{}) {
+// logInOrChallenge logs in a user whose first factor was checked with method,
+// unless they must provide a second factor, in which case a challenge is
+// returned.
+func logInOrChallenge(request *http.Request, tx *sql.Tx, user User, method string) (int, interface{}) {
 	logger := jsonlog.LoggerFromContextOrDefault(request.Context())
-	token, err := generateToken(user)
-	if err == nil {
-		logger.Info("User logged in.", user)
-		return 200, loginResponseBody{
-			User:  user,
-			Token: token,
+	required, err := isTwoFactorRequired(tx, user)
+	if err != nil {
+		logger.Error("Failed to check whether two-factor authentication is required.", err.Error())
+		return 500, errors.New("Failed to log in.")
+	}
+	tf, err := getDbTwoFactor(tx, user)
+	if err != nil {
+		logger.Error("Failed to get two-factor authentication settings.", err.Error())
+		return 500, errors.New("Failed to log in.")
+	}
+	enabled := tf != nil && tf.Enabled
+	if !enabled && !required {
+		if err := recordLogin(request, tx, audit.ActionLogin, user, audit.Actor{user.Id, user.Email}, method); err != nil {
+			return 500, errors.New("Failed to log in.")
 		}
-	} else {
+		return logAuthenticatedUserIn(request, tx, user)
+	}
+	challenge, err := newTwoFactorChallenge(user)
+	if err != nil {
+		logger.Error("Failed to generate two-factor challenge.", err.Error())
+		return 500, errors.New("Failed to log in.")
+	}
+	return 200, loginChallengeResponseBody{
+		TwoFactorRequired:  true,
+		EnrollmentRequired: !enabled,
+		Challenge:          challenge,
+	}
+}
+
+// recordLogin records an attempt to log in as a user in the audit log, along
+// with the method used to authenticate.
+func recordLogin(request *http.Request, db models.XODB, action string, user User, actor audit.Actor, method string) error {
+	return audit.Record(request, db, audit.Entry{
+		Action:     action,
+		Actor:      actor,
+		OwnerId:    user.Id,
+		TargetType: audit.TargetUser,
+		TargetId:   user.Id,
+		After:      map[string]string{"method": method},
+	})
+}
+
+// logAuthenticatedUserIn generates a token and a refresh token for a user
+// that's already been authenticated.
+func logAuthenticatedUserIn(request *http.Request, tx *sql.Tx, user User) (int, interface{}) {
+	logger := jsonlog.LoggerFromContextOrDefault(request.Context())
+	token, err := generateToken(user)
+	if err != nil {
 		logger.Error("Failed to generate token.", err.Error())
 		return 500, errors.New("Failed to generate token.")
 	}
+	refreshToken, err := generateRefreshToken(tx, user)
+	if err != nil {
+		logger.Error("Failed to generate refresh token.", err.Error())
+		return 500, errors.New("Failed to generate token.")
+	}
+	logger.Info("User logged in.", user)
+	return 200, loginResponseBody{
+		User:         user,
+		Token:        token,
+		RefreshToken: refreshToken,
+	}
 }
 
 // TestToken tests a token's validity. For a valid token, it returns the user
@@ -0,0 +1,467 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/models"
+)
+
+const (
+	// oidcStateLifetime is the time a user has to authenticate with the
+	// OpenID Connect provider.
+	oidcStateLifetime = 10 * time.Minute
+	// oidcStatePurpose tells OpenID Connect state cookies apart from the
+	// other JWTs signed with the same secret.
+	oidcStatePurpose = "oidc-state"
+	// oidcStateCookie is the name of the cookie binding an authorization
+	// request to the browser which started it.
+	oidcStateCookie = "trackit_oidc_state"
+	// oidcKeysMinRefresh is the minimum delay between two fetches of the
+	// provider's keys, so unknown key IDs cannot be used to flood it.
+	oidcKeysMinRefresh = time.Minute
+	// oidcClockSkew is the clock skew tolerated when checking the dates of
+	// an ID token.
+	oidcClockSkew = time.Minute
+)
+
+var (
+	ErrOidcNotConfigured   = errors.New("Single sign-on is not configured.")
+	ErrOidcInvalidState    = errors.New("Single sign-on state is invalid or expired.")
+	ErrOidcInvalidIdToken  = errors.New("Single sign-on identity is invalid.")
+	ErrOidcDomainForbidden = errors.New("This email domain is not allowed to log in.")
+	ErrOidcAccountExists   = errors.New("An account already exists with this email. Log in with your password and link it to single sign-on first.")
+	ErrOidcAlreadyLinked   = errors.New("This single sign-on identity is already linked to another account.")
+	errOidcUnknownKey      = errors.New("unknown key ID")
+)
+
+// oidcProvider is a client for an OpenID Connect provider, implementing the
+// authorization code flow. The provider's configuration and keys are fetched
+// on first use.
+type oidcProvider struct {
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	Client       *http.Client
+
+	mutex       sync.Mutex
+	config      *oidcConfiguration
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+// oidcConfiguration is the part of the OpenID Connect discovery document the
+// server uses.
+type oidcConfiguration struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// oidcJwks is a JSON Web Key Set. Only RSA keys are supported.
+type oidcJwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// oidcAudience is the audience of an ID token, which can either be a string
+// or an array of strings.
+type oidcAudience []string
+
+// oidcIdTokenClaims are the claims of an ID token the server checks. They
+// are validated by verifyIdToken rather than by jwt-go.
+type oidcIdTokenClaims struct {
+	Issuer        string       `json:"iss"`
+	Subject       string       `json:"sub"`
+	Audience      oidcAudience `json:"aud"`
+	Expires       int64        `json:"exp"`
+	IssuedAt      int64        `json:"iat"`
+	Nonce         string       `json:"nonce"`
+	Email         string       `json:"email"`
+	EmailVerified *bool        `json:"email_verified"`
+}
+
+// oidcStateClaims are the claims of the cookie binding an authorization
+// request to the browser which started it. They hold the state sent to the
+// provider, which must come back with the code, and the nonce the ID token
+// must carry. The cookie is signed so it does not need to be stored.
+type oidcStateClaims struct {
+	Purpose string `json:"pur"`
+	State   string `json:"state"`
+	Nonce   string `json:"nonce"`
+	Expires int64  `json:"exp"`
+}
+
+// oidcIdentity is the identity of a user authenticated by the provider.
+type oidcIdentity struct {
+	Issuer  string
+	Subject string
+	Email   string
+}
+
+var (
+	defaultOidcProvider     *oidcProvider
+	defaultOidcProviderOnce sync.Once
+)
+
+// getOidcProvider returns the OpenID Connect provider from the configuration,
+// or nil if single sign-on is not configured.
+func getOidcProvider() *oidcProvider {
+	defaultOidcProviderOnce.Do(func() {
+		if config.OidcIssuer != "" {
+			defaultOidcProvider = &oidcProvider{
+				Issuer:       config.OidcIssuer,
+				ClientId:     config.OidcClientId,
+				ClientSecret: config.OidcClientSecret,
+				RedirectUrl:  config.OidcRedirectUrl,
+				Client:       &http.Client{Timeout: 10 * time.Second},
+			}
+		}
+	})
+	return defaultOidcProvider
+}
+
+func (a *oidcAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = oidcAudience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = oidcAudience(multiple)
+	return nil
+}
+
+// contains checks whether the audience contains a client ID.
+func (a oidcAudience) contains(clientId string) bool {
+	for _, aud := range a {
+		if aud == clientId {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid lets oidcIdTokenClaims implement jwt.Claims. The claims are checked
+// by verifyIdToken.
+func (oidcIdTokenClaims) Valid() error {
+	return nil
+}
+
+// Valid lets oidcStateClaims implement jwt.Claims.
+func (c oidcStateClaims) Valid() error {
+	if c.Purpose != oidcStatePurpose || time.Now().Unix() >= c.Expires {
+		return ErrOidcInvalidState
+	}
+	return nil
+}
+
+// getJson fetches a JSON document and decodes it into dst.
+func (p *oidcProvider) getJson(ctx context.Context, url string, dst interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with status %d", url, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(dst)
+}
+
+// configuration retrieves the provider's discovery document.
+func (p *oidcProvider) configuration(ctx context.Context) (*oidcConfiguration, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.config != nil {
+		return p.config, nil
+	}
+	var c oidcConfiguration
+	issuer := strings.TrimSuffix(p.Issuer, "/")
+	if err := p.getJson(ctx, issuer+"/.well-known/openid-configuration", &c); err != nil {
+		return nil, err
+	} else if strings.TrimSuffix(c.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery document has issuer %q instead of %q", c.Issuer, p.Issuer)
+	}
+	p.config = &c
+	return p.config, nil
+}
+
+// key retrieves one of the provider's keys by its ID. The keys are fetched
+// again when the ID is unknown, since the provider may have rotated them.
+func (p *oidcProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c, err := p.configuration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	} else if time.Since(p.keysFetched) < oidcKeysMinRefresh {
+		return nil, errOidcUnknownKey
+	}
+	var jwks oidcJwks
+	if err := p.getJson(ctx, c.JwksUri, &jwks); err != nil {
+		return nil, err
+	}
+	p.keysFetched = time.Now()
+	p.keys = make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		p.keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errOidcUnknownKey
+}
+
+// AuthCodeUrl builds the URL users are sent to in order to authenticate with
+// the provider.
+func (p *oidcProvider) AuthCodeUrl(ctx context.Context, state, nonce string) (string, error) {
+	c, err := p.configuration(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(c.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientId)
+	q.Set("redirect_uri", p.RedirectUrl)
+	q.Set("scope", "openid email")
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange exchanges an authorization code for an ID token and returns the
+// identity it asserts. The nonce must be the one sent with the
+// authorization request.
+func (p *oidcProvider) Exchange(ctx context.Context, code, nonce string) (oidcIdentity, error) {
+	c, err := p.configuration(ctx)
+	if err != nil {
+		return oidcIdentity{}, err
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.RedirectUrl},
+	}
+	req, err := http.NewRequest(http.MethodPost, c.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oidcIdentity{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.ClientId), url.QueryEscape(p.ClientSecret))
+	res, err := p.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return oidcIdentity{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return oidcIdentity{}, ErrOidcInvalidIdToken
+	}
+	var body struct {
+		IdToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return oidcIdentity{}, err
+	}
+	return p.verifyIdToken(ctx, body.IdToken, nonce)
+}
+
+// verifyIdToken checks the signature and claims of an ID token.
+func (p *oidcProvider) verifyIdToken(ctx context.Context, rawIdToken, nonce string) (oidcIdentity, error) {
+	var claims oidcIdTokenClaims
+	_, err := jwt.ParseWithClaims(rawIdToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v.", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil {
+		jsonlog.LoggerFromContextOrDefault(ctx).Warning("Failed to verify ID token.", err.Error())
+		return oidcIdentity{}, ErrOidcInvalidIdToken
+	}
+	now := time.Now()
+	switch {
+	case strings.TrimSuffix(claims.Issuer, "/") != strings.TrimSuffix(p.Issuer, "/"),
+		!claims.Audience.contains(p.ClientId),
+		now.Add(-oidcClockSkew).Unix() >= claims.Expires,
+		claims.IssuedAt > now.Add(oidcClockSkew).Unix(),
+		claims.Nonce != nonce,
+		claims.Subject == "",
+		claims.Email == "",
+		claims.EmailVerified == nil || !*claims.EmailVerified:
+		return oidcIdentity{}, ErrOidcInvalidIdToken
+	}
+	return oidcIdentity{strings.TrimSuffix(claims.Issuer, "/"), claims.Subject, claims.Email}, nil
+}
+
+// newOidcState generates a state and a nonce to be sent with an
+// authorization request, along with the signed value of the cookie binding
+// them to the user's browser.
+func newOidcState() (state string, nonce string, cookie string, err error) {
+	if state, err = generateOpaqueToken(""); err != nil {
+		return
+	} else if nonce, err = generateOpaqueToken(""); err != nil {
+		return
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, oidcStateClaims{
+		Purpose: oidcStatePurpose,
+		State:   state,
+		Nonce:   nonce,
+		Expires: time.Now().Add(oidcStateLifetime).Unix(),
+	})
+	cookie, err = token.SignedString(jwtSecret)
+	return
+}
+
+// parseOidcState checks that a state returned by the provider is the one
+// bound to the browser by its cookie, and retrieves the nonce the cookie
+// holds.
+func parseOidcState(cookie string, state string) (string, error) {
+	var claims oidcStateClaims
+	if _, err := jwt.ParseWithClaims(cookie, &claims, getTokenSigningKey); err != nil {
+		return "", ErrOidcInvalidState
+	} else if subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return "", ErrOidcInvalidState
+	}
+	return claims.Nonce, nil
+}
+
+// isOidcEmailAllowed checks whether the domain of an email is allowed to log
+// in with single sign-on. No domain is allowed if the list is empty.
+func isOidcEmailAllowed(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	for _, d := range domains {
+		if strings.EqualFold(email[at+1:], strings.TrimPrefix(d, "@")) {
+			return true
+		}
+	}
+	return false
+}
+
+// provisionOidcUser retrieves the user linked to an identity, creating it on
+// first login. New users are created as viewers of the configured parent if
+// any. An existing account with the identity's email is not linked to it
+// silently, since whoever controls the provider's account could then take it
+// over: its owner must link it with linkOidcIdentity.
+func provisionOidcUser(ctx context.Context, tx *sql.Tx, identity oidcIdentity) (User, error) {
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	link, err := models.UserOidcIdentityByIssuerSubject(tx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return GetUserWithId(tx, link.UserID)
+	} else if err != sql.ErrNoRows {
+		return User{}, err
+	}
+	user, err := GetUserWithEmail(ctx, tx, identity.Email)
+	if err == nil {
+		return User{}, ErrOidcAccountExists
+	} else if err != ErrUserNotFound {
+		return user, err
+	}
+	if config.OidcViewerParent != "" {
+		parent, err := GetUserWithEmail(ctx, tx, config.OidcViewerParent)
+		if err != nil {
+			logger.Error("Failed to get single sign-on viewer parent.", err.Error())
+			return user, err
+		}
+		user, _, err = CreateUserWithParent(ctx, tx, identity.Email, parent)
+	} else {
+		var password string
+		if password, err = generateOpaqueToken(""); err != nil {
+			return user, err
+		}
+		user, err = CreateUserWithPassword(ctx, tx, identity.Email, password, "")
+	}
+	if err != nil {
+		return user, err
+	} else if err = linkOidcIdentity(tx, user, identity); err != nil {
+		return user, err
+	}
+	logger.Info("User created from single sign-on.", user)
+	return user, nil
+}
+
+// linkOidcIdentity links an identity to a user, so that they can log in with
+// it.
+func linkOidcIdentity(tx *sql.Tx, user User, identity oidcIdentity) error {
+	existing, err := models.UserOidcIdentityByIssuerSubject(tx, identity.Issuer, identity.Subject)
+	if err == nil && existing.UserID == user.Id {
+		return nil
+	} else if err == nil {
+		return ErrOidcAlreadyLinked
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	link := models.UserOidcIdentity{
+		Created: time.Now().UTC(),
+		UserID:  user.Id,
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+	}
+	return link.Insert(tx)
+}
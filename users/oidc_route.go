@@ -0,0 +1,199 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/audit"
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/routes"
+)
+
+// oidcLoginResponseBody is the response body of the oidcLogin route handler.
+type oidcLoginResponseBody struct {
+	Url string `json:"url"`
+}
+
+// oidcCallbackRequestBody is the expected request body for the oidcCallback
+// route handler.
+type oidcCallbackRequestBody struct {
+	Code  string `json:"code"  req:"nonzero"`
+	State string `json:"state" req:"nonzero"`
+}
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodGet: routes.Handler{Func: oidcLogin}.With(
+			routes.Documentation{
+				Summary:     "start a single sign-on login",
+				Description: "Responds with the URL of the OpenID Connect provider the user must be sent to in order to log in. The provider then redirects the user to the configured redirect URL with a code and a state. The state is bound to the browser by an HttpOnly cookie, which must be sent back with the code.",
+			},
+		),
+	}.H().Register("/user/oidc/login")
+	routes.MethodMuxer{
+		http.MethodPost: routes.Handler{Func: oidcCallback}.With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{oidcCallbackRequestBody{"authorizationcode", "state"}},
+			db.RequestTransaction{db.Db},
+			routes.Documentation{
+				Summary:     "finish a single sign-on login",
+				Description: "Exchanges the code and state the OpenID Connect provider redirected the user with for a JWT token, a refresh token and the user's data. The user is created on first login. An existing account with the same email must be linked to single sign-on first. Users with two-factor authentication get a challenge instead, as with /user/login.",
+			},
+		),
+	}.H().Register("/user/oidc/callback")
+	routes.MethodMuxer{
+		http.MethodPost: routes.Handler{Func: oidcLink}.With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{oidcCallbackRequestBody{"authorizationcode", "state"}},
+			db.RequestTransaction{db.Db},
+			RequireAuthenticatedUser{ViewerAsSelf},
+			routes.Documentation{
+				Summary:     "link an account to single sign-on",
+				Description: "Links the OpenID Connect identity the code and state were issued for to the authenticated user, who can then log in with single sign-on.",
+			},
+		),
+	}.H().Register("/user/oidc/link")
+}
+
+// setOidcStateCookie sets the cookie binding an authorization request to the
+// browser. A negative maxAge deletes it.
+func setOidcStateCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/user/oidc",
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// oidcLogin builds the URL to authenticate with the OpenID Connect provider.
+func oidcLogin(w http.ResponseWriter, request *http.Request, a routes.Arguments) (int, interface{}) {
+	logger := jsonlog.LoggerFromContextOrDefault(request.Context())
+	provider := getOidcProvider()
+	if provider == nil {
+		return http.StatusNotFound, ErrOidcNotConfigured
+	}
+	state, nonce, cookie, err := newOidcState()
+	if err != nil {
+		logger.Error("Failed to generate single sign-on state.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to start single sign-on.")
+	}
+	url, err := provider.AuthCodeUrl(request.Context(), state, nonce)
+	if err != nil {
+		logger.Error("Failed to get single sign-on URL.", err.Error())
+		return http.StatusBadGateway, errors.New("Failed to start single sign-on.")
+	}
+	setOidcStateCookie(w, cookie, int(oidcStateLifetime/time.Second))
+	return http.StatusOK, oidcLoginResponseBody{url}
+}
+
+// oidcIdentityFromCallback checks the code and state the OpenID Connect
+// provider redirected the user with against the browser's state cookie, and
+// retrieves the identity they assert. The cookie is deleted, so the state
+// cannot be used again.
+func oidcIdentityFromCallback(w http.ResponseWriter, request *http.Request, a routes.Arguments) (oidcIdentity, int, error) {
+	ctx := request.Context()
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	provider := getOidcProvider()
+	if provider == nil {
+		return oidcIdentity{}, http.StatusNotFound, ErrOidcNotConfigured
+	}
+	var body oidcCallbackRequestBody
+	routes.MustRequestBody(a, &body)
+	cookie, err := request.Cookie(oidcStateCookie)
+	if err != nil {
+		return oidcIdentity{}, http.StatusBadRequest, ErrOidcInvalidState
+	}
+	setOidcStateCookie(w, "", -1)
+	nonce, err := parseOidcState(cookie.Value, body.State)
+	if err != nil {
+		return oidcIdentity{}, http.StatusBadRequest, err
+	}
+	identity, err := provider.Exchange(ctx, body.Code, nonce)
+	if err == ErrOidcInvalidIdToken {
+		return identity, http.StatusUnauthorized, err
+	} else if err != nil {
+		logger.Error("Failed to exchange single sign-on code.", err.Error())
+		return identity, http.StatusBadGateway, errors.New("Failed to finish single sign-on.")
+	}
+	if !isOidcEmailAllowed(identity.Email, config.OidcAllowedDomains) {
+		logger.Warning("Single sign-on email domain not allowed.", identity.Email)
+		return identity, http.StatusForbidden, ErrOidcDomainForbidden
+	}
+	return identity, http.StatusOK, nil
+}
+
+// oidcCallback logs a user in with the code returned by the OpenID Connect
+// provider.
+func oidcCallback(w http.ResponseWriter, request *http.Request, a routes.Arguments) (int, interface{}) {
+	ctx := request.Context()
+	logger := jsonlog.LoggerFromContextOrDefault(ctx)
+	identity, status, err := oidcIdentityFromCallback(w, request, a)
+	if err != nil {
+		return status, err
+	}
+	tx := a[db.Transaction].(*sql.Tx)
+	user, err := provisionOidcUser(ctx, tx, identity)
+	if err == ErrOidcAccountExists {
+		return http.StatusConflict, err
+	} else if err != nil {
+		logger.Error("Failed to provision single sign-on user.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to finish single sign-on.")
+	} else if !user.AwsCustomerEntitlement {
+		logger.Warning("AWS entitlement failure.", user)
+		return http.StatusForbidden, errors.New("Please check your AWS marketplace subscription.")
+	}
+	return logInOrChallenge(request, tx, user, "oidc")
+}
+
+// oidcLink links the identity the code returned by the OpenID Connect
+// provider was issued for to the authenticated user.
+func oidcLink(w http.ResponseWriter, request *http.Request, a routes.Arguments) (int, interface{}) {
+	logger := jsonlog.LoggerFromContextOrDefault(request.Context())
+	identity, status, err := oidcIdentityFromCallback(w, request, a)
+	if err != nil {
+		return status, err
+	}
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	if err := linkOidcIdentity(tx, user, identity); err == ErrOidcAlreadyLinked {
+		return http.StatusConflict, err
+	} else if err != nil {
+		logger.Error("Failed to link single sign-on identity.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to link single sign-on.")
+	}
+	err = audit.Record(request, tx, audit.Entry{
+		Action:     audit.ActionOidcLink,
+		OwnerId:    user.Id,
+		TargetType: audit.TargetUser,
+		TargetId:   user.Id,
+		After:      map[string]string{"issuer": identity.Issuer, "email": identity.Email},
+	})
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to link single sign-on.")
+	}
+	logger.Info("User linked to single sign-on.", user)
+	return http.StatusOK, nil
+}
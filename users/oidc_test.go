@@ -0,0 +1,362 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/routes"
+)
+
+const (
+	testOidcClientId     = "trackit"
+	testOidcClientSecret = "secret"
+	testOidcKeyId        = "key1"
+)
+
+// mockOidcProvider is a minimal OpenID Connect provider. It responds to any
+// authorization code with an ID token built from its claims.
+type mockOidcProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newMockOidcProvider(t *testing.T) *mockOidcProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOidcProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcConfiguration{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JwksUri:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testOidcKeyId,
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, ok := r.BasicAuth(); !ok || id != testOidcClientId || secret != testOidcClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims)
+		token.Header["kid"] = testOidcKeyId
+		idToken, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+	m.server = httptest.NewServer(mux)
+	return m
+}
+
+func (m *mockOidcProvider) provider() *oidcProvider {
+	return &oidcProvider{
+		Issuer:       m.server.URL,
+		ClientId:     testOidcClientId,
+		ClientSecret: testOidcClientSecret,
+		RedirectUrl:  "https://trackit.example.com/sso",
+		Client:       m.server.Client(),
+	}
+}
+
+func (m *mockOidcProvider) validClaims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            m.server.URL,
+		"sub":            "1234",
+		"aud":            []string{testOidcClientId},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+}
+
+func TestOidcAuthorizationCodeFlow(t *testing.T) {
+	m := newMockOidcProvider(t)
+	defer m.server.Close()
+	p := m.provider()
+	ctx := context.Background()
+	state, nonce, cookie, err := newOidcState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authUrl, err := p.AuthCodeUrl(ctx, state, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authUrl)
+	if u.Path != "/authorize" || u.Query().Get("client_id") != testOidcClientId || u.Query().Get("state") != state {
+		t.Errorf("Unexpected authorization URL %s.", authUrl)
+	}
+	parsedNonce, err := parseOidcState(cookie, u.Query().Get("state"))
+	if err != nil || parsedNonce != nonce {
+		t.Fatalf("State cookie should hold nonce %q, holds %q (%v) instead.", nonce, parsedNonce, err)
+	}
+	m.claims = m.validClaims(nonce)
+	identity, err := p.Exchange(ctx, "code", parsedNonce)
+	if err != nil {
+		t.Fatalf("Error should be nil, is '%s' instead.", err.Error())
+	} else if identity.Email != "user@example.com" || identity.Subject != "1234" {
+		t.Errorf("Unexpected identity %#v.", identity)
+	}
+}
+
+func TestOidcRejectsInvalidIdTokens(t *testing.T) {
+	m := newMockOidcProvider(t)
+	defer m.server.Close()
+	p := m.provider()
+	cases := map[string]func(jwt.MapClaims){
+		"wrong nonce":      func(c jwt.MapClaims) { c["nonce"] = "other" },
+		"wrong audience":   func(c jwt.MapClaims) { c["aud"] = "other" },
+		"wrong issuer":     func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"expired":          func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"unverified email": func(c jwt.MapClaims) { c["email_verified"] = false },
+		"missing verified": func(c jwt.MapClaims) { delete(c, "email_verified") },
+		"missing email":    func(c jwt.MapClaims) { delete(c, "email") },
+		"missing subject":  func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, alter := range cases {
+		m.claims = m.validClaims("nonce")
+		alter(m.claims)
+		if _, err := p.Exchange(context.Background(), "code", "nonce"); err != ErrOidcInvalidIdToken {
+			t.Errorf("%s: error should be %v, is %v instead.", name, ErrOidcInvalidIdToken, err)
+		}
+	}
+}
+
+func TestOidcState(t *testing.T) {
+	state, _, cookie, err := newOidcState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, otherCookie, err := newOidcState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, oidcStateClaims{
+		Purpose: oidcStatePurpose,
+		State:   state,
+		Nonce:   "nonce",
+		Expires: time.Now().Add(-time.Minute).Unix(),
+	}).SignedString(jwtSecret)
+	for name, c := range map[string]string{
+		"garbage cookie":       "garbage",
+		"other browser cookie": otherCookie,
+		"expired cookie":       expired,
+	} {
+		if _, err := parseOidcState(c, state); err != ErrOidcInvalidState {
+			t.Errorf("%s: error should be %v, is %v instead.", name, ErrOidcInvalidState, err)
+		}
+	}
+	if _, err := parseOidcState(cookie, "other"); err != ErrOidcInvalidState {
+		t.Errorf("Wrong state: error should be %v, is %v instead.", ErrOidcInvalidState, err)
+	}
+}
+
+// useMockOidcProvider makes the routes use a mock provider allowing
+// example.com addresses. The returned function restores the configuration.
+func useMockOidcProvider(t *testing.T) (*mockOidcProvider, func()) {
+	m := newMockOidcProvider(t)
+	defaultOidcProviderOnce.Do(func() {})
+	defaultOidcProvider = m.provider()
+	config.OidcAllowedDomains = []string{"example.com"}
+	return m, func() {
+		m.server.Close()
+		defaultOidcProvider = nil
+		config.OidcAllowedDomains = nil
+	}
+}
+
+// startOidcLogin starts a login and returns the state cookie it sets, along
+// with the claims it holds.
+func startOidcLogin(t *testing.T) (*http.Cookie, oidcStateClaims) {
+	login := httptest.NewRecorder()
+	if status, _ := oidcLogin(login, httptest.NewRequest(http.MethodGet, "/user/oidc/login", nil), routes.Arguments{}); status != http.StatusOK {
+		t.Fatalf("Login status should be %d, is %d instead.", http.StatusOK, status)
+	}
+	cookies := login.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie || !cookies[0].HttpOnly {
+		t.Fatalf("Login should set an HttpOnly state cookie, sets %v instead.", cookies)
+	}
+	claims := oidcStateClaims{}
+	jwt.ParseWithClaims(cookies[0].Value, &claims, getTokenSigningKey)
+	return cookies[0], claims
+}
+
+func TestOidcCallbackRequiresStateCookie(t *testing.T) {
+	m, restore := useMockOidcProvider(t)
+	defer restore()
+	cookie, claims := startOidcLogin(t)
+	m.claims = m.validClaims(claims.Nonce)
+	callback := routes.Handler{
+		Func: func(w http.ResponseWriter, r *http.Request, a routes.Arguments) (int, interface{}) {
+			identity, status, err := oidcIdentityFromCallback(w, r, a)
+			if err != nil {
+				return status, err
+			}
+			return status, identity
+		},
+	}.With(routes.RequestBody{oidcCallbackRequestBody{}})
+	body := `{"code":"code","state":"` + claims.State + `"}`
+	withoutCookie := httptest.NewRequest(http.MethodPost, "/user/oidc/callback", strings.NewReader(body))
+	if status, _ := callback.Func(httptest.NewRecorder(), withoutCookie, routes.Arguments{}); status != http.StatusBadRequest {
+		t.Errorf("Callback without cookie status should be %d, is %d instead.", http.StatusBadRequest, status)
+	}
+	withCookie := httptest.NewRequest(http.MethodPost, "/user/oidc/callback", strings.NewReader(body))
+	withCookie.AddCookie(cookie)
+	if status, output := callback.Func(httptest.NewRecorder(), withCookie, routes.Arguments{}); status != http.StatusOK {
+		t.Errorf("Callback with cookie status should be %d, is %d (%v) instead.", http.StatusOK, status, output)
+	} else if identity := output.(oidcIdentity); identity.Email != "user@example.com" {
+		t.Errorf("Unexpected identity %#v.", identity)
+	}
+}
+
+func TestOidcEmailAllowed(t *testing.T) {
+	domains := []string{"example.com", "@trackit.io"}
+	for email, expected := range map[string]bool{
+		"user@example.com":      true,
+		"user@TRACKIT.io":       true,
+		"user@example.com.evil": false,
+		"user@sub.example.com":  false,
+		"not an email":          false,
+	} {
+		if allowed := isOidcEmailAllowed(email, domains); allowed != expected {
+			t.Errorf("%s should be allowed: %t, is %t instead.", email, expected, allowed)
+		}
+	}
+	if isOidcEmailAllowed("user@anything.com", nil) {
+		t.Errorf("No domain should be allowed when none is configured.")
+	}
+}
+
+// This test is intended to be run against an empty database with the schema
+// already in place.
+func TestProvisionOidcUser(t *testing.T) {
+	ctx := context.Background()
+	existing, err := CreateUserWithPassword(ctx, db.Db, "oidc.existing@example.trackit.io", "oidcPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	defer tx.Rollback()
+	identity := oidcIdentity{"https://sso.example.com", "existing", existing.Email}
+	if _, err := provisionOidcUser(ctx, tx, identity); err != ErrOidcAccountExists {
+		t.Errorf("Provisioning existing account: error should be %v, is %v instead.", ErrOidcAccountExists, err)
+	}
+	if err := linkOidcIdentity(tx, existing, identity); err != nil {
+		t.Fatalf("Linking identity: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if user, err := provisionOidcUser(ctx, tx, identity); err != nil || user.Id != existing.Id {
+		t.Errorf("Provisioning linked account: user should be %d, is %d (%v) instead.", existing.Id, user.Id, err)
+	}
+	other, err := CreateUserWithPassword(ctx, tx, "oidc.other@example.trackit.io", "oidcPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if err := linkOidcIdentity(tx, other, identity); err != ErrOidcAlreadyLinked {
+		t.Errorf("Linking identity twice: error should be %v, is %v instead.", ErrOidcAlreadyLinked, err)
+	}
+	identity = oidcIdentity{"https://sso.example.com", "new", "oidc.new@example.trackit.io"}
+	created, err := provisionOidcUser(ctx, tx, identity)
+	if err != nil {
+		t.Fatalf("Provisioning new account: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if again, err := provisionOidcUser(ctx, tx, identity); err != nil || again.Id != created.Id {
+		t.Errorf("Provisioning new account again: user should be %d, is %d (%v) instead.", created.Id, again.Id, err)
+	}
+}
+
+// This test is intended to be run against an empty database with the schema
+// already in place.
+func TestOidcCallbackChallengesTwoFactorUsers(t *testing.T) {
+	m, restore := useMockOidcProvider(t)
+	defer restore()
+	ctx := context.Background()
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	defer tx.Rollback()
+	user, err := CreateUserWithPassword(ctx, tx, "oidc.twofactor@example.com", "oidcPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if err := linkOidcIdentity(tx, user, oidcIdentity{m.server.URL, "1234", user.Email}); err != nil {
+		t.Fatalf("Linking identity: error should be nil, instead is \"%s\".", err.Error())
+	}
+	tf := models.UserTwoFactor{
+		Created: time.Now().UTC(),
+		UserID:  user.Id,
+		Secret:  "JBSWY3DPEHPK3PXP",
+		Enabled: true,
+	}
+	if err := tf.Insert(tx); err != nil {
+		t.Fatalf("Enabling two-factor authentication: error should be nil, instead is \"%s\".", err.Error())
+	}
+	cookie, claims := startOidcLogin(t)
+	m.claims = m.validClaims(claims.Nonce)
+	m.claims["email"] = user.Email
+	callback := routes.Handler{
+		Func: func(w http.ResponseWriter, r *http.Request, a routes.Arguments) (int, interface{}) {
+			a[db.Transaction] = tx
+			return oidcCallback(w, r, a)
+		},
+	}.With(routes.RequestBody{oidcCallbackRequestBody{}})
+	request := httptest.NewRequest(http.MethodPost, "/user/oidc/callback", strings.NewReader(`{"code":"code","state":"`+claims.State+`"}`))
+	request.AddCookie(cookie)
+	status, output := callback.Func(httptest.NewRecorder(), request, routes.Arguments{})
+	if status != http.StatusOK {
+		t.Fatalf("Callback status should be %d, is %d (%v) instead.", http.StatusOK, status, output)
+	}
+	body, ok := output.(loginChallengeResponseBody)
+	if !ok || !body.TwoFactorRequired || body.EnrollmentRequired || body.Challenge == "" {
+		t.Fatalf("Callback should return a two-factor challenge, returns %#v instead.", output)
+	}
+	if challenged, err := parseTwoFactorChallenge(tx, body.Challenge); err != nil || challenged.Id != user.Id {
+		t.Errorf("Challenge should be for user %d, is for %d (%v) instead.", user.Id, challenged.Id, err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/satori/go.uuid"
 
 	"github.com/trackit/jsonlog"
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/mail"
 	"github.com/trackit/trackit-server/models"
@@ -52,10 +53,11 @@ func init() {
 		http.MethodPost: routes.H(forgottenPassword).With(
 			routes.RequestContentType{"application/json"},
 			routes.RequestBody{forgottenPasswordRequestBody{"example@example.com"}},
+			forgottenPasswordRateLimit(forgottenPasswordRequestEmail),
 			db.RequestTransaction{db.Db},
 			routes.Documentation{
 				Summary:     "request a forgotten password reset",
-				Description: "Sends an email to a user with a link to reset his forgotten password",
+				Description: "Sends an email to a user with a link to reset his forgotten password. It succeeds whether the user exists or not, and too many requests from an address, or for an e-mail from an address, lock them out for a while.",
 			},
 		),
 	}.H().Register("/user/password/forgotten")
@@ -89,6 +91,14 @@ func cleanExpiredTokens() {
 	}
 }
 
+// forgottenPasswordRequestEmail returns the email of a forgotten password
+// request, for rate limiting.
+func forgottenPasswordRequestEmail(a routes.Arguments) string {
+	var body forgottenPasswordRequestBody
+	routes.MustRequestBody(a, &body)
+	return body.Email
+}
+
 // forgottenPassword handles users requesting to reset a forgotten password
 func forgottenPassword(request *http.Request, a routes.Arguments) (int, interface{}) {
 	var body forgottenPasswordRequestBody
@@ -106,7 +116,10 @@ func forgottenPasswordWithValidBody(request *http.Request, body forgottenPasswor
 			Email string `json:"user"`
 			Error string `json:"error"`
 		}{body.Email, err.Error()})
-		return 404, errors.New("User not found")
+		if err == ErrUserNotFound {
+			return 200, nil
+		}
+		return 500, errors.New("Failed to create forgotten password token")
 	}
 	return createForgottenPasswordEntry(request, body, tx, user)
 }
@@ -188,5 +201,15 @@ func updatePasswordFromForgottenToken(request *http.Request, body resetPasswordR
 	if err != nil {
 		logger.Warning("Unable to delete forgotten password token", err.Error())
 	}
+	err = audit.Record(request, tx, audit.Entry{
+		Action:     audit.ActionPasswordReset,
+		Actor:      audit.Actor{user.Id, user.Email},
+		OwnerId:    user.Id,
+		TargetType: audit.TargetUser,
+		TargetId:   user.Id,
+	})
+	if err != nil {
+		return 500, errors.New("Unable to update user")
+	}
 	return 200, nil
 }
@@ -0,0 +1,212 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/routes"
+)
+
+const (
+	// refreshTokenPrefix prefixes every refresh token.
+	refreshTokenPrefix = "trackitrefresh_"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("Refresh token is invalid or expired.")
+)
+
+// refreshRequestBody is the expected request body for the refresh route
+// handler.
+type refreshRequestBody struct {
+	RefreshToken string `json:"refreshToken" req:"nonzero"`
+}
+
+// logoutRequestBody is the expected request body for the logout route
+// handler.
+type logoutRequestBody struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// logoutEverywhereQueryArg lets the caller revoke all of their tokens when
+// logging out.
+var logoutEverywhereQueryArg = routes.QueryArg{
+	Name:        "everywhere",
+	Type:        routes.QueryArgBool{},
+	Description: "Revoke all the tokens of the user instead of the refresh token in the body only.",
+	Optional:    true,
+}
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodPost: routes.H(refresh).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{refreshRequestBody{"trackitrefresh_token"}},
+			db.RequestTransaction{db.Db},
+			routes.Documentation{
+				Summary:     "refresh a token",
+				Description: "Exchanges a refresh token for a new JWT token and a new refresh token. A refresh token can only be used once: using it again revokes all the tokens of the user.",
+			},
+		),
+	}.H().Register("/user/refresh")
+	routes.MethodMuxer{
+		http.MethodPost: routes.H(logOut).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{logoutRequestBody{"trackitrefresh_token"}},
+			routes.QueryArgs{logoutEverywhereQueryArg},
+			db.RequestTransaction{db.Db},
+			RequireAuthenticatedUser{ViewerAsSelf},
+			routes.Documentation{
+				Summary:     "log out",
+				Description: "Revokes the refresh token in the body. When everywhere is set, all the JWT and refresh tokens of the user are revoked.",
+			},
+		),
+	}.H().Register("/user/logout")
+}
+
+// RevokeTokens revokes all the JWT and refresh tokens issued to a user by
+// bumping their token generation.
+func RevokeTokens(db models.XODB, userId int) error {
+	return models.BumpUserTokenGeneration(db, userId)
+}
+
+// generateRefreshToken generates a refresh token for a user and stores its
+// hash. The refresh token is only valid for the user's current token
+// generation.
+func generateRefreshToken(tx *sql.Tx, user User) (string, error) {
+	tokenString, err := generateOpaqueToken(refreshTokenPrefix)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	if err := models.DeleteExpiredUserRefreshTokens(tx, user.Id, now); err != nil {
+		return "", err
+	}
+	dbToken := models.UserRefreshToken{
+		Created:    now,
+		UserID:     user.Id,
+		TokenHash:  hashApiToken(tokenString),
+		Generation: user.tokenGeneration,
+		Expires:    now.Add(config.RefreshTokenLifetime),
+	}
+	if err := dbToken.Insert(tx); err != nil {
+		return "", err
+	}
+	return tokenString, nil
+}
+
+// useRefreshToken checks whether a refresh token is valid and retrieves the
+// owning User if it is. The refresh token is claimed with a conditional write,
+// so that concurrent uses of a token cannot both succeed. If it was already
+// used, it was most likely stolen: all the user's tokens are revoked. Since
+// the request fails, its transaction is rolled back: the revocation goes
+// through revocationDb instead.
+func useRefreshToken(tx *sql.Tx, revocationDb models.XODB, tokenString string) (User, error) {
+	dbToken, err := models.UserRefreshTokenByTokenHash(tx, hashApiToken(tokenString))
+	if err == sql.ErrNoRows {
+		return User{}, ErrInvalidRefreshToken
+	} else if err != nil {
+		return User{}, err
+	}
+	if dbToken.Used {
+		return User{}, revokeReusedRefreshToken(revocationDb, dbToken.UserID)
+	}
+	user, err := GetUserWithId(tx, dbToken.UserID)
+	if err != nil {
+		return user, err
+	} else if !time.Now().Before(dbToken.Expires) || dbToken.Generation != user.tokenGeneration {
+		return user, ErrInvalidRefreshToken
+	}
+	if claimed, err := models.ClaimUserRefreshToken(tx, dbToken.ID); err != nil {
+		return user, err
+	} else if !claimed {
+		return User{}, revokeReusedRefreshToken(revocationDb, dbToken.UserID)
+	}
+	return user, nil
+}
+
+// revokeReusedRefreshToken revokes all the tokens of a user whose refresh
+// token was used twice. It returns ErrInvalidRefreshToken unless the
+// revocation fails.
+func revokeReusedRefreshToken(revocationDb models.XODB, userId int) error {
+	if err := RevokeTokens(revocationDb, userId); err != nil {
+		return err
+	}
+	return ErrInvalidRefreshToken
+}
+
+// revokeRefreshToken marks a user's refresh token as used so it cannot be
+// used anymore. Unknown tokens and tokens of other users are ignored.
+func revokeRefreshToken(tx *sql.Tx, user User, tokenString string) error {
+	dbToken, err := models.UserRefreshTokenByTokenHash(tx, hashApiToken(tokenString))
+	if err == sql.ErrNoRows || (err == nil && dbToken.UserID != user.Id) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return dbToken.Delete(tx)
+}
+
+// refresh handles users exchanging a refresh token for new tokens.
+func refresh(request *http.Request, a routes.Arguments) (int, interface{}) {
+	logger := jsonlog.LoggerFromContextOrDefault(request.Context())
+	var body refreshRequestBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user, err := useRefreshToken(tx, db.Db, body.RefreshToken)
+	if err == ErrInvalidRefreshToken || err == ErrUserNotFound {
+		return http.StatusUnauthorized, ErrInvalidRefreshToken
+	} else if err != nil {
+		logger.Error("Failed to use refresh token.", err.Error())
+		return http.StatusInternalServerError, errors.New("Failed to refresh token.")
+	} else if !user.AwsCustomerEntitlement {
+		logger.Warning("AWS entitlement failure.", user)
+		return http.StatusForbidden, errors.New("Please check your AWS marketplace subscription.")
+	}
+	return logAuthenticatedUserIn(request, tx, user)
+}
+
+// logOut handles users revoking their tokens.
+func logOut(request *http.Request, a routes.Arguments) (int, interface{}) {
+	logger := jsonlog.LoggerFromContextOrDefault(request.Context())
+	var body logoutRequestBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	var err error
+	if everywhere, ok := a[logoutEverywhereQueryArg].(bool); ok && everywhere {
+		err = RevokeTokens(tx, user.Id)
+	} else if body.RefreshToken != "" {
+		err = revokeRefreshToken(tx, user, body.RefreshToken)
+	}
+	if err != nil {
+		logger.Error("Failed to revoke tokens.", map[string]interface{}{
+			"userId": user.Id,
+			"error":  err.Error(),
+		})
+		return http.StatusInternalServerError, errors.New("Failed to log out.")
+	}
+	logger.Info("User logged out.", user)
+	return http.StatusOK, nil
+}
@@ -0,0 +1,104 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+// These tests are intended to be run against an empty database with the schema
+// already in place.
+
+import (
+	"context"
+	"testing"
+
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+)
+
+// useRefreshTokenInTransaction uses a refresh token in a transaction which is
+// committed iff it succeeds, as db.RequestTransaction would.
+func useRefreshTokenInTransaction(t *testing.T, tokenString string) (User, error) {
+	tx, err := db.Db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	user, err := useRefreshToken(tx, db.Db, tokenString)
+	if err != nil {
+		tx.Rollback()
+	} else if cerr := tx.Commit(); cerr != nil {
+		t.Fatalf("Committing transaction: error should be nil, instead is \"%s\".", cerr.Error())
+	}
+	return user, err
+}
+
+func TestRefreshTokenReuseRevokesTokens(t *testing.T) {
+	ctx := context.Background()
+	user, err := CreateUserWithPassword(ctx, db.Db, "refresh.reuse@example.trackit.io", "refreshPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	tokenString, err := generateRefreshToken(tx, user)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("Generating refresh token: error should be nil, instead is \"%s\".", err.Error())
+	} else if err := tx.Commit(); err != nil {
+		t.Fatalf("Committing transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if _, err := useRefreshTokenInTransaction(t, tokenString); err != nil {
+		t.Fatalf("Using refresh token: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if _, err := useRefreshTokenInTransaction(t, tokenString); err != ErrInvalidRefreshToken {
+		t.Errorf("Reusing refresh token: error should be \"%s\", instead is \"%v\".", ErrInvalidRefreshToken.Error(), err)
+	}
+	revoked, err := GetUserWithId(db.Db, user.Id)
+	if err != nil {
+		t.Fatalf("Getting user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if revoked.tokenGeneration != user.tokenGeneration+1 {
+		t.Errorf("Token generation should be %d after the reuse, instead is %d.", user.tokenGeneration+1, revoked.tokenGeneration)
+	}
+}
+
+func TestClaimRefreshTokenOnce(t *testing.T) {
+	ctx := context.Background()
+	user, err := CreateUserWithPassword(ctx, db.Db, "refresh.claim@example.trackit.io", "refreshPassword", "")
+	if err != nil {
+		t.Fatalf("Creating user: error should be nil, instead is \"%s\".", err.Error())
+	}
+	tx, err := db.Db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Beginning transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	tokenString, err := generateRefreshToken(tx, user)
+	if err != nil {
+		tx.Rollback()
+		t.Fatalf("Generating refresh token: error should be nil, instead is \"%s\".", err.Error())
+	} else if err := tx.Commit(); err != nil {
+		t.Fatalf("Committing transaction: error should be nil, instead is \"%s\".", err.Error())
+	}
+	dbToken, err := models.UserRefreshTokenByTokenHash(db.Db, hashApiToken(tokenString))
+	if err != nil {
+		t.Fatalf("Getting refresh token: error should be nil, instead is \"%s\".", err.Error())
+	}
+	for i, expected := range []bool{true, false} {
+		if claimed, err := models.ClaimUserRefreshToken(db.Db, dbToken.ID); err != nil {
+			t.Fatalf("Claiming refresh token: error should be nil, instead is \"%s\".", err.Error())
+		} else if claimed != expected {
+			t.Errorf("Claim %d should succeed: %v, instead succeeds: %v.", i, expected, claimed)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package shared_account
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/trackit/trackit-server/audit"
+	"github.com/trackit/trackit-server/models"
+)
+
+// recordShareChange records a change to a share of an AWS account in the
+// audit log of the account's owner.
+func recordShareChange(request *http.Request, tx *sql.Tx, action string, share models.SharedAccount, before, after interface{}) error {
+	dbAwsAccount, err := models.AwsAccountByID(tx, share.AccountID)
+	if err != nil {
+		return err
+	}
+	return audit.Record(request, tx, audit.Entry{
+		Action:       action,
+		OwnerId:      dbAwsAccount.UserID,
+		AwsAccountId: share.AccountID,
+		TargetType:   audit.TargetShare,
+		TargetId:     share.ID,
+		Before:       before,
+		After:        after,
+	})
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/satori/go.uuid"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/mail"
 	"github.com/trackit/trackit-server/users"
 	"github.com/trackit/trackit-server/models"
@@ -231,13 +232,19 @@ func InviteUserWithValidBody(request *http.Request, body InviteUserRequest, acco
 	}
 	result, guestId, err := checkUserWithEmail(request.Context(), tx, body.Email, user)
 	if err == nil {
+		var code int
+		var res interface{}
 		if result {
-			code, res := inviteUserAlreadyExist(request.Context(), tx, body, accountId, guestId)
-			return code, res
+			code, res = inviteUserAlreadyExist(request.Context(), tx, body, accountId, guestId)
 		} else {
-			code, res := inviteNewUser(request.Context(), tx, body, accountId)
-			return code, res
+			code, res = inviteNewUser(request.Context(), tx, body, accountId)
 		}
+		if share, ok := res.(models.SharedAccount); ok && code == http.StatusOK {
+			if err := recordShareChange(request, tx, audit.ActionShareInvite, share, nil, share); err != nil {
+				return http.StatusInternalServerError, ErrorInviteUser
+			}
+		}
+		return code, res
 	} else {
 		logger.Error("Error occured while checking body elements.", err.Error())
 		return 403, ErrorInviteNewUser
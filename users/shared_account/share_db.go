@@ -22,6 +22,7 @@ import (
 	"github.com/trackit/jsonlog"
 
 	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/users"
 )
 
 type SharedResults struct {
@@ -73,6 +74,7 @@ func UpdateSharedUser(ctx context.Context, db models.XODB, shareId int, permissi
 }
 
 // DeleteSharedUser deletes a user access to an AWS account by removing entry in shared_account database table.
+// The tokens of the user are revoked.
 func DeleteSharedUser(ctx context.Context, db models.XODB, shareId int) (error) {
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
 	dbSharedAccount, err := models.SharedAccountByID(db, shareId)
@@ -85,5 +87,10 @@ func DeleteSharedUser(ctx context.Context, db models.XODB, shareId int) (error)
 		logger.Error("Error while deleting shared user", err)
 		return err
 	}
+	err = users.RevokeTokens(db, dbSharedAccount.UserID)
+	if err != nil {
+		logger.Error("Error while revoking shared user tokens", err)
+		return err
+	}
 	return nil
 }
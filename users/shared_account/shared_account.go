@@ -18,8 +18,10 @@ import (
 	"database/sql"
 	"net/http"
 
+	"github.com/trackit/trackit-server/audit"
 	"github.com/trackit/trackit-server/db"
 	"github.com/trackit/trackit-server/errors"
+	"github.com/trackit/trackit-server/models"
 	"github.com/trackit/trackit-server/routes"
 	"github.com/trackit/trackit-server/users"
 )
@@ -95,10 +97,17 @@ func updateSharedUserAccessWithValidBody(request *http.Request, body updateUsers
 	if !checkPermissionLevel(body.PermissionLevel) {
 		return http.StatusBadRequest, errors.GetErrorMessage(ctx, &errors.SharedAccountError{errors.SharedAccountBadPermission, "Bad permission level"})
 	}
-	res, err := UpdateSharedUser(request.Context(), db.Db, shareId, body.PermissionLevel)
+	before, err := models.SharedAccountByID(tx, shareId)
 	if err != nil {
 		return http.StatusForbidden, errors.GetErrorMessage(ctx, &errors.SharedAccountError{errors.SharedAccountRequestError, "Error retrieving shared user list"})
 	}
+	res, err := UpdateSharedUser(request.Context(), tx, shareId, body.PermissionLevel)
+	if err != nil {
+		return http.StatusForbidden, errors.GetErrorMessage(ctx, &errors.SharedAccountError{errors.SharedAccountRequestError, "Error retrieving shared user list"})
+	}
+	if err := recordShareChange(request, tx, audit.ActionShareUpdate, *before, *before, res); err != nil {
+		return http.StatusInternalServerError, errors.GetErrorMessage(ctx, &errors.SharedAccountError{errors.SharedAccountRequestError, "Error updating shared user"})
+	}
 	return http.StatusOK, res
 }
 
@@ -111,9 +120,16 @@ func deleteSharedUserAccessWithValidBody(request *http.Request, shareId int, tx
 	} else if !security {
 		return http.StatusForbidden, errors.GetErrorMessage(ctx, &errors.SharedAccountError{errors.SharedAccountNoPermission, "You do not have permission to delete this sharing"})
 	}
-	err = DeleteSharedUser(request.Context(), db.Db, shareId)
+	before, err := models.SharedAccountByID(tx, shareId)
 	if err != nil {
 		return http.StatusBadRequest, errors.GetErrorMessage(ctx, &errors.SharedAccountError{errors.SharedAccountRequestError, "Error deleting shared user"})
 	}
+	err = DeleteSharedUser(request.Context(), tx, shareId)
+	if err != nil {
+		return http.StatusBadRequest, errors.GetErrorMessage(ctx, &errors.SharedAccountError{errors.SharedAccountRequestError, "Error deleting shared user"})
+	}
+	if err := recordShareChange(request, tx, audit.ActionShareDelete, *before, *before, nil); err != nil {
+		return http.StatusInternalServerError, errors.GetErrorMessage(ctx, &errors.SharedAccountError{errors.SharedAccountRequestError, "Error deleting shared user"})
+	}
 	return http.StatusOK, nil
 }
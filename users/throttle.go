@@ -0,0 +1,205 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/trackit/jsonlog"
+	"github.com/trackit/trackit-server/config"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/routes"
+)
+
+// throttleCleanupInterval is the minimum time between two deletions of the
+// expired rate limits by a backend.
+const throttleCleanupInterval = time.Minute
+
+// lastThrottleCleanup is the time, in nanoseconds since the epoch, at which
+// the expired rate limits were last deleted.
+var lastThrottleCleanup int64
+
+// sqlRateLimitStore is a routes.RateLimitStore keeping failed attempts in the
+// SQL database, so that lockouts hold across backends. It does not use the
+// request's transaction, which is rolled back when the attempt fails. Keys
+// are hashed so that emails are not stored as is.
+type sqlRateLimitStore struct {
+	db *sql.DB
+}
+
+func (s sqlRateLimitStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	dbAuthThrottle, err := models.AuthThrottleByThrottleKey(s.db, hashThrottleKey(key))
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	} else if !dbAuthThrottle.LockedUntil.Valid {
+		return time.Time{}, nil
+	}
+	return dbAuthThrottle.LockedUntil.Time, nil
+}
+
+func (s sqlRateLimitStore) Fail(ctx context.Context, key string, maxFailures int, window, lockout time.Duration) error {
+	now := time.Now().UTC()
+	if claimThrottleCleanup(now) {
+		go s.cleanExpired(window, lockout)
+	}
+	return models.FailAuthThrottle(s.db, hashThrottleKey(key), maxFailures, now, now.Add(-window), now.Add(lockout))
+}
+
+func (s sqlRateLimitStore) Reset(ctx context.Context, key string) error {
+	return models.DeleteAuthThrottleByThrottleKey(s.db, hashThrottleKey(key))
+}
+
+// cleanExpired deletes the keys whose failed attempts and lockout are over.
+func (s sqlRateLimitStore) cleanExpired(window, lockout time.Duration) {
+	expire := time.Now().UTC().Add(-window)
+	if lockout > window {
+		expire = time.Now().UTC().Add(-lockout)
+	}
+	if err := models.DeleteExpiredAuthThrottles(s.db, expire); err != nil {
+		jsonlog.DefaultLogger.Error("Failed to delete expired rate limits.", err.Error())
+	}
+}
+
+// claimThrottleCleanup returns whether the expired rate limits should be
+// deleted at now, which happens at most once per throttleCleanupInterval so
+// that failed attempts do not each start a deletion.
+func claimThrottleCleanup(now time.Time) bool {
+	last := atomic.LoadInt64(&lastThrottleCleanup)
+	if now.UnixNano()-last < int64(throttleCleanupInterval) {
+		return false
+	}
+	return atomic.CompareAndSwapInt64(&lastThrottleCleanup, last, now.UnixNano())
+}
+
+// hashThrottleKey hashes a rate limiting key to the form it is stored in.
+func hashThrottleKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// addressRateLimitKey counts failed attempts by client address.
+func addressRateLimitKey() routes.RateLimitKey {
+	return routes.RateLimitKey{
+		Name:        "address",
+		Key:         func(r *http.Request, _ routes.Arguments) string { return routes.RemoteAddress(r) },
+		MaxFailures: config.LoginMaxFailuresPerAddress,
+	}
+}
+
+// loginRateLimit builds the rate limiting decorator for the route used to log
+// in. Failed attempts are counted by client address and by the email returned
+// by email.
+func loginRateLimit(email func(routes.Arguments) string) routes.RateLimit {
+	return routes.RateLimit{
+		Store: sqlRateLimitStore{db.Db},
+		Keys: []routes.RateLimitKey{
+			addressRateLimitKey(),
+			{
+				Name:           "email",
+				Key:            func(_ *http.Request, a routes.Arguments) string { return normalizeThrottleEmail(email(a)) },
+				MaxFailures:    config.LoginMaxFailuresPerEmail,
+				ResetOnSuccess: true,
+			},
+		},
+		Window:  config.LoginFailureWindow,
+		Lockout: config.LoginLockout,
+	}
+}
+
+// forgottenPasswordRateLimit builds the rate limiting decorator for the route
+// used to recover an account, counting every attempt. Attempts are counted
+// by client address and by client address and email together, so that
+// requests from other clients cannot lock a user out of recovering their
+// account.
+func forgottenPasswordRateLimit(email func(routes.Arguments) string) routes.RateLimit {
+	return routes.RateLimit{
+		Store: sqlRateLimitStore{db.Db},
+		Keys: []routes.RateLimitKey{
+			addressRateLimitKey(),
+			{
+				Name: "address-email",
+				Key: func(r *http.Request, a routes.Arguments) string {
+					if email := normalizeThrottleEmail(email(a)); email != "" {
+						return routes.RemoteAddress(r) + " " + email
+					}
+					return ""
+				},
+				MaxFailures: config.LoginMaxFailuresPerEmail,
+			},
+		},
+		Window:   config.LoginFailureWindow,
+		Lockout:  config.LoginLockout,
+		CountAll: true,
+	}
+}
+
+// twoFactorRateLimit builds the rate limiting decorator for the route used to
+// log in with a second factor. Failed attempts are counted by client address
+// and by the user the challenge returned by challenge was issued to, so that
+// codes cannot be guessed from many addresses.
+func twoFactorRateLimit(challenge func(routes.Arguments) string) routes.RateLimit {
+	return routes.RateLimit{
+		Store: sqlRateLimitStore{db.Db},
+		Keys: []routes.RateLimitKey{
+			addressRateLimitKey(),
+			{
+				Name:           "two-factor-user",
+				Key:            func(_ *http.Request, a routes.Arguments) string { return twoFactorChallengeSubject(challenge(a)) },
+				MaxFailures:    config.LoginMaxFailuresPerEmail,
+				ResetOnSuccess: true,
+			},
+		},
+		Window:  config.LoginFailureWindow,
+		Lockout: config.LoginLockout,
+	}
+}
+
+// normalizeThrottleEmail normalizes an email so that variants of it share
+// their failed attempts.
+func normalizeThrottleEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// forgetEmailFailures deletes the failed attempts counted for an email, as
+// stored by loginRateLimit.
+func forgetEmailFailures(db models.XODB, email string) error {
+	return models.DeleteAuthThrottleByThrottleKey(db, hashThrottleKey("email:"+normalizeThrottleEmail(email)))
+}
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// compareDummyPasswordHash spends as much time as checking a password does,
+// so that attempts to log in as users which do not exist cannot be told apart
+// from attempts with a wrong password.
+func compareDummyPasswordHash(password string) {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = getPasswordHash("dummy password")
+	})
+	passwordMatchesHash(password, dummyPasswordHash)
+}
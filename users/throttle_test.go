@@ -0,0 +1,73 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trackit/trackit-server/routes"
+)
+
+func TestForgottenPasswordRateLimitKeysOnAddressAndEmail(t *testing.T) {
+	rl := forgottenPasswordRateLimit(func(routes.Arguments) string { return " User@Example.com" })
+	request := httptest.NewRequest(http.MethodPost, "/user/password/forgotten", nil)
+	other := httptest.NewRequest(http.MethodPost, "/user/password/forgotten", nil)
+	other.RemoteAddr = "198.51.100.1:1234"
+	for _, key := range rl.Keys {
+		if key.Name == "email" {
+			t.Errorf("Forgotten password requests should not be limited by email alone.")
+		}
+	}
+	key := rl.Keys[1]
+	if k := key.Key(request, nil); k != "192.0.2.1 user@example.com" {
+		t.Errorf("Key should be %q, is %q instead.", "192.0.2.1 user@example.com", k)
+	} else if key.Key(other, nil) == k {
+		t.Errorf("Requests for an email from different addresses should not share their key.")
+	}
+}
+
+func TestTwoFactorRateLimitKeysOnUser(t *testing.T) {
+	challenge, err := newTwoFactorChallenge(User{Id: 42})
+	if err != nil {
+		t.Fatalf("Error should be nil, is '%s' instead.", err.Error())
+	}
+	for c, expected := range map[string]string{
+		challenge: "42",
+		"garbage": "",
+	} {
+		c := c
+		rl := twoFactorRateLimit(func(routes.Arguments) string { return c })
+		request := httptest.NewRequest(http.MethodPost, "/user/login/two-factor", nil)
+		if k := rl.Keys[1].Key(request, nil); k != expected {
+			t.Errorf("Key should be %q, is %q instead.", expected, k)
+		}
+	}
+}
+
+func TestClaimThrottleCleanupOncePerInterval(t *testing.T) {
+	now := time.Now()
+	if !claimThrottleCleanup(now) {
+		t.Errorf("The first cleanup should be claimed.")
+	}
+	if claimThrottleCleanup(now.Add(throttleCleanupInterval / 2)) {
+		t.Errorf("A cleanup within the interval should not be claimed.")
+	}
+	if !claimThrottleCleanup(now.Add(throttleCleanupInterval)) {
+		t.Errorf("A cleanup after the interval should be claimed.")
+	}
+}
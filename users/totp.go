@@ -0,0 +1,135 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// totpIssuer is the issuer shown by authenticator applications.
+	totpIssuer = "TrackIt"
+	// totpDigits is the number of digits of a TOTP code.
+	totpDigits = 6
+	// totpPeriod is the time during which a TOTP code is valid.
+	totpPeriod = 30 * time.Second
+	// totpSkew is the number of periods before and after the current one
+	// for which codes are accepted, to allow for clock drift.
+	totpSkew = 1
+	// totpSecretSize is the size of a TOTP secret in bytes, as recommended
+	// by RFC 4226 for HMAC-SHA1.
+	totpSecretSize = 20
+	// recoveryCodeCount is the number of recovery codes generated for a
+	// user.
+	recoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateTotpSecret generates a random TOTP secret, base32 encoded.
+func generateTotpSecret() (string, error) {
+	var secret [totpSecretSize]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret[:]), nil
+}
+
+// totpUri builds the otpauth URI authenticator applications use to enroll a
+// secret, usually shown as a QR code.
+func totpUri(secret, account string) string {
+	q := url.Values{
+		"secret":    {secret},
+		"issuer":    {totpIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(int(totpPeriod.Seconds()))},
+	}
+	label := url.PathEscape(totpIssuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// totpCounter returns the TOTP counter for a date.
+func totpCounter(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod.Seconds())
+}
+
+// hotpCode computes the HOTP code of a secret for a counter, as defined by
+// RFC 4226.
+func hotpCode(secret []byte, counter int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+// validateTotp checks a TOTP code against a secret at a given date. Codes for
+// counters up to lastCounter are rejected so a code cannot be used twice. It
+// returns the counter the code matched.
+func validateTotp(secret, code string, t time.Time, lastCounter int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := totpCounter(t)
+	for counter := current - totpSkew; counter <= current+totpSkew; counter++ {
+		if counter <= lastCounter {
+			continue
+		}
+		expected := hotpCode(key, counter, totpDigits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes generates one-time recovery codes, formatted as two
+// groups of five characters.
+func generateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		var random [7]byte
+		if _, err := rand.Read(random[:]); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(random[:])[:10])
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode normalizes a recovery code typed by a user before it
+// is hashed.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
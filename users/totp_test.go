@@ -0,0 +1,103 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 test vectors for SHA1, truncated to six digits.
+var totpTestVectors = []struct {
+	time int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+}
+
+func TestTotpRfcVectors(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	for _, v := range totpTestVectors {
+		counter, ok := validateTotp(secret, v.code, time.Unix(v.time, 0), 0)
+		if !ok {
+			t.Errorf("Code %s should be valid at %d.", v.code, v.time)
+		} else if counter != totpCounter(time.Unix(v.time, 0)) {
+			t.Errorf("Counter should be %d, is %d instead.", totpCounter(time.Unix(v.time, 0)), counter)
+		}
+	}
+}
+
+func TestTotpSkewAndReplay(t *testing.T) {
+	secret, err := generateTotpSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := totpEncoding.DecodeString(secret)
+	now := time.Now()
+	previous := hotpCode(key, totpCounter(now)-1, totpDigits)
+	counter, ok := validateTotp(secret, previous, now, 0)
+	if !ok {
+		t.Fatalf("Code of the previous period should be valid.")
+	}
+	if _, ok := validateTotp(secret, previous, now, counter); ok {
+		t.Errorf("Code should not be valid twice.")
+	}
+	old := hotpCode(key, totpCounter(now)-3, totpDigits)
+	if _, ok := validateTotp(secret, old, now, 0); ok {
+		t.Errorf("Code of three periods ago should not be valid.")
+	}
+	if _, ok := validateTotp(secret, "12345", now, 0); ok {
+		t.Errorf("Code with the wrong length should not be valid.")
+	}
+}
+
+func TestTotpUri(t *testing.T) {
+	u, err := url.Parse(totpUri("JBSWY3DPEHPK3PXP", "user@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/TrackIt:user@example.com" {
+		t.Errorf("Unexpected URI %s.", u)
+	}
+	if u.Query().Get("secret") != "JBSWY3DPEHPK3PXP" || u.Query().Get("issuer") != totpIssuer {
+		t.Errorf("Unexpected URI parameters %s.", u.RawQuery)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' || seen[c] {
+			t.Errorf("Unexpected recovery code %q.", c)
+		}
+		seen[c] = true
+		if n := normalizeRecoveryCode(" " + strings.ToUpper(c)); n != strings.Replace(c, "-", "", 1) {
+			t.Errorf("Recovery code %q should normalize to %q, is %q instead.", c, strings.Replace(c, "-", "", 1), n)
+		}
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("There should be %d recovery codes, are %d instead.", recoveryCodeCount, len(codes))
+	}
+}
@@ -0,0 +1,303 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/trackit/trackit-server/models"
+)
+
+const (
+	// twoFactorChallengeLifetime is the time a user has to provide their
+	// second factor after their password.
+	twoFactorChallengeLifetime = 5 * time.Minute
+	// twoFactorChallengePurpose tells two-factor challenges apart from the
+	// other JWTs signed with the same secret.
+	twoFactorChallengePurpose = "two-factor"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled   = errors.New("Two-factor authentication is already enabled.")
+	ErrTwoFactorNotEnabled       = errors.New("Two-factor authentication is not enabled.")
+	ErrTwoFactorNotEnrolling     = errors.New("Two-factor authentication enrollment was not started.")
+	ErrTwoFactorRequired         = errors.New("Two-factor authentication is required by your parent user.")
+	ErrTwoFactorInvalidCode      = errors.New("Two-factor authentication code is invalid.")
+	ErrTwoFactorInvalidChallenge = errors.New("Two-factor authentication challenge is invalid or expired.")
+)
+
+// TwoFactorStatus is the two-factor authentication status of a user.
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+// TwoFactorEnrollment holds the secret a user enrolls in their
+// authenticator application.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	Uri    string `json:"uri"`
+}
+
+// twoFactorChallengeClaims are the claims of the challenge returned by the
+// login when a second factor is needed. The challenge is only valid for the
+// user's current token generation.
+type twoFactorChallengeClaims struct {
+	Purpose    string `json:"pur"`
+	Subject    int    `json:"sub"`
+	Generation int    `json:"gen"`
+	Expires    int64  `json:"exp"`
+}
+
+// Valid lets twoFactorChallengeClaims implement jwt.Claims.
+func (c twoFactorChallengeClaims) Valid() error {
+	if c.Purpose != twoFactorChallengePurpose || time.Now().Unix() >= c.Expires {
+		return ErrTwoFactorInvalidChallenge
+	}
+	return nil
+}
+
+// newTwoFactorChallenge generates a challenge proving a user provided their
+// first factor.
+func newTwoFactorChallenge(user User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, twoFactorChallengeClaims{
+		Purpose:    twoFactorChallengePurpose,
+		Subject:    user.Id,
+		Generation: user.tokenGeneration,
+		Expires:    time.Now().Add(twoFactorChallengeLifetime).Unix(),
+	})
+	return token.SignedString(jwtSecret)
+}
+
+// parseTwoFactorChallenge checks a challenge and retrieves the user it was
+// issued to.
+func parseTwoFactorChallenge(tx *sql.Tx, challenge string) (User, error) {
+	var claims twoFactorChallengeClaims
+	if _, err := jwt.ParseWithClaims(challenge, &claims, getTokenSigningKey); err != nil {
+		return User{}, ErrTwoFactorInvalidChallenge
+	}
+	user, err := GetUserWithId(tx, claims.Subject)
+	if err == ErrUserNotFound || (err == nil && user.tokenGeneration != claims.Generation) {
+		return User{}, ErrTwoFactorInvalidChallenge
+	}
+	return user, err
+}
+
+// twoFactorChallengeSubject returns the ID of the user a challenge was issued
+// to, or an empty string if it is not valid. It does not check whether the
+// challenge was revoked.
+func twoFactorChallengeSubject(challenge string) string {
+	var claims twoFactorChallengeClaims
+	if _, err := jwt.ParseWithClaims(challenge, &claims, getTokenSigningKey); err != nil {
+		return ""
+	}
+	return strconv.Itoa(claims.Subject)
+}
+
+// getDbTwoFactor retrieves the two-factor authentication settings of a user, or
+// nil if they never started enrolling.
+func getDbTwoFactor(tx *sql.Tx, user User) (*models.UserTwoFactor, error) {
+	tf, err := models.UserTwoFactorByUserID(tx, user.Id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return tf, err
+}
+
+// isTwoFactorRequired checks whether the parent of a viewer user requires
+// them to use two-factor authentication.
+func isTwoFactorRequired(tx *sql.Tx, user User) (bool, error) {
+	if user.ParentId == nil {
+		return false, nil
+	}
+	parent, err := models.UserByID(tx, *user.ParentId)
+	if err != nil {
+		return false, err
+	}
+	return parent.ViewersRequireTwoFactor, nil
+}
+
+// GetTwoFactorStatus retrieves the two-factor authentication status of a
+// user.
+func GetTwoFactorStatus(tx *sql.Tx, user User) (TwoFactorStatus, error) {
+	var status TwoFactorStatus
+	var err error
+	if status.Required, err = isTwoFactorRequired(tx, user); err != nil {
+		return status, err
+	}
+	tf, err := getDbTwoFactor(tx, user)
+	if err != nil || tf == nil || !tf.Enabled {
+		return status, err
+	}
+	status.Enabled = true
+	codes, err := models.UserRecoveryCodesByUserID(tx, user.Id)
+	for _, c := range codes {
+		if !c.Used {
+			status.RecoveryCodesLeft++
+		}
+	}
+	return status, err
+}
+
+// StartTwoFactorEnrollment generates a new secret for a user. Two-factor
+// authentication is only enabled once the user proved they enrolled it by
+// providing a code.
+func StartTwoFactorEnrollment(tx *sql.Tx, user User) (TwoFactorEnrollment, error) {
+	tf, err := getDbTwoFactor(tx, user)
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	} else if tf == nil {
+		tf = &models.UserTwoFactor{
+			Created: time.Now().UTC(),
+			UserID:  user.Id,
+		}
+	} else if tf.Enabled {
+		return TwoFactorEnrollment{}, ErrTwoFactorAlreadyEnabled
+	}
+	if tf.Secret, err = generateTotpSecret(); err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+	tf.LastCounter = 0
+	if err := tf.Save(tx); err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+	return TwoFactorEnrollment{tf.Secret, totpUri(tf.Secret, user.Email)}, nil
+}
+
+// ConfirmTwoFactorEnrollment enables two-factor authentication for a user
+// who started enrolling, if the code is valid. It returns new recovery codes.
+func ConfirmTwoFactorEnrollment(tx *sql.Tx, user User, code string) ([]string, error) {
+	tf, err := getDbTwoFactor(tx, user)
+	if err != nil {
+		return nil, err
+	} else if tf == nil {
+		return nil, ErrTwoFactorNotEnrolling
+	} else if tf.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	counter, ok := validateTotp(tf.Secret, code, time.Now(), tf.LastCounter)
+	if !ok {
+		return nil, ErrTwoFactorInvalidCode
+	}
+	tf.Enabled = true
+	tf.LastCounter = counter
+	if err := tf.Update(tx); err != nil {
+		return nil, err
+	}
+	return ResetRecoveryCodes(tx, user)
+}
+
+// ResetRecoveryCodes replaces the recovery codes of a user. Only their hashes
+// are stored.
+func ResetRecoveryCodes(tx *sql.Tx, user User) ([]string, error) {
+	if err := models.DeleteUserRecoveryCodesByUserID(tx, user.Id); err != nil {
+		return nil, err
+	}
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	for _, c := range codes {
+		dbCode := models.UserRecoveryCode{
+			Created:  now,
+			UserID:   user.Id,
+			CodeHash: hashApiToken(normalizeRecoveryCode(c)),
+		}
+		if err := dbCode.Insert(tx); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// CheckTwoFactorCode checks a TOTP or recovery code of a user who enabled
+// two-factor authentication. Codes can only be used once.
+func CheckTwoFactorCode(tx *sql.Tx, user User, code string) error {
+	tf, err := getDbTwoFactor(tx, user)
+	if err != nil {
+		return err
+	} else if tf == nil || !tf.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if counter, ok := validateTotp(tf.Secret, code, time.Now(), tf.LastCounter); ok {
+		tf.LastCounter = counter
+		return tf.Update(tx)
+	}
+	codes, err := models.UserRecoveryCodesByUserID(tx, user.Id)
+	if err != nil {
+		return err
+	}
+	hash := hashApiToken(normalizeRecoveryCode(code))
+	for _, c := range codes {
+		if !c.Used && subtle.ConstantTimeCompare([]byte(c.CodeHash), []byte(hash)) == 1 {
+			c.Used = true
+			return c.Update(tx)
+		}
+	}
+	return ErrTwoFactorInvalidCode
+}
+
+// DisableTwoFactor disables two-factor authentication for a user, if the code
+// is valid and their parent does not require it.
+func DisableTwoFactor(tx *sql.Tx, user User, code string) error {
+	if required, err := isTwoFactorRequired(tx, user); err != nil {
+		return err
+	} else if required {
+		return ErrTwoFactorRequired
+	}
+	if err := CheckTwoFactorCode(tx, user, code); err != nil {
+		return err
+	}
+	tf, err := getDbTwoFactor(tx, user)
+	if err != nil {
+		return err
+	} else if err := tf.Delete(tx); err != nil {
+		return err
+	}
+	return models.DeleteUserRecoveryCodesByUserID(tx, user.Id)
+}
+
+// SetViewersRequireTwoFactor sets whether the viewers of a user must use
+// two-factor authentication. When it becomes required, the viewers' tokens
+// are revoked so they log in again.
+func SetViewersRequireTwoFactor(tx *sql.Tx, parent User, required bool) error {
+	dbUser, err := models.UserByID(tx, parent.Id)
+	if err != nil {
+		return err
+	}
+	wasRequired := dbUser.ViewersRequireTwoFactor
+	dbUser.ViewersRequireTwoFactor = required
+	if err := dbUser.Update(tx); err != nil || wasRequired || !required {
+		return err
+	}
+	viewers, err := models.UsersByParentUserID(tx, sql.NullInt64{int64(parent.Id), true})
+	if err != nil {
+		return err
+	}
+	for _, v := range viewers {
+		if err := RevokeTokens(tx, v.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
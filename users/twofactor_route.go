@@ -0,0 +1,331 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/trackit/jsonlog"
+
+	"github.com/trackit/trackit-server/audit"
+	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
+	"github.com/trackit/trackit-server/routes"
+)
+
+type (
+	// twoFactorCodeBody is the body of the routes needing a TOTP or
+	// recovery code.
+	twoFactorCodeBody struct {
+		Code string `json:"code" req:"nonzero"`
+	}
+
+	// twoFactorLoginBody is the body of the second step of the login.
+	twoFactorLoginBody struct {
+		Challenge string `json:"challenge" req:"nonzero"`
+		Code      string `json:"code"      req:"nonzero"`
+	}
+
+	// twoFactorChallengeBody is the body of the enrollment during the
+	// login.
+	twoFactorChallengeBody struct {
+		Challenge string `json:"challenge" req:"nonzero"`
+	}
+
+	// recoveryCodesResponseBody holds newly generated recovery codes.
+	recoveryCodesResponseBody struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+
+	// viewersTwoFactorBody is the two-factor authentication policy of a
+	// user's viewers.
+	viewersTwoFactorBody struct {
+		Required bool `json:"required"`
+	}
+)
+
+func init() {
+	routes.MethodMuxer{
+		http.MethodPost: routes.H(logInTwoFactor).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{twoFactorLoginBody{"challenge", "123456"}},
+			twoFactorRateLimit(twoFactorLoginChallenge),
+			routes.Documentation{
+				Summary:     "log in with a second factor",
+				Description: "Logs a user in with the challenge returned by /user/login and a TOTP or recovery code. If the user had to enroll two-factor authentication, the code confirms the enrollment and recovery codes are returned too.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+	).Register("/user/login/two-factor")
+	routes.MethodMuxer{
+		http.MethodPost: routes.H(enrollTwoFactorOnLogin).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{twoFactorChallengeBody{"challenge"}},
+			routes.Documentation{
+				Summary:     "enroll two-factor authentication to log in",
+				Description: "Generates a TOTP secret for a user who must enroll two-factor authentication before logging in, using the challenge returned by /user/login.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+	).Register("/user/login/two-factor/enroll")
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getTwoFactor).With(
+			routes.Documentation{
+				Summary:     "get the two-factor authentication status",
+				Description: "Responds with whether two-factor authentication is enabled and required for the user, and the number of unused recovery codes.",
+			},
+		),
+		http.MethodPost: routes.H(postTwoFactor).With(
+			routes.Documentation{
+				Summary:     "start enrolling two-factor authentication",
+				Description: "Generates a TOTP secret and responds with it and its otpauth URI. Two-factor authentication is enabled once a code is sent to /user/two-factor/verify.",
+			},
+		),
+		http.MethodDelete: routes.H(deleteTwoFactor).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{twoFactorCodeBody{"123456"}},
+			routes.Documentation{
+				Summary:     "disable two-factor authentication",
+				Description: "Disables two-factor authentication given a TOTP or recovery code, unless the parent user requires it.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		RequireAuthenticatedUser{ViewerAsSelf},
+	).Register("/user/two-factor")
+	routes.MethodMuxer{
+		http.MethodPost: routes.H(verifyTwoFactor).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{twoFactorCodeBody{"123456"}},
+			routes.Documentation{
+				Summary:     "enable two-factor authentication",
+				Description: "Enables two-factor authentication if the TOTP code matches the secret being enrolled, and responds with one-time recovery codes.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		RequireAuthenticatedUser{ViewerAsSelf},
+	).Register("/user/two-factor/verify")
+	routes.MethodMuxer{
+		http.MethodPost: routes.H(resetRecoveryCodes).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{twoFactorCodeBody{"123456"}},
+			routes.Documentation{
+				Summary:     "regenerate the recovery codes",
+				Description: "Replaces the recovery codes given a TOTP or recovery code.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		RequireAuthenticatedUser{ViewerAsSelf},
+	).Register("/user/two-factor/recovery-codes")
+	routes.MethodMuxer{
+		http.MethodGet: routes.H(getViewersTwoFactor).With(
+			routes.Documentation{
+				Summary:     "get the viewers' two-factor authentication policy",
+				Description: "Responds with whether the viewer users must use two-factor authentication.",
+			},
+		),
+		http.MethodPut: routes.H(putViewersTwoFactor).With(
+			routes.RequestContentType{"application/json"},
+			routes.RequestBody{viewersTwoFactorBody{true}},
+			routes.Documentation{
+				Summary:     "set the viewers' two-factor authentication policy",
+				Description: "Sets whether the viewer users must use two-factor authentication. When it becomes required, the viewers are logged out and must enroll at their next login.",
+			},
+		),
+	}.H().With(
+		db.RequestTransaction{db.Db},
+		RequireAuthenticatedUser{ViewerCannot},
+	).Register("/user/viewer/two-factor")
+}
+
+// twoFactorErrorResponse builds the response for an error returned by the
+// two-factor authentication functions.
+func twoFactorErrorResponse(request *http.Request, err error, message string) (int, interface{}) {
+	switch err {
+	case ErrTwoFactorInvalidCode, ErrTwoFactorRequired:
+		return http.StatusForbidden, err
+	case ErrTwoFactorInvalidChallenge:
+		return http.StatusUnauthorized, err
+	case ErrTwoFactorAlreadyEnabled, ErrTwoFactorNotEnabled, ErrTwoFactorNotEnrolling:
+		return http.StatusConflict, err
+	default:
+		jsonlog.LoggerFromContextOrDefault(request.Context()).Error(message, err.Error())
+		return http.StatusInternalServerError, errors.New(message)
+	}
+}
+
+// twoFactorLoginChallenge returns the challenge of a request to log in with a
+// second factor.
+func twoFactorLoginChallenge(a routes.Arguments) string {
+	var body twoFactorLoginBody
+	routes.MustRequestBody(a, &body)
+	return body.Challenge
+}
+
+// logInTwoFactor handles the second step of the login of users using
+// two-factor authentication.
+func logInTwoFactor(request *http.Request, a routes.Arguments) (int, interface{}) {
+	var body twoFactorLoginBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user, err := parseTwoFactorChallenge(tx, body.Challenge)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to log in.")
+	}
+	status, err := GetTwoFactorStatus(tx, user)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to log in.")
+	}
+	var recoveryCodes []string
+	if status.Enabled {
+		err = CheckTwoFactorCode(tx, user, body.Code)
+	} else if status.Required {
+		recoveryCodes, err = ConfirmTwoFactorEnrollment(tx, user, body.Code)
+	}
+	if err != nil {
+		jsonlog.LoggerFromContextOrDefault(request.Context()).Warning("Two-factor authentication failure.", user)
+		return twoFactorErrorResponse(request, err, "Failed to log in.")
+	}
+	if err := recordLogin(request, tx, audit.ActionLogin, user, audit.Actor{user.Id, user.Email}, "two-factor"); err != nil {
+		return http.StatusInternalServerError, errors.New("Failed to log in.")
+	}
+	code, response := logAuthenticatedUserIn(request, tx, user)
+	if body, ok := response.(loginResponseBody); ok {
+		body.RecoveryCodes = recoveryCodes
+		response = body
+	}
+	return code, response
+}
+
+// enrollTwoFactorOnLogin lets a user who must use two-factor authentication
+// enroll it during the login.
+func enrollTwoFactorOnLogin(request *http.Request, a routes.Arguments) (int, interface{}) {
+	var body twoFactorChallengeBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user, err := parseTwoFactorChallenge(tx, body.Challenge)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to start enrollment.")
+	}
+	if required, err := isTwoFactorRequired(tx, user); err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to start enrollment.")
+	} else if !required {
+		return http.StatusBadRequest, errors.New("Two-factor authentication is not required.")
+	}
+	enrollment, err := StartTwoFactorEnrollment(tx, user)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to start enrollment.")
+	}
+	return http.StatusOK, enrollment
+}
+
+// getTwoFactor is a route handler which returns the user's two-factor
+// authentication status.
+func getTwoFactor(request *http.Request, a routes.Arguments) (int, interface{}) {
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	status, err := GetTwoFactorStatus(tx, user)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to get two-factor authentication status.")
+	}
+	return http.StatusOK, status
+}
+
+// postTwoFactor is a route handler which starts the enrollment of two-factor
+// authentication.
+func postTwoFactor(request *http.Request, a routes.Arguments) (int, interface{}) {
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	enrollment, err := StartTwoFactorEnrollment(tx, user)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to start enrollment.")
+	}
+	return http.StatusOK, enrollment
+}
+
+// verifyTwoFactor is a route handler which enables two-factor authentication.
+func verifyTwoFactor(request *http.Request, a routes.Arguments) (int, interface{}) {
+	var body twoFactorCodeBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	codes, err := ConfirmTwoFactorEnrollment(tx, user, body.Code)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to enable two-factor authentication.")
+	}
+	jsonlog.LoggerFromContextOrDefault(request.Context()).Info("Two-factor authentication enabled.", user)
+	return http.StatusOK, recoveryCodesResponseBody{codes}
+}
+
+// deleteTwoFactor is a route handler which disables two-factor
+// authentication.
+func deleteTwoFactor(request *http.Request, a routes.Arguments) (int, interface{}) {
+	var body twoFactorCodeBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	if err := DisableTwoFactor(tx, user, body.Code); err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to disable two-factor authentication.")
+	}
+	jsonlog.LoggerFromContextOrDefault(request.Context()).Info("Two-factor authentication disabled.", user)
+	return http.StatusOK, nil
+}
+
+// resetRecoveryCodes is a route handler which regenerates the recovery codes.
+func resetRecoveryCodes(request *http.Request, a routes.Arguments) (int, interface{}) {
+	var body twoFactorCodeBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	if err := CheckTwoFactorCode(tx, user, body.Code); err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to regenerate recovery codes.")
+	}
+	codes, err := ResetRecoveryCodes(tx, user)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to regenerate recovery codes.")
+	}
+	return http.StatusOK, recoveryCodesResponseBody{codes}
+}
+
+// getViewersTwoFactor is a route handler which returns whether the user's
+// viewers must use two-factor authentication.
+func getViewersTwoFactor(request *http.Request, a routes.Arguments) (int, interface{}) {
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	dbUser, err := models.UserByID(tx, user.Id)
+	if err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to get viewers two-factor authentication policy.")
+	}
+	return http.StatusOK, viewersTwoFactorBody{dbUser.ViewersRequireTwoFactor}
+}
+
+// putViewersTwoFactor is a route handler which sets whether the user's
+// viewers must use two-factor authentication.
+func putViewersTwoFactor(request *http.Request, a routes.Arguments) (int, interface{}) {
+	var body viewersTwoFactorBody
+	routes.MustRequestBody(a, &body)
+	tx := a[db.Transaction].(*sql.Tx)
+	user := a[AuthenticatedUser].(User)
+	if err := SetViewersRequireTwoFactor(tx, user, body.Required); err != nil {
+		return twoFactorErrorResponse(request, err, "Failed to set viewers two-factor authentication policy.")
+	}
+	return http.StatusOK, body
+}
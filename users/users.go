@@ -40,6 +40,12 @@ type User struct {
 	NextExternal            string `json:"-"`
 	ParentId                *int   `json:"parentId,omitempty"`
 	AwsCustomerEntitlement	bool   `json:aws_customer_entitlement`
+	// Currency is the code of the currency costs are displayed in. It is
+	// empty for the default currency.
+	Currency                string `json:"currency"`
+	// tokenGeneration is the user's current token generation. Tokens
+	// issued for an older generation are rejected.
+	tokenGeneration int
 }
 
 // CreateUserWithPassword creates a user with an email and a password. A nil
@@ -121,8 +127,8 @@ func GetUserParent(ctx context.Context, db models.XODB, user User) (parent User,
 	return
 }
 
-// UpdateUserWithPassword updates a user with an email and a password. A nil
-// error indicates a success.
+// UpdateUserWithPassword updates a user with an email and a password, revoking
+// the tokens issued to them. A nil error indicates a success.
 func UpdateUserWithPassword(ctx context.Context, tx *sql.Tx, dbUser *models.User, email string, password string) (User, error) {
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
 	dbUser.Email = email
@@ -131,6 +137,7 @@ func UpdateUserWithPassword(ctx context.Context, tx *sql.Tx, dbUser *models.User
 		logger.Error("Failed to create password hash.", err.Error())
 	} else {
 		dbUser.Auth = auth
+		dbUser.TokenGeneration++
 		err = dbUser.Update(tx)
 		if err != nil {
 			logger.Error("Failed to update user.", err.Error())
@@ -154,12 +161,33 @@ func (u User) UpdateNextExternal(ctx context.Context, db models.XODB) error {
 	}
 }
 
-// Delete deletes the user. A nil error indicates a success.
-func (u User) Delete() error {
-	return ErrNotImplemented
+// Delete deletes the user and their viewers from the database. Their AWS
+// accounts, bill repositories, shares, jobs, snoozes, budgets and tokens are
+// deleted along by the foreign keys. A nil error indicates a success.
+func (u User) Delete(db models.XODB) error {
+	dbUser, err := models.UserByID(db, u.Id)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	dbViewers, err := models.UsersByParentUserID(db, sql.NullInt64{int64(u.Id), true})
+	if err != nil {
+		return err
+	}
+	for _, dbViewer := range dbViewers {
+		if err := forgetEmailFailures(db, dbViewer.Email); err != nil {
+			return err
+		}
+	}
+	if err := forgetEmailFailures(db, dbUser.Email); err != nil {
+		return err
+	}
+	return dbUser.Delete(db)
 }
 
-// UpdatePassword updates a user's password. A nil error indicates a success.
+// UpdatePassword updates a user's password, revoking the tokens issued to them.
+// A nil error indicates a success.
 func (u User) UpdatePassword(db models.XODB, password string) error {
 	dbUser, err := models.UserByID(db, u.Id)
 	if err == nil {
@@ -168,6 +196,7 @@ func (u User) UpdatePassword(db models.XODB, password string) error {
 			return err
 		}
 		dbUser.Auth = auth
+		dbUser.TokenGeneration++
 		return dbUser.Update(db)
 	} else {
 		return err
@@ -217,6 +246,7 @@ func GetUserWithEmailAndPassword(ctx context.Context, db models.XODB, email stri
 	logger := jsonlog.LoggerFromContextOrDefault(ctx)
 	dbUser, err := models.UserByEmail(db, email)
 	if err == sql.ErrNoRows {
+		compareDummyPasswordHash(password)
 		return User{}, ErrUserNotFound
 	} else if err != nil {
 		logger.Error("Error getting user from database.", err.Error())
@@ -233,6 +263,8 @@ func UserFromDbUser(dbUser models.User) User {
 		Id:                     dbUser.ID,
 		Email:                  dbUser.Email,
 		AwsCustomerEntitlement: dbUser.AwsCustomerEntitlement,
+		tokenGeneration:        dbUser.TokenGeneration,
+		Currency:               dbUser.Currency,
 	}
 	if dbUser.NextExternal.Valid {
 		u.NextExternal = dbUser.NextExternal.String
@@ -22,6 +22,7 @@ import (
 	"testing"
 
 	"github.com/trackit/trackit-server/db"
+	"github.com/trackit/trackit-server/models"
 )
 
 func TestNonExistingUserByIdFailure(t *testing.T) {
@@ -63,6 +64,8 @@ func TestCreateUpdateDelete(t *testing.T) {
 	t.Run("CreateWithPasswordSuccess", testCreateWithPasswordSuccess)
 	t.Run("GetAndPasswordSuccess", testGetAndPasswordSuccess)
 	t.Run("GetAndPasswordFailure", testGetAndPasswordFailure)
+	t.Run("GetWithIdAndEmailSuccess", testGetWithIdAndEmailSuccess)
+	t.Run("GetByParentSuccess", testGetByParentSuccess)
 }
 
 type createWithPasswordCase struct {
@@ -102,7 +105,7 @@ var createWithPasswordCases = [5]createWithPasswordCase{
 func testCreateWithPasswordSuccess(t *testing.T) {
 	ctx := context.Background()
 	for _, c := range createWithPasswordCases {
-		u, err := CreateUserWithPassword(ctx, db.Db, c.user.Email, c.password)
+		u, err := CreateUserWithPassword(ctx, db.Db, c.user.Email, c.password, "")
 		if err != nil {
 			t.Errorf("Creating <%s>: error should be nil, instead is \"%s\".", c.user.Email, err.Error())
 		} else {
@@ -135,3 +138,54 @@ func testGetAndPasswordFailure(t *testing.T) {
 		}
 	}
 }
+
+func testGetWithIdAndEmailSuccess(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range createWithPasswordCases {
+		dbUser, err := models.UserByEmail(db.Db, c.user.Email)
+		if err != nil {
+			t.Errorf("Getting <%s>: error should be nil, instead is \"%s\".", c.user.Email, err.Error())
+			continue
+		}
+		dbUser.Currency = "EUR"
+		if err := dbUser.Save(db.Db); err != nil {
+			t.Errorf("Updating <%s>: error should be nil, instead is \"%s\".", c.user.Email, err.Error())
+			continue
+		}
+		byEmail, err := GetUserWithEmail(ctx, db.Db, c.user.Email)
+		if err != nil {
+			t.Errorf("Getting <%s>: error should be nil, instead is \"%s\".", c.user.Email, err.Error())
+			continue
+		}
+		byId, err := GetUserWithId(db.Db, byEmail.Id)
+		if err != nil {
+			t.Errorf("Getting <%s> by ID: error should be nil, instead is \"%s\".", c.user.Email, err.Error())
+			continue
+		}
+		if byId != byEmail {
+			t.Errorf("Getting <%s>: user by ID should be %v, instead is %v.", c.user.Email, byEmail, byId)
+		}
+		if byId.Currency != "EUR" {
+			t.Errorf("Getting <%s>: currency should be \"EUR\", instead is \"%s\".", c.user.Email, byId.Currency)
+		}
+	}
+}
+
+func testGetByParentSuccess(t *testing.T) {
+	ctx := context.Background()
+	parent, err := GetUserWithEmail(ctx, db.Db, createWithPasswordCases[0].user.Email)
+	if err != nil {
+		t.Fatalf("Getting parent: error should be nil, instead is \"%s\".", err.Error())
+	}
+	viewer, _, err := CreateUserWithParent(ctx, db.Db, "viewer."+parent.Email, parent)
+	if err != nil {
+		t.Fatalf("Creating viewer: error should be nil, instead is \"%s\".", err.Error())
+	}
+	viewers, err := GetUsersByParent(ctx, db.Db, parent)
+	if err != nil {
+		t.Fatalf("Getting viewers: error should be nil, instead is \"%s\".", err.Error())
+	}
+	if len(viewers) != 1 || viewers[0].Id != viewer.Id || viewers[0].ParentId == nil || *viewers[0].ParentId != parent.Id {
+		t.Errorf("Viewers should be [%v], instead are %v.", viewer, viewers)
+	}
+}
@@ -0,0 +1,85 @@
+//   Copyright 2018 MSolution.IO
+//
+//   Licensed under the Apache License, Version 2.0 (the "License");
+//   you may not use this file except in compliance with the License.
+//   You may obtain a copy of the License at
+//
+//       http://www.apache.org/licenses/LICENSE-2.0
+//
+//   Unless required by applicable law or agreed to in writing, software
+//   distributed under the License is distributed on an "AS IS" BASIS,
+//   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//   See the License for the specific language governing permissions and
+//   limitations under the License.
+
+package req
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// GetJsonSchema builds a JSON Schema object describing type `typ`, as
+// encoding/json would marshal it. Fields tagged with the `nonzero` directive
+// are listed as required, mirroring the checks CreateValidator performs.
+func GetJsonSchema(typ reflect.Type) map[string]interface{} {
+	switch {
+	case typ == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case typ.Kind() == reflect.Ptr:
+		schema := GetJsonSchema(typ.Elem())
+		schema["nullable"] = true
+		return schema
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": GetJsonSchema(typ.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": GetJsonSchema(typ.Elem())}
+	case reflect.Struct:
+		return getJsonSchemaStruct(typ)
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// getJsonSchemaStruct builds the JSON Schema object for a structure type.
+func getJsonSchemaStruct(typ reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	for i, fc := 0, typ.NumField(); i < fc; i++ {
+		fld := typ.Field(i)
+		if fld.PkgPath != "" || strings.HasPrefix(fld.Tag.Get("json"), "-") {
+			continue
+		}
+		name := getJsonName(fld)
+		properties[name] = GetJsonSchema(fld.Type)
+		if isFieldNonZero(fld) {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -16,8 +16,10 @@ package req
 
 import (
 	"bytes"
+	"encoding/json"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type testWithSomeTags struct {
@@ -42,3 +44,31 @@ func TestSchemaWithTags(t *testing.T) {
 		t.Errorf("Schema should be %#q, is %#q instead.", testWithTagsExpectedSchema, sch)
 	}
 }
+
+type testJsonSchemaNested struct {
+	Names   []string   `json:"names"`
+	Created *time.Time `json:"created"`
+	hidden  bool
+	Ignored string `json:"-"`
+}
+
+type testJsonSchema struct {
+	Foo    string               `req:"nonzero" json:"foo"`
+	Bar    uint                 `json:"bar"`
+	Nested testJsonSchemaNested `req:"nonzero" json:"nested"`
+}
+
+const testJsonSchemaExpected = `{"properties":{"bar":{"minimum":0,"type":"integer"},` +
+	`"foo":{"type":"string"},"nested":{"properties":{"created":{"format":"date-time",` +
+	`"nullable":true,"type":"string"},"names":{"items":{"type":"string"},"type":"array"}},` +
+	`"type":"object"}},"required":["foo","nested"],"type":"object"}`
+
+func TestJsonSchema(t *testing.T) {
+	schema := GetJsonSchema(reflect.TypeOf(testJsonSchema{}))
+	bytes, err := json.Marshal(schema)
+	if err != nil {
+		t.Error(err)
+	} else if string(bytes) != testJsonSchemaExpected {
+		t.Errorf("Schema should be %s, is %s instead.", testJsonSchemaExpected, string(bytes))
+	}
+}